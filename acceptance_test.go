@@ -5,17 +5,21 @@ package nfpm_test
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/rand"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/goreleaser/nfpm/v2"
 	_ "github.com/goreleaser/nfpm/v2/apk"
 	_ "github.com/goreleaser/nfpm/v2/arch"
 	_ "github.com/goreleaser/nfpm/v2/deb"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/squashfs"
 	_ "github.com/goreleaser/nfpm/v2/ipk"
 	_ "github.com/goreleaser/nfpm/v2/msix"
 	_ "github.com/goreleaser/nfpm/v2/rpm"
@@ -247,6 +251,83 @@ func TestSRPM(t *testing.T) {
 	})
 }
 
+func TestSourcePackages(t *testing.T) {
+	t.Parallel()
+	// The source packages and the recipes write their sources next to them,
+	// so each one goes in its own directory, which the dockerfile copies
+	// whole. The native tools rebuild the binary packages, the split one
+	// included, and install them.
+	for _, format := range []string{"dsc", "apkbuild", "pkgbuild"} {
+		func(t *testing.T, testFormat string) {
+			t.Run(fmt.Sprintf("%s/amd64/rebuild", testFormat), func(t *testing.T) {
+				t.Parallel()
+				accept(t, acceptParms{
+					Name:   fmt.Sprintf("%s/rebuild_amd64", testFormat),
+					Conf:   fmt.Sprintf("%s.rebuild.yaml", testFormat),
+					Format: testFormat,
+					Docker: dockerParams{
+						File:      fmt.Sprintf("%s.dockerfile", testFormat),
+						Target:    "rebuild",
+						Arch:      "amd64",
+						BuildArgs: []string{fmt.Sprintf("sources=tmp/%s", testFormat)},
+					},
+				})
+			})
+		}(t, format)
+	}
+}
+
+func TestSquashfs(t *testing.T) {
+	t.Parallel()
+	// The filesystems of the AppImages and the snaps, written with each
+	// compression algorithm, are read by unsquashfs.
+	for _, compression := range []string{"gzip", "xz", "zstd"} {
+		func(t *testing.T, testCompression string) {
+			t.Run(fmt.Sprintf("squashfs/amd64/%s", testCompression), func(t *testing.T) {
+				t.Parallel()
+				tmp, err := filepath.Abs("./testdata/acceptance/tmp")
+				require.NoError(t, err)
+				require.NoError(t, os.MkdirAll(tmp, 0o700))
+
+				// random data is stored uncompressed, in several blocks.
+				random := make([]byte, 2*squashfs.BlockSize+1000)
+				_, err = rand.Read(random)
+				require.NoError(t, err)
+				randomName := fmt.Sprintf("squashfs_%s.random", testCompression)
+				require.NoError(t, os.WriteFile(filepath.Join(tmp, randomName), random, 0o600))
+
+				mtime := time.Date(2023, 11, 5, 23, 15, 17, 0, time.UTC)
+				contents, err := files.PrepareForPackager(files.Contents{
+					{Source: "./testdata/fake", Destination: "/usr/bin/fake", FileInfo: &files.ContentFileInfo{Mode: 0o755}},
+					{Source: "/usr/bin/fake", Destination: "/usr/bin/fake-link", Type: files.TypeSymlink},
+					{Source: "./testdata/acceptance/testapp/logo.png", Destination: "/usr/share/foo/logo.png"},
+					{Source: filepath.Join(tmp, randomName), Destination: "/usr/share/foo/random"},
+					{Destination: "/var/lib/foo", Type: files.TypeDir, FileInfo: &files.ContentFileInfo{Mode: 0o700}},
+				}, 0o002, "", false, mtime)
+				require.NoError(t, err)
+				root, err := squashfs.FromContents(contents, mtime)
+				require.NoError(t, err)
+
+				imageName := fmt.Sprintf("squashfs_%s.squashfs", testCompression)
+				f, err := os.Create(filepath.Join(tmp, imageName))
+				require.NoError(t, err)
+				t.Cleanup(func() { require.NoError(t, f.Close()) })
+				require.NoError(t, squashfs.Write(f, root, testCompression))
+
+				docker(t, dockerParams{
+					File:   "squashfs.dockerfile",
+					Target: "unsquashfs",
+					Arch:   "amd64",
+					BuildArgs: []string{
+						"compression=" + testCompression,
+						"random=" + filepath.Join("tmp", randomName),
+					},
+				}, filepath.Join("tmp", imageName))
+			})
+		}(t, compression)
+	}
+}
+
 func TestDebSpecific(t *testing.T) {
 	t.Parallel()
 	format := "deb"
@@ -388,7 +469,7 @@ func accept(t *testing.T, params acceptParms) {
 	require.NoError(t, err)
 	packageName := params.Name + "." + params.Format
 	target := filepath.Join(tmp, packageName)
-	require.NoError(t, os.MkdirAll(tmp, 0o700))
+	require.NoError(t, os.MkdirAll(filepath.Dir(target), 0o700))
 
 	envFunc := func(s string) string {
 		switch s {
@@ -403,29 +484,40 @@ func accept(t *testing.T, params acceptParms) {
 	config, err := nfpm.ParseFileWithEnvMapping(configFile, envFunc)
 	require.NoError(t, err)
 
-	info, err := config.Get(params.Format)
+	// the source packages and recipes hold the split packages of the config.
+	infos, err := config.GetPackages(params.Format)
 	require.NoError(t, err)
+	info := infos[0]
 	require.NoError(t, nfpm.Validate(info))
 
 	pkg, err := nfpm.Get(params.Format)
 	require.NoError(t, err)
 
+	f, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o764)
+	require.NoError(t, err)
+	info.Target = target
+	require.NoError(t, pkg.Package(nfpm.WithDefaults(info), f))
+	docker(t, params.Docker, filepath.Join("tmp", packageName))
+}
+
+// docker builds the target of the dockerfile in testdata/acceptance, with the
+// given package, relative to it, as the package build argument.
+func docker(t *testing.T, params dockerParams, pkg string) {
+	t.Helper()
+
+	arch := strings.ReplaceAll(params.Arch, "armv", "arm/")
 	cmdArgs := []string{
 		"build", "--rm", "--force-rm",
 		"--platform", fmt.Sprintf("linux/%s", arch),
-		"-f", params.Docker.File,
-		"--target", params.Docker.Target,
-		"--build-arg", "package=" + filepath.Join("tmp", packageName),
+		"-f", params.File,
+		"--target", params.Target,
+		"--build-arg", "package=" + pkg,
 	}
-	for _, arg := range params.Docker.BuildArgs {
+	for _, arg := range params.BuildArgs {
 		cmdArgs = append(cmdArgs, "--build-arg", arg)
 	}
 	cmdArgs = append(cmdArgs, ".")
 
-	f, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o764)
-	require.NoError(t, err)
-	info.Target = target
-	require.NoError(t, pkg.Package(nfpm.WithDefaults(info), f))
 	//nolint:gosec
 	cmd := exec.Command("docker", cmdArgs...)
 	cmd.Dir = "./testdata/acceptance"
@@ -433,10 +525,10 @@ func accept(t *testing.T, params acceptParms) {
 	require.NoError(
 		t,
 		err,
-		"failed: %v; env BUILD_ARCH: %s; package: %s; output: %s",
+		"failed: %v; arch: %s; package: %s; output: %s",
 		cmd.Args,
-		envFunc("BUILD_ARCH"),
-		target,
+		arch,
+		pkg,
 		string(bts),
 	)
 }
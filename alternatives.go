@@ -0,0 +1,81 @@
+package nfpm
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// nolint: gochecknoglobals
+var (
+	validAlternativeName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.+-]*$`)
+	safePath             = regexp.MustCompile(`^[A-Za-z0-9/_.+@:-]+$`)
+)
+
+// Alternative is a link managed by the alternatives system, which points to
+// the target with the highest priority among the installed packages that
+// provide it.
+type Alternative struct {
+	Priority int    `yaml:"priority,omitempty" json:"priority,omitempty" jsonschema:"title=priority"`
+	Target   string `yaml:"target,omitempty" json:"target,omitempty" jsonschema:"title=target"`
+	LinkName string `yaml:"link_name,omitempty" json:"link_name,omitempty" jsonschema:"title=link name"`
+	Name     string `yaml:"name,omitempty" json:"name,omitempty" jsonschema:"title=name of the alternative,description=defaults to the file name of link_name"`
+}
+
+// alternativeName returns the name of the alternative, which defaults to the
+// file name of its link.
+func (a Alternative) alternativeName() string {
+	return cmp.Or(a.Name, path.Base(a.LinkName))
+}
+
+func validateAlternatives(alternatives []Alternative) error {
+	for _, alternative := range alternatives {
+		if alternative.LinkName == "" {
+			return ErrFieldEmpty{"alternatives.link_name"}
+		}
+		if alternative.Target == "" {
+			return ErrFieldEmpty{"alternatives.target"}
+		}
+		for _, p := range []string{alternative.LinkName, alternative.Target} {
+			if !path.IsAbs(p) || !safePath.MatchString(p) {
+				return fmt.Errorf("alternative %s: invalid path: %q", alternative.alternativeName(), p)
+			}
+		}
+		if !validAlternativeName.MatchString(alternative.alternativeName()) {
+			return fmt.Errorf("invalid alternative name: %q", alternative.alternativeName())
+		}
+	}
+	return nil
+}
+
+// alternativesPart returns the part of the maintainer script that installs
+// the alternatives of the package or, if remove is set, removes them. It is
+// skipped if there are no alternatives.
+func alternativesPart(alternatives []Alternative, remove bool, condition string) scriptPart {
+	if len(alternatives) == 0 {
+		return scriptPart{}
+	}
+	var b bytes.Buffer
+	// Fedora ships alternatives, and update-alternatives as an alias, while
+	// debian and SUSE only ship update-alternatives.
+	b.WriteString("alternatives=$(command -v update-alternatives || command -v alternatives) || exit 0\n")
+	for _, alternative := range alternatives {
+		if remove {
+			fmt.Fprintf(&b, "\"$alternatives\" --remove %s %s || exit $?\n", alternative.alternativeName(), alternative.Target)
+		} else {
+			fmt.Fprintf(&b, "\"$alternatives\" --install %s %s %s %d || exit $?\n",
+				alternative.LinkName, alternative.alternativeName(), alternative.Target, alternative.Priority)
+		}
+	}
+	name := "install_alternatives"
+	if remove {
+		name = "remove_alternatives"
+	}
+	return scriptPart{
+		name:      name,
+		content:   b.Bytes(),
+		condition: condition,
+	}
+}
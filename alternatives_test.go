@@ -0,0 +1,73 @@
+package nfpm_test
+
+import (
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func alternativesInfo() *nfpm.Info {
+	return &nfpm.Info{
+		Name:    "foo",
+		Arch:    "amd64",
+		Version: "1.0.0",
+		Overridables: nfpm.Overridables{
+			Alternatives: []nfpm.Alternative{
+				{LinkName: "/usr/bin/editor", Target: "/usr/bin/foo", Priority: 50},
+				{LinkName: "/usr/bin/vi", Target: "/usr/bin/foo-vi", Priority: 10, Name: "foo-vi"},
+			},
+		},
+	}
+}
+
+func TestAlternativesDeb(t *testing.T) {
+	scripts, err := nfpm.MaintainerScripts(alternativesInfo(), "deb")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"postinst", "prerm"}, keys(scripts))
+	require.Contains(t, string(scripts["postinst"]), "if [ \"$1\" = configure ]; then\n\tnfpm_install_alternatives \"$@\"")
+	require.Contains(t, string(scripts["postinst"]), "\"$alternatives\" --install /usr/bin/editor editor /usr/bin/foo 50 || exit $?\n")
+	require.Contains(t, string(scripts["postinst"]), "\"$alternatives\" --install /usr/bin/vi foo-vi /usr/bin/foo-vi 10 || exit $?\n")
+	require.Contains(t, string(scripts["prerm"]), "if [ \"$1\" = remove ] || [ \"$1\" = deconfigure ]; then\n\tnfpm_remove_alternatives \"$@\"")
+	require.Contains(t, string(scripts["prerm"]), "\"$alternatives\" --remove editor /usr/bin/foo || exit $?\n")
+}
+
+func TestAlternativesRPM(t *testing.T) {
+	info := alternativesInfo()
+	info.Scripts.PostRemove = writeScript(t, "postremove.sh", "echo postremove\n")
+	scripts, err := nfpm.MaintainerScripts(info, "rpm")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"post", "postun"}, keys(scripts))
+	require.Contains(t, string(scripts["post"]), "\nnfpm_install_alternatives \"$@\"\n")
+	require.Contains(t, string(scripts["post"]), "\"$alternatives\" --install /usr/bin/editor editor /usr/bin/foo 50 || exit $?\n")
+	require.Contains(t, string(scripts["postun"]), "nfpm_postremove \"$@\"")
+	require.Contains(t, string(scripts["postun"]), "if [ \"$1\" -eq 0 ]; then\n\tnfpm_remove_alternatives \"$@\"")
+	require.Contains(t, string(scripts["postun"]), "\"$alternatives\" --remove foo-vi /usr/bin/foo-vi || exit $?\n")
+}
+
+func TestAlternativesOtherPackagers(t *testing.T) {
+	for _, packager := range []string{"ipk", "apk", "archlinux"} {
+		scripts, err := nfpm.MaintainerScripts(alternativesInfo(), packager)
+		require.NoError(t, err)
+		require.Empty(t, scripts, packager)
+	}
+}
+
+func TestAlternativesValidate(t *testing.T) {
+	for expected, alternative := range map[string]nfpm.Alternative{
+		"package alternatives.link_name must be provided": {Target: "/usr/bin/foo"},
+		"package alternatives.target must be provided":    {LinkName: "/usr/bin/editor"},
+		`alternative editor: invalid path: "usr/bin/foo"`: {LinkName: "/usr/bin/editor", Target: "usr/bin/foo"},
+		`alternative editor: invalid path: "/usr/bin/$(foo)"`: {
+			LinkName: "/usr/bin/editor",
+			Target:   "/usr/bin/$(foo)",
+		},
+		`invalid alternative name: "my editor"`: {LinkName: "/usr/bin/editor", Target: "/usr/bin/foo", Name: "my editor"},
+	} {
+		info := alternativesInfo()
+		info.Alternatives = append(info.Alternatives, alternative)
+		require.EqualError(t, nfpm.Validate(info), expected)
+		_, err := nfpm.MaintainerScripts(info, "deb")
+		require.EqualError(t, err, expected)
+	}
+}
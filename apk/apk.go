@@ -47,6 +47,7 @@ import (
 	"github.com/goreleaser/nfpm/v2"
 	"github.com/goreleaser/nfpm/v2/files"
 	"github.com/goreleaser/nfpm/v2/internal/maps"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
 	"github.com/goreleaser/nfpm/v2/internal/sign"
 	gzip "github.com/klauspost/pgzip"
 )
@@ -56,6 +57,8 @@ const packagerName = "apk"
 // nolint: gochecknoinits
 func init() {
 	nfpm.RegisterPackager(packagerName, Default)
+	nfpm.RegisterPackager(formatAPKBUILD.String(), DefaultAPKBUILD)
+	nfpm.RegisterReader(packagerName, Default)
 }
 
 // https://wiki.alpinelinux.org/wiki/Architecture
@@ -92,28 +95,51 @@ func ensureValidArch(info *nfpm.Info) *nfpm.Info {
 // nolint: gochecknoglobals
 var Default = &Apk{}
 
+// DefaultAPKBUILD APKBUILD recipe packager.
+// nolint: gochecknoglobals
+var DefaultAPKBUILD = &Apk{formatAPKBUILD}
+
+type format uint
+
+const (
+	formatAPK format = iota
+	formatAPKBUILD
+)
+
+// String implements fmt.Stringer.
+func (f format) String() string { return [2]string{"apk", "apkbuild"}[f] }
+
 // Apk is an apk packager implementation.
-type Apk struct{}
+type Apk struct {
+	format format
+}
 
 func (a *Apk) ConventionalFileName(info *nfpm.Info) string {
 	info = ensureValidArch(info)
+	// abuild only reads recipes named APKBUILD.
+	if a.format == formatAPKBUILD {
+		return "APKBUILD"
+	}
 	version := pkgver(info)
 	return fmt.Sprintf("%s_%s_%s.apk", info.Name, version, info.Arch)
 }
 
 // ConventionalExtension returns the file name conventionally used for Apk packages
-func (*Apk) ConventionalExtension() string {
+func (a *Apk) ConventionalExtension() string {
+	if a.format == formatAPKBUILD {
+		return ""
+	}
 	return ".apk"
 }
 
 // Package writes a new apk package to the given writer using the given info.
-func (*Apk) Package(info *nfpm.Info, apk io.Writer) (err error) {
-	if info.Platform != "linux" {
-		return fmt.Errorf("invalid platform: %s", info.Platform)
+func (a *Apk) Package(info *nfpm.Info, apk io.Writer) (err error) {
+	defer info.Cleanup()
+	if a.format == formatAPKBUILD {
+		return a.packageAPKBUILD(info, apk)
 	}
-	info = ensureValidArch(info)
 
-	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
+	if err := prepare(info); err != nil {
 		return err
 	}
 
@@ -146,6 +172,24 @@ func (*Apk) Package(info *nfpm.Info, apk io.Writer) (err error) {
 	return combineToApk(apk, &bufSignature, &bufControl, &bufData)
 }
 
+func prepare(info *nfpm.Info) error {
+	if info.Platform != "linux" {
+		return fmt.Errorf("invalid platform: %s", info.Platform)
+	}
+	info = ensureValidArch(info)
+
+	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
+		return err
+	}
+
+	if err := nfpm.PrepareAutoDepends(info, packagerName); err != nil {
+		return err
+	}
+	return nfpm.PrepareRelations(info, packagerName, func(arch string) bool {
+		return arch == info.Arch || archToAlpine[arch] == info.Arch
+	})
+}
+
 type writerCounter struct {
 	io.Writer
 	count  uint64
@@ -347,20 +391,13 @@ func createBuilderControl(info *nfpm.Info, size int64, dataDigest []byte) func(t
 		// bin/echo 'running preinstall.sh' // do stuff here
 		//
 		// exit 0
-		scripts := map[string]string{
-			".pre-install":    info.Scripts.PreInstall,
-			".pre-upgrade":    info.APK.Scripts.PreUpgrade,
-			".post-install":   info.Scripts.PostInstall,
-			".post-upgrade":   info.APK.Scripts.PostUpgrade,
-			".pre-deinstall":  info.Scripts.PreRemove,
-			".post-deinstall": info.Scripts.PostRemove,
+		scripts, err := nfpm.MaintainerScripts(info, packagerName)
+		if err != nil {
+			return err
 		}
+		mtime := modtime.Get(info.MTime)
 		for _, name := range maps.Keys(scripts) {
-			path := scripts[name]
-			if path == "" {
-				continue
-			}
-			if err := newScriptInsideTarGz(tw, path, name); err != nil {
+			if err := newScriptInsideTarGz(tw, scripts[name], name, mtime); err != nil {
 				return err
 			}
 		}
@@ -369,20 +406,12 @@ func createBuilderControl(info *nfpm.Info, size int64, dataDigest []byte) func(t
 	}
 }
 
-func newScriptInsideTarGz(out *tar.Writer, path, dest string) error {
-	file, err := os.Stat(path) //nolint:gosec
-	if err != nil {
-		return err
-	}
-	content, err := os.ReadFile(path)
-	if err != nil {
-		return err
-	}
+func newScriptInsideTarGz(out *tar.Writer, content []byte, dest string, mtime time.Time) error {
 	return newItemInsideTarGz(out, content, &tar.Header{
 		Name:     files.ToNixPath(dest),
 		Size:     int64(len(content)),
 		Mode:     0o755,
-		ModTime:  file.ModTime(),
+		ModTime:  mtime,
 		Typeflag: tar.TypeReg,
 	})
 }
@@ -505,6 +534,9 @@ provides = {{ $prov }}
 {{- range $dep := .Info.Depends}}
 depend = {{ $dep }}
 {{- end }}
+{{- range $conflict := .Info.Conflicts}}
+depend = !{{ $conflict }}
+{{- end }}
 {{- if .Info.License}}
 license = {{.Info.License}}
 {{- end }}
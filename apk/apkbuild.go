@@ -0,0 +1,232 @@
+package apk
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/maps"
+)
+
+// ErrAPKBUILDWithoutTarget happens if an APKBUILD is written without a target
+// file, next to which its sources are written.
+var ErrAPKBUILDWithoutTarget = errors.New("apkbuild: the target file is required to write the sources next to it")
+
+// nolint: gochecknoglobals
+var nonIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// apkbuildSource is a file written next to the APKBUILD.
+type apkbuildSource struct {
+	name    string
+	content []byte
+	mode    os.FileMode
+}
+
+// packageAPKBUILD writes an APKBUILD recipe to w, and the sources it builds
+// the packages from next to the target: the payload of each package, which
+// its package function extracts as it is, and its install scripts.
+// Running `abuild -r` on the result reproduces the apk packages.
+func (*Apk) packageAPKBUILD(info *nfpm.Info, w io.Writer) error {
+	if info.Target == "" {
+		return ErrAPKBUILDWithoutTarget
+	}
+
+	infos := append([]*nfpm.Info{info}, info.SplitPackages...)
+	var payloads, scripts []apkbuildSource
+	for _, pkg := range infos {
+		if err := prepare(pkg); err != nil {
+			if pkg != info {
+				return fmt.Errorf("%s: %w", pkg.Name, err)
+			}
+			return err
+		}
+
+		var payload bytes.Buffer
+		size := int64(0)
+		if _, err := createData(&payload, pkg, &size); err != nil {
+			return err
+		}
+		payloads = append(payloads, apkbuildSource{
+			name:    apkbuildPayloadName(pkg),
+			content: payload.Bytes(),
+			mode:    0o644,
+		})
+
+		pkgScripts, err := nfpm.MaintainerScripts(pkg, packagerName)
+		if err != nil {
+			return err
+		}
+		// abuild reads the install scripts of each package from files
+		// named after it, e.g. foo.pre-install.
+		for _, name := range maps.Keys(pkgScripts) {
+			scripts = append(scripts, apkbuildSource{
+				name:    pkg.Name + name,
+				content: pkgScripts[name],
+				mode:    0o755,
+			})
+		}
+	}
+
+	for _, source := range append(payloads, scripts...) {
+		target := filepath.Join(filepath.Dir(info.Target), source.name)
+		if err := os.WriteFile(target, source.content, source.mode); err != nil {
+			return fmt.Errorf("cannot write %s: %w", source.name, err)
+		}
+	}
+
+	_, err := io.WriteString(w, generateAPKBUILD(infos, payloads, scripts))
+	return err
+}
+
+// apkbuildPayloadName returns the name of the tarball holding the contents of
+// the package.
+func apkbuildPayloadName(info *nfpm.Info) string {
+	return fmt.Sprintf("%s-%s.tar.gz", info.Name, pkgver(info))
+}
+
+// apkbuildVersion returns the pkgver and the pkgrel of the package, which
+// pkgver formats together for apk packages.
+func apkbuildVersion(info *nfpm.Info) (string, string) {
+	version := info.Version
+	if info.Prerelease != "" {
+		version += "_" + info.Prerelease
+	}
+	if meta := info.VersionMetadata; meta != "" {
+		if !strings.HasPrefix(meta, "p") &&
+			!strings.HasPrefix(meta, "cvs") &&
+			!strings.HasPrefix(meta, "svn") &&
+			!strings.HasPrefix(meta, "git") &&
+			!strings.HasPrefix(meta, "hg") {
+			meta = "p" + meta
+		}
+		version += "_" + meta
+	}
+	return version, cmp.Or(strings.TrimPrefix(info.Release, "r"), "0")
+}
+
+// generateAPKBUILD synthesizes an APKBUILD building the packages from their
+// payloads: the split packages are its subpackages, nothing is built,
+// stripped or traced, and the package functions extract the payloads, owners
+// included, as abuild runs them under fakeroot.
+func generateAPKBUILD(infos []*nfpm.Info, payloads, scripts []apkbuildSource) string {
+	info := infos[0]
+	version, release := apkbuildVersion(info)
+
+	var b strings.Builder
+	b.WriteString("# Generated by nfpm\n")
+	if info.Maintainer != "" {
+		fmt.Fprintf(&b, "# Maintainer: %s\n", info.Maintainer)
+	}
+	writeVar(&b, "", "pkgname", info.Name)
+	writeVar(&b, "", "pkgver", version)
+	writeVar(&b, "", "pkgrel", release)
+	writeRelationVars(&b, "", info)
+	writeVar(&b, "", "url", info.Homepage)
+	writeVar(&b, "", "arch", info.Arch)
+	writeVar(&b, "", "license", info.License)
+
+	// abuild creates the users and groups owning the contents on the build
+	// host.
+	var users, groups []string
+	for _, pkg := range infos {
+		for _, user := range pkg.Users {
+			users = append(users, user.Name)
+			groups = append(groups, user.Name)
+		}
+		for _, group := range pkg.Groups {
+			groups = append(groups, group.Name)
+		}
+	}
+	if len(users) > 0 {
+		writeVar(&b, "", "pkgusers", users...)
+	}
+	if len(groups) > 0 {
+		writeVar(&b, "", "pkggroups", groups...)
+	}
+
+	if len(scripts) > 0 {
+		var names []string
+		for _, script := range scripts {
+			names = append(names, script.name)
+		}
+		writeVar(&b, "", "install", names...)
+	}
+	if len(infos) > 1 {
+		var subpackages []string
+		for _, split := range infos[1:] {
+			subpackages = append(subpackages, split.Name+":"+apkbuildFunc(split))
+		}
+		writeVar(&b, "", "subpackages", subpackages...)
+	}
+	writeVar(&b, "", "options", "!check", "!strip", "!tracedeps")
+	var sources []string
+	for _, payload := range payloads {
+		sources = append(sources, payload.name)
+	}
+	writeVar(&b, "", "source", sources...)
+	b.WriteString("builddir=\"$srcdir\"\n")
+
+	b.WriteString("\n# the payloads are extracted by the package functions, unpack only verifies\n# their checksums.\n")
+	b.WriteString("unpack() {\n\tverify\n}\n")
+	b.WriteString("\nbuild() {\n\t:\n}\n")
+	fmt.Fprintf(&b, "\npackage() {\n\tmkdir -p \"$pkgdir\"\n\ttar -xpf \"$srcdir\"/%s -C \"$pkgdir\"\n}\n", payloads[0].name)
+	for i, split := range infos[1:] {
+		fmt.Fprintf(&b, "\n%s() {\n", apkbuildFunc(split))
+		writeRelationVars(&b, "\t", split)
+		fmt.Fprintf(&b, "\tmkdir -p \"$subpkgdir\"\n\ttar -xpf \"$srcdir\"/%s -C \"$subpkgdir\"\n}\n", payloads[i+1].name)
+	}
+
+	b.WriteString("\nsha512sums=\"\n")
+	for _, payload := range payloads {
+		fmt.Fprintf(&b, "%x  %s\n", sha512.Sum512(payload.content), payload.name)
+	}
+	b.WriteString("\"\n")
+	return b.String()
+}
+
+// writeRelationVars writes the description and the relations of a package.
+func writeRelationVars(b *strings.Builder, indent string, info *nfpm.Info) {
+	writeVar(b, indent, "pkgdesc", oneline(info.Description))
+	depends := append([]string{}, info.Depends...)
+	// apk declares conflicts as negated dependencies.
+	for _, conflict := range info.Conflicts {
+		depends = append(depends, "!"+conflict)
+	}
+	writeVar(b, indent, "depends", depends...)
+	if len(info.Provides) > 0 {
+		writeVar(b, indent, "provides", info.Provides...)
+	}
+	if len(info.Replaces) > 0 {
+		writeVar(b, indent, "replaces", info.Replaces...)
+	}
+}
+
+// writeVar writes the assignment of the values, joined with spaces, to the
+// variable.
+func writeVar(b *strings.Builder, indent, key string, values ...string) {
+	fmt.Fprintf(b, "%s%s=%s\n", indent, key, shellQuote(strings.Join(values, " ")))
+}
+
+// shellQuote quotes s as a single double-quoted word.
+func shellQuote(s string) string {
+	return `"` + strings.NewReplacer(`"`, `\"`, "`", "\\`", `\`, `\\`, "$", `\$`).Replace(s) + `"`
+}
+
+// apkbuildFunc returns the name of the function packaging the split package.
+func apkbuildFunc(info *nfpm.Info) string {
+	return "_" + nonIdentifierChars.ReplaceAllString(info.Name, "_")
+}
+
+// oneline returns the first line of the description, as pkgdesc is a single
+// line.
+func oneline(description string) string {
+	return strings.TrimSpace(strings.SplitN(strings.TrimSpace(description), "\n", 2)[0])
+}
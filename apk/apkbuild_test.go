@@ -0,0 +1,82 @@
+package apk
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+func exampleAPKBUILDInfo(t *testing.T) *nfpm.Info {
+	t.Helper()
+	info := exampleInfo()
+	info.Target = filepath.Join(t.TempDir(), DefaultAPKBUILD.ConventionalFileName(info))
+	info.Scripts.PostInstall = "../testdata/scripts/postinstall.sh"
+	info.SplitPackages = []*nfpm.Info{nfpm.WithDefaults(&nfpm.Info{
+		Name:        "foo-doc",
+		Arch:        "all",
+		Description: "Foo's documentation",
+		Version:     "v1.0.0",
+		Prerelease:  "beta1",
+		Release:     "r1",
+		Maintainer:  info.Maintainer,
+		Overridables: nfpm.Overridables{
+			Depends: []string{"foo"},
+			Contents: []*files.Content{
+				{
+					Source:      "../testdata/whatever.conf",
+					Destination: "/usr/share/doc/foo/README",
+				},
+			},
+		},
+	})}
+	return info
+}
+
+func TestAPKBUILDConventionalFileName(t *testing.T) {
+	require.Equal(t, "APKBUILD", DefaultAPKBUILD.ConventionalFileName(exampleInfo()))
+	require.Empty(t, DefaultAPKBUILD.ConventionalExtension())
+}
+
+func TestAPKBUILD(t *testing.T) {
+	info := exampleAPKBUILDInfo(t)
+	var apkbuild bytes.Buffer
+	require.NoError(t, DefaultAPKBUILD.Package(info, &apkbuild))
+
+	dir := filepath.Dir(info.Target)
+	for _, name := range []string{"foo-1.0.0_beta1-r1.tar.gz", "foo-doc-1.0.0_beta1-r1.tar.gz"} {
+		payload, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Contains(t, apkbuild.String(), fmt.Sprintf("\n%x  %s\n", sha512.Sum512(payload), name))
+	}
+	script, err := os.ReadFile(filepath.Join(dir, "foo.post-install"))
+	require.NoError(t, err)
+	expected, err := os.ReadFile(info.Scripts.PostInstall)
+	require.NoError(t, err)
+	require.Equal(t, expected, script)
+
+	require.Contains(t, apkbuild.String(), "pkgname=\"foo\"\npkgver=\"1.0.0_beta1\"\npkgrel=\"1\"\npkgdesc=\"Foo does things\"\n")
+	require.Contains(t, apkbuild.String(), "depends=\"bash foo !zsh !foobarsh\"\n")
+	require.Contains(t, apkbuild.String(), "install=\"foo.post-install\"\n")
+	require.Contains(t, apkbuild.String(), "subpackages=\"foo-doc:_foo_doc\"\n")
+	require.Contains(t, apkbuild.String(), "source=\"foo-1.0.0_beta1-r1.tar.gz foo-doc-1.0.0_beta1-r1.tar.gz\"\n")
+	require.Contains(t, apkbuild.String(), "\nunpack() {\n\tverify\n}\n")
+	require.Contains(t, apkbuild.String(), "\npackage() {\n\tmkdir -p \"$pkgdir\"\n\ttar -xpf \"$srcdir\"/foo-1.0.0_beta1-r1.tar.gz -C \"$pkgdir\"\n}\n")
+	require.Contains(t, apkbuild.String(), "\n_foo_doc() {\n\tpkgdesc=\"Foo's documentation\"\n\tdepends=\"foo\"\n")
+}
+
+func TestAPKBUILDQuoting(t *testing.T) {
+	require.Equal(t, "\"a \\\"b\\\" \\$c \\`d\\`\"", shellQuote("a \"b\" $c `d`"))
+}
+
+func TestAPKBUILDWithoutTarget(t *testing.T) {
+	info := exampleAPKBUILDInfo(t)
+	info.Target = ""
+	require.ErrorIs(t, DefaultAPKBUILD.Package(info, &bytes.Buffer{}), ErrAPKBUILDWithoutTarget)
+}
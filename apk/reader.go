@@ -0,0 +1,250 @@
+package apk
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	gzip "github.com/klauspost/pgzip"
+)
+
+// ErrInvalidPackage happens when a file cannot be read as an apk package.
+var ErrInvalidPackage = errors.New("invalid apk package")
+
+// maps the control archive member names to the nfpm script names.
+// nolint: gochecknoglobals
+var controlScripts = map[string]string{
+	".pre-install":    "preinstall",
+	".post-install":   "postinstall",
+	".pre-upgrade":    "preupgrade",
+	".post-upgrade":   "postupgrade",
+	".pre-deinstall":  "preremove",
+	".post-deinstall": "postremove",
+	".trigger":        "trigger",
+}
+
+// Read implements nfpm.Reader.
+func (*Apk) Read(r io.Reader) (*nfpm.Inspection, error) {
+	segments, err := splitSegments(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &nfpm.Inspection{
+		Format: packagerName,
+		Info: &nfpm.Info{
+			Platform: "linux",
+		},
+	}
+
+	// the signature segment is optional, but the control and data ones are
+	// always the last two.
+	if len(segments) < 2 || len(segments) > 3 {
+		return nil, fmt.Errorf("%w: expected 2 or 3 gzip streams, got %d", ErrInvalidPackage, len(segments))
+	}
+	if len(segments) == 3 {
+		if err := readSignatureSegment(segments[0], result); err != nil {
+			return nil, err
+		}
+	}
+	if err := readControlSegment(segments[len(segments)-2], result); err != nil {
+		return nil, err
+	}
+	if err := readDataSegment(segments[len(segments)-1], result.Info); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// splitSegments splits an apk into the raw bytes of each of its concatenated
+// gzip streams.
+func splitSegments(r io.Reader) ([][]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// bytes.Reader is an io.ByteReader, so the gzip reader does not read past
+	// the end of each stream and the offsets can be tracked precisely.
+	br := bytes.NewReader(data)
+	var segments [][]byte
+	for br.Len() > 0 {
+		start := len(data) - br.Len()
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+		gz.Multistream(false)
+		if _, err := io.Copy(io.Discard, gz); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+		if err := gz.Close(); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+		segments = append(segments, data[start:len(data)-br.Len()])
+	}
+	return segments, nil
+}
+
+// walkSegment calls fn for every entry of the tarball in the given segment.
+func walkSegment(segment []byte, fn func(hdr *tar.Header, r io.Reader) error) error {
+	gz, err := gzip.NewReader(bytes.NewReader(segment))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+	defer gz.Close() // nolint: errcheck
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// signatureFromName parses the name of a signature file, e.g.
+// .SIGN.RSA.foo@example.com.rsa.pub, into its type and key name.
+func signatureFromName(name string) (nfpm.SignatureInfo, bool) {
+	rest, ok := strings.CutPrefix(name, ".SIGN.")
+	if !ok {
+		return nfpm.SignatureInfo{}, false
+	}
+	sigType, keyName, ok := strings.Cut(rest, ".")
+	if !ok {
+		return nfpm.SignatureInfo{}, false
+	}
+	return nfpm.SignatureInfo{
+		Type: strings.ToLower(sigType),
+		Name: keyName,
+	}, true
+}
+
+func readSignatureSegment(segment []byte, result *nfpm.Inspection) error {
+	return walkSegment(segment, func(hdr *tar.Header, _ io.Reader) error {
+		if sig, ok := signatureFromName(hdr.Name); ok {
+			result.Signatures = append(result.Signatures, sig)
+		}
+		return nil
+	})
+}
+
+func readControlSegment(segment []byte, result *nfpm.Inspection) error {
+	var found bool
+	err := walkSegment(segment, func(hdr *tar.Header, r io.Reader) error {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", hdr.Name, err)
+		}
+		if hdr.Name == ".PKGINFO" {
+			found = true
+			infoFromPkginfo(result.Info, content)
+			return nil
+		}
+		if script, ok := controlScripts[hdr.Name]; ok {
+			if result.Scripts == nil {
+				result.Scripts = map[string]string{}
+			}
+			result.Scripts[script] = string(content)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%w: missing .PKGINFO", ErrInvalidPackage)
+	}
+	return nil
+}
+
+// parsePkginfo parses the "key = value" lines of a .PKGINFO file. Lines
+// starting with whitespace continue the value of the previous key.
+func parsePkginfo(content []byte) [][2]string {
+	var result [][2]string
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(result) > 0 {
+			result[len(result)-1][1] += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		key, value, ok := strings.Cut(line, " = ")
+		if !ok {
+			continue
+		}
+		result = append(result, [2]string{strings.TrimSpace(key), value})
+	}
+	return result
+}
+
+func infoFromPkginfo(info *nfpm.Info, content []byte) {
+	for _, kv := range parsePkginfo(content) {
+		switch key, value := kv[0], kv[1]; key {
+		case "pkgname":
+			info.Name = value
+		case "pkgver":
+			info.Version, info.Release = splitPkgver(value)
+		case "arch":
+			info.Arch = value
+		case "pkgdesc":
+			info.Description = value
+		case "url":
+			info.Homepage = value
+		case "maintainer":
+			info.Maintainer = value
+		case "license":
+			info.License = value
+		case "replaces":
+			info.Replaces = append(info.Replaces, value)
+		case "provides":
+			info.Provides = append(info.Provides, value)
+		case "depend":
+			// conflicts are dependencies prefixed with a !.
+			if conflict, ok := strings.CutPrefix(value, "!"); ok {
+				info.Conflicts = append(info.Conflicts, conflict)
+			} else {
+				info.Depends = append(info.Depends, value)
+			}
+		}
+	}
+}
+
+// splitPkgver splits an apk version into the version and the release, e.g.
+// 1.0.0-r1 into 1.0.0 and 1.
+func splitPkgver(pkgver string) (version, release string) {
+	i := strings.LastIndex(pkgver, "-r")
+	if i < 0 {
+		return pkgver, ""
+	}
+	return pkgver[:i], pkgver[i+2:]
+}
+
+func readDataSegment(segment []byte, info *nfpm.Info) error {
+	err := walkSegment(segment, func(hdr *tar.Header, _ io.Reader) error {
+		if content := files.FromTarHeader(hdr); content != nil {
+			info.Contents = append(info.Contents, content)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Sort(info.Contents)
+	return nil
+}
@@ -0,0 +1,70 @@
+package apk
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	info := exampleInfo()
+	info.Description = "Foo does things\nand more"
+	info.License = "MIT"
+	info.Scripts.PreInstall = "../testdata/scripts/preinstall.sh"
+	info.APK.Scripts.PostUpgrade = "../testdata/scripts/postupgrade.sh"
+	info.APK.Signature.KeyFile = "../internal/sign/testdata/rsa.priv"
+	info.APK.Signature.KeyName = "testkey.rsa.pub"
+	info.APK.Signature.KeyPassphrase = "hunter2"
+
+	var apk bytes.Buffer
+	require.NoError(t, Default.Package(info, &apk))
+
+	result, err := Default.Read(&apk)
+	require.NoError(t, err)
+	require.Equal(t, packagerName, result.Format)
+
+	got := result.Info
+	require.Equal(t, "foo", got.Name)
+	require.Equal(t, "1.0.0_beta1", got.Version)
+	require.Equal(t, "1", got.Release)
+	require.Equal(t, "x86_64", got.Arch)
+	require.Equal(t, "MIT", got.License)
+	require.Equal(t, info.Description, got.Description)
+	require.Equal(t, info.Depends, got.Depends)
+	require.Equal(t, info.Conflicts, got.Conflicts)
+	require.Equal(t, info.Provides, got.Provides)
+	require.Equal(t, info.Replaces, got.Replaces)
+
+	contents := map[string]*files.Content{}
+	for _, c := range got.Contents {
+		contents[c.Destination] = c
+	}
+	require.Equal(t, files.TypeFile, contents["/usr/bin/fake"].Type)
+	require.Equal(t, files.TypeDir, contents["/var/log/whatever"].Type)
+
+	require.Contains(t, result.Scripts, "preinstall")
+	require.Contains(t, result.Scripts, "postupgrade")
+	require.Equal(t, []nfpm.SignatureInfo{{
+		Type: "rsa",
+		Name: "testkey.rsa.pub",
+	}}, result.Signatures)
+}
+
+func TestReadUnsigned(t *testing.T) {
+	var apk bytes.Buffer
+	require.NoError(t, Default.Package(exampleInfo(), &apk))
+
+	result, err := Default.Read(&apk)
+	require.NoError(t, err)
+	require.Empty(t, result.Signatures)
+	require.Equal(t, "foo", result.Info.Name)
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, err := Default.Read(strings.NewReader("not an apk"))
+	require.ErrorIs(t, err, ErrInvalidPackage)
+}
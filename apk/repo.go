@@ -0,0 +1,288 @@
+package apk
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha1" // nolint:gosec
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+)
+
+const repoIndexName = "APKINDEX.tar.gz"
+
+// RepoOptions configures the repository created by IndexRepo.
+type RepoOptions struct {
+	// Description of the repository, e.g. v3.19, written into the DESCRIPTION
+	// file of the index.
+	Description string
+
+	// RSA private key used to sign the index. The index is not signed if
+	// empty.
+	KeyFile       string
+	KeyPassphrase string
+	// Name of the public key as installed in /etc/apk/keys, defaults to the
+	// name of the key file with a .rsa.pub extension.
+	KeyName string
+}
+
+// repoPackage is an apk found while indexing a repository.
+type repoPackage struct {
+	name     string
+	version  string
+	arch     string
+	filename string
+	entry    []byte
+}
+
+// IndexRepo indexes all apk packages found in dir, creating an APKINDEX.tar.gz
+// for each architecture in dir/<arch>, signed if a key is given. As apk
+// fetches packages as <arch>/<name>-<version>.apk, packages stored elsewhere
+// are copied there. It returns the paths of the created files.
+func IndexRepo(dir string, opts RepoOptions) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(name) == ".apk" {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	sources := map[string]string{}
+	pkgs := map[string]*repoPackage{}
+	for _, name := range names {
+		pkg, err := readRepoPackage(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		target := filepath.Join(dir, pkg.arch, pkg.filename)
+		if sources[target] == target {
+			// the package is already stored where apk expects it.
+			continue
+		}
+		sources[target] = name
+		pkgs[target] = pkg
+	}
+
+	var created []string
+	for _, target := range sortedKeys(sources) {
+		if source := sources[target]; source != target {
+			if err := copyRepoPackage(source, target); err != nil {
+				return nil, err
+			}
+			created = append(created, target)
+		}
+	}
+
+	byArch := map[string][]*repoPackage{}
+	for _, pkg := range pkgs {
+		byArch[pkg.arch] = append(byArch[pkg.arch], pkg)
+	}
+	for _, arch := range sortedKeys(byArch) {
+		index, err := createRepoIndex(byArch[arch], opts)
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.Join(dir, arch, repoIndexName)
+		if err := os.WriteFile(name, index, 0o644); err != nil { //nolint:gosec
+			return nil, err
+		}
+		created = append(created, name)
+	}
+	return created, nil
+}
+
+// readRepoPackage reads the .PKGINFO of the given apk and renders its
+// APKINDEX entry.
+func readRepoPackage(name string) (*repoPackage, error) {
+	f, err := os.Open(name) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint: errcheck
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	segments, err := splitSegments(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) < 2 {
+		return nil, fmt.Errorf("%w: missing control archive", ErrInvalidPackage)
+	}
+	control := segments[len(segments)-2]
+
+	var pkginfo []byte
+	err = walkSegment(control, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Name != ".PKGINFO" {
+			return nil
+		}
+		pkginfo, err = io.ReadAll(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pkginfo == nil {
+		return nil, fmt.Errorf("%w: missing .PKGINFO", ErrInvalidPackage)
+	}
+
+	digest := sha1.Sum(control) // nolint:gosec
+	pkg := &repoPackage{}
+	var entry bytes.Buffer
+	fmt.Fprintf(&entry, "C:Q1%s\n", base64.StdEncoding.EncodeToString(digest[:]))
+
+	fields := map[string][]string{}
+	for _, kv := range parsePkginfo(pkginfo) {
+		fields[kv[0]] = append(fields[kv[0]], strings.ReplaceAll(kv[1], "\n", " "))
+	}
+	pkg.name = first(fields["pkgname"])
+	pkg.version = first(fields["pkgver"])
+	pkg.arch = first(fields["arch"])
+	if pkg.name == "" || pkg.version == "" || pkg.arch == "" {
+		return nil, fmt.Errorf("%w: .PKGINFO misses pkgname, pkgver or arch", ErrInvalidPackage)
+	}
+	pkg.filename = pkg.name + "-" + pkg.version + ".apk"
+
+	// the index fields, in the order used by apk-tools.
+	// https://wiki.alpinelinux.org/wiki/Apk_spec#APKINDEX_Format
+	for _, field := range []struct {
+		key      string
+		pkginfo  string
+		multiple bool
+	}{
+		{"P", "pkgname", false},
+		{"V", "pkgver", false},
+		{"A", "arch", false},
+		{"S", "", false},
+		{"I", "size", false},
+		{"T", "pkgdesc", false},
+		{"U", "url", false},
+		{"L", "license", false},
+		{"o", "origin", false},
+		{"m", "maintainer", false},
+		{"t", "builddate", false},
+		{"c", "commit", false},
+		{"k", "provider_priority", false},
+		{"D", "depend", true},
+		{"p", "provides", true},
+		{"r", "replaces", true},
+		{"i", "install_if", true},
+	} {
+		value := first(fields[field.pkginfo])
+		switch {
+		case field.key == "S":
+			value = fmt.Sprint(stat.Size())
+		case field.multiple:
+			value = strings.Join(fields[field.pkginfo], " ")
+		}
+		if value != "" {
+			fmt.Fprintf(&entry, "%s:%s\n", field.key, value)
+		}
+	}
+	pkg.entry = entry.Bytes()
+	return pkg, nil
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func copyRepoPackage(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	content, err := os.ReadFile(src) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, content, 0o644) //nolint:gosec
+}
+
+// createRepoIndex creates an APKINDEX.tar.gz with the given packages. Like a
+// package, a signed index is a signature tarball followed by the index
+// tarball, whose gzip stream is what gets signed.
+func createRepoIndex(pkgs []*repoPackage, opts RepoOptions) ([]byte, error) {
+	sort.Slice(pkgs, func(i, j int) bool {
+		if pkgs[i].name != pkgs[j].name {
+			return pkgs[i].name < pkgs[j].name
+		}
+		return pkgs[i].version < pkgs[j].version
+	})
+
+	var index bytes.Buffer
+	for _, pkg := range pkgs {
+		index.Write(pkg.entry)
+		index.WriteString("\n")
+	}
+
+	var indexTgz bytes.Buffer
+	digest, err := writeTgz(&indexTgz, tarFull, func(tw *tar.Writer) error {
+		for _, file := range []struct {
+			name    string
+			content []byte
+		}{
+			{"DESCRIPTION", []byte(opts.Description)},
+			{"APKINDEX", index.Bytes()},
+		} {
+			if err := writeFile(tw, &tar.Header{
+				Name: file.name,
+				Mode: 0o644,
+				Size: int64(len(file.content)),
+			}, bytes.NewReader(file.content)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, sha1.New()) // nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.KeyFile == "" {
+		return indexTgz.Bytes(), nil
+	}
+
+	keyName := opts.KeyName
+	if keyName == "" {
+		keyName = strings.TrimSuffix(filepath.Base(opts.KeyFile), filepath.Ext(opts.KeyFile))
+	}
+	info := &nfpm.Info{}
+	info.APK.Signature.KeyFile = opts.KeyFile
+	info.APK.Signature.KeyPassphrase = opts.KeyPassphrase
+	info.APK.Signature.KeyName = keyName
+
+	var signatureTgz bytes.Buffer
+	if err := createSignature(&signatureTgz, info, digest); err != nil {
+		return nil, err
+	}
+	signatureTgz.Write(indexTgz.Bytes())
+	return signatureTgz.Bytes(), nil
+}
@@ -0,0 +1,105 @@
+package apk
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha1" // nolint:gosec
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRepoApk(t *testing.T, dir, arch string) string {
+	t.Helper()
+	info := exampleInfo()
+	info.Arch = arch
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	target := filepath.Join(dir, Default.ConventionalFileName(info))
+	require.NoError(t, os.WriteFile(target, signedApk(t, info), 0o644))
+	return target
+}
+
+// readRepoIndex returns the segments of an index and the content of its
+// files.
+func readRepoIndex(t *testing.T, name string) ([][]byte, map[string]string) {
+	t.Helper()
+	f, err := os.Open(name)
+	require.NoError(t, err)
+	defer f.Close()
+	segments, err := splitSegments(f)
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for _, segment := range segments {
+		require.NoError(t, walkSegment(segment, func(hdr *tar.Header, r io.Reader) error {
+			content, err := io.ReadAll(r)
+			files[hdr.Name] = string(content)
+			return err
+		}))
+	}
+	return segments, files
+}
+
+func TestIndexRepo(t *testing.T) {
+	dir := t.TempDir()
+	src := writeRepoApk(t, filepath.Join(dir, "dist"), "amd64")
+	writeRepoApk(t, filepath.Join(dir, "dist"), "arm64")
+
+	created, err := IndexRepo(dir, RepoOptions{Description: "v1.0"})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "aarch64", "foo-1.0.0_beta1-r1.apk"),
+		filepath.Join(dir, "x86_64", "foo-1.0.0_beta1-r1.apk"),
+		filepath.Join(dir, "aarch64", "APKINDEX.tar.gz"),
+		filepath.Join(dir, "x86_64", "APKINDEX.tar.gz"),
+	}, created)
+
+	segments, files := readRepoIndex(t, filepath.Join(dir, "x86_64", "APKINDEX.tar.gz"))
+	require.Len(t, segments, 1)
+	require.Equal(t, "v1.0", files["DESCRIPTION"])
+
+	content, err := os.ReadFile(src)
+	require.NoError(t, err)
+	apkSegments, err := splitSegments(bytes.NewReader(content))
+	require.NoError(t, err)
+	digest := sha1.Sum(apkSegments[1]) // nolint:gosec
+
+	index := files["APKINDEX"]
+	require.True(t, strings.HasPrefix(index, "C:Q1"+base64.StdEncoding.EncodeToString(digest[:])+"\n"), index)
+	require.Contains(t, index, "\nP:foo\nV:1.0.0_beta1-r1\nA:x86_64\n")
+	require.Contains(t, index, fmt.Sprintf("\nS:%d\n", len(content)))
+	require.Contains(t, index, "\nD:bash foo !zsh !foobarsh\n")
+	require.Contains(t, index, "\np:bzr zzz\n")
+	require.True(t, strings.HasSuffix(index, "\n\n"))
+
+	// indexing again must not copy the packages one more time.
+	created, err = IndexRepo(dir, RepoOptions{})
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+}
+
+func TestIndexRepoSigned(t *testing.T) {
+	dir := t.TempDir()
+	writeRepoApk(t, filepath.Join(dir, "x86_64"), "amd64")
+
+	_, err := IndexRepo(dir, RepoOptions{
+		KeyFile:       "../internal/sign/testdata/rsa.priv",
+		KeyPassphrase: "hunter2",
+		KeyName:       "testkey",
+	})
+	require.NoError(t, err)
+
+	segments, files := readRepoIndex(t, filepath.Join(dir, "x86_64", "APKINDEX.tar.gz"))
+	require.Len(t, segments, 2)
+	signature, ok := files[".SIGN.RSA.testkey.rsa.pub"]
+	require.True(t, ok)
+	digest := sha1.Sum(segments[1]) // nolint:gosec
+	require.NoError(t, sign.RSAVerifySHA1Digest(digest[:], []byte(signature), "../internal/sign/testdata/rsa.pub"))
+}
@@ -0,0 +1,106 @@
+package apk
+
+import (
+	"archive/tar"
+	"crypto/sha1" // nolint:gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+)
+
+// ErrUnsupportedSignature happens when a signature uses a scheme other than
+// RSA over SHA1, which is the only one nfpm creates.
+var ErrUnsupportedSignature = errors.New("unsupported signature type")
+
+// ErrDataHashMismatch happens when the data archive does not match the
+// datahash recorded in the signed .PKGINFO.
+var ErrDataHashMismatch = errors.New("data archive does not match the signed datahash")
+
+// Verify implements nfpm.Verifier. Every .SIGN.RSA.* signature is checked
+// against the given PEM RSA public key. The signatures cover the control
+// archive, which in turn holds the checksum of the data archive, so that one
+// is checked as well.
+func (*Apk) Verify(r io.Reader, keyFile string) ([]nfpm.SignatureVerification, error) {
+	segments, err := splitSegments(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) < 2 || len(segments) > 3 {
+		return nil, fmt.Errorf("%w: expected 2 or 3 gzip streams, got %d", ErrInvalidPackage, len(segments))
+	}
+	if len(segments) == 2 {
+		return nil, nil
+	}
+
+	control, data := segments[1], segments[2]
+	dataErr, err := verifyDataHash(control, data)
+	if err != nil {
+		return nil, err
+	}
+	controlDigest := sha1.Sum(control) // nolint:gosec
+
+	var results []nfpm.SignatureVerification
+	err = walkSegment(segments[0], func(hdr *tar.Header, r io.Reader) error {
+		info, ok := signatureFromName(hdr.Name)
+		if !ok {
+			return nil
+		}
+		sig, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", hdr.Name, err)
+		}
+		result := nfpm.SignatureVerification{SignatureInfo: info}
+		switch {
+		case info.Type != "rsa":
+			result.Err = fmt.Errorf("%w: %s", ErrUnsupportedSignature, info.Type)
+		case dataErr != nil:
+			result.Err = dataErr
+		default:
+			result.Err = sign.RSAVerifySHA1Digest(controlDigest[:], sig, keyFile)
+		}
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// verifyDataHash compares the datahash of the .PKGINFO in the control segment
+// with the checksum of the data segment. The returned verification error is
+// nil if they match, err is only set if the control segment cannot be read.
+func verifyDataHash(control, data []byte) (verifyErr, err error) {
+	var datahash string
+	err = walkSegment(control, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Name != ".PKGINFO" {
+			return nil
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", hdr.Name, err)
+		}
+		for _, kv := range parsePkginfo(content) {
+			if kv[0] == "datahash" {
+				datahash = kv[1]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if datahash == "" {
+		return fmt.Errorf("%w: missing datahash", ErrDataHashMismatch), nil
+	}
+	digest := sha256.Sum256(data)
+	if hex.EncodeToString(digest[:]) != datahash {
+		return ErrDataHashMismatch, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,66 @@
+package apk
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func signedApk(tb testing.TB, info *nfpm.Info) []byte {
+	tb.Helper()
+	info.APK.Signature.KeyFile = "../internal/sign/testdata/rsa.priv"
+	info.APK.Signature.KeyName = "testkey.rsa.pub"
+	info.APK.Signature.KeyPassphrase = "hunter2"
+
+	var apk bytes.Buffer
+	require.NoError(tb, Default.Package(info, &apk))
+	return apk.Bytes()
+}
+
+func TestVerify(t *testing.T) {
+	apk := signedApk(t, exampleInfo())
+
+	results, err := Default.Verify(bytes.NewReader(apk), "../internal/sign/testdata/rsa.pub")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "testkey.rsa.pub", results[0].Name)
+	require.NoError(t, results[0].Err)
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	apk := signedApk(t, exampleInfo())
+
+	results, err := Default.Verify(bytes.NewReader(apk), "../internal/sign/testdata/rsa_unprotected.pub")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Error(t, results[0].Err)
+}
+
+func TestVerifyTamperedData(t *testing.T) {
+	apk := signedApk(t, exampleInfo())
+	other := exampleInfo()
+	other.Contents = other.Contents[:1]
+	otherApk := signedApk(t, other)
+
+	segments, err := splitSegments(bytes.NewReader(apk))
+	require.NoError(t, err)
+	otherSegments, err := splitSegments(bytes.NewReader(otherApk))
+	require.NoError(t, err)
+	tampered := bytes.Join([][]byte{segments[0], segments[1], otherSegments[2]}, nil)
+
+	results, err := Default.Verify(bytes.NewReader(tampered), "../internal/sign/testdata/rsa.pub")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.ErrorIs(t, results[0].Err, ErrDataHashMismatch)
+}
+
+func TestVerifyUnsigned(t *testing.T) {
+	var apk bytes.Buffer
+	require.NoError(t, Default.Package(exampleInfo(), &apk))
+
+	results, err := Default.Verify(&apk, "../internal/sign/testdata/rsa.pub")
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
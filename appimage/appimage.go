@@ -0,0 +1,297 @@
+// Package appimage implements nfpm.Packager providing AppImage bindings.
+//
+// An AppImage is the type-2 runtime, an ELF executable, followed by a squashfs
+// filesystem holding the AppDir: the contents, the AppRun the runtime runs
+// once it mounted the filesystem, the desktop entry and the icon of the
+// application. nfpm does not build the runtime, it is read from a local file,
+// e.g. as released by https://github.com/AppImage/type2-runtime.
+package appimage
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/squashfs"
+)
+
+const packagerName = "appimage"
+
+// nolint: gochecknoinits
+func init() {
+	nfpm.RegisterPackager(packagerName, Default)
+}
+
+// ErrZsyncWithoutTarget happens when the .zsync file is requested but the
+// AppImage is not written to a file.
+var ErrZsyncWithoutTarget = errors.New("appimage: the target file is required to write the .zsync file next to it")
+
+// nolint: gochecknoglobals
+var archToAppImage = map[string]string{
+	"amd64":   "x86_64",
+	"x86_64":  "x86_64",
+	"386":     "i686",
+	"i386":    "i686",
+	"i686":    "i686",
+	"arm64":   "aarch64",
+	"aarch64": "aarch64",
+	"arm6":    "armhf",
+	"arm7":    "armhf",
+}
+
+func ensureValidArch(info *nfpm.Info) *nfpm.Info {
+	if info.AppImage.Arch != "" {
+		info.Arch = info.AppImage.Arch
+	} else if arch, ok := archToAppImage[info.Arch]; ok {
+		info.Arch = arch
+	}
+
+	return info
+}
+
+// Default AppImage packager.
+// nolint: gochecknoglobals
+var Default = &AppImage{}
+
+// AppImage is an AppImage packager implementation.
+type AppImage struct{}
+
+// ConventionalFileName returns a file name according to the conventions for
+// AppImages: name-version-arch.AppImage.
+func (*AppImage) ConventionalFileName(info *nfpm.Info) string {
+	info = ensureValidArch(info)
+	return fmt.Sprintf("%s-%s-%s.AppImage", info.Name, appVersion(info), info.Arch)
+}
+
+// ConventionalExtension returns the file name conventionally used for
+// AppImages.
+func (*AppImage) ConventionalExtension() string {
+	return ".AppImage"
+}
+
+// appVersion returns the version of the application, with its prerelease.
+func appVersion(info *nfpm.Info) string {
+	if info.Prerelease != "" {
+		return info.Version + "-" + info.Prerelease
+	}
+	return info.Version
+}
+
+// Package writes a new AppImage to the given writer using the given info,
+// and its .zsync file next to the target when requested.
+func (*AppImage) Package(info *nfpm.Info, w io.Writer) error {
+	info = ensureValidArch(info)
+
+	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
+		return err
+	}
+	if info.AppImage.Runtime == "" {
+		return fmt.Errorf("package %s must be provided", "appimage.runtime")
+	}
+	if info.AppImage.Icon == "" {
+		return fmt.Errorf("package %s must be provided", "appimage.icon")
+	}
+	if info.AppImage.Zsync && info.Target == "" {
+		return ErrZsyncWithoutTarget
+	}
+
+	runtime, err := readRuntime(info.AppImage.Runtime, info.AppImage.UpdateInformation)
+	if err != nil {
+		return err
+	}
+	mtime := modtime.Get(info.MTime)
+	root, err := appDir(info, mtime)
+	if err != nil {
+		return err
+	}
+	image := bytes.NewBuffer(runtime)
+	if err := squashfs.Write(image, root, info.AppImage.Compression); err != nil {
+		return err
+	}
+
+	if info.AppImage.Zsync {
+		name := filepath.Base(info.Target)
+		control := zsyncControl(image.Bytes(), name, mtime)
+		if err := os.WriteFile(info.Target+".zsync", control, 0o644); err != nil { // nolint: gosec
+			return fmt.Errorf("cannot write %s.zsync: %w", name, err)
+		}
+	}
+	_, err = w.Write(image.Bytes())
+	return err
+}
+
+// readRuntime reads the type-2 runtime, writing the update information into
+// its .upd_info section.
+func readRuntime(path, updateInformation string) ([]byte, error) {
+	runtime, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// the magic bytes of type-2 AppImages, in the padding of the ELF
+	// identification.
+	if len(runtime) < 11 || string(runtime[8:11]) != "AI\x02" {
+		return nil, fmt.Errorf("%s is not a type-2 AppImage runtime", path)
+	}
+	f, err := elf.NewFile(bytes.NewReader(runtime))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read runtime: %w", err)
+	}
+	// the runtime finds the filesystem right after the end of the ELF file.
+	if size := elfSize(f, runtime); size != uint64(len(runtime)) {
+		return nil, fmt.Errorf("%s is followed by %d bytes which are not a part of the runtime", path, uint64(len(runtime))-size)
+	}
+	if updateInformation == "" {
+		return runtime, nil
+	}
+
+	section := f.Section(".upd_info")
+	if section == nil || section.Type == elf.SHT_NOBITS || section.Offset+section.Size > uint64(len(runtime)) {
+		return nil, fmt.Errorf("%s has no .upd_info section", path)
+	}
+	// the update information is NUL terminated.
+	if uint64(len(updateInformation)) >= section.Size {
+		return nil, fmt.Errorf("the update information must be shorter than the %d bytes of the .upd_info section", section.Size)
+	}
+	data := runtime[section.Offset : section.Offset+section.Size]
+	clear(data)
+	copy(data, updateInformation)
+	return runtime, nil
+}
+
+// elfSize returns the size of the ELF file, as computed by the runtime: the
+// end of its section header table or of its last section.
+func elfSize(f *elf.File, data []byte) uint64 {
+	var shoff, shentsize, shnum uint64
+	if f.Class == elf.ELFCLASS64 {
+		shoff = f.ByteOrder.Uint64(data[0x28:])
+		shentsize = uint64(f.ByteOrder.Uint16(data[0x3a:]))
+		shnum = uint64(f.ByteOrder.Uint16(data[0x3c:]))
+	} else {
+		shoff = uint64(f.ByteOrder.Uint32(data[0x20:]))
+		shentsize = uint64(f.ByteOrder.Uint16(data[0x2e:]))
+		shnum = uint64(f.ByteOrder.Uint16(data[0x30:]))
+	}
+	size := shoff + shentsize*shnum
+	if len(f.Sections) > 0 {
+		last := f.Sections[len(f.Sections)-1]
+		size = max(size, last.Offset+last.FileSize)
+	}
+	return size
+}
+
+// appDir returns the root directory of the AppDir: the contents under their
+// destinations, the AppRun, unless the contents hold one, the desktop entry,
+// the icon and the .DirIcon, a link to the icon.
+func appDir(info *nfpm.Info, mtime time.Time) (*squashfs.Node, error) {
+	root, err := squashfs.FromContents(info.Contents, mtime)
+	if err != nil {
+		return nil, err
+	}
+
+	exec := cmp.Or(info.AppImage.Exec, "/usr/bin/"+info.Name)
+	if root.Child("AppRun") == nil {
+		if node := root.Lookup(exec); node == nil || node.Type == squashfs.TypeDir {
+			return nil, fmt.Errorf("%s, the program AppRun runs, is not in the contents", exec)
+		}
+		if err := root.Add("AppRun", &squashfs.Node{Type: squashfs.TypeFile, Mode: 0o755, MTime: mtime, Data: appRun(exec)}); err != nil {
+			return nil, err
+		}
+	}
+
+	desktop := desktopEntry(info, exec)
+	if info.AppImage.Desktop != "" {
+		if desktop, err = os.ReadFile(info.AppImage.Desktop); err != nil {
+			return nil, err
+		}
+	}
+	ext := strings.ToLower(filepath.Ext(info.AppImage.Icon))
+	if ext != ".png" && ext != ".svg" {
+		return nil, fmt.Errorf("the icon must be a PNG or SVG file: %s", info.AppImage.Icon)
+	}
+	icon, err := os.ReadFile(info.AppImage.Icon)
+	if err != nil {
+		return nil, err
+	}
+	iconName := cmp.Or(desktopIcon(desktop), info.Name) + ext
+	for _, entry := range []struct {
+		name string
+		node *squashfs.Node
+	}{
+		{info.Name + ".desktop", &squashfs.Node{Type: squashfs.TypeFile, Mode: 0o644, Data: desktop}},
+		{iconName, &squashfs.Node{Type: squashfs.TypeFile, Mode: 0o644, Data: icon}},
+		{".DirIcon", &squashfs.Node{Type: squashfs.TypeSymlink, Mode: 0o777, Data: []byte(iconName)}},
+	} {
+		entry.node.MTime = mtime
+		if err := root.Add(entry.name, entry.node); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// appRun returns the AppRun running the given program of the AppDir, with
+// the programs and libraries of the AppDir in the paths.
+func appRun(exec string) []byte {
+	return []byte(`#!/bin/sh
+HERE="${APPDIR:-$(dirname "$(readlink -f "$0")")}"
+export PATH="$HERE/usr/bin:$PATH"
+export LD_LIBRARY_PATH="$HERE/usr/lib${LD_LIBRARY_PATH:+:$LD_LIBRARY_PATH}"
+exec "$HERE"` + shellQuote(exec) + ` "$@"
+`)
+}
+
+// shellQuote quotes s as a single single-quoted word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// desktopEntry returns the desktop entry of the application, its icon being
+// named after the package.
+func desktopEntry(info *nfpm.Info, exec string) []byte {
+	categories := info.AppImage.Categories
+	if len(categories) == 0 {
+		categories = []string{"Utility"}
+	}
+	var b bytes.Buffer
+	b.WriteString("[Desktop Entry]\nType=Application\n")
+	fmt.Fprintf(&b, "Name=%s\n", info.Name)
+	if comment, _, _ := strings.Cut(strings.TrimSpace(info.Description), "\n"); comment != "" {
+		fmt.Fprintf(&b, "Comment=%s\n", strings.TrimSpace(comment))
+	}
+	fmt.Fprintf(&b, "Exec=%s\n", path.Base(exec))
+	fmt.Fprintf(&b, "Icon=%s\n", info.Name)
+	fmt.Fprintf(&b, "Categories=%s;\n", strings.Join(categories, ";"))
+	fmt.Fprintf(&b, "Terminal=%t\n", info.AppImage.Terminal)
+	fmt.Fprintf(&b, "X-AppImage-Version=%s\n", appVersion(info))
+	return b.Bytes()
+}
+
+// desktopIcon returns the icon of the desktop entry, which names the icon of
+// the AppDir without its extension.
+func desktopIcon(desktop []byte) string {
+	var group string
+	scanner := bufio.NewScanner(bytes.NewReader(desktop))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			group = line
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && group == "[Desktop Entry]" && strings.TrimSpace(key) == "Icon" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
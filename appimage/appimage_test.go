@@ -0,0 +1,346 @@
+package appimage
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/squashfs"
+	"github.com/goreleaser/nfpm/v2/internal/squashfs/squashfstest"
+	"github.com/stretchr/testify/require"
+)
+
+var mtime = time.Date(2023, 11, 5, 23, 15, 17, 0, time.UTC)
+
+// fakeRuntime writes an ELF file with the magic bytes of the type-2 runtimes
+// and a .upd_info section, and returns its path.
+func fakeRuntime(t *testing.T) string {
+	t.Helper()
+	const updInfoSize = 64
+	shstrtab := "\x00.upd_info\x00.shstrtab\x00"
+	shoff := 64 + updInfoSize + len(shstrtab)
+
+	var b bytes.Buffer
+	ident := [elf.EI_NIDENT]byte{0x7f, 'E', 'L', 'F', byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT), 0, 'A', 'I', 2}
+	require.NoError(t, binary.Write(&b, binary.LittleEndian, elf.Header64{
+		Ident:     ident,
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     uint64(shoff),
+		Ehsize:    64,
+		Shentsize: 64,
+		Shnum:     3,
+		Shstrndx:  2,
+	}))
+	b.Write(make([]byte, updInfoSize))
+	b.WriteString(shstrtab)
+	require.NoError(t, binary.Write(&b, binary.LittleEndian, []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_PROGBITS), Off: 64, Size: updInfoSize, Addralign: 1},
+		{Name: 11, Type: uint32(elf.SHT_STRTAB), Off: 64 + updInfoSize, Size: uint64(len(shstrtab)), Addralign: 1},
+	}))
+	require.Equal(t, shoff+3*64, b.Len())
+
+	path := filepath.Join(t.TempDir(), "runtime")
+	require.NoError(t, os.WriteFile(path, b.Bytes(), 0o755))
+	return path
+}
+
+func exampleInfo(t *testing.T) *nfpm.Info {
+	t.Helper()
+	return nfpm.WithDefaults(&nfpm.Info{
+		Name:        "fake",
+		Arch:        "amd64",
+		Description: "Fake does things\nand more things",
+		Version:     "v1.0.0",
+		Prerelease:  "rc1",
+		MTime:       mtime,
+		Overridables: nfpm.Overridables{
+			Contents: []*files.Content{
+				{
+					Source:      "../testdata/fake",
+					Destination: "/usr/bin/fake",
+					FileInfo: &files.ContentFileInfo{
+						Mode: 0o755,
+					},
+				},
+				{
+					Source:      "../testdata/whatever.conf",
+					Destination: "/usr/share/fake/fake.conf",
+				},
+				{
+					Source:      "fake",
+					Destination: "/usr/bin/fake-link",
+					Type:        files.TypeSymlink,
+				},
+			},
+			AppImage: nfpm.AppImage{
+				Runtime:    fakeRuntime(t),
+				Icon:       "../testdata/acceptance/testapp/logo.png",
+				Categories: []string{"Development", "Utility"},
+			},
+		},
+	})
+}
+
+// readAppImage returns the runtime and the entries of the filesystem of an
+// AppImage.
+func readAppImage(t *testing.T, info *nfpm.Info, appImage []byte) ([]byte, map[string]squashfstest.Entry) {
+	t.Helper()
+	runtime, err := os.ReadFile(info.AppImage.Runtime)
+	require.NoError(t, err)
+	return appImage[:len(runtime)], squashfstest.Read(t, appImage[len(runtime):])
+}
+
+func TestConventionalFileName(t *testing.T) {
+	for arch, expected := range map[string]string{
+		"amd64":   "fake-1.0.0-rc1-x86_64.AppImage",
+		"arm64":   "fake-1.0.0-rc1-aarch64.AppImage",
+		"arm7":    "fake-1.0.0-rc1-armhf.AppImage",
+		"386":     "fake-1.0.0-rc1-i686.AppImage",
+		"riscv64": "fake-1.0.0-rc1-riscv64.AppImage",
+	} {
+		t.Run(arch, func(t *testing.T) {
+			info := exampleInfo(t)
+			info.Arch = arch
+			require.Equal(t, expected, Default.ConventionalFileName(info))
+		})
+	}
+}
+
+func TestPackage(t *testing.T) {
+	info := exampleInfo(t)
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	runtime, entries := readAppImage(t, info, buf.Bytes())
+	expectedRuntime, err := os.ReadFile(info.AppImage.Runtime)
+	require.NoError(t, err)
+	require.Equal(t, expectedRuntime, runtime)
+
+	var paths []string
+	for path, e := range entries {
+		paths = append(paths, path)
+		require.Equal(t, uint32(mtime.Unix()), e.MTime, path)
+	}
+	require.ElementsMatch(t, []string{
+		"",
+		".DirIcon",
+		"AppRun",
+		"fake.desktop",
+		"fake.png",
+		"usr",
+		"usr/bin",
+		"usr/bin/fake",
+		"usr/bin/fake-link",
+		"usr/share",
+		"usr/share/fake",
+		"usr/share/fake/fake.conf",
+	}, paths)
+
+	fake, err := os.ReadFile("../testdata/fake")
+	require.NoError(t, err)
+	require.Equal(t, fake, entries["usr/bin/fake"].Data)
+	require.Equal(t, uint16(0o755), entries["usr/bin/fake"].Mode)
+	require.Equal(t, uint16(squashfs.TypeSymlink), entries["usr/bin/fake-link"].Type)
+	require.Equal(t, "fake", string(entries["usr/bin/fake-link"].Data))
+
+	require.Equal(t, uint16(0o755), entries["AppRun"].Mode)
+	require.Contains(t, string(entries["AppRun"].Data), `exec "$HERE"'/usr/bin/fake' "$@"`)
+	require.Equal(t, strings.Join([]string{
+		"[Desktop Entry]",
+		"Type=Application",
+		"Name=fake",
+		"Comment=Fake does things",
+		"Exec=fake",
+		"Icon=fake",
+		"Categories=Development;Utility;",
+		"Terminal=false",
+		"X-AppImage-Version=1.0.0-rc1",
+		"",
+	}, "\n"), string(entries["fake.desktop"].Data))
+	icon, err := os.ReadFile("../testdata/acceptance/testapp/logo.png")
+	require.NoError(t, err)
+	require.Equal(t, icon, entries["fake.png"].Data)
+	require.Equal(t, uint16(squashfs.TypeSymlink), entries[".DirIcon"].Type)
+	require.Equal(t, "fake.png", string(entries[".DirIcon"].Data))
+}
+
+func TestPackageReproducible(t *testing.T) {
+	info := exampleInfo(t)
+	var a, b bytes.Buffer
+	require.NoError(t, Default.Package(info, &a))
+	require.NoError(t, Default.Package(info, &b))
+	require.Equal(t, a.Bytes(), b.Bytes())
+}
+
+func TestPackageCompression(t *testing.T) {
+	for compression, id := range map[string]uint16{
+		"":     6,
+		"zstd": 6,
+		"xz":   4,
+		"gzip": 1,
+	} {
+		t.Run(compression, func(t *testing.T) {
+			info := exampleInfo(t)
+			info.AppImage.Compression = compression
+			var buf bytes.Buffer
+			require.NoError(t, Default.Package(info, &buf))
+			runtime, entries := readAppImage(t, info, buf.Bytes())
+			require.Equal(t, id, binary.LittleEndian.Uint16(buf.Bytes()[len(runtime)+20:]))
+			fake, err := os.ReadFile("../testdata/fake")
+			require.NoError(t, err)
+			require.Equal(t, fake, entries["usr/bin/fake"].Data)
+		})
+	}
+
+	info := exampleInfo(t)
+	info.AppImage.Compression = "lz4"
+	require.EqualError(t, Default.Package(info, io.Discard), "unknown compression algorithm: lz4")
+}
+
+func TestPackageDesktop(t *testing.T) {
+	desktop := "[Desktop Entry]\nType=Application\nName=Fake\nExec=fake %F\nIcon=org.example.Fake\nCategories=Utility;\n"
+	path := filepath.Join(t.TempDir(), "fake.desktop")
+	require.NoError(t, os.WriteFile(path, []byte(desktop), 0o644))
+	info := exampleInfo(t)
+	info.AppImage.Desktop = path
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	_, entries := readAppImage(t, info, buf.Bytes())
+	require.Equal(t, desktop, string(entries["fake.desktop"].Data))
+	require.Contains(t, entries, "org.example.Fake.png")
+	require.NotContains(t, entries, "fake.png")
+	require.Equal(t, "org.example.Fake.png", string(entries[".DirIcon"].Data))
+}
+
+func TestPackageAppRun(t *testing.T) {
+	info := exampleInfo(t)
+	info.Contents = append(info.Contents, &files.Content{
+		Source:      "../testdata/fake",
+		Destination: "/AppRun",
+		FileInfo:    &files.ContentFileInfo{Mode: 0o755},
+	})
+	info.AppImage.Exec = "/usr/bin/missing"
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	_, entries := readAppImage(t, info, buf.Bytes())
+	fake, err := os.ReadFile("../testdata/fake")
+	require.NoError(t, err)
+	require.Equal(t, fake, entries["AppRun"].Data)
+}
+
+func TestPackageMissingExec(t *testing.T) {
+	info := exampleInfo(t)
+	info.AppImage.Exec = "/usr/bin/missing"
+	err := Default.Package(info, io.Discard)
+	require.EqualError(t, err, "/usr/bin/missing, the program AppRun runs, is not in the contents")
+}
+
+func TestPackageRequiredFields(t *testing.T) {
+	info := exampleInfo(t)
+	info.AppImage.Runtime = ""
+	require.EqualError(t, Default.Package(info, io.Discard), "package appimage.runtime must be provided")
+
+	info = exampleInfo(t)
+	info.AppImage.Icon = ""
+	require.EqualError(t, Default.Package(info, io.Discard), "package appimage.icon must be provided")
+
+	info = exampleInfo(t)
+	info.AppImage.Icon = "../testdata/whatever.conf"
+	require.EqualError(t, Default.Package(info, io.Discard), "the icon must be a PNG or SVG file: ../testdata/whatever.conf")
+}
+
+func TestPackageInvalidRuntime(t *testing.T) {
+	info := exampleInfo(t)
+	info.AppImage.Runtime = "../testdata/fake"
+	err := Default.Package(info, io.Discard)
+	require.EqualError(t, err, "../testdata/fake is not a type-2 AppImage runtime")
+
+	// e.g. an AppImage given as the runtime.
+	info = exampleInfo(t)
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	path := filepath.Join(t.TempDir(), "fake.AppImage")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o755))
+	info.AppImage.Runtime = path
+	err = Default.Package(info, io.Discard)
+	require.ErrorContains(t, err, "which are not a part of the runtime")
+}
+
+func TestPackageUpdateInformation(t *testing.T) {
+	info := exampleInfo(t)
+	info.AppImage.UpdateInformation = "gh-releases-zsync|foo|bar|latest|fake-*x86_64.AppImage.zsync"
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	runtime, _ := readAppImage(t, info, buf.Bytes())
+	f, err := elf.NewFile(bytes.NewReader(runtime))
+	require.NoError(t, err)
+	data, err := f.Section(".upd_info").Data()
+	require.NoError(t, err)
+	require.Equal(t, info.AppImage.UpdateInformation, string(bytes.TrimRight(data, "\x00")))
+
+	info.AppImage.UpdateInformation = strings.Repeat("x", 64)
+	err = Default.Package(info, io.Discard)
+	require.EqualError(t, err, "the update information must be shorter than the 64 bytes of the .upd_info section")
+}
+
+func TestPackageZsync(t *testing.T) {
+	info := exampleInfo(t)
+	info.AppImage.Zsync = true
+	require.ErrorIs(t, Default.Package(info, io.Discard), ErrZsyncWithoutTarget)
+
+	info.Target = filepath.Join(t.TempDir(), "fake.AppImage")
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	control, err := os.ReadFile(info.Target + ".zsync")
+	require.NoError(t, err)
+	header, checksums, ok := bytes.Cut(control, []byte("\n\n"))
+	require.True(t, ok)
+	require.Contains(t, string(header), "\nFilename: fake.AppImage\n")
+	require.Contains(t, string(header), "\nURL: fake.AppImage\n")
+	require.Contains(t, string(header), "\nMTime: Sun, 05 Nov 2023 23:15:17 +0000\n")
+	var seqMatches, rsumLen, checksumLen int
+	_, err = fmt.Sscanf(string(header[bytes.Index(header, []byte("Hash-Lengths: ")):]), "Hash-Lengths: %d,%d,%d", &seqMatches, &rsumLen, &checksumLen)
+	require.NoError(t, err)
+	require.Equal(t, 2, seqMatches)
+	blocks := (buf.Len() + 2047) / 2048
+	require.Len(t, checksums, blocks*(rsumLen+checksumLen))
+}
+
+func TestZsyncControl(t *testing.T) {
+	data := bytes.Repeat([]byte("hello\n"), 5000)
+	control := zsyncControl(data, "hello.txt", mtime)
+	header, checksums, ok := bytes.Cut(control, []byte("\n\n"))
+	require.True(t, ok)
+	require.Equal(t, strings.Join([]string{
+		"zsync: 0.6.2",
+		"Filename: hello.txt",
+		"MTime: Sun, 05 Nov 2023 23:15:17 +0000",
+		"Blocksize: 2048",
+		"Length: 30000",
+		"Hash-Lengths: 2,2,3",
+		"URL: hello.txt",
+		"SHA-1: 78f6b79fc8589425c3a3d3b4bf08bbe4a6ea9cae",
+	}, "\n"), string(header))
+	// 15 blocks, each with the second half of its rolling checksum and the
+	// start of its MD4 checksum.
+	require.Len(t, checksums, 15*5)
+	require.Equal(t, []byte{0x39, 0x32}, checksums[:2])
+	// the last block, padded with zeros.
+	require.Equal(t, []byte{0x2f, 0x5f}, checksums[14*5:14*5+2])
+}
+
+func TestRollingChecksum(t *testing.T) {
+	// a = 1+2+3, b = 3*1+2*2+1*3.
+	require.Equal(t, [4]byte{0, 6, 0, 10}, rollingChecksum([]byte{1, 2, 3}))
+}
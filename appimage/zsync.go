@@ -0,0 +1,74 @@
+package appimage
+
+import (
+	"bytes"
+	"crypto/sha1" // nolint: gosec
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"golang.org/x/crypto/md4" // nolint: staticcheck
+)
+
+// zsyncControl returns the control file of the given file, as written by
+// zsyncmake(1): zsync(1) reads it to find the blocks of the file a local
+// copy of an older version already has, and downloads the others. The file
+// is expected next to its control file.
+func zsyncControl(data []byte, name string, mtime time.Time) []byte {
+	blockSize := 2048
+	if len(data) >= 100_000_000 {
+		blockSize = 4096
+	}
+	blocks := (len(data) + blockSize - 1) / blockSize
+
+	// the lengths of the checksums of the blocks, long enough to make
+	// false positives unlikely.
+	seqMatches := 1
+	if len(data) > blockSize {
+		seqMatches = 2
+	}
+	length := float64(len(data))
+	logBlocks := math.Log(float64(1 + len(data)/blockSize))
+	rsumLen := int(math.Ceil(((math.Log(length)+math.Log(float64(blockSize)))/math.Log(2) - 8.6) / float64(seqMatches) / 8))
+	rsumLen = min(max(rsumLen, 2), 4)
+	checksumLen := int(math.Ceil((20 + (math.Log(length)+logBlocks)/math.Log(2)) / float64(seqMatches) / 8))
+	checksumLen = min(max(checksumLen, int((7.9+(20+logBlocks/math.Log(2)))/8)), md4.Size)
+
+	var b bytes.Buffer
+	b.WriteString("zsync: 0.6.2\n")
+	fmt.Fprintf(&b, "Filename: %s\n", name)
+	fmt.Fprintf(&b, "MTime: %s\n", mtime.UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Blocksize: %d\n", blockSize)
+	fmt.Fprintf(&b, "Length: %d\n", len(data))
+	fmt.Fprintf(&b, "Hash-Lengths: %d,%d,%d\n", seqMatches, rsumLen, checksumLen)
+	fmt.Fprintf(&b, "URL: %s\n", name)
+	fmt.Fprintf(&b, "SHA-1: %x\n\n", sha1.Sum(data)) // nolint: gosec
+
+	block := make([]byte, blockSize)
+	for i := range blocks {
+		// the last block is padded with zeros.
+		clear(block[copy(block, data[i*blockSize:]):])
+		rsum := rollingChecksum(block)
+		b.Write(rsum[4-rsumLen:])
+		h := md4.New()
+		h.Write(block)
+		b.Write(h.Sum(nil)[:checksumLen])
+	}
+	return b.Bytes()
+}
+
+// rollingChecksum returns the weak checksum of the block zsync rolls over the
+// local file: the sum of its bytes, and the sum of the bytes weighted by
+// their distance to the end of the block.
+func rollingChecksum(block []byte) [4]byte {
+	var a, b uint16
+	for i, c := range block {
+		a += uint16(c)
+		b += uint16(len(block)-i) * uint16(c)
+	}
+	var rsum [4]byte
+	binary.BigEndian.PutUint16(rsum[:], a)
+	binary.BigEndian.PutUint16(rsum[2:], b)
+	return rsum
+}
@@ -30,15 +30,33 @@ const packagerName = "archlinux"
 // nolint: gochecknoinits
 func init() {
 	nfpm.RegisterPackager(packagerName, Default)
+	nfpm.RegisterPackager(formatPKGBUILD.String(), DefaultPKGBUILD)
+	nfpm.RegisterReader(packagerName, Default)
 }
 
 // Default ArchLinux packager.
 // nolint: gochecknoglobals
 var Default = ArchLinux{}
 
+// DefaultPKGBUILD PKGBUILD recipe packager.
+// nolint: gochecknoglobals
+var DefaultPKGBUILD = ArchLinux{formatPKGBUILD}
+
+type format uint
+
+const (
+	formatArchLinux format = iota
+	formatPKGBUILD
+)
+
+// String implements fmt.Stringer.
+func (f format) String() string { return [2]string{"archlinux", "pkgbuild"}[f] }
+
 // ArchLinux packager.
 // nolint: revive
-type ArchLinux struct{}
+type ArchLinux struct {
+	format format
+}
 
 // nolint: gochecknoglobals
 var archToArchLinux = map[string]string{
@@ -68,8 +86,12 @@ func ensureValidArch(info *nfpm.Info) *nfpm.Info {
 // ConventionalFileName returns a file name for a package conforming
 // to Arch Linux package naming guidelines. See:
 // https://wiki.archlinux.org/title/Arch_package_guidelines#Package_naming
-func (ArchLinux) ConventionalFileName(info *nfpm.Info) string {
+func (a ArchLinux) ConventionalFileName(info *nfpm.Info) string {
 	info = ensureValidArch(info)
+	// makepkg only reads recipes named PKGBUILD.
+	if a.format == formatPKGBUILD {
+		return "PKGBUILD"
+	}
 
 	pkgrel, err := strconv.Atoi(info.Release)
 	if err != nil {
@@ -116,21 +138,16 @@ func isOneOf(r rune, rr ...rune) bool {
 }
 
 // Package writes a new archlinux package to the given writer using the given info.
-func (ArchLinux) Package(info *nfpm.Info, w io.Writer) error {
-	if info.Platform != "linux" {
-		return fmt.Errorf("invalid platform: %s", info.Platform)
+func (a ArchLinux) Package(info *nfpm.Info, w io.Writer) error {
+	defer info.Cleanup()
+	if a.format == formatPKGBUILD {
+		return a.packagePKGBUILD(info, w)
 	}
-	info = ensureValidArch(info)
 
-	err := nfpm.PrepareForPackager(info, packagerName)
-	if err != nil {
+	if err := prepare(info); err != nil {
 		return err
 	}
 
-	if !nameIsValid(info.Name) {
-		return ErrInvalidPkgName
-	}
-
 	zw, err := zstd.NewWriter(w)
 	if err != nil {
 		return err
@@ -161,8 +178,37 @@ func (ArchLinux) Package(info *nfpm.Info, w io.Writer) error {
 	return createScripts(info, tw)
 }
 
+func prepare(info *nfpm.Info) error {
+	if info.Platform != "linux" {
+		return fmt.Errorf("invalid platform: %s", info.Platform)
+	}
+	info = ensureValidArch(info)
+
+	err := nfpm.PrepareForPackager(info, packagerName)
+	if err != nil {
+		return err
+	}
+
+	if err := nfpm.PrepareAutoDepends(info, packagerName); err != nil {
+		return err
+	}
+	if err := nfpm.PrepareRelations(info, packagerName, func(arch string) bool {
+		return arch == info.Arch || archToArchLinux[arch] == info.Arch
+	}); err != nil {
+		return err
+	}
+
+	if !nameIsValid(info.Name) {
+		return ErrInvalidPkgName
+	}
+	return nil
+}
+
 // ConventionalExtension returns the file name conventionally used for Arch Linux packages
-func (ArchLinux) ConventionalExtension() string {
+func (a ArchLinux) ConventionalExtension() string {
+	if a.format == formatPKGBUILD {
+		return ""
+	}
 	return ".pkg.tar.zst"
 }
 
@@ -518,30 +564,9 @@ func createMtree(tw *tar.Writer, entries []MtreeEntry, mtime time.Time) error {
 }
 
 func createScripts(info *nfpm.Info, tw *tar.Writer) error {
-	scripts := map[string]string{}
-
-	if info.Scripts.PreInstall != "" {
-		scripts["pre_install"] = info.Scripts.PreInstall
-	}
-
-	if info.Scripts.PostInstall != "" {
-		scripts["post_install"] = info.Scripts.PostInstall
-	}
-
-	if info.Scripts.PreRemove != "" {
-		scripts["pre_remove"] = info.Scripts.PreRemove
-	}
-
-	if info.Scripts.PostRemove != "" {
-		scripts["post_remove"] = info.Scripts.PostRemove
-	}
-
-	if info.ArchLinux.Scripts.PreUpgrade != "" {
-		scripts["pre_upgrade"] = info.ArchLinux.Scripts.PreUpgrade
-	}
-
-	if info.ArchLinux.Scripts.PostUpgrade != "" {
-		scripts["post_upgrade"] = info.ArchLinux.Scripts.PostUpgrade
+	scripts, err := nfpm.MaintainerScripts(info, packagerName)
+	if err != nil {
+		return err
 	}
 
 	if len(scripts) == 0 {
@@ -550,7 +575,7 @@ func createScripts(info *nfpm.Info, tw *tar.Writer) error {
 
 	buf := &bytes.Buffer{}
 
-	err := writeScripts(buf, scripts)
+	err = writeScripts(buf, scripts)
 	if err != nil {
 		return err
 	}
@@ -570,22 +595,14 @@ func createScripts(info *nfpm.Info, tw *tar.Writer) error {
 	return err
 }
 
-func writeScripts(w io.Writer, scripts map[string]string) error {
+func writeScripts(w io.Writer, scripts map[string][]byte) error {
 	for _, script := range maps.Keys(scripts) {
 		fmt.Fprintf(w, "function %s() {\n", script)
 
-		fl, err := os.Open(scripts[script])
+		_, err := w.Write(scripts[script])
 		if err != nil {
 			return err
 		}
-		defer fl.Close() //nolint: errcheck
-
-		_, err = io.Copy(w, fl)
-		if err != nil {
-			return err
-		}
-
-		_ = fl.Close()
 
 		_, err = io.WriteString(w, "\n}\n\n")
 		if err != nil {
@@ -0,0 +1,215 @@
+package arch
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/klauspost/pgzip"
+)
+
+// ErrPKGBUILDWithoutTarget happens if a PKGBUILD is written without a target
+// file, next to which its sources are written.
+var ErrPKGBUILDWithoutTarget = errors.New("pkgbuild: the target file is required to write the sources next to it")
+
+// makepkg options of the generated PKGBUILDs: the contents are packaged as
+// they are, so nothing is stripped, compressed or removed from them.
+// nolint: gochecknoglobals
+var pkgbuildOptions = []string{"!strip", "!debug", "!zipman", "!purge", "!libtool", "!staticlibs", "emptydirs"}
+
+// pkgbuildSource is a file written next to the PKGBUILD.
+type pkgbuildSource struct {
+	name    string
+	content []byte
+}
+
+// packagePKGBUILD writes a PKGBUILD recipe to w, and the sources it builds
+// the packages from next to the target: the payload of each package, which
+// its package function extracts as it is, and its install file.
+// Running `makepkg` on the result reproduces the archlinux packages.
+func (ArchLinux) packagePKGBUILD(info *nfpm.Info, w io.Writer) error {
+	if info.Target == "" {
+		return ErrPKGBUILDWithoutTarget
+	}
+
+	infos := append([]*nfpm.Info{info}, info.SplitPackages...)
+	var payloads, installs []pkgbuildSource
+	for _, pkg := range infos {
+		if err := prepare(pkg); err != nil {
+			if pkg != info {
+				return fmt.Errorf("%s: %w", pkg.Name, err)
+			}
+			return err
+		}
+
+		payload, err := createPayload(pkg)
+		if err != nil {
+			return err
+		}
+		payloads = append(payloads, pkgbuildSource{
+			name:    fmt.Sprintf("%s-%s.tar.gz", pkg.Name, pkgbuildFileVersion(pkg)),
+			content: payload,
+		})
+
+		scripts, err := nfpm.MaintainerScripts(pkg, packagerName)
+		if err != nil {
+			return err
+		}
+		var install bytes.Buffer
+		if err := writeScripts(&install, scripts); err != nil {
+			return err
+		}
+		installs = append(installs, pkgbuildSource{
+			name:    pkg.Name + ".install",
+			content: install.Bytes(),
+		})
+	}
+
+	for _, source := range append(payloads, installs...) {
+		if len(source.content) == 0 {
+			continue
+		}
+		target := filepath.Join(filepath.Dir(info.Target), source.name)
+		if err := os.WriteFile(target, source.content, 0o644); err != nil { // nolint: gosec
+			return fmt.Errorf("cannot write %s: %w", source.name, err)
+		}
+	}
+
+	_, err := io.WriteString(w, generatePKGBUILD(infos, payloads, installs))
+	return err
+}
+
+// createPayload returns the tarball holding the contents of the package.
+func createPayload(info *nfpm.Info) ([]byte, error) {
+	var buf bytes.Buffer
+	gw := pgzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	if _, _, err := createFilesInTar(info, tw); err != nil {
+		return nil, fmt.Errorf("create files in tar: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pkgbuildVersion returns the pkgver and pkgrel of the package.
+func pkgbuildVersion(info *nfpm.Info) (string, int) {
+	pkgrel, err := strconv.Atoi(info.Release)
+	if err != nil {
+		pkgrel = 1
+	}
+	return info.Version + strings.ReplaceAll(info.Prerelease, "-", "_"), pkgrel
+}
+
+// pkgbuildFileVersion returns the version of the package as used in file
+// names.
+func pkgbuildFileVersion(info *nfpm.Info) string {
+	pkgver, pkgrel := pkgbuildVersion(info)
+	return fmt.Sprintf("%s-%d", pkgver, pkgrel)
+}
+
+// generatePKGBUILD synthesizes a PKGBUILD building the packages from their
+// payloads: the split packages are built from the same pkgbase, nothing is
+// built, stripped or compressed, and the package functions extract the
+// payloads, owners included, as makepkg runs them under fakeroot.
+func generatePKGBUILD(infos []*nfpm.Info, payloads, installs []pkgbuildSource) string {
+	info := infos[0]
+	pkgver, pkgrel := pkgbuildVersion(info)
+
+	var b strings.Builder
+	b.WriteString("# Generated by nfpm\n")
+	if maintainer := cmp.Or(info.ArchLinux.Packager, info.Maintainer); maintainer != "" {
+		fmt.Fprintf(&b, "# Maintainer: %s\n", maintainer)
+	}
+
+	writeArray := func(indent, key string, values ...string) {
+		quoted := make([]string, 0, len(values))
+		for _, value := range values {
+			quoted = append(quoted, shellQuote(value))
+		}
+		fmt.Fprintf(&b, "%s%s=(%s)\n", indent, key, strings.Join(quoted, " "))
+	}
+	writePackageVars := func(indent string, info *nfpm.Info, install pkgbuildSource) {
+		fmt.Fprintf(&b, "%spkgdesc=%s\n", indent, shellQuote(strings.ReplaceAll(info.Description, "\n", " ")))
+		writeArray(indent, "depends", info.Depends...)
+		writeArray(indent, "provides", info.Provides...)
+		writeArray(indent, "conflicts", info.Conflicts...)
+		writeArray(indent, "replaces", info.Replaces...)
+		var backup []string
+		for _, content := range info.Contents {
+			switch content.Type {
+			case files.TypeConfig, files.TypeConfigNoReplace, files.TypeConfigMissingOK:
+				backup = append(backup, files.AsRelativePath(content.Destination))
+			}
+		}
+		writeArray(indent, "backup", backup...)
+		if len(install.content) > 0 {
+			fmt.Fprintf(&b, "%sinstall=%s\n", indent, shellQuote(install.name))
+		} else if indent != "" {
+			// the split packages would install the file of the pkgbase.
+			fmt.Fprintf(&b, "%sinstall=\n", indent)
+		}
+	}
+
+	if len(infos) > 1 {
+		fmt.Fprintf(&b, "pkgbase=%s\n", shellQuote(cmp.Or(info.ArchLinux.Pkgbase, info.Name)))
+		names := make([]string, 0, len(infos))
+		for _, pkg := range infos {
+			names = append(names, pkg.Name)
+		}
+		writeArray("", "pkgname", names...)
+	} else {
+		fmt.Fprintf(&b, "pkgname=%s\n", shellQuote(info.Name))
+	}
+	fmt.Fprintf(&b, "pkgver=%s\n", shellQuote(pkgver))
+	fmt.Fprintf(&b, "pkgrel=%d\n", pkgrel)
+	if info.Epoch != "" {
+		fmt.Fprintf(&b, "epoch=%s\n", shellQuote(info.Epoch))
+	}
+	writeArray("", "arch", info.Arch)
+	fmt.Fprintf(&b, "url=%s\n", shellQuote(info.Homepage))
+	writeArray("", "license", info.License)
+	if len(infos) == 1 {
+		writePackageVars("", info, installs[0])
+	}
+	writeArray("", "options", pkgbuildOptions...)
+	var sources, sums []string
+	for _, payload := range payloads {
+		sources = append(sources, payload.name)
+		sums = append(sums, fmt.Sprintf("%x", sha256.Sum256(payload.content)))
+	}
+	writeArray("", "source", sources...)
+	// the payloads are extracted by the package functions.
+	writeArray("", "noextract", sources...)
+	writeArray("", "sha256sums", sums...)
+
+	for i, pkg := range infos {
+		if len(infos) == 1 {
+			b.WriteString("\npackage() {\n")
+		} else {
+			fmt.Fprintf(&b, "\npackage_%s() {\n", pkg.Name)
+			writePackageVars("\t", pkg, installs[i])
+		}
+		fmt.Fprintf(&b, "\ttar -xpf \"$srcdir\"/%s -C \"$pkgdir\"\n}\n", shellQuote(payloads[i].name))
+	}
+	return b.String()
+}
+
+// shellQuote quotes s as a single single-quoted word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
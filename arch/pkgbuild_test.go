@@ -0,0 +1,103 @@
+package arch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+func examplePKGBUILDInfo(t *testing.T) *nfpm.Info {
+	t.Helper()
+	info := exampleInfo()
+	info.MTime = mtime
+	info.Target = filepath.Join(t.TempDir(), DefaultPKGBUILD.ConventionalFileName(info))
+	info.SplitPackages = []*nfpm.Info{nfpm.WithDefaults(&nfpm.Info{
+		Name:        "foo-test-doc",
+		Arch:        "any",
+		Description: "Foo's documentation",
+		Version:     "1.0.0",
+		Prerelease:  "beta-1",
+		Maintainer:  info.Maintainer,
+		Overridables: nfpm.Overridables{
+			Depends: []string{"foo-test"},
+			Contents: []*files.Content{
+				{
+					Source:      "../testdata/whatever.conf",
+					Destination: "/usr/share/doc/foo/README",
+				},
+			},
+		},
+	})}
+	return info
+}
+
+func TestPKGBUILDConventionalFileName(t *testing.T) {
+	require.Equal(t, "PKGBUILD", DefaultPKGBUILD.ConventionalFileName(exampleInfo()))
+	require.Empty(t, DefaultPKGBUILD.ConventionalExtension())
+}
+
+func TestPKGBUILD(t *testing.T) {
+	info := examplePKGBUILDInfo(t)
+	info.SplitPackages = nil
+	var pkgbuild bytes.Buffer
+	require.NoError(t, DefaultPKGBUILD.Package(info, &pkgbuild))
+
+	dir := filepath.Dir(info.Target)
+	payload, err := os.ReadFile(filepath.Join(dir, "foo-test-1.0.0beta_1-1.tar.gz"))
+	require.NoError(t, err)
+	install, err := os.ReadFile(filepath.Join(dir, "foo-test.install"))
+	require.NoError(t, err)
+	require.Contains(t, string(install), "function post_install() {\n")
+	require.Contains(t, string(install), "function pre_upgrade() {\n")
+
+	require.Equal(t, fmt.Sprintf(`# Generated by nfpm
+# Maintainer: Carlos A Becker <pkg@carlosbecker.com>
+pkgname='foo-test'
+pkgver='1.0.0beta_1'
+pkgrel=1
+arch=('x86_64')
+url='http://carlosbecker.com'
+license=('MIT')
+pkgdesc='Foo does things'
+depends=('bash')
+provides=('bzr')
+conflicts=('zsh')
+replaces=('svn')
+backup=('etc/fake/fake.conf')
+install='foo-test.install'
+options=('!strip' '!debug' '!zipman' '!purge' '!libtool' '!staticlibs' 'emptydirs')
+source=('foo-test-1.0.0beta_1-1.tar.gz')
+noextract=('foo-test-1.0.0beta_1-1.tar.gz')
+sha256sums=('%x')
+
+package() {
+	tar -xpf "$srcdir"/'foo-test-1.0.0beta_1-1.tar.gz' -C "$pkgdir"
+}
+`, sha256.Sum256(payload)), pkgbuild.String())
+}
+
+func TestPKGBUILDSplit(t *testing.T) {
+	info := examplePKGBUILDInfo(t)
+	var pkgbuild bytes.Buffer
+	require.NoError(t, DefaultPKGBUILD.Package(info, &pkgbuild))
+
+	require.Contains(t, pkgbuild.String(), "pkgbase='foo-test'\npkgname=('foo-test' 'foo-test-doc')\n")
+	require.Contains(t, pkgbuild.String(), "\npackage_foo-test() {\n\tpkgdesc='Foo does things'\n")
+	require.Contains(t, pkgbuild.String(), "\tinstall='foo-test.install'\n")
+	require.Contains(t, pkgbuild.String(), "\npackage_foo-test-doc() {\n\tpkgdesc='Foo'\\''s documentation'\n\tdepends=('foo-test')\n")
+	require.Contains(t, pkgbuild.String(), "\tbackup=()\n\tinstall=\n\ttar -xpf \"$srcdir\"/'foo-test-doc-1.0.0beta_1-1.tar.gz' -C \"$pkgdir\"\n}\n")
+	require.NoFileExists(t, filepath.Join(filepath.Dir(info.Target), "foo-test-doc.install"))
+}
+
+func TestPKGBUILDWithoutTarget(t *testing.T) {
+	info := examplePKGBUILDInfo(t)
+	info.Target = ""
+	require.ErrorIs(t, DefaultPKGBUILD.Package(info, &bytes.Buffer{}), ErrPKGBUILDWithoutTarget)
+}
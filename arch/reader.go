@@ -0,0 +1,196 @@
+package arch
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrInvalidPackage happens when a file cannot be read as an Arch Linux package.
+var ErrInvalidPackage = errors.New("invalid archlinux package")
+
+// maps the .INSTALL function names to the nfpm script names.
+// nolint: gochecknoglobals
+var installFunctions = map[string]string{
+	"pre_install":  "preinstall",
+	"post_install": "postinstall",
+	"pre_remove":   "preremove",
+	"post_remove":  "postremove",
+	"pre_upgrade":  "preupgrade",
+	"post_upgrade": "postupgrade",
+}
+
+// nolint: gochecknoglobals
+var installFunctionRe = regexp.MustCompile(`(?m)^function (\w+)\(\) \{\n`)
+
+// Read implements nfpm.Reader.
+func (ArchLinux) Read(r io.Reader) (*nfpm.Inspection, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+	defer zr.Close()
+
+	result := &nfpm.Inspection{
+		Format: packagerName,
+		Info: &nfpm.Info{
+			Platform: "linux",
+		},
+	}
+
+	var (
+		pkginfo []byte
+		backup  = map[string]bool{}
+	)
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+
+		name := path.Clean(hdr.Name)
+		switch name {
+		case ".PKGINFO":
+			if pkginfo, err = io.ReadAll(tr); err != nil {
+				return nil, fmt.Errorf("cannot read %s: %w", name, err)
+			}
+			continue
+		case ".INSTALL":
+			install, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read %s: %w", name, err)
+			}
+			result.Scripts = parseInstall(install)
+			continue
+		}
+		if strings.HasPrefix(name, ".") {
+			// other package metadata, e.g. .MTREE or .BUILDINFO
+			continue
+		}
+		if content := files.FromTarHeader(hdr); content != nil {
+			result.Info.Contents = append(result.Info.Contents, content)
+		}
+	}
+	if pkginfo == nil {
+		return nil, fmt.Errorf("%w: missing .PKGINFO", ErrInvalidPackage)
+	}
+
+	for _, kv := range parsePkginfo(pkginfo) {
+		if kv[0] == "backup" {
+			backup[files.NormalizeAbsoluteFilePath(kv[1])] = true
+		}
+	}
+	infoFromPkginfo(result.Info, pkginfo)
+	for _, content := range result.Info.Contents {
+		if content.Type == files.TypeFile && backup[content.Destination] {
+			content.Type = files.TypeConfig
+		}
+	}
+	sort.Sort(result.Info.Contents)
+	return result, nil
+}
+
+// parsePkginfo parses the "key = value" lines of a .PKGINFO file.
+func parsePkginfo(content []byte) [][2]string {
+	var result [][2]string
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, " = ")
+		if !ok {
+			continue
+		}
+		result = append(result, [2]string{strings.TrimSpace(key), value})
+	}
+	return result
+}
+
+func infoFromPkginfo(info *nfpm.Info, content []byte) {
+	for _, kv := range parsePkginfo(content) {
+		switch key, value := kv[0], kv[1]; key {
+		case "pkgname":
+			info.Name = value
+		case "pkgbase":
+			info.ArchLinux.Pkgbase = value
+		case "pkgver":
+			info.Epoch, info.Version, info.Release = splitPkgver(value)
+		case "pkgdesc":
+			info.Description = value
+		case "url":
+			info.Homepage = value
+		case "builddate":
+			if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				info.MTime = time.Unix(sec, 0).UTC()
+			}
+		case "packager":
+			info.ArchLinux.Packager = value
+		case "arch":
+			info.Arch = value
+		case "license":
+			info.License = value
+		case "replaces":
+			info.Replaces = append(info.Replaces, value)
+		case "conflict":
+			info.Conflicts = append(info.Conflicts, value)
+		case "provides":
+			info.Provides = append(info.Provides, value)
+		case "depend":
+			info.Depends = append(info.Depends, value)
+		}
+	}
+}
+
+// splitPkgver splits an Arch Linux version, e.g. 1:1.0.0-1, into its epoch,
+// version and release.
+func splitPkgver(pkgver string) (epoch, version, release string) {
+	if e, rest, ok := strings.Cut(pkgver, ":"); ok {
+		epoch, pkgver = e, rest
+	}
+	if i := strings.LastIndex(pkgver, "-"); i >= 0 {
+		pkgver, release = pkgver[:i], pkgver[i+1:]
+	}
+	return epoch, pkgver, release
+}
+
+// parseInstall extracts the bodies of the functions of an .INSTALL file as
+// written by writeScripts.
+func parseInstall(install []byte) map[string]string {
+	scripts := map[string]string{}
+	content := string(install)
+	matches := installFunctionRe.FindAllStringSubmatchIndex(content, -1)
+	for i, match := range matches {
+		end := len(content)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		body := strings.TrimSuffix(strings.TrimRight(content[match[1]:end], "\n"), "}")
+		name := content[match[2]:match[3]]
+		if script, ok := installFunctions[name]; ok {
+			scripts[script] = strings.TrimSuffix(body, "\n")
+		}
+	}
+	if len(scripts) == 0 {
+		return nil
+	}
+	return scripts
+}
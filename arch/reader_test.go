@@ -0,0 +1,60 @@
+package arch
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	info := exampleInfo()
+	info.Epoch = "2"
+	info.Release = "3"
+	info.MTime = mtime
+	info.ArchLinux.Packager = "Foo Bar <foo@bar.com>"
+
+	var pkg bytes.Buffer
+	require.NoError(t, Default.Package(info, &pkg))
+
+	result, err := Default.Read(&pkg)
+	require.NoError(t, err)
+	require.Equal(t, packagerName, result.Format)
+
+	got := result.Info
+	require.Equal(t, "foo-test", got.Name)
+	require.Equal(t, "foo-test", got.ArchLinux.Pkgbase)
+	require.Equal(t, "2", got.Epoch)
+	require.Equal(t, "1.0.0beta_1", got.Version)
+	require.Equal(t, "3", got.Release)
+	require.Equal(t, "x86_64", got.Arch)
+	require.Equal(t, "MIT", got.License)
+	require.Equal(t, mtime, got.MTime)
+	require.Equal(t, info.ArchLinux.Packager, got.ArchLinux.Packager)
+	require.Equal(t, nfpm.Relations{"bash"}, got.Depends)
+	require.Equal(t, nfpm.Relations{"zsh"}, got.Conflicts)
+
+	contents := map[string]*files.Content{}
+	for _, c := range got.Contents {
+		contents[c.Destination] = c
+		require.False(t, strings.HasPrefix(c.Destination, "/."), c.Destination)
+	}
+	require.Equal(t, files.TypeFile, contents["/usr/bin/fake"].Type)
+	require.Equal(t, files.TypeConfig, contents["/etc/fake/fake.conf"].Type)
+	require.Equal(t, files.TypeSymlink, contents["/etc/fake/fake-link.conf"].Type)
+	require.Equal(t, files.TypeDir, contents["/var/log/whatever"].Type)
+
+	require.Len(t, result.Scripts, 6)
+	preinstall, err := os.ReadFile("../testdata/scripts/preinstall.sh")
+	require.NoError(t, err)
+	require.Equal(t, string(preinstall), result.Scripts["preinstall"])
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, err := Default.Read(strings.NewReader("not a package"))
+	require.ErrorIs(t, err, ErrInvalidPackage)
+}
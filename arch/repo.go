@@ -0,0 +1,431 @@
+package arch
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	defaultRepoName = "repo"
+	repoPackageExt  = ".pkg.tar.zst"
+)
+
+// RepoOptions configures the repository created by IndexRepo.
+type RepoOptions struct {
+	// Name of the repository, as used in pacman.conf, defaults to repo.
+	Name string
+
+	// PGP secret key used to create detached signatures of the databases, can
+	// be ASCII-armored. The databases are not signed if empty.
+	KeyFile       string
+	KeyID         *string
+	KeyPassphrase string
+}
+
+// repoPackage is a package found while indexing a repository.
+type repoPackage struct {
+	// name of its database entry, <pkgname>-<pkgver>.
+	name     string
+	pkgname  string
+	pkgver   string
+	filename string
+	desc     []byte
+	files    []byte
+}
+
+// IndexRepo indexes all Arch Linux packages in dir, creating the
+// <name>.db.tar.gz and <name>.files.tar.gz databases, the <name>.db and
+// <name>.files links to them and, if a key is given, their detached .sig
+// signatures. It returns the paths of the created files.
+//
+// If the directory holds several versions of a package, only the newest one is
+// indexed, as repo-add does.
+func IndexRepo(dir string, opts RepoOptions) ([]string, error) {
+	name := opts.Name
+	if name == "" {
+		name = defaultRepoName
+	}
+
+	// pacman fetches the packages next to the databases, so subdirectories
+	// are not indexed.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	newest := map[string]*repoPackage{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), repoPackageExt) {
+			continue
+		}
+		file := filepath.Join(dir, entry.Name())
+		pkg, err := readRepoPackage(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		other, ok := newest[pkg.pkgname]
+		if !ok {
+			newest[pkg.pkgname] = pkg
+			continue
+		}
+		switch vercmp(pkg.pkgver, other.pkgver) {
+		case 1:
+			newest[pkg.pkgname] = pkg
+		case 0:
+			// pacman repositories hold a single architecture, usually in
+			// <repo>/os/<arch>.
+			return nil, fmt.Errorf("%s and %s are both %s, a pacman repository holds a single version of each package", other.filename, pkg.filename, pkg.name)
+		}
+	}
+	pkgs := make([]*repoPackage, 0, len(newest))
+	for _, pkg := range newest {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].pkgname < pkgs[j].pkgname
+	})
+
+	var created []string
+	for _, db := range []struct {
+		name      string
+		withFiles bool
+	}{
+		{name + ".db", false},
+		{name + ".files", true},
+	} {
+		content, err := createRepoDatabase(pkgs, db.withFiles)
+		if err != nil {
+			return nil, err
+		}
+		files := map[string][]byte{db.name + ".tar.gz": content}
+		if opts.KeyFile != "" {
+			sig, err := sign.PGPSignerWithKeyID(opts.KeyFile, opts.KeyPassphrase, opts.KeyID)(content)
+			if err != nil {
+				return nil, &nfpm.ErrSigningFailure{Err: err}
+			}
+			files[db.name+".tar.gz.sig"] = sig
+		}
+		for _, file := range []string{db.name + ".tar.gz", db.name + ".tar.gz.sig"} {
+			content, ok := files[file]
+			if !ok {
+				continue
+			}
+			target := filepath.Join(dir, file)
+			if err := os.WriteFile(target, content, 0o644); err != nil { //nolint:gosec
+				return nil, err
+			}
+			// pacman downloads the databases without the archive extension.
+			link := filepath.Join(dir, strings.Replace(file, ".tar.gz", "", 1))
+			if err := os.Remove(link); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			if err := os.Symlink(file, link); err != nil {
+				return nil, err
+			}
+			created = append(created, target, link)
+		}
+	}
+	return created, nil
+}
+
+// readRepoPackage reads the .PKGINFO and the file list of the given package
+// and renders its database entry.
+func readRepoPackage(file string) (*repoPackage, error) {
+	content, err := os.ReadFile(file) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zstd.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+	defer zr.Close()
+
+	var (
+		pkginfo []byte
+		names   []string
+	)
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+		name := path.Clean(hdr.Name)
+		if name == ".PKGINFO" {
+			if pkginfo, err = io.ReadAll(tr); err != nil {
+				return nil, fmt.Errorf("cannot read %s: %w", name, err)
+			}
+			continue
+		}
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	if pkginfo == nil {
+		return nil, fmt.Errorf("%w: missing .PKGINFO", ErrInvalidPackage)
+	}
+	sort.Strings(names)
+
+	values := map[string][]string{}
+	for _, kv := range parsePkginfo(pkginfo) {
+		values[kv[0]] = append(values[kv[0]], kv[1])
+	}
+	pkgname, pkgver := first(values["pkgname"]), first(values["pkgver"])
+	if pkgname == "" || pkgver == "" {
+		return nil, fmt.Errorf("%w: .PKGINFO misses pkgname or pkgver", ErrInvalidPackage)
+	}
+
+	sum := sha256.Sum256(content)
+	var pgpsig []string
+	if sig, err := os.ReadFile(file + ".sig"); err == nil { //nolint:gosec
+		pgpsig = append(pgpsig, base64.StdEncoding.EncodeToString(sig))
+	}
+
+	// the desc sections, in the order used by repo-add.
+	var desc bytes.Buffer
+	for _, section := range []struct {
+		name   string
+		values []string
+	}{
+		{"FILENAME", []string{filepath.Base(file)}},
+		{"NAME", values["pkgname"]},
+		{"BASE", values["pkgbase"]},
+		{"VERSION", values["pkgver"]},
+		{"DESC", values["pkgdesc"]},
+		{"GROUPS", values["group"]},
+		{"CSIZE", []string{fmt.Sprint(len(content))}},
+		{"ISIZE", values["size"]},
+		{"SHA256SUM", []string{hex.EncodeToString(sum[:])}},
+		{"PGPSIG", pgpsig},
+		{"URL", values["url"]},
+		{"LICENSE", values["license"]},
+		{"ARCH", values["arch"]},
+		{"BUILDDATE", values["builddate"]},
+		{"PACKAGER", values["packager"]},
+		{"REPLACES", values["replaces"]},
+		{"CONFLICTS", values["conflict"]},
+		{"PROVIDES", values["provides"]},
+		{"DEPENDS", values["depend"]},
+		{"OPTDEPENDS", values["optdepend"]},
+		{"MAKEDEPENDS", values["makedepend"]},
+		{"CHECKDEPENDS", values["checkdepend"]},
+	} {
+		writeRepoSection(&desc, section.name, section.values...)
+	}
+
+	var files bytes.Buffer
+	writeRepoSection(&files, "FILES", names...)
+
+	return &repoPackage{
+		name:     pkgname + "-" + pkgver,
+		pkgname:  pkgname,
+		pkgver:   pkgver,
+		filename: filepath.Base(file),
+		desc:     desc.Bytes(),
+		files:    files.Bytes(),
+	}, nil
+}
+
+// vercmp compares two [epoch:]version[-release] versions as pacman's vercmp
+// does, returning -1, 0 or 1.
+func vercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	epochA, versionA, releaseA := parseEVR(a)
+	epochB, versionB, releaseB := parseEVR(b)
+	if ret := rpmvercmp(epochA, epochB); ret != 0 {
+		return ret
+	}
+	if ret := rpmvercmp(versionA, versionB); ret != 0 {
+		return ret
+	}
+	if releaseA == "" || releaseB == "" {
+		return 0
+	}
+	return rpmvercmp(releaseA, releaseB)
+}
+
+// parseEVR splits a version into its epoch, defaulting to 0, version and
+// release.
+func parseEVR(evr string) (epoch, version, release string) {
+	epoch, version = "0", evr
+	digits := strings.IndexFunc(evr, func(r rune) bool { return r < '0' || r > '9' })
+	if digits >= 0 && evr[digits] == ':' {
+		epoch, version = cmp.Or(evr[:digits], "0"), evr[digits+1:]
+	}
+	if i := strings.LastIndexByte(version, '-'); i >= 0 {
+		version, release = version[:i], version[i+1:]
+	}
+	return epoch, version, release
+}
+
+// rpmvercmp compares two versions segment by segment, as libalpm does: the
+// numeric segments are compared as numbers, the alphabetic ones as strings,
+// and a numeric segment is newer than an alphabetic one.
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	isSeparator := func(c byte) bool { return !isDigit(c) && !isAlpha(c) }
+	for a != "" && b != "" {
+		sepA, sepB := span(a, isSeparator), span(b, isSeparator)
+		a, b = a[sepA:], b[sepB:]
+		if a == "" || b == "" {
+			break
+		}
+		// versions with different separators are different.
+		if sepA != sepB {
+			if sepA < sepB {
+				return -1
+			}
+			return 1
+		}
+
+		isNum := isDigit(a[0])
+		class := isAlpha
+		if isNum {
+			class = isDigit
+		}
+		segA, segB := a[:span(a, class)], b[:span(b, class)]
+		a, b = a[len(segA):], b[len(segB):]
+		if segB == "" {
+			// the segments are of different types.
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+		if isNum {
+			segA, segB = strings.TrimLeft(segA, "0"), strings.TrimLeft(segB, "0")
+			if len(segA) != len(segB) {
+				if len(segA) < len(segB) {
+					return -1
+				}
+				return 1
+			}
+		}
+		if ret := strings.Compare(segA, segB); ret != 0 {
+			return ret
+		}
+	}
+	if a == "" && b == "" {
+		return 0
+	}
+	// a remaining alphabetic segment is older than nothing, e.g. 1.0alpha is
+	// older than 1.0, anything else is newer.
+	if a == "" && !isAlpha(b[0]) || a != "" && isAlpha(a[0]) {
+		return -1
+	}
+	return 1
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isAlpha(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+// span returns the length of the prefix of s made of the given class of
+// characters.
+func span(s string, class func(byte) bool) int {
+	i := 0
+	for i < len(s) && class(s[i]) {
+		i++
+	}
+	return i
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// writeRepoSection writes a %SECTION% of a database entry, skipping it if
+// it has no values.
+func writeRepoSection(w *bytes.Buffer, section string, values ...string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%%%s%%\n", section)
+	for _, value := range values {
+		w.WriteString(value + "\n")
+	}
+	w.WriteString("\n")
+}
+
+// createRepoDatabase creates a database with a <name>-<version> directory
+// for each package, holding its desc file and, for the files database, its
+// files list.
+func createRepoDatabase(pkgs []*repoPackage, withFiles bool) ([]byte, error) {
+	mtime := modtime.Get(modtime.FromEnv())
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, pkg := range pkgs {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     pkg.name + "/",
+			Mode:     0o755,
+			ModTime:  mtime,
+		}); err != nil {
+			return nil, err
+		}
+		entries := map[string][]byte{"desc": pkg.desc}
+		if withFiles {
+			entries["files"] = pkg.files
+		}
+		for _, name := range []string{"desc", "files"} {
+			content, ok := entries[name]
+			if !ok {
+				continue
+			}
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     pkg.name + "/" + name,
+				Mode:     0o644,
+				Size:     int64(len(content)),
+				ModTime:  mtime,
+			}); err != nil {
+				return nil, err
+			}
+			if _, err := tw.Write(content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,169 @@
+package arch
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRepoPackage(t *testing.T, dir, name string) string {
+	t.Helper()
+	info := exampleInfo()
+	info.Name = name
+	target := filepath.Join(dir, Default.ConventionalFileName(info))
+	f, err := os.Create(target)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, Default.Package(info, f))
+	return target
+}
+
+func readRepoDatabase(t *testing.T, name string) map[string]string {
+	t.Helper()
+	content, err := os.ReadFile(name)
+	require.NoError(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	require.NoError(t, err)
+	entries := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		entries[hdr.Name] = string(body)
+	}
+}
+
+func TestIndexRepo(t *testing.T) {
+	dir := t.TempDir()
+	writeRepoPackage(t, dir, "foo")
+	writeRepoPackage(t, dir, "bar")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	writeRepoPackage(t, filepath.Join(dir, "sub"), "ignored")
+
+	created, err := IndexRepo(dir, RepoOptions{Name: "test"})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "test.db.tar.gz"),
+		filepath.Join(dir, "test.db"),
+		filepath.Join(dir, "test.files.tar.gz"),
+		filepath.Join(dir, "test.files"),
+	}, created)
+
+	link, err := os.Readlink(filepath.Join(dir, "test.db"))
+	require.NoError(t, err)
+	require.Equal(t, "test.db.tar.gz", link)
+
+	db := readRepoDatabase(t, filepath.Join(dir, "test.db.tar.gz"))
+	require.Len(t, db, 4)
+	desc := db["foo-1.0.0-1/desc"]
+	require.Contains(t, desc, "%FILENAME%\nfoo-1.0.0beta_1-1-x86_64.pkg.tar.zst\n\n")
+	require.Contains(t, desc, "%NAME%\nfoo\n\n")
+	require.Contains(t, desc, "%ARCH%\nx86_64\n\n")
+	require.Contains(t, desc, "%DEPENDS%\nbash\n")
+	require.NotContains(t, db, "foo-1.0.0-1/files")
+
+	files := readRepoDatabase(t, filepath.Join(dir, "test.files.tar.gz"))
+	require.Len(t, files, 6)
+	require.Contains(t, files["foo-1.0.0-1/files"], "%FILES%\n")
+	require.Contains(t, files["foo-1.0.0-1/files"], "\nusr/bin/fake\n")
+	require.NotContains(t, files["foo-1.0.0-1/files"], ".PKGINFO")
+
+	// indexing again replaces the links.
+	_, err = IndexRepo(dir, RepoOptions{Name: "test"})
+	require.NoError(t, err)
+}
+
+func TestIndexRepoDuplicate(t *testing.T) {
+	dir := t.TempDir()
+	writeRepoPackage(t, dir, "foo")
+	info := exampleInfo()
+	info.Name = "foo"
+	info.Arch = "arm64"
+	f, err := os.Create(filepath.Join(dir, Default.ConventionalFileName(info)))
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, Default.Package(info, f))
+
+	_, err = IndexRepo(dir, RepoOptions{})
+	require.EqualError(t, err, "foo-1.0.0beta_1-1-aarch64.pkg.tar.zst and foo-1.0.0beta_1-1-x86_64.pkg.tar.zst are both foo-1.0.0-1, a pacman repository holds a single version of each package")
+}
+
+func TestIndexRepoNewest(t *testing.T) {
+	dir := t.TempDir()
+	writeRepoPackage(t, dir, "foo")
+	for _, version := range []string{"1.1.0", "1.0.1"} {
+		info := exampleInfo()
+		info.Name = "foo"
+		info.Version = version
+		f, err := os.Create(filepath.Join(dir, Default.ConventionalFileName(info)))
+		require.NoError(t, err)
+		require.NoError(t, Default.Package(info, f))
+		require.NoError(t, f.Close())
+	}
+
+	_, err := IndexRepo(dir, RepoOptions{})
+	require.NoError(t, err)
+	db := readRepoDatabase(t, filepath.Join(dir, "repo.db.tar.gz"))
+	require.Contains(t, db, "foo-1.1.0-1/desc")
+	require.Len(t, db, 2)
+}
+
+func TestVercmp(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.5.0", "1.5.0", 0},
+		{"1.5.1", "1.5.0", 1},
+		{"1.5.1", "1.5", 1},
+		{"1.5.0-1", "1.5.0-2", -1},
+		{"1.5.0-2", "1.5.1-1", -1},
+		{"1.5", "1.5-1", 0},
+		{"1.0", "1.0.0", -1},
+		{"1.0.0", "1.0.a", 1},
+		{"1.0a", "1.0alpha", -1},
+		{"1.0alpha", "1.0b", -1},
+		{"1.0rc", "1.0", -1},
+		{"1.0010", "1.9", 1},
+		{"1_0_0", "1.0.0", 0},
+		{"1.1.1", "1.1..1", -1},
+		{"0:1.0", "1.0", 0},
+		{"1:1.0", "2.0", 1},
+		{"1:1.0", "1:1.1", -1},
+	} {
+		require.Equal(t, tc.want, vercmp(tc.a, tc.b), "%s %s", tc.a, tc.b)
+		require.Equal(t, -tc.want, vercmp(tc.b, tc.a), "%s %s", tc.b, tc.a)
+	}
+}
+
+func TestIndexRepoSigned(t *testing.T) {
+	dir := t.TempDir()
+	writeRepoPackage(t, dir, "foo")
+
+	_, err := IndexRepo(dir, RepoOptions{
+		KeyFile:       "../internal/sign/testdata/privkey.asc",
+		KeyPassphrase: "hunter2",
+	})
+	require.NoError(t, err)
+
+	for _, db := range []string{"repo.db", "repo.files"} {
+		content, err := os.ReadFile(filepath.Join(dir, db))
+		require.NoError(t, err)
+		sig, err := os.ReadFile(filepath.Join(dir, db+".sig"))
+		require.NoError(t, err)
+		require.NoError(t, sign.PGPVerify(bytes.NewReader(content), sig, "../internal/sign/testdata/pubkey.asc"))
+	}
+}
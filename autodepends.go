@@ -0,0 +1,138 @@
+package nfpm
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2/internal/shlibs"
+)
+
+// ErrMissingLibraryPackages happens when the package providing a shared library
+// needed by the package is unknown.
+type ErrMissingLibraryPackages struct {
+	Sonames []string
+}
+
+func (e ErrMissingLibraryPackages) Error() string {
+	return fmt.Sprintf(
+		"unknown packages for shared libraries %s, add them to auto_depends.packages",
+		strings.Join(e.Sonames, ", "),
+	)
+}
+
+// PrepareAutoDepends adds the shared libraries needed by the ELF files of the
+// package to its dependencies, and the shared libraries it ships to what it
+// provides, if enabled, in the syntax of the given packager:
+//
+//   - rpm: libfoo.so.1()(64bit) and libfoo.so.1(FOO_1.0)(64bit)
+//   - apk: so:libfoo.so.1, provided as so:libfoo.so.1=1.2.3
+//   - archlinux: provided as libfoo.so=1-64
+//
+// deb, ipk, archlinux and freebsd depend on the packages given by soname in
+// AutoDepends.Packages instead.
+//
+// It must be called after PrepareForPackager, as it reads the prepared
+// contents, and before PrepareRelations.
+func PrepareAutoDepends(info *Info, packager string) error {
+	if !info.AutoDepends.Enabled {
+		return nil
+	}
+	libs, err := shlibs.Find(info.Contents)
+	if err != nil {
+		return err
+	}
+
+	var depends, provides []string
+	switch packager {
+	case "rpm":
+		for _, lib := range libs.Needed {
+			depends = append(depends, rpmLibrary(lib)...)
+		}
+		for _, lib := range libs.Provided {
+			provides = append(provides, rpmLibrary(lib)...)
+		}
+	case "apk":
+		for _, lib := range libs.Needed {
+			depends = append(depends, "so:"+lib.Soname)
+		}
+		for _, lib := range libs.Provided {
+			// the version of the library file, e.g. 1.2.3 for libfoo.so.1.2.3.
+			version := "0"
+			if _, v, ok := strings.Cut(path.Base(lib.Destination), ".so."); ok {
+				version = v
+			}
+			provides = append(provides, "so:"+lib.Soname+"="+version)
+		}
+	case "deb", "ipk", "archlinux", "freebsd":
+		depends, err = libraryPackages(libs.Needed, info.AutoDepends.Packages)
+		if err != nil {
+			return err
+		}
+		if packager != "archlinux" {
+			break
+		}
+		for _, lib := range libs.Provided {
+			name, version, ok := strings.Cut(lib.Soname, ".so.")
+			if !ok {
+				continue
+			}
+			bits := "32"
+			if lib.Class64 {
+				bits = "64"
+			}
+			provides = append(provides, name+".so="+version+"-"+bits)
+		}
+	default:
+		return fmt.Errorf("auto_depends is not supported by %s", packager)
+	}
+
+	for _, depend := range depends {
+		if !slices.Contains(info.Depends, depend) {
+			info.Depends = append(info.Depends, depend)
+		}
+	}
+	for _, provide := range provides {
+		if !slices.Contains(info.Provides, provide) {
+			info.Provides = append(info.Provides, provide)
+		}
+	}
+	return nil
+}
+
+// rpmLibrary returns the capabilities of a shared library as generated by
+// rpm's elfdeps.
+func rpmLibrary(lib shlibs.Library) []string {
+	marker := ""
+	if lib.Class64 {
+		marker = "(64bit)"
+	}
+	capabilities := []string{lib.Soname + "()" + marker}
+	for _, version := range lib.Versions {
+		capabilities = append(capabilities, lib.Soname+"("+version+")"+marker)
+	}
+	return capabilities
+}
+
+// libraryPackages returns the packages providing the given shared libraries.
+// Libraries mapped to an empty string are skipped.
+func libraryPackages(libs []shlibs.Library, packages map[string]string) ([]string, error) {
+	var depends, missing []string
+	for _, lib := range libs {
+		pkg, ok := packages[lib.Soname]
+		if !ok {
+			missing = append(missing, lib.Soname)
+			continue
+		}
+		if pkg != "" && !slices.Contains(depends, pkg) {
+			depends = append(depends, pkg)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, ErrMissingLibraryPackages{slices.Compact(missing)}
+	}
+	return depends, nil
+}
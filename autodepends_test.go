@@ -0,0 +1,95 @@
+package nfpm_test
+
+import (
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+func autoDependsInfo() *nfpm.Info {
+	return &nfpm.Info{
+		Overridables: nfpm.Overridables{
+			Depends: []string{"bash"},
+			AutoDepends: nfpm.AutoDepends{
+				Enabled: true,
+				Packages: map[string]string{
+					"libc.so.6": "libc6 (>= 2.34)",
+					"libm.so.6": "",
+				},
+			},
+			Contents: files.Contents{
+				{
+					Source:      "./internal/shlibs/testdata/bar",
+					Destination: "/usr/bin/bar",
+					Type:        files.TypeFile,
+				},
+				{
+					Source:      "./internal/shlibs/testdata/libfoo.so.1.2.3",
+					Destination: "/usr/lib/libfoo.so.1.2.3",
+					Type:        files.TypeFile,
+				},
+			},
+		},
+	}
+}
+
+func TestPrepareAutoDepends(t *testing.T) {
+	for packager, expected := range map[string]struct {
+		depends  nfpm.Relations
+		provides nfpm.Relations
+	}{
+		"rpm": {
+			depends: nfpm.Relations{
+				"bash",
+				"libc.so.6()(64bit)",
+				"libc.so.6(GLIBC_2.2.5)(64bit)",
+				"libc.so.6(GLIBC_2.34)(64bit)",
+				"libm.so.6()(64bit)",
+				"libm.so.6(GLIBC_2.2.5)(64bit)",
+			},
+			provides: nfpm.Relations{"libfoo.so.1()(64bit)", "libfoo.so.1(FOO_1.0)(64bit)"},
+		},
+		"apk": {
+			depends:  nfpm.Relations{"bash", "so:libc.so.6", "so:libm.so.6"},
+			provides: nfpm.Relations{"so:libfoo.so.1=1.2.3"},
+		},
+		"deb": {
+			depends: nfpm.Relations{"bash", "libc6 (>= 2.34)"},
+		},
+		"archlinux": {
+			depends:  nfpm.Relations{"bash", "libc6 (>= 2.34)"},
+			provides: nfpm.Relations{"libfoo.so=1-64"},
+		},
+	} {
+		t.Run(packager, func(t *testing.T) {
+			info := autoDependsInfo()
+			require.NoError(t, nfpm.PrepareAutoDepends(info, packager))
+			require.Equal(t, expected.depends, info.Depends)
+			require.Equal(t, expected.provides, info.Provides)
+		})
+	}
+}
+
+func TestPrepareAutoDependsDisabled(t *testing.T) {
+	info := autoDependsInfo()
+	info.AutoDepends.Enabled = false
+	require.NoError(t, nfpm.PrepareAutoDepends(info, "rpm"))
+	require.Equal(t, nfpm.Relations{"bash"}, info.Depends)
+	require.Empty(t, info.Provides)
+}
+
+func TestPrepareAutoDependsMissingPackages(t *testing.T) {
+	info := autoDependsInfo()
+	info.AutoDepends.Packages = nil
+	err := nfpm.PrepareAutoDepends(info, "deb")
+	var missing nfpm.ErrMissingLibraryPackages
+	require.ErrorAs(t, err, &missing)
+	require.Equal(t, []string{"libc.so.6", "libm.so.6"}, missing.Sonames)
+	require.EqualError(t, err, "unknown packages for shared libraries libc.so.6, libm.so.6, add them to auto_depends.packages")
+}
+
+func TestPrepareAutoDependsUnsupported(t *testing.T) {
+	require.Error(t, nfpm.PrepareAutoDepends(autoDependsInfo(), "msix"))
+}
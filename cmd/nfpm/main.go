@@ -33,7 +33,7 @@ func main() {
 
 func buildVersion(version, commit, date, builtBy, treeState string) goversion.Info {
 	return goversion.GetVersionInfo(
-		goversion.WithAppDetails("nfpm", "a simple and 0-dependencies apk, arch linux, deb, ipk, msix, and rpm packager written in Go", website),
+		goversion.WithAppDetails("nfpm", "a simple and 0-dependencies apk, appimage, arch linux, conda, deb, freebsd, gpkg, ipk, macos pkg, msix, nupkg, oci, rpm, and snap packager written in Go", website),
 		goversion.WithASCIIName(asciiArt),
 		func(i *goversion.Info) {
 			if commit != "" {
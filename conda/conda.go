@@ -0,0 +1,393 @@
+// Package conda implements nfpm.Packager providing conda bindings.
+//
+// A conda package holds the contents, relative to the prefix of the
+// environment it is installed into, and the info directory describing them.
+// It is written as a .conda file, a zip file holding the contents and the
+// info directory as two zstd compressed tarballs.
+package conda
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/klauspost/compress/zstd"
+)
+
+const packagerName = "conda"
+
+// nolint: gochecknoinits
+func init() {
+	nfpm.RegisterPackager(packagerName, Default)
+}
+
+// nolint: gochecknoglobals
+var versionPattern = regexp.MustCompile(`^[0-9A-Za-z_.+!]+$`)
+
+// nolint: gochecknoglobals
+var platformToConda = map[string]string{
+	"linux":   "linux",
+	"darwin":  "osx",
+	"windows": "win",
+}
+
+// nolint: gochecknoglobals
+var archToConda = map[string]string{
+	"amd64":   "64",
+	"x86_64":  "64",
+	"386":     "32",
+	"i386":    "32",
+	"arm64":   "aarch64",
+	"aarch64": "aarch64",
+	"arm6":    "armv6l",
+	"arm7":    "armv7l",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"all":     "noarch",
+	"noarch":  "noarch",
+}
+
+// subdir returns the subdirectory of the channel the package belongs to,
+// e.g. linux-64, and the platform and the architecture conda gives it.
+func subdir(info *nfpm.Info) (subdir, platform, arch string) {
+	subdir = info.Conda.Subdir
+	if subdir == "" {
+		platform := cmp.Or(platformToConda[info.Platform], info.Platform)
+		arch := cmp.Or(archToConda[info.Arch], info.Arch)
+		switch {
+		case arch == "noarch":
+			subdir = arch
+		case arch == "aarch64" && platform != "linux":
+			subdir = platform + "-arm64"
+		default:
+			subdir = platform + "-" + arch
+		}
+	}
+	if subdir == "noarch" {
+		return subdir, "", ""
+	}
+	platform, arch, _ = strings.Cut(subdir, "-")
+	switch arch {
+	case "64":
+		arch = "x86_64"
+	case "32":
+		arch = "x86"
+	}
+	return subdir, platform, arch
+}
+
+// Default conda packager.
+// nolint: gochecknoglobals
+var Default = &Conda{}
+
+// Conda is a conda packager implementation.
+type Conda struct{}
+
+// ConventionalFileName returns a file name according to the conventions for
+// conda packages: name-version-build.conda.
+func (*Conda) ConventionalFileName(info *nfpm.Info) string {
+	// an invalid build number is reported when packaging.
+	build, _ := buildString(info)
+	return fmt.Sprintf("%s-%s-%s.conda", info.Name, packageVersion(info), build)
+}
+
+// ConventionalExtension returns the file name conventionally used for conda
+// packages.
+func (*Conda) ConventionalExtension() string {
+	return ".conda"
+}
+
+// packageVersion returns the version of the package, with its prerelease:
+// conda versions cannot have dashes, which it would otherwise ignore.
+func packageVersion(info *nfpm.Info) string {
+	return strings.ReplaceAll(info.Version+info.Prerelease, "-", "_")
+}
+
+// buildNumber returns the build number of the package, its release.
+func buildNumber(info *nfpm.Info) (int, error) {
+	if info.Release == "" {
+		return 0, nil
+	}
+	number, err := strconv.Atoi(info.Release)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid conda build number: %s", info.Release)
+	}
+	return number, nil
+}
+
+// buildString returns the build string of the package, its build number by
+// default.
+func buildString(info *nfpm.Info) (string, error) {
+	number, err := buildNumber(info)
+	if info.Conda.Build != "" {
+		return info.Conda.Build, nil
+	}
+	return strconv.Itoa(number), err
+}
+
+// Package writes a new conda package to the given writer using the given
+// info.
+func (*Conda) Package(info *nfpm.Info, w io.Writer) error {
+	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
+		return err
+	}
+	if !versionPattern.MatchString(packageVersion(info)) {
+		return fmt.Errorf("invalid conda version: %s", packageVersion(info))
+	}
+	if err := nfpm.PrepareRelations(info, packagerName, func(arch string) bool {
+		return arch == info.Arch || archToConda[arch] == archToConda[info.Arch]
+	}); err != nil {
+		return err
+	}
+
+	mtime := modtime.Get(info.MTime)
+	pkg, paths, err := payload(info, mtime)
+	if err != nil {
+		return err
+	}
+	infoFiles, err := infoDir(info, paths, mtime)
+	if err != nil {
+		return err
+	}
+
+	build, _ := buildString(info)
+	name := fmt.Sprintf("%s-%s-%s", info.Name, packageVersion(info), build)
+	zw := zip.NewWriter(w)
+	header := &zip.FileHeader{Name: "metadata.json", Method: zip.Deflate, Modified: mtime}
+	f, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte(`{"conda_pkg_format_version": 2}`)); err != nil {
+		return err
+	}
+	for _, entry := range []struct {
+		name  string
+		files []tarFile
+	}{
+		{"pkg-" + name + ".tar.zst", pkg},
+		{"info-" + name + ".tar.zst", infoFiles},
+	} {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name: entry.name,
+			// the tarballs are already compressed.
+			Method:   zip.Store,
+			Modified: mtime,
+		})
+		if err != nil {
+			return err
+		}
+		zstdw, err := zstd.NewWriter(f)
+		if err != nil {
+			return err
+		}
+		if err := writeTar(zstdw, entry.files); err != nil {
+			return err
+		}
+		if err := zstdw.Close(); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// tarFile is a file, a directory or a symlink of the package.
+type tarFile struct {
+	header *tar.Header
+	data   []byte
+}
+
+func writeTar(w io.Writer, entries []tarFile) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		if err := tw.WriteHeader(entry.header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(entry.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// pathEntry describes a file of the package in info/paths.json.
+type pathEntry struct {
+	Path        string `json:"_path"`
+	PathType    string `json:"path_type"`
+	SHA256      string `json:"sha256,omitempty"`
+	SizeInBytes *int   `json:"size_in_bytes,omitempty"`
+}
+
+// payload returns the contents of the package, relative to the prefix, and
+// their entries in info/paths.json.
+func payload(info *nfpm.Info, mtime time.Time) ([]tarFile, []pathEntry, error) {
+	contents := slices.Clone(info.Contents)
+	slices.SortFunc(contents, func(a, b *files.Content) int {
+		return strings.Compare(a.Destination, b.Destination)
+	})
+
+	var entries []tarFile
+	paths := []pathEntry{}
+	for _, content := range contents {
+		name := strings.TrimPrefix(files.NormalizeAbsoluteFilePath(content.Destination), "/")
+		// the implicit directories are created along with the files.
+		if name == "" || content.Type == files.TypeImplicitDir {
+			continue
+		}
+		if name == "info" || strings.HasPrefix(name, "info/") {
+			return nil, nil, fmt.Errorf("%s: the info directory is reserved to the package metadata", content.Destination)
+		}
+		header := &tar.Header{
+			Name:    name,
+			Mode:    int64(content.Mode() & 0o7777),
+			ModTime: content.ModTime(),
+		}
+		if header.ModTime.IsZero() {
+			header.ModTime = mtime
+		}
+		var data []byte
+		switch content.Type {
+		case files.TypeDir:
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+			paths = append(paths, pathEntry{Path: name, PathType: "directory"})
+		case files.TypeSymlink:
+			header.Typeflag, header.Mode = tar.TypeSymlink, 0o777
+			header.Linkname = content.Source
+			paths = append(paths, pathEntry{Path: name, PathType: "softlink"})
+		case files.TypeFile, files.TypeConfig, files.TypeConfigNoReplace, files.TypeConfigMissingOK:
+			var err error
+			if data, err = os.ReadFile(content.Source); err != nil {
+				return nil, nil, err
+			}
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(data))
+			sum := sha256.Sum256(data)
+			size := len(data)
+			paths = append(paths, pathEntry{Path: name, PathType: "hardlink", SHA256: hex.EncodeToString(sum[:]), SizeInBytes: &size})
+		default:
+			// ignore everything else
+			continue
+		}
+		entries = append(entries, tarFile{header, data})
+	}
+	return entries, paths, nil
+}
+
+type condaIndex struct {
+	Arch        string   `json:"arch,omitempty"`
+	Build       string   `json:"build"`
+	BuildNumber int      `json:"build_number"`
+	Depends     []string `json:"depends"`
+	License     string   `json:"license,omitempty"`
+	Name        string   `json:"name"`
+	Noarch      string   `json:"noarch,omitempty"`
+	Platform    string   `json:"platform,omitempty"`
+	Subdir      string   `json:"subdir"`
+	Timestamp   int64    `json:"timestamp"`
+	Version     string   `json:"version"`
+}
+
+type condaAbout struct {
+	Description string `json:"description,omitempty"`
+	Home        string `json:"home,omitempty"`
+	License     string `json:"license,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+}
+
+type condaPaths struct {
+	Paths        []pathEntry `json:"paths"`
+	PathsVersion int         `json:"paths_version"`
+}
+
+// infoDir returns the files of the info directory: index.json, the metadata
+// of the package, about.json, its description, paths.json and files, the
+// list of its files.
+func infoDir(info *nfpm.Info, paths []pathEntry, mtime time.Time) ([]tarFile, error) {
+	number, err := buildNumber(info)
+	if err != nil {
+		return nil, err
+	}
+	build, _ := buildString(info)
+	dir, platform, arch := subdir(info)
+	index := condaIndex{
+		Arch:        arch,
+		Build:       build,
+		BuildNumber: number,
+		Depends:     append(append([]string{}, info.Depends...), info.Conda.Depends...),
+		License:     info.License,
+		Name:        info.Name,
+		Platform:    platform,
+		Subdir:      dir,
+		// in milliseconds.
+		Timestamp: mtime.UnixMilli(),
+		Version:   packageVersion(info),
+	}
+	if dir == "noarch" {
+		index.Noarch = "generic"
+	}
+	description := strings.TrimSpace(info.Description)
+	summary, _, _ := strings.Cut(description, "\n")
+	about := condaAbout{
+		Description: description,
+		Home:        info.Homepage,
+		License:     info.License,
+		Summary:     strings.TrimSpace(summary),
+	}
+
+	var list bytes.Buffer
+	for _, path := range paths {
+		if path.PathType != "directory" {
+			list.WriteString(path.Path + "\n")
+		}
+	}
+	var entries []tarFile
+	for _, file := range []struct {
+		name string
+		v    any
+	}{
+		{"info/about.json", about},
+		{"info/files", nil},
+		{"info/index.json", index},
+		{"info/paths.json", condaPaths{Paths: paths, PathsVersion: 1}},
+	} {
+		data := list.Bytes()
+		if file.v != nil {
+			// the match specs have comparison operators, left unescaped.
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(file.v); err != nil {
+				return nil, err
+			}
+			data = buf.Bytes()
+		}
+		entries = append(entries, tarFile{
+			header: &tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     file.name,
+				Mode:     0o644,
+				Size:     int64(len(data)),
+				ModTime:  mtime,
+			},
+			data: data,
+		})
+	}
+	return entries, nil
+}
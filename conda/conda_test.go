@@ -0,0 +1,234 @@
+package conda
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+var mtime = time.Date(2023, 11, 5, 23, 15, 17, 0, time.UTC)
+
+func exampleInfo() *nfpm.Info {
+	return nfpm.WithDefaults(&nfpm.Info{
+		Name:        "foo",
+		Arch:        "amd64",
+		Description: "Foo does things\nand more things",
+		Maintainer:  "Carlos A Becker <pkg@carlosbecker.com>",
+		Version:     "v1.0.0",
+		Prerelease:  "rc-1",
+		Release:     "2",
+		Homepage:    "http://carlosbecker.com",
+		License:     "MIT",
+		MTime:       mtime,
+		Overridables: nfpm.Overridables{
+			Depends: []string{
+				"bash (>= 5.0)",
+				"zsh [arm64]",
+			},
+			Contents: []*files.Content{
+				{
+					Source:      "../testdata/fake",
+					Destination: "/bin/fake",
+				},
+				{
+					Source:      "../testdata/whatever.conf",
+					Destination: "/etc/fake.conf",
+					Type:        files.TypeConfig,
+				},
+				{
+					Source:      "fake",
+					Destination: "/bin/fake-link",
+					Type:        files.TypeSymlink,
+				},
+				{
+					Destination: "/var/foo",
+					Type:        files.TypeDir,
+				},
+			},
+			Conda: nfpm.Conda{
+				Depends: []string{"python >=3.8,<4"},
+			},
+		},
+	})
+}
+
+// readTar returns the entries of the tarball and the contents of its files.
+func readTar(t *testing.T, r io.Reader) ([]*tar.Header, map[string][]byte) {
+	t.Helper()
+	var headers []*tar.Header
+	contents := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, mtime.Unix(), hdr.ModTime.Unix(), hdr.Name)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		headers = append(headers, hdr)
+		contents[hdr.Name] = data
+	}
+	return headers, contents
+}
+
+// names returns the names of the entries.
+func names(headers []*tar.Header) []string {
+	var result []string
+	for _, hdr := range headers {
+		result = append(result, hdr.Name)
+	}
+	return result
+}
+
+func TestConventionalFileName(t *testing.T) {
+	info := exampleInfo()
+	require.Equal(t, "foo-1.0.0rc_1-2.conda", Default.ConventionalFileName(info))
+	info.Conda.Build = "h1234567_2"
+	require.Equal(t, "foo-1.0.0rc_1-h1234567_2.conda", Default.ConventionalFileName(info))
+	require.Equal(t, ".conda", Default.ConventionalExtension())
+}
+
+func TestSubdir(t *testing.T) {
+	for _, tc := range []struct {
+		platform, arch, subdir string
+		wantSubdir             string
+		wantPlatform, wantArch string
+	}{
+		{"linux", "amd64", "", "linux-64", "linux", "x86_64"},
+		{"linux", "386", "", "linux-32", "linux", "x86"},
+		{"linux", "arm64", "", "linux-aarch64", "linux", "aarch64"},
+		{"linux", "ppc64le", "", "linux-ppc64le", "linux", "ppc64le"},
+		{"darwin", "arm64", "", "osx-arm64", "osx", "arm64"},
+		{"windows", "amd64", "", "win-64", "win", "x86_64"},
+		{"linux", "all", "", "noarch", "", ""},
+		{"linux", "amd64", "noarch", "noarch", "", ""},
+		{"linux", "amd64", "linux-armv7l", "linux-armv7l", "linux", "armv7l"},
+	} {
+		t.Run(tc.platform+"-"+tc.arch+"-"+tc.subdir, func(t *testing.T) {
+			info := exampleInfo()
+			info.Platform, info.Arch, info.Conda.Subdir = tc.platform, tc.arch, tc.subdir
+			subdir, platform, arch := subdir(info)
+			require.Equal(t, tc.wantSubdir, subdir)
+			require.Equal(t, tc.wantPlatform, platform)
+			require.Equal(t, tc.wantArch, arch)
+		})
+	}
+}
+
+func TestPackage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(exampleInfo(), &buf))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 3)
+	tarballs := map[string][]byte{}
+	for _, f := range zr.File {
+		require.Equal(t, mtime.Unix(), f.Modified.Unix(), f.Name)
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		tarballs[f.Name] = data
+	}
+	require.Equal(t, `{"conda_pkg_format_version": 2}`, string(tarballs["metadata.json"]))
+	require.Equal(t, "metadata.json", zr.File[0].Name)
+
+	zstdr, err := zstd.NewReader(bytes.NewReader(tarballs["pkg-foo-1.0.0rc_1-2.tar.zst"]))
+	require.NoError(t, err)
+	defer zstdr.Close()
+	headers, contents := readTar(t, zstdr)
+	require.Equal(t, []string{"bin/fake", "bin/fake-link", "etc/fake.conf", "var/foo/"}, names(headers))
+	require.Equal(t, "fake", headers[1].Linkname)
+	require.Equal(t, byte(tar.TypeDir), headers[3].Typeflag)
+	fake, err := os.ReadFile("../testdata/fake")
+	require.NoError(t, err)
+	require.Equal(t, fake, contents["bin/fake"])
+
+	require.NoError(t, zstdr.Reset(bytes.NewReader(tarballs["info-foo-1.0.0rc_1-2.tar.zst"])))
+	headers, contents = readTar(t, zstdr)
+	require.Equal(t, []string{"info/about.json", "info/files", "info/index.json", "info/paths.json"}, names(headers))
+	require.Equal(t, "bin/fake\nbin/fake-link\netc/fake.conf\n", string(contents["info/files"]))
+
+	var index condaIndex
+	require.NoError(t, json.Unmarshal(contents["info/index.json"], &index))
+	require.Equal(t, condaIndex{
+		Arch:        "x86_64",
+		Build:       "2",
+		BuildNumber: 2,
+		Depends:     []string{"bash >=5.0", "python >=3.8,<4"},
+		License:     "MIT",
+		Name:        "foo",
+		Platform:    "linux",
+		Subdir:      "linux-64",
+		Timestamp:   mtime.UnixMilli(),
+		Version:     "1.0.0rc_1",
+	}, index)
+	require.Contains(t, string(contents["info/index.json"]), `"python >=3.8,<4"`)
+
+	var about condaAbout
+	require.NoError(t, json.Unmarshal(contents["info/about.json"], &about))
+	require.Equal(t, condaAbout{
+		Description: "Foo does things\nand more things",
+		Home:        "http://carlosbecker.com",
+		License:     "MIT",
+		Summary:     "Foo does things",
+	}, about)
+
+	var paths condaPaths
+	require.NoError(t, json.Unmarshal(contents["info/paths.json"], &paths))
+	require.Equal(t, 1, paths.PathsVersion)
+	require.Len(t, paths.Paths, 4)
+	size := len(fake)
+	require.Equal(t, pathEntry{
+		Path:        "bin/fake",
+		PathType:    "hardlink",
+		SHA256:      "056302317aae93b3c0cfcf9b2d8300c6f77fca580d1848d229799cc4edd47901",
+		SizeInBytes: &size,
+	}, paths.Paths[0])
+	require.Equal(t, pathEntry{Path: "bin/fake-link", PathType: "softlink"}, paths.Paths[1])
+	require.Equal(t, pathEntry{Path: "var/foo", PathType: "directory"}, paths.Paths[3])
+}
+
+func TestPackageInvalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		update func(info *nfpm.Info)
+		err    string
+	}{
+		"version": {
+			update: func(info *nfpm.Info) { info.Prerelease = "rc 1" },
+			err:    "invalid conda version: 1.0.0rc 1",
+		},
+		"build number": {
+			update: func(info *nfpm.Info) { info.Release = "two" },
+			err:    "invalid conda build number: two",
+		},
+		"info": {
+			update: func(info *nfpm.Info) {
+				info.Contents = append(info.Contents, &files.Content{Source: "../testdata/fake", Destination: "/info/index.json"})
+			},
+			err: "/info/index.json: the info directory is reserved to the package metadata",
+		},
+		"alternatives": {
+			update: func(info *nfpm.Info) { info.Depends = []string{"bar | baz"} },
+			err:    "conda does not support alternative relations: bar | baz",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			info := exampleInfo()
+			tc.update(info)
+			require.EqualError(t, Default.Package(info, io.Discard), tc.err)
+		})
+	}
+}
@@ -33,11 +33,16 @@ import (
 	"github.com/ulikunitz/xz"
 )
 
+// packagerName is the packager name used to prepare contents and scripts.
+// Both .deb and .dsc use "deb", as a source package builds the very same
+// binary packages.
 const packagerName = "deb"
 
 // nolint: gochecknoinits
 func init() {
 	nfpm.RegisterPackager(packagerName, Default)
+	nfpm.RegisterPackager(formatDSC.String(), DefaultDSC)
+	nfpm.RegisterReader(packagerName, Default)
 }
 
 // https://wiki.debian.org/ArchitectureSpecificsMemo
@@ -79,15 +84,37 @@ func ensureValidArch(info *nfpm.Info) *nfpm.Info {
 // nolint: gochecknoglobals
 var Default = &Deb{}
 
+// DefaultDSC Debian source package packager.
+// nolint: gochecknoglobals
+var DefaultDSC = &Deb{formatDSC}
+
+type format uint
+
+const (
+	formatDeb format = iota
+	formatDSC
+)
+
+// String implements fmt.Stringer.
+func (f format) String() string { return [2]string{"deb", "dsc"}[f] }
+
 // Deb is a deb packager implementation.
-type Deb struct{}
+type Deb struct {
+	format format
+}
 
 // ConventionalFileName returns a file name according
 // to the conventions for debian packages. See:
 // https://manpages.debian.org/buster/dpkg-dev/dpkg-name.1.en.html
-func (*Deb) ConventionalFileName(info *nfpm.Info) string {
+func (d *Deb) ConventionalFileName(info *nfpm.Info) string {
 	info = ensureValidArch(info)
 
+	// source packages are named after their version only, as they build the
+	// packages of every architecture.
+	if d.format == formatDSC {
+		return fmt.Sprintf("%s_%s%s", info.Name, dscFileVersion(info), d.ConventionalExtension())
+	}
+
 	version := info.Version
 	if info.Prerelease != "" {
 		version += "~" + info.Prerelease
@@ -106,7 +133,10 @@ func (*Deb) ConventionalFileName(info *nfpm.Info) string {
 }
 
 // ConventionalExtension returns the file name conventionally used for Deb packages
-func (*Deb) ConventionalExtension() string {
+func (d *Deb) ConventionalExtension() string {
+	if d.format == formatDSC {
+		return ".dsc"
+	}
 	return ".deb"
 }
 
@@ -116,6 +146,11 @@ var ErrInvalidSignatureType = errors.New("invalid signature type")
 
 // Package writes a new deb package to the given writer using the given info.
 func (d *Deb) Package(info *nfpm.Info, deb io.Writer) (err error) { // nolint: funlen
+	defer info.Cleanup()
+	if d.format == formatDSC {
+		return d.packageDSC(info, deb)
+	}
+
 	info = ensureValidArch(info)
 
 	err = nfpm.PrepareForPackager(withChangelogIfRequested(info), packagerName)
@@ -123,6 +158,15 @@ func (d *Deb) Package(info *nfpm.Info, deb io.Writer) (err error) { // nolint: f
 		return err
 	}
 
+	if err := nfpm.PrepareAutoDepends(info, packagerName); err != nil {
+		return err
+	}
+	if err := nfpm.PrepareRelations(info, packagerName, func(arch string) bool {
+		return arch == info.Arch || archToDebian[arch] == info.Arch
+	}); err != nil {
+		return err
+	}
+
 	// Set up some deb specific defaults
 	d.SetPackagerDefaults(info)
 
@@ -637,28 +681,29 @@ func createControl(instSize int64, md5sums []byte, info *nfpm.Info) (controlTarG
 		}
 	}
 
+	scripts, err := nfpm.MaintainerScripts(info, packagerName)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range maps.Keys(scripts) {
+		if err := newItemInsideTar(out, scripts[name], &tar.Header{
+			Name:     files.AsExplicitRelativePath(name),
+			Size:     int64(len(scripts[name])),
+			Mode:     0o755,
+			ModTime:  mtime,
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatGNU,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
 	type fileAndMode struct {
 		fileName string
 		mode     int64
 	}
 
 	specialFiles := map[string]*fileAndMode{
-		"preinst": {
-			fileName: info.Scripts.PreInstall,
-			mode:     0o755,
-		},
-		"postinst": {
-			fileName: info.Scripts.PostInstall,
-			mode:     0o755,
-		},
-		"prerm": {
-			fileName: info.Scripts.PreRemove,
-			mode:     0o755,
-		},
-		"postrm": {
-			fileName: info.Scripts.PostRemove,
-			mode:     0o755,
-		},
 		"rules": {
 			fileName: info.Deb.Scripts.Rules,
 			mode:     0o755,
@@ -830,7 +875,12 @@ type controlData struct {
 
 func writeControl(w io.Writer, data controlData) error {
 	tmpl := template.New("control")
-	tmpl.Funcs(template.FuncMap{
+	tmpl.Funcs(controlFuncs())
+	return template.Must(tmpl.Parse(controlTemplate)).Execute(w, data)
+}
+
+func controlFuncs() template.FuncMap {
+	return template.FuncMap{
 		"join": func(strs []string) string {
 			return strings.Trim(strings.Join(strs, ", "), " ")
 		},
@@ -861,8 +911,7 @@ func writeControl(w io.Writer, data controlData) error {
 			}
 			return result
 		},
-	})
-	return template.Must(tmpl.Parse(controlTemplate)).Execute(w, data)
+	}
 }
 
 func tarHeader(content *files.Content, preferredModTimes ...time.Time) (*tar.Header, error) {
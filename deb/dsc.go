@@ -0,0 +1,666 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"crypto/md5" // nolint:gas
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/goreleaser/chglog"
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/maps"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+)
+
+const (
+	// debhelperCompat is the debhelper compatibility level of the generated
+	// debian directory.
+	debhelperCompat = 13
+	// standardsVersion is the version of the Debian policy the generated
+	// source packages comply with.
+	standardsVersion = "4.6.2"
+)
+
+// ErrDSCWithoutTarget happens if a source package is built without a target
+// file, next to which its tarballs are written.
+var ErrDSCWithoutTarget = errors.New("dsc: the target file is required to write the source tarballs next to it")
+
+// debhelper steps skipped by the generated rules: the contents are installed
+// as they are, so nothing is built, stripped, compressed or inspected, and the
+// systemd units are handled by the maintainer scripts.
+// nolint: gochecknoglobals
+var dscSkippedSteps = []string{
+	"dh_auto_configure",
+	"dh_auto_build",
+	"dh_auto_test",
+	"dh_auto_install",
+	"dh_auto_clean",
+	"dh_strip",
+	"dh_strip_nondeterminism",
+	"dh_dwz",
+	"dh_compress",
+	"dh_makeshlibs",
+	"dh_shlibdeps",
+	"dh_usrlocal",
+	"dh_installchangelogs",
+	"dh_installinit",
+	"dh_installsystemd",
+}
+
+// upstreamVersion returns the version of the package without its epoch and
+// revision, which names the orig tarball.
+func upstreamVersion(info *nfpm.Info) string {
+	version := info.Version
+	if info.Prerelease != "" {
+		version += "~" + info.Prerelease
+	}
+	if info.VersionMetadata != "" {
+		version += "+" + info.VersionMetadata
+	}
+	return version
+}
+
+// dscFileVersion returns the version of the source package without its
+// epoch, as used in file names. Source packages in the 3.0 (quilt) format
+// always have a revision, which defaults to 1.
+func dscFileVersion(info *nfpm.Info) string {
+	return upstreamVersion(info) + "-" + cmp.Or(info.Release, "1")
+}
+
+// dscVersion returns the full version of the source package.
+func dscVersion(info *nfpm.Info) string {
+	if info.Epoch != "" {
+		return info.Epoch + ":" + dscFileVersion(info)
+	}
+	return dscFileVersion(info)
+}
+
+// packageDSC builds a Debian source package, writing its .dsc to w and the
+// orig and debian tarballs it describes next to the target.
+//
+// nfpm has no source tree, so the orig tarball holds the would-be payload of
+// each binary package, and the synthesized debian directory installs it as it
+// is: the rules skip every build step and only run debhelper's packaging
+// steps. Running dpkg-buildpackage on the result reproduces the deb packages.
+func (*Deb) packageDSC(info *nfpm.Info, w io.Writer) error {
+	if info.Target == "" {
+		return ErrDSCWithoutTarget
+	}
+
+	infos := append([]*nfpm.Info{info}, info.SplitPackages...)
+	for _, pkg := range infos {
+		if err := prepareDSC(pkg); err != nil {
+			if pkg != info {
+				return fmt.Errorf("%s: %w", pkg.Name, err)
+			}
+			return err
+		}
+	}
+
+	mtime := modtime.Get(info.MTime)
+	orig, err := createOrigTarball(infos, info.Name+"-"+upstreamVersion(info), mtime)
+	if err != nil {
+		return err
+	}
+	debian, err := createDebianTarball(infos, mtime)
+	if err != nil {
+		return err
+	}
+
+	tarballs := []dscFile{
+		{Name: fmt.Sprintf("%s_%s.orig.tar.gz", info.Name, upstreamVersion(info)), Content: orig},
+		{Name: fmt.Sprintf("%s_%s.debian.tar.gz", info.Name, dscFileVersion(info)), Content: debian},
+	}
+	for _, tarball := range tarballs {
+		target := filepath.Join(filepath.Dir(info.Target), tarball.Name)
+		if err := os.WriteFile(target, tarball.Content, 0o644); err != nil { // nolint: gosec
+			return fmt.Errorf("cannot write %s: %w", tarball.Name, err)
+		}
+	}
+
+	var body bytes.Buffer
+	if err := writeDSC(&body, dscData{Info: info, Packages: infos, Files: tarballs}); err != nil {
+		return err
+	}
+	dsc := body.Bytes()
+
+	if info.Deb.Signature.KeyFile != "" || info.Deb.Signature.SignFn != nil {
+		if signFn := info.Deb.Signature.SignFn; signFn != nil {
+			dsc, err = signFn(bytes.NewReader(dsc))
+		} else {
+			dsc, err = sign.PGPClearSignWithKeyID(bytes.NewReader(dsc), info.Deb.Signature.KeyFile, info.Deb.Signature.KeyPassphrase, info.Deb.Signature.KeyID)
+		}
+		if err != nil {
+			return &nfpm.ErrSigningFailure{Err: err}
+		}
+	}
+
+	_, err = w.Write(dsc)
+	return err
+}
+
+func prepareDSC(info *nfpm.Info) error {
+	info = ensureValidArch(info)
+	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
+		return err
+	}
+	if err := nfpm.PrepareAutoDepends(info, packagerName); err != nil {
+		return err
+	}
+	if err := nfpm.PrepareRelations(info, packagerName, func(arch string) bool {
+		return arch == info.Arch || archToDebian[arch] == info.Arch
+	}); err != nil {
+		return err
+	}
+	Default.SetPackagerDefaults(info)
+
+	for _, content := range info.Contents {
+		if strings.ContainsAny(content.Destination, " \t\n") {
+			return fmt.Errorf("%s: debhelper does not support white space in installed paths", content.Destination)
+		}
+	}
+	return nil
+}
+
+// dscInstalled reports whether the content is installed from the orig tarball
+// by the install list of its package.
+func dscInstalled(content *files.Content) bool {
+	switch content.Type {
+	case files.TypeRPMGhost, files.TypeImplicitDir, files.TypeDir, files.TypeDebChangelog:
+		return false
+	default:
+		return true
+	}
+}
+
+// createOrigTarball returns the orig tarball, holding the contents of each
+// package in a directory named after it.
+func createOrigTarball(infos []*nfpm.Info, root string, mtime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	compress := gzip.NewWriter(&buf)
+	out := tar.NewWriter(compress)
+	// the writers are properly closed later, this is just in case that we have
+	// an error in another part of the code.
+	defer out.Close()      // nolint: errcheck
+	defer compress.Close() // nolint: errcheck
+
+	for _, info := range infos {
+		for _, content := range info.Contents {
+			if !dscInstalled(content) {
+				continue
+			}
+			if err := addToOrigTarball(out, content, path.Join(root, info.Name), mtime); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("closing orig tarball: %w", err)
+	}
+	if err := compress.Close(); err != nil {
+		return nil, fmt.Errorf("closing orig tarball: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func addToOrigTarball(out *tar.Writer, content *files.Content, dir string, mtime time.Time) error {
+	var preferredModTimes []time.Time
+	if content.Type == files.TypeSymlink {
+		preferredModTimes = append(preferredModTimes, mtime)
+	}
+	header, err := tarHeader(content, preferredModTimes...)
+	if err != nil {
+		return fmt.Errorf("build header for %q: %w", content.Destination, err)
+	}
+	header.Name = path.Join(dir, files.ToNixPath(content.Destination))
+	// the owners are set by the rules, the source tree belongs to whoever
+	// unpacks it.
+	header.Uname, header.Gname = "", ""
+
+	if header.Typeflag != tar.TypeReg {
+		return newItemInsideTar(out, nil, header)
+	}
+	f, err := os.Open(content.Source)
+	if err != nil {
+		return fmt.Errorf("could not add %s to the orig tarball: %w", content.Source, err)
+	}
+	defer f.Close() // nolint: errcheck
+	if err := out.WriteHeader(header); err != nil {
+		return fmt.Errorf("cannot write header of %s to the orig tarball: %w", content.Source, err)
+	}
+	if _, err := io.Copy(out, f); err != nil {
+		return fmt.Errorf("%s: failed to copy: %w", content.Source, err)
+	}
+	return nil
+}
+
+// createDebianTarball returns the tarball holding the debian directory that
+// builds the packages from the orig tarball.
+func createDebianTarball(infos []*nfpm.Info, mtime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	compress := gzip.NewWriter(&buf)
+	out := tar.NewWriter(compress)
+	// the writers are properly closed later, this is just in case that we have
+	// an error in another part of the code.
+	defer out.Close()      // nolint: errcheck
+	defer compress.Close() // nolint: errcheck
+
+	debianFiles, err := debianDirectory(infos)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range []string{"debian/", "debian/source/"} {
+		if err := out.WriteHeader(&tar.Header{
+			Name:     dir,
+			Mode:     0o755,
+			ModTime:  mtime,
+			Typeflag: tar.TypeDir,
+			Format:   tar.FormatGNU,
+		}); err != nil {
+			return nil, fmt.Errorf("cannot write %s to the debian tarball: %w", dir, err)
+		}
+	}
+	for _, name := range maps.Keys(debianFiles) {
+		file := debianFiles[name]
+		if err := newItemInsideTar(out, file.Content, &tar.Header{
+			Name:     "debian/" + name,
+			Size:     int64(len(file.Content)),
+			Mode:     file.Mode,
+			ModTime:  mtime,
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatGNU,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("closing debian tarball: %w", err)
+	}
+	if err := compress.Close(); err != nil {
+		return nil, fmt.Errorf("closing debian tarball: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+type debianFile struct {
+	Content []byte
+	Mode    int64
+}
+
+// debianDirectory returns the files of the debian directory, keyed by their
+// path inside it.
+func debianDirectory(infos []*nfpm.Info) (map[string]debianFile, error) {
+	source := infos[0]
+	result := map[string]debianFile{
+		"source/format": {Content: []byte("3.0 (quilt)\n"), Mode: 0o644},
+		"rules":         {Content: dscRules(infos), Mode: 0o755},
+	}
+
+	var control bytes.Buffer
+	if err := writeDebianControl(&control, dscData{Info: source, Packages: infos}); err != nil {
+		return nil, err
+	}
+	result["control"] = debianFile{Content: control.Bytes(), Mode: 0o644}
+
+	changelog, err := dscChangelog(source)
+	if err != nil {
+		return nil, err
+	}
+	result["changelog"] = debianFile{Content: changelog, Mode: 0o644}
+
+	for _, info := range infos {
+		var install, dirs, conffiles bytes.Buffer
+		for _, content := range info.Contents {
+			destination := files.ToNixPath(strings.TrimPrefix(content.Destination, "/"))
+			switch {
+			case content.Type == files.TypeDir:
+				fmt.Fprintln(&dirs, destination)
+			case dscInstalled(content):
+				fmt.Fprintf(&install, "%s %s\n", path.Join(info.Name, destination), path.Dir(destination))
+			}
+			// debhelper already marks the files in /etc as conffiles.
+			switch content.Type {
+			case files.TypeConfig, files.TypeConfigNoReplace, files.TypeConfigMissingOK:
+				if !strings.HasPrefix(destination, "etc/") {
+					fmt.Fprintln(&conffiles, "/"+destination)
+				}
+			}
+		}
+
+		add := func(name string, content []byte, mode int64) {
+			if len(content) > 0 {
+				result[info.Name+"."+name] = debianFile{Content: content, Mode: mode}
+			}
+		}
+		add("install", install.Bytes(), 0o644)
+		add("dirs", dirs.Bytes(), 0o644)
+		add("conffiles", conffiles.Bytes(), 0o644)
+		add("triggers", createTriggers(info), 0o644)
+
+		scripts, err := nfpm.MaintainerScripts(info, packagerName)
+		if err != nil {
+			return nil, err
+		}
+		for name, script := range scripts {
+			add(name, script, 0o755)
+		}
+
+		for name, debconf := range map[string]struct {
+			fileName string
+			mode     int64
+		}{
+			"templates": {info.Deb.Scripts.Templates, 0o644},
+			"config":    {info.Deb.Scripts.Config, 0o755},
+		} {
+			if debconf.fileName == "" {
+				continue
+			}
+			content, err := os.ReadFile(debconf.fileName)
+			if err != nil {
+				return nil, err
+			}
+			add(name, content, debconf.mode)
+		}
+	}
+
+	return result, nil
+}
+
+// dscRules returns the rules of the source package, which skip debhelper's
+// build steps and set the modes and owners of the installed contents, as
+// declared, in place of dh_fixperms.
+func dscRules(infos []*nfpm.Info) []byte {
+	var b bytes.Buffer
+	b.WriteString("#!/usr/bin/make -f\n")
+	b.WriteString("# generated by nfpm\n\n")
+	b.WriteString("%:\n\tdh $@\n\n")
+	for _, step := range dscSkippedSteps {
+		fmt.Fprintf(&b, "override_%s:\n", step)
+	}
+	b.WriteString("\noverride_dh_fixperms:\n")
+	for _, info := range infos {
+		for _, content := range info.Contents {
+			if content.Type == files.TypeSymlink || (!dscInstalled(content) && content.Type != files.TypeDir) {
+				continue
+			}
+			target := rulesQuote(path.Join("debian", info.Name, files.ToNixPath(content.Destination)))
+			mode := content.Mode() & 0o777
+			if content.Mode()&fs.ModeSetuid != 0 {
+				mode |= 0o4000
+			}
+			if content.Mode()&fs.ModeSetgid != 0 {
+				mode |= 0o2000
+			}
+			if content.Mode()&fs.ModeSticky != 0 {
+				mode |= 0o1000
+			}
+			if owner, group := ownership(content); owner != "root" || group != "root" {
+				fmt.Fprintf(&b, "\tchown %s %s\n", rulesQuote(owner+":"+group), target)
+			}
+			// chown clears the setuid and setgid bits, so the modes are set
+			// last.
+			fmt.Fprintf(&b, "\tchmod %04o %s\n", uint32(mode), target)
+		}
+	}
+	return b.Bytes()
+}
+
+// ownership returns the owner and group of the content, which default to
+// root.
+func ownership(content *files.Content) (string, string) {
+	if content.FileInfo == nil {
+		return "root", "root"
+	}
+	return cmp.Or(content.FileInfo.Owner, "root"), cmp.Or(content.FileInfo.Group, "root")
+}
+
+// rulesRequiresRoot returns the Rules-Requires-Root field of the source
+// package, as the rules only need root to set the owners of the contents.
+func rulesRequiresRoot(infos []*nfpm.Info) string {
+	for _, info := range infos {
+		for _, content := range info.Contents {
+			if content.Type == files.TypeImplicitDir {
+				continue
+			}
+			if owner, group := ownership(content); owner != "root" || group != "root" {
+				return "binary-targets"
+			}
+		}
+	}
+	return "no"
+}
+
+// rulesQuote quotes s as a single word of a shell command in a make recipe.
+func rulesQuote(s string) string {
+	return strings.ReplaceAll("'"+strings.ReplaceAll(s, "'", `'\''`)+"'", "$", "$$")
+}
+
+// dscChangelog returns the changelog of the source package: the entries of
+// the changelog of the package, if any, preceded by an entry for the version
+// of the package if it is not the latest one.
+func dscChangelog(info *nfpm.Info) ([]byte, error) {
+	var entries chglog.ChangeLogEntries
+	if info.Changelog != "" {
+		changelog, err := info.GetChangeLog()
+		if err != nil {
+			return nil, err
+		}
+		entries = changelog.Entries
+	}
+
+	current := func(entry *chglog.ChangeLog) bool {
+		semver := strings.TrimPrefix(entry.Semver, "v")
+		return semver == info.Version || semver == upstreamVersion(info) || semver == dscFileVersion(info)
+	}
+	if len(entries) == 0 || !current(entries[0]) {
+		entries = append(chglog.ChangeLogEntries{{
+			Semver:   info.Version,
+			Date:     modtime.Get(info.MTime),
+			Packager: info.Maintainer,
+			Changes:  chglog.ChangeLogChanges{{Note: "Packaged with nfpm."}},
+		}}, entries...)
+	}
+
+	var b bytes.Buffer
+	for i, entry := range entries {
+		version := strings.TrimPrefix(entry.Semver, "v")
+		if i == 0 {
+			version = dscVersion(info)
+		}
+		distribution, urgency := "unstable", "medium"
+		if entry.Deb != nil {
+			distribution = cmp.Or(strings.Join(entry.Deb.Distributions, " "), distribution)
+			urgency = cmp.Or(entry.Deb.Urgency, urgency)
+		}
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s (%s) %s; urgency=%s\n\n", info.Name, version, distribution, urgency)
+		for _, change := range entry.Changes {
+			lines := strings.Split(strings.TrimSpace(change.Note), "\n")
+			fmt.Fprintf(&b, "  * %s\n", strings.TrimSpace(lines[0]))
+			for _, line := range lines[1:] {
+				if line = strings.TrimSpace(line); line != "" {
+					fmt.Fprintf(&b, "    %s\n", line)
+				}
+			}
+		}
+		if len(entry.Changes) == 0 {
+			fmt.Fprintf(&b, "  * Release %s.\n", version)
+		}
+		fmt.Fprintf(&b, "\n -- %s  %s\n",
+			cmp.Or(entry.Packager, info.Maintainer),
+			entry.Date.UTC().Format("Mon, 02 Jan 2006 15:04:05 -0700"),
+		)
+	}
+	return b.Bytes(), nil
+}
+
+const debianControlTemplate = `
+{{- /* Source stanza */ -}}
+Source: {{.Info.Name}}
+{{- if .Info.Section}}
+Section: {{.Info.Section}}
+{{- end }}
+Priority: {{.Info.Priority}}
+Maintainer: {{.Info.Maintainer}}
+Build-Depends: debhelper-compat (= {{.DebhelperCompat}})
+Standards-Version: {{.StandardsVersion}}
+Rules-Requires-Root: {{.RulesRequiresRoot}}
+{{- if .Info.Homepage}}
+Homepage: {{.Info.Homepage}}
+{{- end }}
+{{- /* Binary stanzas */ -}}
+{{- range .Packages }}
+
+Package: {{.Name}}
+Architecture: {{arch .}}
+{{- if .Deb.ArchVariant}}
+XB-Architecture-Variant: {{ .Deb.ArchVariant }}
+{{- end }}
+{{- with .Replaces}}
+Replaces: {{join .}}
+{{- end }}
+{{- with nonEmpty .Provides}}
+Provides: {{join .}}
+{{- end }}
+{{- with .Deb.Predepends}}
+Pre-Depends: {{join .}}
+{{- end }}
+{{- with .Depends}}
+Depends: {{join .}}
+{{- end }}
+{{- with .Recommends}}
+Recommends: {{join .}}
+{{- end }}
+{{- with .Suggests}}
+Suggests: {{join .}}
+{{- end }}
+{{- with .Conflicts}}
+Conflicts: {{join .}}
+{{- end }}
+{{- with .Deb.Breaks}}
+Breaks: {{join .}}
+{{- end }}
+Description: {{multiline .Description}}
+{{- range $key, $value := .Deb.Fields }}
+{{- if $value }}
+XB-{{$key}}: {{$value}}
+{{- end }}
+{{- end }}
+{{- end }}
+`
+
+const dscTemplate = `
+{{- /* Mandatory fields */ -}}
+Format: 3.0 (quilt)
+Source: {{.Info.Name}}
+Binary: {{join .Binaries}}
+Architecture: {{.Architectures}}
+Version: {{.Version}}
+Maintainer: {{.Info.Maintainer}}
+{{- if .Info.Homepage}}
+Homepage: {{.Info.Homepage}}
+{{- end }}
+Standards-Version: {{.StandardsVersion}}
+Build-Depends: debhelper-compat (= {{.DebhelperCompat}})
+Package-List:
+{{- range .Packages }}
+ {{.Name}} deb {{or .Section "unknown"}} {{or .Priority "unknown"}} arch={{arch .}}
+{{- end }}
+Checksums-Sha1:
+{{- range .Files }}
+ {{sha1 .Content}} {{len .Content}} {{.Name}}
+{{- end }}
+Checksums-Sha256:
+{{- range .Files }}
+ {{sha256 .Content}} {{len .Content}} {{.Name}}
+{{- end }}
+Files:
+{{- range .Files }}
+ {{md5 .Content}} {{len .Content}} {{.Name}}
+{{- end }}
+`
+
+type dscFile struct {
+	Name    string
+	Content []byte
+}
+
+type dscData struct {
+	Info     *nfpm.Info
+	Packages []*nfpm.Info
+	Files    []dscFile
+}
+
+func (d dscData) Version() string { return dscVersion(d.Info) }
+
+func (dscData) DebhelperCompat() int { return debhelperCompat }
+
+func (dscData) StandardsVersion() string { return standardsVersion }
+
+func (d dscData) RulesRequiresRoot() string { return rulesRequiresRoot(d.Packages) }
+
+// Binaries returns the names of the binary packages.
+func (d dscData) Binaries() []string {
+	binaries := make([]string, 0, len(d.Packages))
+	for _, info := range d.Packages {
+		binaries = append(binaries, info.Name)
+	}
+	return binaries
+}
+
+// Architectures returns the architectures of the binary packages.
+func (d dscData) Architectures() string {
+	var architectures []string
+	for _, info := range d.Packages {
+		if arch := dscArch(info); !slices.Contains(architectures, arch) {
+			architectures = append(architectures, arch)
+		}
+	}
+	return strings.Join(architectures, " ")
+}
+
+func dscArch(info *nfpm.Info) string {
+	if info.Platform != "linux" {
+		return info.Platform + "-" + info.Arch
+	}
+	return info.Arch
+}
+
+func dscFuncs() template.FuncMap {
+	funcs := controlFuncs()
+	funcs["arch"] = dscArch
+	funcs["md5"] = func(b []byte) string { return fmt.Sprintf("%x", md5.Sum(b)) } // nolint:gas
+	funcs["sha1"] = func(b []byte) string { return fmt.Sprintf("%x", sha1.Sum(b)) }
+	funcs["sha256"] = func(b []byte) string { return fmt.Sprintf("%x", sha256.Sum256(b)) }
+	return funcs
+}
+
+func writeDebianControl(w io.Writer, data dscData) error {
+	tmpl := template.New("debian-control").Funcs(dscFuncs())
+	return template.Must(tmpl.Parse(debianControlTemplate)).Execute(w, data)
+}
+
+func writeDSC(w io.Writer, data dscData) error {
+	tmpl := template.New("dsc").Funcs(dscFuncs())
+	return template.Must(tmpl.Parse(dscTemplate)).Execute(w, data)
+}
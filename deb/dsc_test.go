@@ -0,0 +1,181 @@
+package deb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/stretchr/testify/require"
+)
+
+func exampleDSCInfo(t *testing.T) *nfpm.Info {
+	t.Helper()
+	info := exampleInfo()
+	info.MTime = mtime
+	info.Target = filepath.Join(t.TempDir(), DefaultDSC.ConventionalFileName(info))
+	info.SplitPackages = []*nfpm.Info{nfpm.WithDefaults(&nfpm.Info{
+		Name:        "foo-doc",
+		Arch:        "all",
+		Description: "Foo's documentation",
+		Version:     "v1.0.0",
+		Maintainer:  info.Maintainer,
+		Overridables: nfpm.Overridables{
+			Contents: []*files.Content{
+				{
+					Source:      "../testdata/whatever.conf",
+					Destination: "/usr/share/doc/foo/README",
+					FileInfo: &files.ContentFileInfo{
+						Owner: "nobody",
+						Mode:  0o640,
+					},
+				},
+			},
+		},
+	})}
+	return info
+}
+
+func TestDSCConventionalFileName(t *testing.T) {
+	info := &nfpm.Info{
+		Name:            "testpkg",
+		Arch:            "amd64",
+		Epoch:           "2",
+		Version:         "1.2.3",
+		Prerelease:      "rc1",
+		VersionMetadata: "git",
+	}
+	require.Equal(t, "testpkg_1.2.3~rc1+git-1.dsc", DefaultDSC.ConventionalFileName(info))
+	info.Release = "4"
+	require.Equal(t, "testpkg_1.2.3~rc1+git-4.dsc", DefaultDSC.ConventionalFileName(info))
+	require.Equal(t, ".dsc", DefaultDSC.ConventionalExtension())
+}
+
+func TestDSC(t *testing.T) {
+	info := exampleDSCInfo(t)
+	var dsc bytes.Buffer
+	require.NoError(t, DefaultDSC.Package(info, &dsc))
+
+	dir := filepath.Dir(info.Target)
+	orig, err := os.ReadFile(filepath.Join(dir, "foo_1.0.0.orig.tar.gz"))
+	require.NoError(t, err)
+	debian, err := os.ReadFile(filepath.Join(dir, "foo_1.0.0-1.debian.tar.gz"))
+	require.NoError(t, err)
+
+	require.Contains(t, dsc.String(), "Format: 3.0 (quilt)\nSource: foo\nBinary: foo, foo-doc\nArchitecture: amd64 all\nVersion: 1.0.0-1\n")
+	require.Contains(t, dsc.String(), "Package-List:\n foo deb default extra arch=amd64\n foo-doc deb unknown optional arch=all\n")
+	for name, content := range map[string][]byte{
+		"foo_1.0.0.orig.tar.gz":     orig,
+		"foo_1.0.0-1.debian.tar.gz": debian,
+	} {
+		require.Contains(t, dsc.String(), fmt.Sprintf("\n %x %d %s\n", sha256.Sum256(content), len(content), name))
+	}
+
+	origTar := inflate(t, "gz", orig)
+	require.Equal(t, []byte("echo test\n"), extractFileFromTar(t, origTar, "foo-1.0.0/foo/usr/bin/fake"))
+	require.True(t, tarContains(t, origTar, "foo-1.0.0/foo-doc/usr/share/doc/foo/README"))
+	require.False(t, tarContains(t, origTar, "foo-1.0.0/foo/var/log/whatever"))
+
+	debianTar := inflate(t, "gz", debian)
+	require.Equal(t, "3.0 (quilt)\n", string(extractFileFromTar(t, debianTar, "debian/source/format")))
+	control := string(extractFileFromTar(t, debianTar, "debian/control"))
+	require.Contains(t, control, "Source: foo\nSection: default\nPriority: extra\n")
+	require.Contains(t, control, "Build-Depends: debhelper-compat (= 13)\n")
+	require.Contains(t, control, "Rules-Requires-Root: binary-targets\n")
+	require.Contains(t, control, "\nPackage: foo\nArchitecture: amd64\nReplaces: svn\nProvides: bzr\nPre-Depends: less\nDepends: bash\n")
+	require.Contains(t, control, "\nPackage: foo-doc\nArchitecture: all\nDescription: Foo's documentation\n")
+	require.Contains(t, string(extractFileFromTar(t, debianTar, "debian/foo.install")), "foo/usr/bin/fake usr/bin\n")
+	require.Contains(t, string(extractFileFromTar(t, debianTar, "debian/foo.dirs")), "var/log/whatever\n")
+	require.Equal(t, "foo-doc/usr/share/doc/foo/README usr/share/doc/foo\n", string(extractFileFromTar(t, debianTar, "debian/foo-doc.install")))
+
+	rules := string(extractFileFromTar(t, debianTar, "debian/rules"))
+	require.Contains(t, rules, "%:\n\tdh $@\n")
+	require.Contains(t, rules, "override_dh_strip:\n")
+	require.Contains(t, rules, "\tchmod 0755 'debian/foo/var/log/whatever'\n")
+	require.Contains(t, rules, "\tchown 'nobody:root' 'debian/foo-doc/usr/share/doc/foo/README'\n\tchmod 0640 'debian/foo-doc/usr/share/doc/foo/README'\n")
+
+	require.Equal(t, strings.Join([]string{
+		"foo (1.0.0-1) unstable; urgency=medium",
+		"",
+		"  * Packaged with nfpm.",
+		"",
+		" -- Carlos A Becker <pkg@carlosbecker.com>  Sun, 05 Nov 2023 23:15:17 +0000",
+		"",
+	}, "\n"), string(extractFileFromTar(t, debianTar, "debian/changelog")))
+}
+
+func TestDSCScripts(t *testing.T) {
+	info := exampleDSCInfo(t)
+	info.Scripts.PostInstall = "../testdata/scripts/postinstall.sh"
+	var dsc bytes.Buffer
+	require.NoError(t, DefaultDSC.Package(info, &dsc))
+
+	debian, err := os.ReadFile(filepath.Join(filepath.Dir(info.Target), "foo_1.0.0-1.debian.tar.gz"))
+	require.NoError(t, err)
+	script, err := os.ReadFile(info.Scripts.PostInstall)
+	require.NoError(t, err)
+	debianTar := inflate(t, "gz", debian)
+	require.Equal(t, script, extractFileFromTar(t, debianTar, "debian/foo.postinst"))
+	require.False(t, tarContains(t, debianTar, "debian/foo-doc.postinst"))
+}
+
+func TestDSCChangelog(t *testing.T) {
+	info := exampleDSCInfo(t)
+	info.Version = "1.1.0"
+	info.Epoch = "1"
+	info.Changelog = "../testdata/changelog.yaml"
+
+	changelog, err := dscChangelog(info)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(changelog), strings.Join([]string{
+		"foo (1:1.1.0-1) bookworm; urgency=medium",
+		"",
+		"  * note 1",
+		"  * note 2",
+		"",
+		" -- Carlos A Becker <pkg@carlosbecker.com>  Tue, 08 Dec 2009 22:00:00 +0000",
+		"",
+		"foo (1.0.0-1) bookworm; urgency=medium",
+		"",
+		"  * note 3",
+	}, "\n")), string(changelog))
+
+	// a changelog without the version of the package gets an entry for it.
+	info.Version = "1.2.0"
+	changelog, err = dscChangelog(info)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(changelog), "foo (1:1.2.0-1) unstable; urgency=medium\n"))
+	require.Contains(t, string(changelog), "\nfoo (1.1.0-1) bookworm; urgency=medium\n")
+}
+
+func TestDSCSignature(t *testing.T) {
+	info := exampleDSCInfo(t)
+	info.Deb.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+	info.Deb.Signature.KeyPassphrase = "hunter2"
+
+	var dsc bytes.Buffer
+	require.NoError(t, DefaultDSC.Package(info, &dsc))
+	require.True(t, strings.HasPrefix(dsc.String(), "-----BEGIN PGP SIGNED MESSAGE-----\n"))
+
+	msg, err := sign.PGPReadMessage(dsc.Bytes(), "../internal/sign/testdata/pubkey.asc")
+	require.NoError(t, err)
+	require.Contains(t, string(msg), "Source: foo\n")
+}
+
+func TestDSCWithoutTarget(t *testing.T) {
+	info := exampleDSCInfo(t)
+	info.Target = ""
+	require.ErrorIs(t, DefaultDSC.Package(info, &bytes.Buffer{}), ErrDSCWithoutTarget)
+}
+
+func TestDSCWhiteSpace(t *testing.T) {
+	info := exampleDSCInfo(t)
+	info.SplitPackages[0].Contents[0].Destination = "/usr/share/doc/foo/READ ME"
+	require.EqualError(t, DefaultDSC.Package(info, &bytes.Buffer{}), "foo-doc: /usr/share/doc/foo/READ ME: debhelper does not support white space in installed paths")
+}
@@ -0,0 +1,330 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/blakesmith/ar"
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/deb822"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// ErrInvalidPackage happens when a file cannot be read as a deb package.
+var ErrInvalidPackage = errors.New("invalid deb package")
+
+// control fields that are mapped to nfpm.Info and not to Deb.Fields.
+// nolint: gochecknoglobals
+var knownControlFields = map[string]bool{
+	"package":              true,
+	"version":              true,
+	"section":              true,
+	"priority":             true,
+	"architecture":         true,
+	"architecture-variant": true,
+	"maintainer":           true,
+	"installed-size":       true,
+	"replaces":             true,
+	"provides":             true,
+	"pre-depends":          true,
+	"depends":              true,
+	"recommends":           true,
+	"suggests":             true,
+	"conflicts":            true,
+	"breaks":               true,
+	"homepage":             true,
+	"description":          true,
+}
+
+// maps the control archive member names to the nfpm script names.
+// nolint: gochecknoglobals
+var controlScripts = map[string]string{
+	"preinst":   "preinstall",
+	"postinst":  "postinstall",
+	"prerm":     "preremove",
+	"postrm":    "postremove",
+	"config":    "config",
+	"templates": "templates",
+	"rules":     "rules",
+}
+
+// Read implements nfpm.Reader.
+func (*Deb) Read(r io.Reader) (*nfpm.Inspection, error) {
+	members, err := readArMembers(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &nfpm.Inspection{
+		Format: packagerName,
+		Info: &nfpm.Info{
+			Platform: "linux",
+		},
+	}
+
+	var controlName, dataName string
+	for _, name := range sortedMemberNames(members) {
+		switch {
+		case strings.HasPrefix(name, "control.tar"):
+			controlName = name
+		case strings.HasPrefix(name, "data.tar"):
+			dataName = name
+		case strings.HasPrefix(name, "_gpg"):
+			result.Signatures = append(result.Signatures, signatureInfo(name, members[name]))
+		}
+	}
+	if controlName == "" || dataName == "" {
+		return nil, fmt.Errorf("%w: missing control or data archive", ErrInvalidPackage)
+	}
+
+	if err := readControlTarball(controlName, members[controlName], result); err != nil {
+		return nil, err
+	}
+	if err := readDataTarball(dataName, members[dataName], result.Info); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func readArMembers(r io.Reader) (map[string][]byte, error) {
+	members := map[string][]byte{}
+	reader := ar.NewReader(r)
+	for {
+		hdr, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", hdr.Name, err)
+		}
+		name := strings.TrimSuffix(strings.TrimSpace(hdr.Name), "/")
+		if _, ok := members[name]; ok {
+			return nil, fmt.Errorf("%w: duplicate member %s", ErrInvalidPackage, name)
+		}
+		members[name] = body
+	}
+	if string(members["debian-binary"]) != "2.0\n" {
+		return nil, fmt.Errorf("%w: unsupported debian-binary version", ErrInvalidPackage)
+	}
+	return members, nil
+}
+
+func sortedMemberNames(members map[string][]byte) []string {
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func signatureInfo(name string, sig []byte) nfpm.SignatureInfo {
+	info := nfpm.SignatureInfo{
+		Type: "debsign",
+		Name: strings.TrimPrefix(name, "_gpg"),
+	}
+	if bytes.HasPrefix(sig, []byte("-----BEGIN PGP SIGNED MESSAGE-----")) {
+		info.Type = "dpkg-sig"
+	}
+	info.KeyID, _ = sign.PGPSignatureKeyID(sig)
+	return info
+}
+
+// decompressedTar opens the tarball with the given ar member name, guessing its
+// compression from the extension.
+func decompressedTar(name string, body []byte) (*tar.Reader, error) {
+	var r io.Reader = bytes.NewReader(body)
+	switch path.Ext(name) {
+	case ".gz":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decompress %s: %w", name, err)
+		}
+		r = gz
+	case ".xz":
+		xzr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decompress %s: %w", name, err)
+		}
+		r = xzr
+	case ".zst":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decompress %s: %w", name, err)
+		}
+		r = zr
+	case ".tar":
+	default:
+		return nil, fmt.Errorf("%w: unsupported compression for %s", ErrInvalidPackage, name)
+	}
+	return tar.NewReader(r), nil
+}
+
+func readControlTarball(name string, body []byte, result *nfpm.Inspection) error {
+	tr, err := decompressedTar(name, body)
+	if err != nil {
+		return err
+	}
+
+	var control, conffiles, triggers []byte
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", hdr.Name, err)
+		}
+		switch base := path.Base(hdr.Name); base {
+		case "control":
+			control = content
+		case "conffiles":
+			conffiles = content
+		case "triggers":
+			triggers = content
+		default:
+			if script, ok := controlScripts[base]; ok {
+				if result.Scripts == nil {
+					result.Scripts = map[string]string{}
+				}
+				result.Scripts[script] = string(content)
+			}
+		}
+	}
+	if control == nil {
+		return fmt.Errorf("%w: missing control file", ErrInvalidPackage)
+	}
+
+	paragraph, err := deb822.ParseOne(bytes.NewReader(control))
+	if err != nil {
+		return fmt.Errorf("cannot parse control file: %w", err)
+	}
+	infoFromControl(result.Info, paragraph)
+	infoFromTriggers(result.Info, triggers)
+	for _, conf := range strings.Fields(string(conffiles)) {
+		result.Info.Contents = append(result.Info.Contents, &files.Content{
+			Destination: conf,
+			Type:        files.TypeConfig,
+		})
+	}
+	return nil
+}
+
+func infoFromControl(info *nfpm.Info, control deb822.Paragraph) {
+	info.Name = control.Get("Package")
+	info.Epoch, info.Version, info.Release = splitVersion(control.Get("Version"))
+	info.Section = control.Get("Section")
+	info.Priority = control.Get("Priority")
+	info.Arch = control.Get("Architecture")
+	if platform, arch, ok := strings.Cut(info.Arch, "-"); ok {
+		info.Platform, info.Arch = platform, arch
+	}
+	info.Deb.ArchVariant = control.Get("Architecture-Variant")
+	info.Maintainer = control.Get("Maintainer")
+	info.Replaces = deb822.List(control.Get("Replaces"))
+	info.Provides = deb822.List(control.Get("Provides"))
+	info.Deb.Predepends = deb822.List(control.Get("Pre-Depends"))
+	info.Depends = deb822.List(control.Get("Depends"))
+	info.Recommends = deb822.List(control.Get("Recommends"))
+	info.Suggests = deb822.List(control.Get("Suggests"))
+	info.Conflicts = deb822.List(control.Get("Conflicts"))
+	info.Deb.Breaks = deb822.List(control.Get("Breaks"))
+	info.Homepage = control.Get("Homepage")
+	info.Description = deb822.Multiline(control.Get("Description"))
+
+	for _, field := range control {
+		if knownControlFields[strings.ToLower(field.Name)] {
+			continue
+		}
+		if info.Deb.Fields == nil {
+			info.Deb.Fields = map[string]string{}
+		}
+		info.Deb.Fields[field.Name] = field.Value
+	}
+}
+
+func infoFromTriggers(info *nfpm.Info, triggers []byte) {
+	directives := map[string]*[]string{
+		"interest":         &info.Deb.Triggers.Interest,
+		"interest-await":   &info.Deb.Triggers.InterestAwait,
+		"interest-noawait": &info.Deb.Triggers.InterestNoAwait,
+		"activate":         &info.Deb.Triggers.Activate,
+		"activate-await":   &info.Deb.Triggers.ActivateAwait,
+		"activate-noawait": &info.Deb.Triggers.ActivateNoAwait,
+	}
+	for _, line := range strings.Split(string(triggers), "\n") {
+		directive, name, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		if names, ok := directives[directive]; ok {
+			*names = append(*names, strings.TrimSpace(name))
+		}
+	}
+}
+
+// splitVersion splits a debian version into its epoch, upstream version and
+// revision.
+func splitVersion(version string) (epoch, upstream, revision string) {
+	if e, rest, ok := strings.Cut(version, ":"); ok {
+		epoch, version = e, rest
+	}
+	if i := strings.LastIndex(version, "-"); i >= 0 {
+		version, revision = version[:i], version[i+1:]
+	}
+	return epoch, version, revision
+}
+
+func readDataTarball(name string, body []byte, info *nfpm.Info) error {
+	tr, err := decompressedTar(name, body)
+	if err != nil {
+		return err
+	}
+
+	conffiles := map[string]bool{}
+	for _, c := range info.Contents {
+		conffiles[c.Destination] = true
+	}
+	info.Contents = nil
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", name, err)
+		}
+		content := files.FromTarHeader(hdr)
+		if content == nil {
+			continue
+		}
+		if content.Type == files.TypeFile && conffiles[content.Destination] {
+			content.Type = files.TypeConfig
+		}
+		info.Contents = append(info.Contents, content)
+	}
+	sort.Sort(info.Contents)
+	return nil
+}
@@ -0,0 +1,72 @@
+package deb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	info := exampleInfo()
+	info.Epoch = "2"
+	info.Release = "3"
+	info.Description = "Foo does things\n\nand other things"
+	info.Scripts.PreInstall = "../testdata/scripts/preinstall.sh"
+	info.Deb.Fields = map[string]string{"Bugs": "https://github.com/goreleaser/nfpm/issues"}
+	info.Deb.Triggers.Interest = []string{"foo"}
+	info.Contents = append(info.Contents, &files.Content{
+		Source:      "/usr/bin/fake",
+		Destination: "/usr/bin/fake-link",
+		Type:        files.TypeSymlink,
+	})
+	info.Deb.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+	info.Deb.Signature.KeyPassphrase = "hunter2"
+
+	var deb bytes.Buffer
+	require.NoError(t, Default.Package(info, &deb))
+
+	result, err := Default.Read(&deb)
+	require.NoError(t, err)
+	require.Equal(t, packagerName, result.Format)
+
+	got := result.Info
+	require.Equal(t, "foo", got.Name)
+	require.Equal(t, "2", got.Epoch)
+	require.Equal(t, "1.0.0", got.Version)
+	require.Equal(t, "3", got.Release)
+	require.Equal(t, "amd64", got.Arch)
+	require.Equal(t, "linux", got.Platform)
+	require.Equal(t, info.Maintainer, got.Maintainer)
+	require.Equal(t, info.Description, got.Description)
+	require.Equal(t, nfpm.Relations{"bash"}, got.Depends)
+	require.Equal(t, nfpm.Relations{"less"}, got.Deb.Predepends)
+	require.Equal(t, []string{"foo"}, got.Deb.Triggers.Interest)
+	require.Equal(t, info.Deb.Fields, got.Deb.Fields)
+
+	contents := map[string]*files.Content{}
+	for _, c := range got.Contents {
+		contents[c.Destination] = c
+	}
+	require.Equal(t, files.TypeFile, contents["/usr/bin/fake"].Type)
+	require.Equal(t, files.TypeConfig, contents["/etc/fake/fake.conf"].Type)
+	require.Equal(t, files.TypeDir, contents["/var/log/whatever"].Type)
+	require.Equal(t, files.TypeSymlink, contents["/usr/bin/fake-link"].Type)
+	require.Equal(t, "/usr/bin/fake", contents["/usr/bin/fake-link"].Source)
+	require.Equal(t, "root", contents["/usr/bin/fake"].FileInfo.Owner)
+
+	require.Contains(t, result.Scripts, "preinstall")
+	require.Equal(t, []nfpm.SignatureInfo{{
+		Type:  "debsign",
+		Name:  "origin",
+		KeyID: "9890904dfb2ec88a",
+	}}, result.Signatures)
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, err := Default.Read(strings.NewReader("not a deb"))
+	require.ErrorIs(t, err, ErrInvalidPackage)
+}
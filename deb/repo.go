@@ -0,0 +1,342 @@
+package deb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"  // nolint:gosec
+	"crypto/sha1" // nolint:gosec
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/deb822"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+)
+
+const defaultRepoComponent = "main"
+
+// RepoOptions configures the APT repository created by IndexRepo.
+type RepoOptions struct {
+	// Suite of the repository, e.g. stable. If set, a pool style repository
+	// is created, with the indexes in dists/<suite>/<component>/binary-<arch>.
+	// Otherwise a flat repository is created, with the indexes next to the
+	// packages.
+	Suite       string
+	Codename    string
+	Component   string // defaults to main
+	Origin      string
+	Label       string
+	Description string
+	// Date of the Release file, defaults to $SOURCE_DATE_EPOCH or now.
+	Date time.Time
+
+	// PGP secret key used to create InRelease and Release.gpg, can be
+	// ASCII-armored. The repository is not signed if empty.
+	KeyFile       string
+	KeyID         *string
+	KeyPassphrase string
+}
+
+// repoPackage is a deb found while indexing a repository.
+type repoPackage struct {
+	filename string // relative to the repository root, slash separated
+	control  deb822.Paragraph
+}
+
+// repoFile is an index file listed in the Release file.
+type repoFile struct {
+	name    string // relative to the Release file, slash separated
+	content []byte
+}
+
+// IndexRepo indexes all deb packages found in dir, creating the Packages,
+// Packages.gz and Release files and, if a key is given, the InRelease and
+// Release.gpg signatures. It returns the paths of the created files.
+func IndexRepo(dir string, opts RepoOptions) ([]string, error) {
+	pkgs, err := scanRepo(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	component := opts.Component
+	if component == "" {
+		component = defaultRepoComponent
+	}
+
+	var archs []string
+	for _, pkg := range pkgs {
+		if arch := pkg.control.Get("Architecture"); arch != "all" && !slices.Contains(archs, arch) {
+			archs = append(archs, arch)
+		}
+	}
+	sort.Strings(archs)
+	if len(archs) == 0 {
+		archs = []string{"all"}
+	}
+
+	releaseDir := dir
+	indexes := map[string][]*repoPackage{}
+	if opts.Suite == "" {
+		indexes[""] = pkgs
+	} else {
+		releaseDir = filepath.Join(dir, "dists", opts.Suite)
+		for _, arch := range archs {
+			name := path.Join(component, "binary-"+arch)
+			indexes[name] = []*repoPackage{}
+			for _, pkg := range pkgs {
+				if pkgArch := pkg.control.Get("Architecture"); pkgArch == arch || pkgArch == "all" {
+					indexes[name] = append(indexes[name], pkg)
+				}
+			}
+		}
+	}
+
+	var files []repoFile
+	for _, name := range sortedIndexNames(indexes) {
+		packages, err := packagesIndex(indexes[name])
+		if err != nil {
+			return nil, err
+		}
+		packagesGz, err := gzipBytes(packages)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files,
+			repoFile{name: path.Join(name, "Packages"), content: packages},
+			repoFile{name: path.Join(name, "Packages.gz"), content: packagesGz},
+		)
+	}
+
+	release, err := releaseFile(opts, component, archs, files)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, repoFile{name: "Release", content: release})
+
+	if opts.KeyFile != "" {
+		inRelease, err := sign.PGPClearSignWithKeyID(bytes.NewReader(release), opts.KeyFile, opts.KeyPassphrase, opts.KeyID)
+		if err != nil {
+			return nil, &nfpm.ErrSigningFailure{Err: err}
+		}
+		releaseGpg, err := sign.PGPArmoredDetachSignWithKeyID(bytes.NewReader(release), opts.KeyFile, opts.KeyPassphrase, opts.KeyID)
+		if err != nil {
+			return nil, &nfpm.ErrSigningFailure{Err: err}
+		}
+		files = append(files,
+			repoFile{name: "InRelease", content: inRelease},
+			repoFile{name: "Release.gpg", content: releaseGpg},
+		)
+	}
+
+	created := make([]string, 0, len(files))
+	for _, file := range files {
+		name := filepath.Join(releaseDir, filepath.FromSlash(file.name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(name, file.content, 0o644); err != nil { //nolint:gosec
+			return nil, err
+		}
+		created = append(created, name)
+	}
+	return created, nil
+}
+
+// scanRepo reads the control file of every deb in dir, sorted by package
+// name, version and file name.
+func scanRepo(dir string) ([]*repoPackage, error) {
+	var pkgs []*repoPackage
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(name) != ".deb" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		pkg, err := readRepoPackage(name, filepath.ToSlash(rel))
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		pkgs = append(pkgs, pkg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		a, b := pkgs[i].control, pkgs[j].control
+		if a.Get("Package") != b.Get("Package") {
+			return a.Get("Package") < b.Get("Package")
+		}
+		if a.Get("Version") != b.Get("Version") {
+			return a.Get("Version") < b.Get("Version")
+		}
+		return pkgs[i].filename < pkgs[j].filename
+	})
+	return pkgs, nil
+}
+
+// readRepoPackage reads the control file of the given deb and adds the
+// fields needed by the Packages index to it.
+func readRepoPackage(name, filename string) (*repoPackage, error) {
+	content, err := os.ReadFile(name) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	members, err := readArMembers(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	control, err := readControlParagraph(members)
+	if err != nil {
+		return nil, err
+	}
+
+	// the description is conventionally the last field of a paragraph.
+	description := control.Get("Description")
+	var paragraph deb822.Paragraph
+	for _, f := range control {
+		if !strings.EqualFold(f.Name, "Description") {
+			paragraph = append(paragraph, f)
+		}
+	}
+	paragraph = append(paragraph,
+		deb822.Field{Name: "Filename", Value: filename},
+		deb822.Field{Name: "Size", Value: fmt.Sprint(len(content))},
+		deb822.Field{Name: "MD5sum", Value: hashHex(md5.New(), content)}, // nolint:gosec
+		deb822.Field{Name: "SHA1", Value: hashHex(sha1.New(), content)},  // nolint:gosec
+		deb822.Field{Name: "SHA256", Value: hashHex(sha256.New(), content)},
+		deb822.Field{Name: "Description", Value: description},
+	)
+	return &repoPackage{
+		filename: filename,
+		control:  paragraph,
+	}, nil
+}
+
+func readControlParagraph(members map[string][]byte) (deb822.Paragraph, error) {
+	for _, name := range sortedMemberNames(members) {
+		if !strings.HasPrefix(name, "control.tar") {
+			continue
+		}
+		tr, err := decompressedTar(name, members[name])
+		if err != nil {
+			return nil, err
+		}
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("cannot read %s: %w", name, err)
+			}
+			if path.Base(hdr.Name) != "control" {
+				continue
+			}
+			paragraph, err := deb822.ParseOne(tr)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse control file: %w", err)
+			}
+			return paragraph, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: missing control file", ErrInvalidPackage)
+}
+
+func packagesIndex(pkgs []*repoPackage) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, pkg := range pkgs {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if _, err := pkg.control.WriteTo(&buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func releaseFile(opts RepoOptions, component string, archs []string, files []repoFile) ([]byte, error) {
+	date := opts.Date
+	if date.IsZero() {
+		date = modtime.Get(modtime.FromEnv())
+	}
+
+	paragraph := deb822.Paragraph{
+		{Name: "Origin", Value: opts.Origin},
+		{Name: "Label", Value: opts.Label},
+		{Name: "Suite", Value: opts.Suite},
+		{Name: "Codename", Value: opts.Codename},
+		{Name: "Date", Value: date.UTC().Format(time.RFC1123)},
+		{Name: "Architectures", Value: strings.Join(archs, " ")},
+	}
+	if opts.Suite != "" {
+		paragraph = append(paragraph, deb822.Field{Name: "Components", Value: component})
+	}
+	paragraph = append(paragraph, deb822.Field{Name: "Description", Value: opts.Description})
+
+	var buf bytes.Buffer
+	if _, err := paragraph.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	for _, sum := range []struct {
+		field string
+		hash  func() hash.Hash
+	}{
+		{"MD5Sum", md5.New},
+		{"SHA1", sha1.New},
+		{"SHA256", sha256.New},
+	} {
+		fmt.Fprintf(&buf, "%s:\n", sum.field)
+		for _, file := range files {
+			fmt.Fprintf(&buf, " %s %d %s\n", hashHex(sum.hash(), file.content), len(file.content), file.name)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func sortedIndexNames(indexes map[string][]*repoPackage) []string {
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func hashHex(h hash.Hash, content []byte) string {
+	_, _ = h.Write(content)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func gzipBytes(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gz.Write(content); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
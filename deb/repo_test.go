@@ -0,0 +1,152 @@
+package deb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2/internal/deb822"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRepoDeb(t *testing.T, dir, name, arch string) string {
+	t.Helper()
+	info := exampleInfo()
+	info.Name = name
+	info.Arch = arch
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	target := filepath.Join(dir, Default.ConventionalFileName(info))
+	f, err := os.Create(target)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, Default.Package(info, f))
+	return target
+}
+
+func readPackagesIndex(t *testing.T, name string) []deb822.Paragraph {
+	t.Helper()
+	content, err := os.ReadFile(name)
+	require.NoError(t, err)
+	gz, err := os.ReadFile(name + ".gz")
+	require.NoError(t, err)
+	gzr, err := gzip.NewReader(bytes.NewReader(gz))
+	require.NoError(t, err)
+	uncompressed, err := io.ReadAll(gzr)
+	require.NoError(t, err)
+	require.Equal(t, content, uncompressed)
+
+	paragraphs, err := deb822.Parse(bytes.NewReader(content))
+	require.NoError(t, err)
+	return paragraphs
+}
+
+func TestIndexRepoFlat(t *testing.T) {
+	dir := t.TempDir()
+	debFile := writeRepoDeb(t, dir, "foo", "amd64")
+	writeRepoDeb(t, filepath.Join(dir, "sub"), "bar", "all")
+
+	created, err := IndexRepo(dir, RepoOptions{
+		Origin: "nfpm",
+		Date:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "Packages"),
+		filepath.Join(dir, "Packages.gz"),
+		filepath.Join(dir, "Release"),
+	}, created)
+
+	paragraphs := readPackagesIndex(t, filepath.Join(dir, "Packages"))
+	require.Len(t, paragraphs, 2)
+	require.Equal(t, "bar", paragraphs[0].Get("Package"))
+	require.Equal(t, "sub/bar_1.0.0_all.deb", paragraphs[0].Get("Filename"))
+	require.Equal(t, "foo", paragraphs[1].Get("Package"))
+	require.Equal(t, "foo_1.0.0_amd64.deb", paragraphs[1].Get("Filename"))
+	require.Equal(t, "Description", paragraphs[1][len(paragraphs[1])-1].Name)
+
+	content, err := os.ReadFile(debFile)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprint(len(content)), paragraphs[1].Get("Size"))
+	require.Equal(t, fmt.Sprintf("%x", sha256.Sum256(content)), paragraphs[1].Get("SHA256"))
+
+	release, err := os.ReadFile(filepath.Join(dir, "Release"))
+	require.NoError(t, err)
+	packages, err := os.ReadFile(filepath.Join(dir, "Packages"))
+	require.NoError(t, err)
+	require.Contains(t, string(release), "Origin: nfpm\n")
+	require.Contains(t, string(release), "Date: Tue, 02 Jan 2024 03:04:05 UTC\n")
+	require.Contains(t, string(release), "Architectures: amd64\n")
+	require.Contains(t, string(release), fmt.Sprintf("SHA256:\n %x %d Packages\n", sha256.Sum256(packages), len(packages)))
+	require.NotContains(t, string(release), "Components:")
+}
+
+func TestIndexRepoPool(t *testing.T) {
+	dir := t.TempDir()
+	pool := filepath.Join(dir, "pool", "main")
+	writeRepoDeb(t, pool, "foo", "amd64")
+	writeRepoDeb(t, pool, "foo", "arm64")
+	writeRepoDeb(t, pool, "bar", "all")
+
+	_, err := IndexRepo(dir, RepoOptions{
+		Suite:     "stable",
+		Component: "contrib",
+	})
+	require.NoError(t, err)
+
+	for _, arch := range []string{"amd64", "arm64"} {
+		paragraphs := readPackagesIndex(t, filepath.Join(dir, "dists", "stable", "contrib", "binary-"+arch, "Packages"))
+		require.Len(t, paragraphs, 2)
+		require.Equal(t, "pool/main/bar_1.0.0_all.deb", paragraphs[0].Get("Filename"))
+		require.Equal(t, "pool/main/foo_1.0.0_"+arch+".deb", paragraphs[1].Get("Filename"))
+	}
+
+	release, err := os.ReadFile(filepath.Join(dir, "dists", "stable", "Release"))
+	require.NoError(t, err)
+	require.Contains(t, string(release), "Suite: stable\n")
+	require.Contains(t, string(release), "Architectures: amd64 arm64\n")
+	require.Contains(t, string(release), "Components: contrib\n")
+	require.Contains(t, string(release), " contrib/binary-arm64/Packages.gz\n")
+}
+
+func TestIndexRepoSigned(t *testing.T) {
+	dir := t.TempDir()
+	writeRepoDeb(t, dir, "foo", "amd64")
+
+	_, err := IndexRepo(dir, RepoOptions{
+		KeyFile:       "../internal/sign/testdata/privkey.asc",
+		KeyPassphrase: "hunter2",
+	})
+	require.NoError(t, err)
+
+	release, err := os.ReadFile(filepath.Join(dir, "Release"))
+	require.NoError(t, err)
+
+	inRelease, err := os.ReadFile(filepath.Join(dir, "InRelease"))
+	require.NoError(t, err)
+	plaintext, err := sign.PGPReadMessage(inRelease, "../internal/sign/testdata/pubkey.asc")
+	require.NoError(t, err)
+	require.Equal(t, string(bytes.TrimSpace(release)), string(bytes.TrimSpace(plaintext)))
+
+	releaseGpg, err := os.ReadFile(filepath.Join(dir, "Release.gpg"))
+	require.NoError(t, err)
+	require.NoError(t, sign.PGPVerify(bytes.NewReader(release), releaseGpg, "../internal/sign/testdata/pubkey.asc"))
+}
+
+func TestIndexRepoSignError(t *testing.T) {
+	dir := t.TempDir()
+	writeRepoDeb(t, dir, "foo", "amd64")
+
+	_, err := IndexRepo(dir, RepoOptions{
+		KeyFile:       "../internal/sign/testdata/privkey.asc",
+		KeyPassphrase: "wrong",
+	})
+	require.Error(t, err)
+	require.NoFileExists(t, filepath.Join(dir, "Release"))
+}
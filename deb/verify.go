@@ -0,0 +1,137 @@
+package deb
+
+import (
+	"crypto/md5"  // nolint:gosec
+	"crypto/sha1" // nolint:gosec
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+)
+
+// ErrSignatureMismatch happens when a dpkg-sig signature is valid but the
+// checksums it holds do not match the package members.
+var ErrSignatureMismatch = errors.New("signed checksums do not match the package")
+
+// Verify implements nfpm.Verifier. Both debsign (_gpgorigin and friends) and
+// dpkg-sig (_gpgbuilder) signatures are checked against the given OpenPGP
+// public key.
+func (*Deb) Verify(r io.Reader, keyFile string) ([]nfpm.SignatureVerification, error) {
+	members, err := readArMembers(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var controlName, dataName string
+	for _, name := range sortedMemberNames(members) {
+		var archive *string
+		switch {
+		case strings.HasPrefix(name, "control.tar"):
+			archive = &controlName
+		case strings.HasPrefix(name, "data.tar"):
+			archive = &dataName
+		default:
+			continue
+		}
+		if *archive != "" {
+			return nil, fmt.Errorf("%w: duplicate archives %s and %s", ErrInvalidPackage, *archive, name)
+		}
+		*archive = name
+	}
+	if controlName == "" || dataName == "" {
+		return nil, fmt.Errorf("%w: missing control or data archive", ErrInvalidPackage)
+	}
+
+	var results []nfpm.SignatureVerification
+	for _, name := range sortedMemberNames(members) {
+		if !strings.HasPrefix(name, "_gpg") {
+			continue
+		}
+		sig := members[name]
+		result := nfpm.SignatureVerification{
+			SignatureInfo: signatureInfo(name, sig),
+		}
+		if result.Type == "dpkg-sig" {
+			result.Err = verifyDpkgSig(sig, keyFile, members)
+		} else {
+			result.Err = sign.PGPVerify(
+				readDebsignData(members["debian-binary"], members[controlName], members[dataName]),
+				sig,
+				keyFile,
+			)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// verifyDpkgSig checks the clearsigned dpkg-sig message and then the
+// checksums of the ar members it lists, which must include every member but
+// the signatures.
+func verifyDpkgSig(sig []byte, keyFile string, members map[string][]byte) error {
+	msg, err := sign.PGPReadMessage(sig, keyFile)
+	if err != nil {
+		return err
+	}
+	_, list, ok := strings.Cut(string(msg), "Files:")
+	if !ok {
+		return fmt.Errorf("%w: missing Files section", ErrSignatureMismatch)
+	}
+
+	checked := map[string]bool{}
+	for _, line := range strings.Split(list, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 4 {
+			return fmt.Errorf("%w: invalid line %q", ErrSignatureMismatch, line)
+		}
+		member, ok := dpkgSigMember(members, fields[3])
+		if !ok {
+			return fmt.Errorf("%w: missing %s", ErrSignatureMismatch, fields[3])
+		}
+		if checked[member] {
+			return fmt.Errorf("%w: %s listed twice", ErrSignatureMismatch, member)
+		}
+		content := members[member]
+		md5Sum, sha1Sum := md5.Sum(content), sha1.Sum(content) // nolint:gosec
+		if fields[0] != hex.EncodeToString(md5Sum[:]) ||
+			fields[1] != hex.EncodeToString(sha1Sum[:]) ||
+			fields[2] != strconv.Itoa(len(content)) {
+			return fmt.Errorf("%w: %s", ErrSignatureMismatch, fields[3])
+		}
+		checked[member] = true
+	}
+	for _, name := range sortedMemberNames(members) {
+		if !strings.HasPrefix(name, "_") && !checked[name] {
+			return fmt.Errorf("%w: %s is not signed", ErrSignatureMismatch, name)
+		}
+	}
+	return nil
+}
+
+// dpkgSigMember finds the name of the ar member a dpkg-sig line refers to.
+// nfpm always lists the archives as control.tar.gz and data.tar.gz
+// regardless of the compression actually used, so archives are also matched
+// by their prefix; Verify made sure there is only one of each.
+func dpkgSigMember(members map[string][]byte, name string) (string, bool) {
+	if _, ok := members[name]; ok {
+		return name, true
+	}
+	prefix, _, ok := strings.Cut(name, ".tar")
+	if !ok {
+		return "", false
+	}
+	for _, member := range sortedMemberNames(members) {
+		if strings.HasPrefix(member, prefix+".tar") {
+			return member, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,103 @@
+package deb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/blakesmith/ar"
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	for _, method := range []string{"debsign", "dpkg-sig"} {
+		t.Run(method, func(t *testing.T) {
+			info := exampleInfo()
+			info.Deb.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+			info.Deb.Signature.KeyPassphrase = "hunter2"
+			info.Deb.Signature.Method = method
+			info.Deb.Compression = "xz"
+
+			var deb bytes.Buffer
+			require.NoError(t, Default.Package(info, &deb))
+
+			results, err := Default.Verify(&deb, "../internal/sign/testdata/pubkey.asc")
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			require.Equal(t, method, results[0].Type)
+			require.NoError(t, results[0].Err)
+		})
+	}
+}
+
+func TestVerifyInvalidSignature(t *testing.T) {
+	for _, method := range []string{"debsign", "dpkg-sig"} {
+		t.Run(method, func(t *testing.T) {
+			info := exampleInfo()
+			info.Deb.Signature.Method = method
+			info.Deb.Signature.SignFn = func(r io.Reader) ([]byte, error) {
+				r = io.MultiReader(r, strings.NewReader("\ttampered\n"))
+				if method == "dpkg-sig" {
+					return sign.PGPClearSignWithKeyID(r, "../internal/sign/testdata/privkey.asc", "hunter2", nil)
+				}
+				return sign.PGPArmoredDetachSignWithKeyID(r, "../internal/sign/testdata/privkey.asc", "hunter2", nil)
+			}
+
+			var deb bytes.Buffer
+			require.NoError(t, Default.Package(info, &deb))
+
+			results, err := Default.Verify(&deb, "../internal/sign/testdata/pubkey.asc")
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			require.Error(t, results[0].Err)
+		})
+	}
+}
+
+func TestVerifyExtraMembers(t *testing.T) {
+	for name, tc := range map[string]struct {
+		member string
+		err    error
+	}{
+		"unsigned":          {"extra", ErrSignatureMismatch},
+		"duplicate":         {"control.tar.xz", ErrInvalidPackage},
+		"duplicate archive": {"data.tar.gz", ErrInvalidPackage},
+	} {
+		t.Run(name, func(t *testing.T) {
+			info := exampleInfo()
+			info.Deb.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+			info.Deb.Signature.KeyPassphrase = "hunter2"
+			info.Deb.Signature.Method = "dpkg-sig"
+			info.Deb.Compression = "xz"
+
+			var deb bytes.Buffer
+			require.NoError(t, Default.Package(info, &deb))
+			// the global header is already written.
+			w := ar.NewWriter(&deb)
+			require.NoError(t, w.WriteHeader(&ar.Header{Name: tc.member, Mode: 0o644, Size: 2}))
+			_, err := w.Write([]byte("{}"))
+			require.NoError(t, err)
+
+			results, err := Default.Verify(&deb, "../internal/sign/testdata/pubkey.asc")
+			if errors.Is(tc.err, ErrInvalidPackage) {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			require.ErrorIs(t, results[0].Err, tc.err)
+		})
+	}
+}
+
+func TestVerifyUnsigned(t *testing.T) {
+	var deb bytes.Buffer
+	require.NoError(t, Default.Package(exampleInfo(), &deb))
+
+	_, err := nfpm.Verify(&deb, packagerName, "../internal/sign/testdata/pubkey.asc")
+	require.ErrorIs(t, err, nfpm.ErrUnsigned)
+}
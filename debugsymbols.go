@@ -0,0 +1,261 @@
+package nfpm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/debuginfo"
+)
+
+// debugPackageSuffixes are appended to the name of a package to name its
+// debug package, as the distributions of each format do.
+// nolint: gochecknoglobals
+var debugPackageSuffixes = map[string]string{
+	"deb":       "-dbgsym",
+	"rpm":       "-debuginfo",
+	"apk":       "-dbg",
+	"archlinux": "-debug",
+	"ipk":       "-dbg",
+}
+
+// ErrDebugSymbolsNotSplit happens when a package enabling debug_symbols.split
+// is packaged without calling SplitDebugSymbols first.
+var ErrDebugSymbolsNotSplit = errors.New("debug_symbols.split is enabled, but the debug symbols were not split with SplitDebugSymbols")
+
+// SplitDebugSymbols strips the debug sections of the ELF files of the package,
+// if enabled, and returns the info of its debug package, which ships their
+// debug files in /usr/lib/debug, e.g.
+// /usr/lib/debug/.build-id/ab/cdef0123.debug, where debuggers look for them.
+// The stripped and the debug files are written to dir, which must outlive the
+// packaging of both packages.
+//
+// Packagers don't split the debug symbols: callers enabling
+// debug_symbols.split must call it before packaging the package, which fails
+// with ErrDebugSymbolsNotSplit otherwise, and package the debug package too.
+//
+// The debug package is named after the package with the suffix of the
+// packager, e.g. foo-dbgsym for deb, foo-debuginfo for rpm and foo-dbg for
+// apk and ipk. It returns nil if there are no debug symbols to split.
+//
+// The debug package depends on the exact version of the package, as the debug
+// symbols only match the files of the same build.
+//
+// It prepares the contents of info with PrepareForPackager.
+func SplitDebugSymbols(info *Info, packager, dir string) (*Info, error) {
+	if !info.DebugSymbols.Split {
+		return nil, nil
+	}
+	debug, err := DebugPackage(info, packager)
+	if err != nil {
+		return nil, err
+	}
+	info.debugSymbolsSplit = true
+	contents, err := files.PrepareForPackager(info.Contents, info.Umask, packager, info.DisableGlobbing, info.MTime)
+	if err != nil {
+		return nil, err
+	}
+
+	var debugContents files.Contents
+	var buildIDs []string
+	for i, content := range contents {
+		if content.Type != files.TypeFile {
+			continue
+		}
+		data, err := os.ReadFile(content.Source)
+		if err != nil {
+			return nil, err
+		}
+		split, err := debuginfo.Split(data, content.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("cannot split debug symbols of %s: %w", content.Source, err)
+		}
+		if split == nil {
+			continue
+		}
+
+		stripped := filepath.Join(dir, strconv.Itoa(i))
+		if err := os.WriteFile(stripped, split.Stripped, 0o600); err != nil {
+			return nil, err
+		}
+		debugFile := stripped + ".debug"
+		if err := os.WriteFile(debugFile, split.Debug, 0o600); err != nil {
+			return nil, err
+		}
+		debugContent := &files.Content{
+			Source:      debugFile,
+			Destination: split.Path,
+			Type:        files.TypeFile,
+			FileInfo: &files.ContentFileInfo{
+				Mode:  0o644,
+				MTime: content.FileInfo.MTime,
+			},
+		}
+		content.Source = stripped
+		content.FileInfo.Size = int64(len(split.Stripped))
+
+		// the same file may be installed several times.
+		if !containsDestination(debugContents, split.Path) {
+			debugContents = append(debugContents, debugContent)
+			if split.BuildID != "" {
+				buildIDs = append(buildIDs, split.BuildID)
+			}
+		}
+	}
+	info.Contents = contents
+	if len(debugContents) == 0 {
+		return nil, nil
+	}
+	debug.Contents = debugContents
+	if len(buildIDs) > 0 {
+		sort.Strings(buildIDs)
+		debug.Deb.Fields["Build-Ids"] = strings.Join(buildIDs, " ")
+	}
+	return debug, nil
+}
+
+// DebugPackage returns the info of the debug package SplitDebugSymbols
+// creates for info, without its contents, e.g. to name it before splitting
+// the debug symbols.
+func DebugPackage(info *Info, packager string) (*Info, error) {
+	suffix, ok := debugPackageSuffixes[packager]
+	if !ok {
+		return nil, fmt.Errorf("debug_symbols is not supported by %s", packager)
+	}
+	// archlinux debug packages are built from the same pkgbase.
+	pkgbase := info.ArchLinux.Pkgbase
+	if pkgbase == "" {
+		pkgbase = info.Name
+	}
+
+	debug := &Info{
+		Name:            info.Name + suffix,
+		Arch:            info.Arch,
+		Platform:        info.Platform,
+		Epoch:           info.Epoch,
+		Version:         info.Version,
+		VersionSchema:   "none",
+		Release:         info.Release,
+		Prerelease:      info.Prerelease,
+		VersionMetadata: info.VersionMetadata,
+		Section:         "debug",
+		Priority:        "optional",
+		Maintainer:      info.Maintainer,
+		Description:     "debug symbols for " + info.Name,
+		Vendor:          info.Vendor,
+		Homepage:        info.Homepage,
+		License:         info.License,
+		MTime:           info.MTime,
+		Overridables: Overridables{
+			Depends: Relations{fmt.Sprintf("%s (= %s)", info.Name, exactVersion(info, packager))},
+			Umask:   info.Umask,
+			Deb: Deb{
+				Arch:        info.Deb.Arch,
+				ArchVariant: info.Deb.ArchVariant,
+				Signature:   info.Deb.Signature,
+				Compression: info.Deb.Compression,
+				// the fields of the debug packages built by debhelper.
+				Fields: map[string]string{
+					"Auto-Built-Package": "debug-symbols",
+				},
+			},
+			RPM: RPM{
+				Arch:        info.RPM.Arch,
+				BuildHost:   info.RPM.BuildHost,
+				Group:       "Development/Debug",
+				Compression: info.RPM.Compression,
+				Signature:   info.RPM.Signature,
+				Packager:    info.RPM.Packager,
+			},
+			APK: APK{
+				Arch:      info.APK.Arch,
+				Signature: info.APK.Signature,
+			},
+			ArchLinux: ArchLinux{
+				Pkgbase:  pkgbase,
+				Arch:     info.ArchLinux.Arch,
+				Packager: info.ArchLinux.Packager,
+			},
+			IPK: IPK{
+				ABIVersion: info.IPK.ABIVersion,
+				Arch:       info.IPK.Arch,
+			},
+		},
+	}
+	return debug, nil
+}
+
+func containsDestination(contents files.Contents, destination string) bool {
+	for _, content := range contents {
+		if content.Destination == destination {
+			return true
+		}
+	}
+	return false
+}
+
+// exactVersion returns the full version of the package, as the packager
+// writes it, e.g. [epoch:]version[-release] for deb and rpm, which debhelper
+// and rpm debug packages depend on as ${binary:Version} and
+// %{version}-%{release}.
+func exactVersion(info *Info, packager string) string {
+	version := info.Version
+	switch packager {
+	case "apk":
+		if info.Prerelease != "" {
+			version += "_" + info.Prerelease
+		}
+		if info.Release != "" {
+			version += "-r" + strings.TrimPrefix(info.Release, "r")
+		}
+		if meta := info.VersionMetadata; meta != "" {
+			if !strings.HasPrefix(meta, "p") &&
+				!strings.HasPrefix(meta, "cvs") &&
+				!strings.HasPrefix(meta, "svn") &&
+				!strings.HasPrefix(meta, "git") &&
+				!strings.HasPrefix(meta, "hg") {
+				meta = "p" + meta
+			}
+			version += "-" + meta
+		}
+		return version
+	case "archlinux":
+		pkgrel, err := strconv.Atoi(info.Release)
+		if err != nil {
+			pkgrel = 1
+		}
+		// the .PKGINFO only holds the prerelease along with an epoch.
+		if epoch, err := strconv.ParseUint(info.Epoch, 10, 64); err == nil {
+			return fmt.Sprintf("%d:%s%s-%d", epoch, version, strings.ReplaceAll(info.Prerelease, "-", "_"), pkgrel)
+		}
+		return fmt.Sprintf("%s-%d", version, pkgrel)
+	}
+
+	// deb, ipk and rpm.
+	if info.Prerelease != "" {
+		prerelease := info.Prerelease
+		if packager == "rpm" {
+			prerelease = strings.ReplaceAll(prerelease, "-", "_")
+		}
+		version += "~" + prerelease
+	}
+	if info.VersionMetadata != "" {
+		version += "+" + info.VersionMetadata
+	}
+	release := info.Release
+	if packager == "rpm" && release == "" {
+		release = "1"
+	}
+	if release != "" {
+		version += "-" + release
+	}
+	if info.Epoch != "" {
+		version = info.Epoch + ":" + version
+	}
+	return version
+}
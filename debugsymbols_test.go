@@ -0,0 +1,147 @@
+package nfpm_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+func debugSymbolsInfo() *nfpm.Info {
+	return nfpm.WithDefaults(&nfpm.Info{
+		Name:    "foo",
+		Version: "1.2.3",
+		Overridables: nfpm.Overridables{
+			DebugSymbols: nfpm.DebugSymbols{Split: true},
+			Contents: files.Contents{
+				{
+					Source:      "./internal/debuginfo/testdata/hello",
+					Destination: "/usr/bin/hello",
+				},
+				{
+					Source:      "./internal/debuginfo/testdata/hello",
+					Destination: "/usr/libexec/hello",
+				},
+				{
+					Source:      "./internal/shlibs/testdata/bar",
+					Destination: "/usr/bin/bar",
+				},
+				{
+					Source:      "./internal/debuginfo/testdata/hello.c",
+					Destination: "/usr/share/doc/foo/hello.c",
+				},
+			},
+		},
+	})
+}
+
+func TestSplitDebugSymbols(t *testing.T) {
+	for packager, tc := range map[string]struct {
+		name, depends string
+	}{
+		"deb":       {"foo-dbgsym", "foo (= 1.2.3)"},
+		"rpm":       {"foo-debuginfo", "foo (= 1.2.3-1)"},
+		"apk":       {"foo-dbg", "foo (= 1.2.3)"},
+		"archlinux": {"foo-debug", "foo (= 1.2.3-1)"},
+		"ipk":       {"foo-dbg", "foo (= 1.2.3)"},
+	} {
+		t.Run(packager, func(t *testing.T) {
+			info := debugSymbolsInfo()
+			dir := t.TempDir()
+			debug, err := nfpm.SplitDebugSymbols(info, packager, dir)
+			require.NoError(t, err)
+			require.NotNil(t, debug)
+			require.Equal(t, tc.name, debug.Name)
+			require.Equal(t, "1.2.3", debug.Version)
+			require.Equal(t, nfpm.Relations{tc.depends}, debug.Depends)
+
+			const debugFile = "/usr/lib/debug/.build-id/fb/9085a2478dcb078728c9e9473121d63a8a55a0.debug"
+			require.Len(t, debug.Contents, 1)
+			require.Equal(t, debugFile, debug.Contents[0].Destination)
+			require.Equal(t, dir, filepath.Dir(debug.Contents[0].Source))
+
+			sources := map[string]string{}
+			for _, content := range info.Contents {
+				if content.Type == files.TypeFile {
+					sources[content.Destination] = content.Source
+				}
+			}
+			require.Equal(t, filepath.Dir(sources["/usr/bin/hello"]), dir)
+			require.Equal(t, filepath.Dir(sources["/usr/libexec/hello"]), dir)
+			require.Equal(t, "internal/shlibs/testdata/bar", sources["/usr/bin/bar"])
+			require.Equal(t, "internal/debuginfo/testdata/hello.c", sources["/usr/share/doc/foo/hello.c"])
+
+			stripped, err := os.Stat(sources["/usr/bin/hello"])
+			require.NoError(t, err)
+			original, err := os.Stat("./internal/debuginfo/testdata/hello")
+			require.NoError(t, err)
+			require.Less(t, stripped.Size(), original.Size())
+			symbols, err := os.Stat(debug.Contents[0].Source)
+			require.NoError(t, err)
+			require.Less(t, symbols.Size(), original.Size())
+		})
+	}
+}
+
+func TestSplitDebugSymbolsDebFields(t *testing.T) {
+	debug, err := nfpm.SplitDebugSymbols(debugSymbolsInfo(), "deb", t.TempDir())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"Auto-Built-Package": "debug-symbols",
+		"Build-Ids":          "fb9085a2478dcb078728c9e9473121d63a8a55a0",
+	}, debug.Deb.Fields)
+	require.Equal(t, "debug", debug.Section)
+}
+
+func TestSplitDebugSymbolsExactVersion(t *testing.T) {
+	for packager, depends := range map[string]string{
+		"deb":       "foo (= 2:1.2.3~rc-1-3)",
+		"rpm":       "foo (= 2:1.2.3~rc_1-3)",
+		"apk":       "foo (= 1.2.3_rc-1-r3)",
+		"archlinux": "foo (= 2:1.2.3rc_1-3)",
+		"ipk":       "foo (= 2:1.2.3~rc-1-3)",
+	} {
+		t.Run(packager, func(t *testing.T) {
+			info := debugSymbolsInfo()
+			info.Epoch, info.Prerelease, info.Release = "2", "rc-1", "3"
+			debug, err := nfpm.SplitDebugSymbols(info, packager, t.TempDir())
+			require.NoError(t, err)
+			require.Equal(t, nfpm.Relations{depends}, debug.Depends)
+		})
+	}
+}
+
+func TestSplitDebugSymbolsDisabled(t *testing.T) {
+	info := debugSymbolsInfo()
+	info.DebugSymbols.Split = false
+	debug, err := nfpm.SplitDebugSymbols(info, "deb", t.TempDir())
+	require.NoError(t, err)
+	require.Nil(t, debug)
+	require.Equal(t, debugSymbolsInfo().Contents, info.Contents)
+}
+
+func TestSplitDebugSymbolsNothingToSplit(t *testing.T) {
+	info := debugSymbolsInfo()
+	info.Contents = info.Contents[2:]
+	debug, err := nfpm.SplitDebugSymbols(info, "deb", t.TempDir())
+	require.NoError(t, err)
+	require.Nil(t, debug)
+}
+
+func TestSplitDebugSymbolsUnsupported(t *testing.T) {
+	_, err := nfpm.SplitDebugSymbols(debugSymbolsInfo(), "msix", t.TempDir())
+	require.EqualError(t, err, "debug_symbols is not supported by msix")
+}
+
+func TestPrepareForPackagerDebugSymbolsNotSplit(t *testing.T) {
+	info := debugSymbolsInfo()
+	info.Arch = "amd64"
+	require.ErrorIs(t, nfpm.PrepareForPackager(info, "deb"), nfpm.ErrDebugSymbolsNotSplit)
+
+	_, err := nfpm.SplitDebugSymbols(info, "deb", t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, nfpm.PrepareForPackager(info, "deb"))
+}
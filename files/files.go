@@ -1,6 +1,7 @@
 package files
 
 import (
+	"archive/tar"
 	"fmt"
 	"io/fs"
 	"os"
@@ -75,6 +76,10 @@ type Content struct {
 	FileInfo      *ContentFileInfo `yaml:"file_info,omitempty" json:"file_info,omitempty"`
 	Expand        bool             `yaml:"expand,omitempty" json:"expand,omitempty"`
 	DisownSubtree []string         `yaml:"disown_subtree,omitempty" json:"disown_subtree,omitempty"`
+
+	// globbed is set by ExpandGlobs on the files it matched, whose sources
+	// must not be globbed again.
+	globbed bool
 }
 
 type ContentFileInfo struct {
@@ -242,6 +247,57 @@ func (c *Content) String() string {
 	return fmt.Sprintf("Content(%s)", strings.Join(properties, ","))
 }
 
+// ExpandGlobs returns the contents with the files matched by the glob of each
+// file and config content, one content per file, as PrepareForPackager
+// matches them. The globs are matched once, so the contents can be prepared
+// for several packagers without matching them again.
+//
+// The contents whose globs fail are left as they are, so PrepareForPackager
+// reports the error if a packager needs them.
+func ExpandGlobs(contents Contents, disableGlobbing bool) Contents {
+	expanded := make(Contents, 0, len(contents))
+	for _, content := range contents {
+		switch content.Type {
+		case TypeConfig, TypeConfigNoReplace, TypeConfigMissingOK, TypeFile, "":
+		default:
+			expanded = append(expanded, content)
+			continue
+		}
+		if content.globbed {
+			expanded = append(expanded, content)
+			continue
+		}
+		globbed, err := glob.Glob(
+			filepath.ToSlash(content.Source),
+			filepath.ToSlash(content.Destination),
+			disableGlobbing,
+		)
+		if err != nil {
+			expanded = append(expanded, content)
+			continue
+		}
+
+		sources := make([]string, 0, len(globbed))
+		for src := range globbed {
+			sources = append(sources, src)
+		}
+		sort.Strings(sources)
+		for _, src := range sources {
+			file := *content
+			if file.FileInfo != nil {
+				fileInfo := *file.FileInfo
+				fileInfo.Size = 0
+				file.FileInfo = &fileInfo
+			}
+			file.Source = ToNixPath(src)
+			file.Destination = NormalizeAbsoluteFilePath(globbed[src])
+			file.globbed = true
+			expanded = append(expanded, &file)
+		}
+	}
+	return expanded
+}
+
 // PrepareForPackager performs the following steps to prepare the contents for
 // the provided packager:
 //
@@ -326,7 +382,7 @@ func PrepareForPackager(
 			globbed, err := glob.Glob(
 				filepath.ToSlash(content.Source),
 				filepath.ToSlash(content.Destination),
-				disableGlobbing,
+				disableGlobbing || content.globbed,
 			)
 			if err != nil {
 				return nil, err
@@ -647,3 +703,37 @@ func NormalizeAbsoluteFilePath(src string) string {
 func NormalizeAbsoluteDirPath(path string) string {
 	return NormalizeAbsoluteFilePath(strings.TrimRight(path, "/")) + "/"
 }
+
+// FromTarHeader converts the header of an entry of a package payload back into
+// a Content, as needed when inspecting existing packages. Symlinks keep their
+// target as the source. It returns nil for the archive root and for entry
+// types nfpm cannot produce.
+func FromTarHeader(hdr *tar.Header) *Content {
+	dst := NormalizeAbsoluteFilePath(hdr.Name)
+	if dst == "/" {
+		return nil
+	}
+
+	content := &Content{
+		Destination: dst,
+		FileInfo: &ContentFileInfo{
+			Owner: hdr.Uname,
+			Group: hdr.Gname,
+			Mode:  hdr.FileInfo().Mode() &^ fs.ModeType,
+			MTime: hdr.ModTime,
+			Size:  hdr.Size,
+		},
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		content.Type = TypeDir
+	case tar.TypeSymlink:
+		content.Type = TypeSymlink
+		content.Source = hdr.Linkname
+	case tar.TypeReg:
+		content.Type = TypeFile
+	default:
+		return nil
+	}
+	return content
+}
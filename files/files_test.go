@@ -1,6 +1,7 @@
 package files_test
 
 import (
+	"archive/tar"
 	"fmt"
 	"io/fs"
 	"os"
@@ -468,6 +469,62 @@ func TestGlobbingWhenFilesHaveBrackets(t *testing.T) {
 	}
 }
 
+func TestExpandGlobs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("doesn't work on windows")
+	}
+	fileInfo := &files.ContentFileInfo{Mode: 0o600}
+	contents := files.Contents{
+		{
+			Source:      "./testdata/\\{test\\}/",
+			Destination: "/etc/test",
+			Type:        files.TypeConfig,
+			FileInfo:    fileInfo,
+		},
+		{
+			Source:      "./testdata/missing/*",
+			Destination: "/usr/share/missing",
+			Packager:    "rpm",
+		},
+		{
+			Source:      "foo",
+			Destination: "/usr/bin/bar",
+			Type:        files.TypeSymlink,
+		},
+	}
+	expanded := files.ExpandGlobs(contents, false)
+	require.Len(t, expanded, 4)
+	require.Equal(t, "testdata/{test}/[f]oo", expanded[0].Source)
+	require.Equal(t, "/etc/test/[f]oo", expanded[0].Destination)
+	require.Equal(t, "testdata/{test}/bar", expanded[1].Source)
+	require.Equal(t, "/etc/test/bar", expanded[1].Destination)
+	for _, content := range expanded[:2] {
+		require.Equal(t, files.TypeConfig, content.Type)
+		require.Equal(t, fileInfo, content.FileInfo)
+		require.NotSame(t, fileInfo, content.FileInfo)
+	}
+	// the contents that can't be globbed, or aren't globbed, are kept.
+	require.Same(t, contents[1], expanded[2])
+	require.Same(t, contents[2], expanded[3])
+
+	// the matched files aren't globbed again, even with brackets in their name.
+	result, err := files.PrepareForPackager(expanded[:2], 0, "deb", false, mtime)
+	require.NoError(t, err)
+	var sources []string
+	for _, content := range result {
+		if content.Type == files.TypeConfig {
+			sources = append(sources, content.Source+" "+content.Destination)
+		}
+	}
+	require.Equal(t, []string{
+		"testdata/{test}/[f]oo /etc/test/[f]oo",
+		"testdata/{test}/bar /etc/test/bar",
+	}, sources)
+
+	_, err = files.PrepareForPackager(expanded, 0, "rpm", false, mtime)
+	require.Error(t, err)
+}
+
 func TestGlobbingFilesWithDifferentSizesWithFileInfo(t *testing.T) {
 	result, err := files.PrepareForPackager(
 		files.Contents{
@@ -1321,3 +1378,43 @@ func TestTreeWithDisown(t *testing.T) {
 		require.Equal(t, expect[file.Destination], file.Type, "invalid type for %s", file.Destination)
 	}
 }
+
+func TestFromTarHeader(t *testing.T) {
+	require.Nil(t, files.FromTarHeader(&tar.Header{Name: "./", Typeflag: tar.TypeDir}))
+	require.Nil(t, files.FromTarHeader(&tar.Header{Name: "./dev/null", Typeflag: tar.TypeChar}))
+
+	c := files.FromTarHeader(&tar.Header{
+		Name:     "./usr/bin/fake",
+		Typeflag: tar.TypeReg,
+		Mode:     0o4755,
+		Uname:    "root",
+		Gname:    "wheel",
+		Size:     42,
+		ModTime:  mtime,
+	})
+	require.Equal(t, &files.Content{
+		Destination: "/usr/bin/fake",
+		Type:        files.TypeFile,
+		FileInfo: &files.ContentFileInfo{
+			Owner: "root",
+			Group: "wheel",
+			Mode:  0o755 | fs.ModeSetuid,
+			MTime: mtime,
+			Size:  42,
+		},
+	}, c)
+
+	c = files.FromTarHeader(&tar.Header{
+		Name:     "usr/bin/link",
+		Typeflag: tar.TypeSymlink,
+		Linkname: "fake",
+		Mode:     0o777,
+	})
+	require.Equal(t, files.TypeSymlink, c.Type)
+	require.Equal(t, "fake", c.Source)
+	require.Equal(t, "/usr/bin/link", c.Destination)
+
+	c = files.FromTarHeader(&tar.Header{Name: "./etc/", Typeflag: tar.TypeDir, Mode: 0o755})
+	require.Equal(t, files.TypeDir, c.Type)
+	require.Equal(t, "/etc", c.Destination)
+}
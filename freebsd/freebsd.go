@@ -0,0 +1,396 @@
+// Package freebsd implements nfpm.Packager providing FreeBSD .pkg bindings.
+//
+// A FreeBSD package is a compressed tarball starting with the
+// +COMPACT_MANIFEST and +MANIFEST files, which describe the package in UCL, a
+// superset of JSON, followed by the contents under their absolute paths. See
+// pkg-create(8) and https://github.com/freebsd/pkg.
+package freebsd
+
+import (
+	"archive/tar"
+	"cmp"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const packagerName = "freebsd"
+
+// prefix is the installation prefix of the packages, under which pkg resolves
+// the relative paths of the manifest.
+const prefix = "/usr/local"
+
+// nolint: gochecknoinits
+func init() {
+	nfpm.RegisterPackager(packagerName, Default)
+	nfpm.RegisterReader(packagerName, Default)
+}
+
+// nolint: gochecknoglobals
+var archToFreeBSD = map[string]string{
+	"all":     "*",
+	"386":     "i386",
+	"i386":    "i386",
+	"amd64":   "amd64",
+	"x86_64":  "amd64",
+	"arm64":   "aarch64",
+	"aarch64": "aarch64",
+	"arm6":    "armv6",
+	"arm7":    "armv7",
+	"ppc64":   "powerpc64",
+	"ppc64le": "powerpc64le",
+	"riscv64": "riscv64",
+}
+
+// pkg tells the versions apart from the package names, port revisions and
+// epochs with these characters.
+// nolint: gochecknoglobals
+var versionReplacer = strings.NewReplacer("-", ".", "_", ".", ",", ".")
+
+func ensureValidArch(info *nfpm.Info) *nfpm.Info {
+	if info.FreeBSD.Arch != "" {
+		info.Arch = info.FreeBSD.Arch
+	} else if arch, ok := archToFreeBSD[info.Arch]; ok {
+		info.Arch = arch
+	}
+
+	return info
+}
+
+// Default FreeBSD packager.
+// nolint: gochecknoglobals
+var Default = &FreeBSD{}
+
+// FreeBSD is a FreeBSD pkg packager implementation.
+type FreeBSD struct{}
+
+// ConventionalFileName returns a file name according to the conventions for
+// FreeBSD packages: name-version.pkg.
+func (*FreeBSD) ConventionalFileName(info *nfpm.Info) string {
+	return fmt.Sprintf("%s-%s.pkg", info.Name, pkgVersion(info))
+}
+
+// ConventionalExtension returns the file name conventionally used for
+// FreeBSD packages.
+func (*FreeBSD) ConventionalExtension() string {
+	return ".pkg"
+}
+
+// pkgVersion returns the version of the package in the FreeBSD syntax:
+// version_revision,epoch, where the prerelease is a part of the version, e.g.
+// 1.2.3.rc1_1,2.
+func pkgVersion(info *nfpm.Info) string {
+	version := info.Version
+	if info.Prerelease != "" {
+		version += "." + versionReplacer.Replace(info.Prerelease)
+	}
+	if info.Release != "" && info.Release != "0" {
+		version += "_" + info.Release
+	}
+	if info.Epoch != "" && info.Epoch != "0" {
+		version += "," + info.Epoch
+	}
+	return version
+}
+
+// Package writes a new FreeBSD package to the given writer using the given
+// info.
+func (*FreeBSD) Package(info *nfpm.Info, w io.Writer) error {
+	info = ensureValidArch(info)
+
+	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
+		return err
+	}
+	if err := nfpm.PrepareAutoDepends(info, packagerName); err != nil {
+		return err
+	}
+	if err := nfpm.PrepareRelations(info, packagerName, func(arch string) bool {
+		return arch == info.Arch || archToFreeBSD[arch] == info.Arch
+	}); err != nil {
+		return err
+	}
+
+	m, err := newManifest(info)
+	if err != nil {
+		return err
+	}
+
+	compressor, err := newCompressor(w, info.FreeBSD.Compression)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(compressor)
+	mtime := modtime.Get(info.MTime)
+
+	// pkg reads the manifests before the contents, they must come first.
+	compact := m.compact()
+	for _, entry := range []struct {
+		name string
+		m    *manifest
+	}{
+		{"+COMPACT_MANIFEST", &compact},
+		{"+MANIFEST", m},
+	} {
+		content, err := json.Marshal(entry.m)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(tw, &tar.Header{
+			Name:    entry.name,
+			Mode:    0o644,
+			Uname:   "root",
+			Gname:   "wheel",
+			ModTime: mtime,
+		}, content); err != nil {
+			return err
+		}
+	}
+
+	if err := writeContents(tw, info.Contents); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return compressor.Close()
+}
+
+// manifest is the manifest of a FreeBSD package, in the format of
+// pkg-create(8).
+type manifest struct {
+	Name         string                `json:"name"`
+	Origin       string                `json:"origin"`
+	Version      string                `json:"version"`
+	Comment      string                `json:"comment"`
+	Maintainer   string                `json:"maintainer"`
+	WWW          string                `json:"www"`
+	ABI          string                `json:"abi"`
+	Prefix       string                `json:"prefix"`
+	Flatsize     int64                 `json:"flatsize"`
+	LicenseLogic string                `json:"licenselogic,omitempty"`
+	Licenses     []string              `json:"licenses,omitempty"`
+	Desc         string                `json:"desc"`
+	Categories   []string              `json:"categories,omitempty"`
+	Deps         map[string]dependency `json:"deps,omitempty"`
+	Conflicts    []string              `json:"conflicts,omitempty"`
+	Provides     []string              `json:"provides,omitempty"`
+	Users        []string              `json:"users,omitempty"`
+	Groups       []string              `json:"groups,omitempty"`
+	Files        map[string]string     `json:"files,omitempty"`
+	Directories  map[string]string     `json:"directories,omitempty"`
+	Config       []string              `json:"config,omitempty"`
+	Scripts      map[string]string     `json:"scripts,omitempty"`
+}
+
+// dependency is a dependency of a FreeBSD package, keyed by its name.
+type dependency struct {
+	Origin  string `json:"origin"`
+	Version string `json:"version"`
+}
+
+// compact returns the +COMPACT_MANIFEST, which omits the contents and the
+// scripts of the package.
+func (m manifest) compact() manifest {
+	m.Files, m.Directories, m.Config, m.Scripts = nil, nil, nil, nil
+	return m
+}
+
+func newManifest(info *nfpm.Info) (*manifest, error) {
+	origin := cmp.Or(info.FreeBSD.Origin, "misc/"+info.Name)
+	description := strings.TrimSpace(info.Description)
+	m := &manifest{
+		Name:        info.Name,
+		Origin:      origin,
+		Version:     pkgVersion(info),
+		Comment:     strings.TrimSpace(strings.SplitN(description, "\n", 2)[0]),
+		Maintainer:  maintainer(info.Maintainer),
+		WWW:         info.Homepage,
+		ABI:         cmp.Or(info.FreeBSD.ABI, "FreeBSD:*:"+info.Arch),
+		Prefix:      prefix,
+		Desc:        description,
+		Conflicts:   info.Conflicts,
+		Provides:    info.Provides,
+		Files:       map[string]string{},
+		Directories: map[string]string{},
+	}
+	if category := path.Dir(origin); category != "." {
+		m.Categories = []string{category}
+	}
+	if info.License != "" {
+		m.LicenseLogic = "single"
+		m.Licenses = []string{info.License}
+	}
+	for _, depend := range info.Depends {
+		name, dep := parseDependency(depend)
+		if m.Deps == nil {
+			m.Deps = map[string]dependency{}
+		}
+		m.Deps[name] = dep
+	}
+	for _, user := range info.Users {
+		m.Users = append(m.Users, user.Name)
+		m.Groups = append(m.Groups, user.Name)
+	}
+	for _, group := range info.Groups {
+		m.Groups = append(m.Groups, group.Name)
+	}
+
+	for _, content := range info.Contents {
+		dst := files.NormalizeAbsoluteFilePath(content.Destination)
+		switch content.Type {
+		case files.TypeDir:
+			// y tells pkg to remove the directory if it is empty.
+			m.Directories[dst] = "y"
+		case files.TypeSymlink:
+			m.Files[dst] = checksum([]byte(content.Source))
+		case files.TypeFile, files.TypeConfig, files.TypeConfigNoReplace, files.TypeConfigMissingOK:
+			data, err := os.ReadFile(content.Source)
+			if err != nil {
+				return nil, err
+			}
+			m.Files[dst] = checksum(data)
+			m.Flatsize += int64(len(data))
+			if content.Type != files.TypeFile {
+				m.Config = append(m.Config, dst)
+			}
+		}
+	}
+
+	scripts, err := nfpm.MaintainerScripts(info, packagerName)
+	if err != nil {
+		return nil, err
+	}
+	for name, script := range scripts {
+		if m.Scripts == nil {
+			m.Scripts = map[string]string{}
+		}
+		m.Scripts[name] = string(script)
+	}
+	return m, nil
+}
+
+// parseDependency returns the name and the dependency of a relation in the
+// apk syntax, e.g. foo>=1.2. The relation may be given as the origin of the
+// dependency in the ports tree, e.g. shells/bash, which defaults to its name
+// otherwise, as pkg resolves the dependencies by name.
+func parseDependency(relation string) (string, dependency) {
+	name, version := relation, ""
+	if i := strings.IndexAny(relation, "<>="); i >= 0 {
+		name, version = relation[:i], strings.TrimLeft(relation[i:], "<>=")
+	}
+	return path.Base(name), dependency{
+		Origin:  name,
+		Version: version,
+	}
+}
+
+// maintainer returns the email address of the maintainer, which is how pkg
+// identifies them.
+func maintainer(s string) string {
+	if address, err := mail.ParseAddress(s); err == nil {
+		return address.Address
+	}
+	return s
+}
+
+// checksum returns the SHA256 checksum of the data, in the hexadecimal format
+// of pkg, which prefixes it with 1$.
+func checksum(data []byte) string {
+	return fmt.Sprintf("1$%x", sha256.Sum256(data))
+}
+
+// group returns the group on FreeBSD, where the group of root is wheel.
+func group(name string) string {
+	if name == "root" {
+		return "wheel"
+	}
+	return name
+}
+
+// writeContents writes the contents to the tarball, skipping the implicit
+// directories, which pkg creates as needed.
+func writeContents(tw *tar.Writer, contents files.Contents) error {
+	for _, content := range contents {
+		header := &tar.Header{
+			Name:    files.NormalizeAbsoluteFilePath(content.Destination),
+			Mode:    int64(content.Mode()),
+			Uname:   content.FileInfo.Owner,
+			Gname:   group(content.FileInfo.Group),
+			ModTime: content.ModTime(),
+		}
+		switch content.Type {
+		case files.TypeDir:
+			header.Typeflag = tar.TypeDir
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+		case files.TypeSymlink:
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = content.Source
+			header.Mode = 0o777
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+		case files.TypeFile, files.TypeConfig, files.TypeConfigNoReplace, files.TypeConfigMissingOK:
+			data, err := os.ReadFile(content.Source)
+			if err != nil {
+				return err
+			}
+			if err := writeFile(tw, header, data); err != nil {
+				return err
+			}
+		default:
+			// ignore everything else
+		}
+	}
+	return nil
+}
+
+// writeFile writes a regular file to the tarball.
+func writeFile(tw *tar.Writer, header *tar.Header, data []byte) error {
+	header.Typeflag = tar.TypeReg
+	header.Size = int64(len(data))
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("cannot write header of %s: %w", header.Name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("cannot write %s: %w", header.Name, err)
+	}
+	return nil
+}
+
+// newCompressor returns the writer compressing the tarball with the given
+// algorithm, zstd by default, as pkg does.
+func newCompressor(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case "", "zstd":
+		return zstd.NewWriter(w)
+	case "xz":
+		return xz.NewWriter(w)
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "none":
+		return nopCloser{w}, nil
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm: %s", compression)
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,220 @@
+package freebsd
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+var mtime = time.Date(2023, 11, 5, 23, 15, 17, 0, time.UTC)
+
+func exampleInfo() *nfpm.Info {
+	return nfpm.WithDefaults(&nfpm.Info{
+		Name:        "foo",
+		Arch:        "amd64",
+		Description: "Foo does things\nand more things",
+		Maintainer:  "Carlos A Becker <pkg@carlosbecker.com>",
+		Version:     "v1.0.0",
+		Prerelease:  "rc-1",
+		Release:     "2",
+		Homepage:    "http://carlosbecker.com",
+		License:     "MIT",
+		MTime:       mtime,
+		Overridables: nfpm.Overridables{
+			Depends: []string{
+				"bash (>= 5.0)",
+				"shells/zsh",
+			},
+			Conflicts: []string{
+				"foo-legacy",
+			},
+			Contents: []*files.Content{
+				{
+					Source:      "../testdata/fake",
+					Destination: "/usr/local/bin/fake",
+				},
+				{
+					Source:      "../testdata/whatever.conf",
+					Destination: "/usr/local/etc/fake.conf",
+					Type:        files.TypeConfig,
+				},
+				{
+					Source:      "/usr/local/bin/fake",
+					Destination: "/usr/local/bin/fake-link",
+					Type:        files.TypeSymlink,
+				},
+				{
+					Destination: "/var/db/foo",
+					Type:        files.TypeDir,
+					FileInfo: &files.ContentFileInfo{
+						Owner: "foo",
+						Group: "foo",
+					},
+				},
+			},
+			Users: []nfpm.User{
+				{Name: "foo", UID: 1234},
+			},
+			FreeBSD: nfpm.FreeBSD{
+				Origin: "sysutils/foo",
+			},
+		},
+	})
+}
+
+// readPackage returns the entries of the package and its manifests.
+func readPackage(t *testing.T, pkg []byte) ([]*tar.Header, map[string][]byte) {
+	t.Helper()
+	r, err := decompress(bytes.NewReader(pkg))
+	require.NoError(t, err)
+	var headers []*tar.Header
+	bodies := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return headers, bodies
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		headers = append(headers, hdr)
+		bodies[hdr.Name] = body
+	}
+}
+
+func TestConventionalFileName(t *testing.T) {
+	info := exampleInfo()
+	require.Equal(t, "foo-1.0.0.rc.1_2.pkg", Default.ConventionalFileName(info))
+	info.Epoch = "3"
+	info.Release = ""
+	require.Equal(t, "foo-1.0.0.rc.1,3.pkg", Default.ConventionalFileName(info))
+	require.Equal(t, ".pkg", Default.ConventionalExtension())
+}
+
+func TestPackage(t *testing.T) {
+	var pkg bytes.Buffer
+	require.NoError(t, Default.Package(exampleInfo(), &pkg))
+
+	headers, bodies := readPackage(t, pkg.Bytes())
+	require.Equal(t, "+COMPACT_MANIFEST", headers[0].Name)
+	require.Equal(t, "+MANIFEST", headers[1].Name)
+
+	var m manifest
+	require.NoError(t, json.Unmarshal(bodies["+MANIFEST"], &m))
+	require.Equal(t, "foo", m.Name)
+	require.Equal(t, "sysutils/foo", m.Origin)
+	require.Equal(t, "1.0.0.rc.1_2", m.Version)
+	require.Equal(t, "Foo does things", m.Comment)
+	require.Equal(t, "Foo does things\nand more things", m.Desc)
+	require.Equal(t, "pkg@carlosbecker.com", m.Maintainer)
+	require.Equal(t, "FreeBSD:*:amd64", m.ABI)
+	require.Equal(t, []string{"sysutils"}, m.Categories)
+	require.Equal(t, []string{"MIT"}, m.Licenses)
+	require.Equal(t, map[string]dependency{
+		"bash": {Origin: "bash", Version: "5.0"},
+		"zsh":  {Origin: "shells/zsh"},
+	}, m.Deps)
+	require.Equal(t, []string{"foo-legacy"}, m.Conflicts)
+	require.Equal(t, []string{"foo"}, m.Users)
+	require.Equal(t, []string{"foo"}, m.Groups)
+	require.Equal(t, []string{"/usr/local/etc/fake.conf"}, m.Config)
+	require.Equal(t, map[string]string{"/var/db/foo": "y"}, m.Directories)
+
+	fake, err := os.ReadFile("../testdata/fake")
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("1$%x", sha256.Sum256(fake)), m.Files["/usr/local/bin/fake"])
+	require.Equal(t, fmt.Sprintf("1$%x", sha256.Sum256([]byte("/usr/local/bin/fake"))), m.Files["/usr/local/bin/fake-link"])
+	require.Equal(t, fake, bodies["/usr/local/bin/fake"])
+	require.Contains(t, m.Scripts["pre-install"], "pw useradd -n foo -g foo -d / -s /usr/sbin/nologin -u 1234")
+
+	var compact manifest
+	require.NoError(t, json.Unmarshal(bodies["+COMPACT_MANIFEST"], &compact))
+	require.Equal(t, m.compact(), compact)
+
+	for _, hdr := range headers {
+		switch hdr.Name {
+		case "/usr/local/bin/fake":
+			require.Equal(t, "root", hdr.Uname)
+			require.Equal(t, "wheel", hdr.Gname)
+			require.Equal(t, mtime, hdr.ModTime.UTC())
+		case "/var/db/foo":
+			require.Equal(t, byte(tar.TypeDir), hdr.Typeflag)
+			require.Equal(t, "foo", hdr.Uname)
+		case "/usr/local", "/var", "/var/db":
+			require.Fail(t, "implicit directory in the package", hdr.Name)
+		}
+	}
+}
+
+func TestPackageCompression(t *testing.T) {
+	for compression, magic := range map[string][]byte{
+		"":     {0x28, 0xb5, 0x2f, 0xfd},
+		"zstd": {0x28, 0xb5, 0x2f, 0xfd},
+		"xz":   {0xfd, '7', 'z', 'X', 'Z', 0x00},
+		"gzip": {0x1f, 0x8b},
+		"none": []byte("+COMPACT_MANIFEST"),
+	} {
+		t.Run(compression, func(t *testing.T) {
+			info := exampleInfo()
+			info.FreeBSD.Compression = compression
+			var pkg bytes.Buffer
+			require.NoError(t, Default.Package(info, &pkg))
+			require.True(t, bytes.HasPrefix(pkg.Bytes(), magic))
+			headers, _ := readPackage(t, pkg.Bytes())
+			require.Equal(t, "+MANIFEST", headers[1].Name)
+		})
+	}
+
+	info := exampleInfo()
+	info.FreeBSD.Compression = "lzma"
+	require.EqualError(t, Default.Package(info, &bytes.Buffer{}), "unknown compression algorithm: lzma")
+}
+
+func TestPackageArch(t *testing.T) {
+	for arch, abi := range map[string]string{
+		"arm64": "FreeBSD:*:aarch64",
+		"386":   "FreeBSD:*:i386",
+		"all":   "FreeBSD:*:*",
+	} {
+		info := exampleInfo()
+		info.Arch = arch
+		m, err := newManifest(ensureValidArch(info))
+		require.NoError(t, err)
+		require.Equal(t, abi, m.ABI)
+	}
+
+	info := exampleInfo()
+	info.FreeBSD.ABI = "FreeBSD:14:amd64"
+	m, err := newManifest(ensureValidArch(info))
+	require.NoError(t, err)
+	require.Equal(t, "FreeBSD:14:amd64", m.ABI)
+}
+
+func TestPackageScripts(t *testing.T) {
+	info := exampleInfo()
+	info.Users = nil
+	info.Contents = info.Contents[:3]
+	info.Scripts.PostUpgrade = "../testdata/scripts/postupgrade.sh"
+	info.Scripts.PreRemove = "../testdata/scripts/preremove.sh"
+	require.NoError(t, nfpm.PrepareForPackager(info, packagerName))
+	m, err := newManifest(info)
+	require.NoError(t, err)
+
+	require.NotContains(t, m.Scripts, "pre-install")
+	require.Contains(t, m.Scripts["post-install"], `if [ "${PKG_UPGRADE:-}" = true ]; then`)
+	preremove, err := os.ReadFile(info.Scripts.PreRemove)
+	require.NoError(t, err)
+	require.Equal(t, string(preremove), m.Scripts["pre-deinstall"])
+}
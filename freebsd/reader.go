@@ -0,0 +1,170 @@
+package freebsd
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/maps"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// ErrInvalidPackage happens when a file cannot be read as a FreeBSD package.
+var ErrInvalidPackage = errors.New("invalid freebsd package")
+
+// maps the manifest script names to the nfpm script names.
+// nolint: gochecknoglobals
+var manifestScripts = map[string]string{
+	"pre-install":    "preinstall",
+	"post-install":   "postinstall",
+	"pre-deinstall":  "preremove",
+	"post-deinstall": "postremove",
+}
+
+// Read implements nfpm.Reader. The manifest is expected in JSON, as written by
+// nfpm and pkg-create(8), and the tarball may be compressed with zstd, xz or
+// gzip, or not at all.
+func (*FreeBSD) Read(r io.Reader) (*nfpm.Inspection, error) {
+	decompressed, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var m *manifest
+	var contents files.Contents
+	tr := tar.NewReader(decompressed)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+		switch hdr.Name {
+		case "+MANIFEST":
+			m = &manifest{}
+			if err := json.NewDecoder(tr).Decode(m); err != nil {
+				return nil, fmt.Errorf("cannot parse +MANIFEST: %w", err)
+			}
+		case "+COMPACT_MANIFEST":
+			// a subset of the +MANIFEST.
+		default:
+			if content := files.FromTarHeader(hdr); content != nil {
+				contents = append(contents, content)
+			}
+		}
+	}
+	if m == nil {
+		return nil, fmt.Errorf("%w: missing +MANIFEST", ErrInvalidPackage)
+	}
+
+	result := &nfpm.Inspection{
+		Format: packagerName,
+		Info:   infoFromManifest(m),
+	}
+	config := map[string]bool{}
+	for _, path := range m.Config {
+		config[path] = true
+	}
+	for _, content := range contents {
+		if content.Type == files.TypeFile && config[content.Destination] {
+			content.Type = files.TypeConfig
+		}
+	}
+	sort.Sort(contents)
+	result.Info.Contents = contents
+	for name, script := range m.Scripts {
+		if nfpmName, ok := manifestScripts[name]; ok {
+			if result.Scripts == nil {
+				result.Scripts = map[string]string{}
+			}
+			result.Scripts[nfpmName] = script
+		}
+	}
+	return result, nil
+}
+
+// decompress returns the tarball of the package, detecting its compression
+// from its magic bytes.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(6)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+		return zr, nil
+	case bytes.HasPrefix(magic, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+		return xr, nil
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+		return gr, nil
+	default:
+		return br, nil
+	}
+}
+
+func infoFromManifest(m *manifest) *nfpm.Info {
+	info := &nfpm.Info{
+		Name:        m.Name,
+		Platform:    "freebsd",
+		Description: m.Desc,
+		Maintainer:  m.Maintainer,
+		Homepage:    m.WWW,
+		License:     strings.Join(m.Licenses, " "),
+	}
+	info.Version, info.Release, info.Epoch = splitVersion(m.Version)
+	if i := strings.LastIndex(m.ABI, ":"); i >= 0 {
+		info.Arch = m.ABI[i+1:]
+	}
+	info.FreeBSD.ABI = m.ABI
+	info.FreeBSD.Origin = m.Origin
+	for _, name := range maps.Keys(m.Deps) {
+		depend := name
+		if version := m.Deps[name].Version; version != "" {
+			depend += " (>= " + version + ")"
+		}
+		info.Depends = append(info.Depends, depend)
+	}
+	info.Conflicts = m.Conflicts
+	info.Provides = m.Provides
+	for _, user := range m.Users {
+		info.Users = append(info.Users, nfpm.User{Name: user})
+	}
+	for _, group := range m.Groups {
+		info.Groups = append(info.Groups, nfpm.Group{Name: group})
+	}
+	return info
+}
+
+// splitVersion splits a FreeBSD version into its version, port revision and
+// epoch.
+func splitVersion(version string) (upstream, revision, epoch string) {
+	if v, e, ok := strings.Cut(version, ","); ok {
+		version, epoch = v, e
+	}
+	if i := strings.LastIndex(version, "_"); i >= 0 {
+		version, revision = version[:i], version[i+1:]
+	}
+	return version, revision, epoch
+}
@@ -0,0 +1,54 @@
+package freebsd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	info := exampleInfo()
+	info.Epoch = "1"
+	info.Scripts.PostInstall = "../testdata/scripts/postinstall.sh"
+
+	var pkg bytes.Buffer
+	require.NoError(t, Default.Package(info, &pkg))
+
+	result, err := Default.Read(&pkg)
+	require.NoError(t, err)
+	require.Equal(t, packagerName, result.Format)
+
+	got := result.Info
+	require.Equal(t, "foo", got.Name)
+	require.Equal(t, "freebsd", got.Platform)
+	require.Equal(t, "1.0.0.rc.1", got.Version)
+	require.Equal(t, "2", got.Release)
+	require.Equal(t, "1", got.Epoch)
+	require.Equal(t, "amd64", got.Arch)
+	require.Equal(t, "sysutils/foo", got.FreeBSD.Origin)
+	require.Equal(t, nfpm.Relations{"bash (>= 5.0)", "zsh"}, got.Depends)
+	require.Equal(t, []nfpm.User{{Name: "foo"}}, got.Users)
+	require.Contains(t, result.Scripts, "postinstall")
+	require.Contains(t, result.Scripts, "preinstall")
+
+	contents := map[string]*files.Content{}
+	for _, c := range got.Contents {
+		contents[c.Destination] = c
+	}
+	require.Equal(t, files.TypeFile, contents["/usr/local/bin/fake"].Type)
+	require.Equal(t, files.TypeConfig, contents["/usr/local/etc/fake.conf"].Type)
+	require.Equal(t, files.TypeSymlink, contents["/usr/local/bin/fake-link"].Type)
+	require.Equal(t, "/usr/local/bin/fake", contents["/usr/local/bin/fake-link"].Source)
+	require.Equal(t, files.TypeDir, contents["/var/db/foo"].Type)
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, err := Default.Read(strings.NewReader("not a freebsd package"))
+	require.ErrorIs(t, err, ErrInvalidPackage)
+	_, err = Default.Read(strings.NewReader(""))
+	require.ErrorIs(t, err, ErrInvalidPackage)
+}
@@ -0,0 +1,508 @@
+// Package gpkg implements nfpm.Packager providing Gentoo binary package
+// (GPKG) bindings.
+//
+// A GPKG is an uncompressed tarball holding, under a directory named after
+// the package, the gpkg-1 marker, the metadata and image tarballs, their
+// signatures, and the Manifest listing the checksums of all of them, as
+// described in GLEP 78.
+package gpkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"crypto/md5" // nolint: gosec
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	packagerName = "gpkg"
+	// eapi is the version of the ebuild API of the generated ebuild.
+	eapi = "8"
+)
+
+// nolint: gochecknoinits
+func init() {
+	nfpm.RegisterPackager(packagerName, Default)
+}
+
+// nolint: gochecknoglobals
+var archToGentoo = map[string]string{
+	"amd64":    "amd64",
+	"x86_64":   "amd64",
+	"386":      "x86",
+	"i386":     "x86",
+	"arm64":    "arm64",
+	"aarch64":  "arm64",
+	"arm5":     "arm",
+	"arm6":     "arm",
+	"arm7":     "arm",
+	"ppc64":    "ppc64",
+	"ppc64le":  "ppc64",
+	"riscv64":  "riscv",
+	"s390x":    "s390",
+	"loong64":  "loong",
+	"mips":     "mips",
+	"mipsle":   "mips",
+	"mips64":   "mips",
+	"mips64le": "mips",
+}
+
+// nolint: gochecknoglobals
+var (
+	// versionPattern matches the versions of packages, without their
+	// revision.
+	versionPattern = regexp.MustCompile(`^\d+(\.\d+)*[a-z]?(_(alpha|beta|pre|rc|p)\d*)*$`)
+	// prereleaseSeparators are removed from the prereleases, e.g. rc.1.
+	prereleaseSeparators = strings.NewReplacer(".", "", "-", "", "_", "")
+)
+
+// Default GPKG packager.
+// nolint: gochecknoglobals
+var Default = &GPKG{}
+
+// GPKG is a Gentoo binary package packager implementation.
+type GPKG struct{}
+
+// ConventionalFileName returns a file name according to the conventions for
+// Gentoo binary packages: name-version-rrelease.gpkg.tar.
+func (*GPKG) ConventionalFileName(info *nfpm.Info) string {
+	return fullName(info) + ".gpkg.tar"
+}
+
+// ConventionalExtension returns the file name conventionally used for Gentoo
+// binary packages.
+func (*GPKG) ConventionalExtension() string {
+	return ".gpkg.tar"
+}
+
+// packageVersion returns the version of the package, its prerelease being a
+// suffix, e.g. 1.0.0_rc1.
+func packageVersion(info *nfpm.Info) string {
+	if info.Prerelease == "" {
+		return info.Version
+	}
+	return info.Version + "_" + prereleaseSeparators.Replace(info.Prerelease)
+}
+
+// fullName returns the name of the package, followed by its version and its
+// revision, which Gentoo calls PF.
+func fullName(info *nfpm.Info) string {
+	pf := info.Name + "-" + packageVersion(info)
+	if info.Release != "" && info.Release != "0" {
+		pf += "-r" + info.Release
+	}
+	return pf
+}
+
+// keywords returns the keywords of the package, the stable keyword of its
+// architecture by default.
+func keywords(info *nfpm.Info) []string {
+	if len(info.Gentoo.Keywords) > 0 {
+		return info.Gentoo.Keywords
+	}
+	if keyword, ok := archToGentoo[info.Arch]; ok {
+		return []string{keyword}
+	}
+	if info.Arch == "all" {
+		return nil
+	}
+	return []string{info.Arch}
+}
+
+// Package writes a new Gentoo binary package to the given writer using the
+// given info.
+func (*GPKG) Package(info *nfpm.Info, w io.Writer) error {
+	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
+		return err
+	}
+	if !versionPattern.MatchString(packageVersion(info)) {
+		return fmt.Errorf("invalid Gentoo version: %s", packageVersion(info))
+	}
+	if _, err := strconv.ParseUint(info.Release, 10, 64); info.Release != "" && err != nil {
+		return fmt.Errorf("invalid Gentoo revision: %s", info.Release)
+	}
+	if err := nfpm.PrepareRelations(info, packagerName, func(arch string) bool {
+		keyword, ok := archToGentoo[arch]
+		return arch == info.Arch || ok && keyword == archToGentoo[info.Arch]
+	}); err != nil {
+		return err
+	}
+
+	mtime := modtime.Get(info.MTime)
+	ext, err := compressionExtension(info.Gentoo.Compression)
+	if err != nil {
+		return err
+	}
+	var contents bytes.Buffer
+	var size int64
+	image, err := compressedTar(info.Gentoo.Compression, func(tw *tar.Writer) error {
+		var err error
+		size, err = writeImage(tw, info.Contents, &contents, mtime)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	metadata, err := compressedTar(info.Gentoo.Compression, func(tw *tar.Writer) error {
+		return writeMetadata(tw, info, contents.Bytes(), size, mtime)
+	})
+	if err != nil {
+		return err
+	}
+
+	type member struct {
+		name string
+		data []byte
+	}
+	members := []member{
+		{"gpkg-1", nil},
+		{"metadata.tar" + ext, metadata},
+	}
+	signed := info.Gentoo.Signature.KeyFile != "" || info.Gentoo.Signature.SignFn != nil
+	if signed {
+		sig, err := detachSign(info, metadata)
+		if err != nil {
+			return err
+		}
+		members = append(members, member{"metadata.tar" + ext + ".sig", sig})
+	}
+	members = append(members, member{"image.tar" + ext, image})
+	if signed {
+		sig, err := detachSign(info, image)
+		if err != nil {
+			return err
+		}
+		members = append(members, member{"image.tar" + ext + ".sig", sig})
+	}
+
+	var manifest bytes.Buffer
+	for _, m := range members {
+		b2 := blake2b.Sum512(m.data)
+		sha := sha512.Sum512(m.data)
+		fmt.Fprintf(&manifest, "DATA %s %d BLAKE2B %x SHA512 %x\n", m.name, len(m.data), b2, sha)
+	}
+	manifestData := manifest.Bytes()
+	if signed {
+		if manifestData, err = clearSign(info, manifestData); err != nil {
+			return err
+		}
+	}
+	members = append(members, member{"Manifest", manifestData})
+
+	dir := fullName(info)
+	tw := tar.NewWriter(w)
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     dir + "/",
+		Mode:     0o755,
+		ModTime:  mtime,
+	}); err != nil {
+		return err
+	}
+	for _, m := range members {
+		if err := writeFile(tw, &tar.Header{
+			Name:    dir + "/" + m.name,
+			Mode:    0o644,
+			ModTime: mtime,
+		}, m.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// detachSign returns the armored detached signature of the data.
+func detachSign(info *nfpm.Info, data []byte) ([]byte, error) {
+	var sig []byte
+	var err error
+	if signFn := info.Gentoo.Signature.SignFn; signFn != nil {
+		sig, err = signFn(bytes.NewReader(data))
+	} else {
+		sig, err = sign.PGPArmoredDetachSignWithKeyID(bytes.NewReader(data), info.Gentoo.Signature.KeyFile, info.Gentoo.Signature.KeyPassphrase, info.Gentoo.Signature.KeyID)
+	}
+	if err != nil {
+		return nil, &nfpm.ErrSigningFailure{Err: err}
+	}
+	return sig, nil
+}
+
+// clearSign returns the data with its cleartext signature.
+func clearSign(info *nfpm.Info, data []byte) ([]byte, error) {
+	var signed []byte
+	var err error
+	if signFn := info.Gentoo.Signature.SignFn; signFn != nil {
+		signed, err = signFn(bytes.NewReader(data))
+	} else {
+		signed, err = sign.PGPClearSignWithKeyID(bytes.NewReader(data), info.Gentoo.Signature.KeyFile, info.Gentoo.Signature.KeyPassphrase, info.Gentoo.Signature.KeyID)
+	}
+	if err != nil {
+		return nil, &nfpm.ErrSigningFailure{Err: err}
+	}
+	return signed, nil
+}
+
+// writeImage writes the contents under the image directory, and their
+// entries in the CONTENTS file. It returns the size of the files.
+func writeImage(tw *tar.Writer, contents files.Contents, entries io.Writer, mtime time.Time) (int64, error) {
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     "image/",
+		Mode:     0o755,
+		ModTime:  mtime,
+	}); err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, content := range contents {
+		dst := files.NormalizeAbsoluteFilePath(content.Destination)
+		if dst == "/" {
+			continue
+		}
+		header := &tar.Header{
+			Name:    "image" + dst,
+			Mode:    int64(content.Mode() & 0o7777),
+			Uname:   content.FileInfo.Owner,
+			Gname:   content.FileInfo.Group,
+			ModTime: content.ModTime(),
+		}
+		if header.ModTime.IsZero() {
+			header.ModTime = mtime
+		}
+		switch content.Type {
+		case files.TypeDir, files.TypeImplicitDir:
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+			if err := tw.WriteHeader(header); err != nil {
+				return 0, err
+			}
+			fmt.Fprintf(entries, "dir %s\n", dst)
+		case files.TypeSymlink:
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = content.Source
+			header.Mode = 0o777
+			if err := tw.WriteHeader(header); err != nil {
+				return 0, err
+			}
+			fmt.Fprintf(entries, "sym %s -> %s %d\n", dst, content.Source, header.ModTime.Unix())
+		case files.TypeFile, files.TypeConfig, files.TypeConfigNoReplace, files.TypeConfigMissingOK:
+			data, err := os.ReadFile(content.Source)
+			if err != nil {
+				return 0, err
+			}
+			if err := writeFile(tw, header, data); err != nil {
+				return 0, err
+			}
+			size += int64(len(data))
+			fmt.Fprintf(entries, "obj %s %x %d\n", dst, md5.Sum(data), header.ModTime.Unix()) // nolint: gosec
+		default:
+			// ignore everything else
+		}
+	}
+	return size, nil
+}
+
+// writeMetadata writes the files of the metadata directory: the variables of
+// the package, one per file, the CONTENTS and the ebuild, whose phase
+// functions run the scripts.
+func writeMetadata(tw *tar.Writer, info *nfpm.Info, contents []byte, size int64, mtime time.Time) error {
+	rdepend := slices.Clone(info.Depends)
+	for _, conflict := range info.Conflicts {
+		// a blocker.
+		rdepend = append(rdepend, "!"+conflict)
+	}
+	description, _, _ := strings.Cut(strings.TrimSpace(info.Description), "\n")
+	phases, err := phaseFunctions(info)
+	if err != nil {
+		return err
+	}
+	var defined []string
+	for _, phase := range phases {
+		defined = append(defined, phase.name)
+	}
+	slices.Sort(defined)
+
+	vars := []struct {
+		name, value string
+	}{
+		{"DESCRIPTION", strings.TrimSpace(description)},
+		{"HOMEPAGE", info.Homepage},
+		{"LICENSE", info.License},
+		{"SLOT", cmp.Or(info.Gentoo.Slot, "0")},
+		{"KEYWORDS", strings.Join(keywords(info), " ")},
+		{"RDEPEND", strings.Join(rdepend, " ")},
+	}
+	var ebuild bytes.Buffer
+	fmt.Fprintf(&ebuild, "EAPI=%s\n\n", eapi)
+	for _, v := range vars {
+		fmt.Fprintf(&ebuild, "%s=%s\n", v.name, bashQuote(v.value))
+	}
+	for _, phase := range phases {
+		fmt.Fprintf(&ebuild, "\npkg_%s() {\n\t%s <<'NFPM_EOF' || die \"%s script failed\"\n%sNFPM_EOF\n}\n",
+			phase.name, phase.interpreter, phase.script, phase.body)
+	}
+
+	metadata := map[string]string{
+		"BUILD_TIME":               strconv.FormatInt(mtime.Unix(), 10),
+		"CATEGORY":                 cmp.Or(info.Gentoo.Category, "app-misc"),
+		"CONTENTS":                 string(contents),
+		"DEFINED_PHASES":           strings.Join(defined, " "),
+		"EAPI":                     eapi,
+		"PF":                       fullName(info),
+		"SIZE":                     strconv.FormatInt(size, 10),
+		fullName(info) + ".ebuild": ebuild.String(),
+	}
+	for _, v := range vars {
+		metadata[v.name] = v.value
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     "metadata/",
+		Mode:     0o755,
+		ModTime:  mtime,
+	}); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(metadata)) {
+		value := metadata[name]
+		if value == "" {
+			continue
+		}
+		if !strings.HasSuffix(value, "\n") {
+			value += "\n"
+		}
+		if err := writeFile(tw, &tar.Header{
+			Name:    "metadata/" + name,
+			Mode:    0o644,
+			ModTime: mtime,
+		}, []byte(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// phase is a phase function of the ebuild, running one of the scripts.
+type phase struct {
+	name        string
+	script      string
+	interpreter string
+	body        []byte
+}
+
+// phaseFunctions returns the phase functions running the scripts: pkg_preinst
+// and pkg_postinst around the merge, pkg_prerm and pkg_postrm around the
+// unmerge.
+func phaseFunctions(info *nfpm.Info) ([]phase, error) {
+	var phases []phase
+	for _, p := range []struct {
+		name, script, path string
+	}{
+		{"preinst", "preinstall", info.Scripts.PreInstall},
+		{"postinst", "postinstall", info.Scripts.PostInstall},
+		{"prerm", "preremove", info.Scripts.PreRemove},
+		{"postrm", "postremove", info.Scripts.PostRemove},
+	} {
+		if p.path == "" {
+			continue
+		}
+		body, err := os.ReadFile(p.path)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.HasSuffix(body, []byte("\n")) {
+			body = append(body, '\n')
+		}
+		// the script is fed to the interpreter of its shebang.
+		interpreter := "/bin/sh"
+		if line, _, _ := bytes.Cut(body, []byte("\n")); bytes.HasPrefix(line, []byte("#!")) {
+			interpreter = strings.TrimSpace(string(line[2:]))
+		}
+		phases = append(phases, phase{name: p.name, script: p.script, interpreter: interpreter, body: body})
+	}
+	return phases, nil
+}
+
+// bashQuote quotes s as a double-quoted bash string.
+func bashQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`").Replace(s) + `"`
+}
+
+// writeFile writes a regular file to the tarball.
+func writeFile(tw *tar.Writer, header *tar.Header, data []byte) error {
+	header.Typeflag = tar.TypeReg
+	header.Size = int64(len(data))
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("cannot write header of %s: %w", header.Name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("cannot write %s: %w", header.Name, err)
+	}
+	return nil
+}
+
+// compressionExtension returns the extension of the tarballs compressed with
+// the given algorithm.
+func compressionExtension(compression string) (string, error) {
+	switch compression {
+	case "", "zstd":
+		return ".zst", nil
+	case "xz":
+		return ".xz", nil
+	case "gzip":
+		return ".gz", nil
+	default:
+		return "", fmt.Errorf("unknown compression algorithm: %s", compression)
+	}
+}
+
+// compressedTar returns the tarball populated by the given function,
+// compressed with the given algorithm, zstd by default, as portage does.
+func compressedTar(compression string, populate func(tw *tar.Writer) error) ([]byte, error) {
+	var buf bytes.Buffer
+	var compressor io.WriteCloser
+	var err error
+	switch compression {
+	case "", "zstd":
+		compressor, err = zstd.NewWriter(&buf)
+	case "xz":
+		compressor, err = xz.NewWriter(&buf)
+	case "gzip":
+		compressor = gzip.NewWriter(&buf)
+	default:
+		err = fmt.Errorf("unknown compression algorithm: %s", compression)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(compressor)
+	if err := populate(tw); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := compressor.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,313 @@
+package gpkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/md5" // nolint: gosec
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+	"golang.org/x/crypto/blake2b"
+)
+
+var mtime = time.Date(2023, 11, 5, 23, 15, 17, 0, time.UTC)
+
+func exampleInfo() *nfpm.Info {
+	return nfpm.WithDefaults(&nfpm.Info{
+		Name:        "foo",
+		Arch:        "amd64",
+		Description: "Foo does things\nand more things",
+		Maintainer:  "Carlos A Becker <pkg@carlosbecker.com>",
+		Version:     "v1.0.0",
+		Prerelease:  "rc-1",
+		Release:     "2",
+		Homepage:    "http://carlosbecker.com",
+		License:     "MIT",
+		MTime:       mtime,
+		Overridables: nfpm.Overridables{
+			Depends: []string{
+				"dev-libs/bar (>= 5.0)",
+				"zsh [arm64]",
+			},
+			Conflicts: []string{"baz"},
+			Contents: []*files.Content{
+				{
+					Source:      "../testdata/fake",
+					Destination: "/usr/bin/fake",
+				},
+				{
+					Source:      "../testdata/whatever.conf",
+					Destination: "/etc/fake.conf",
+					Type:        files.TypeConfig,
+				},
+				{
+					Source:      "fake",
+					Destination: "/usr/bin/fake-link",
+					Type:        files.TypeSymlink,
+				},
+				{
+					Destination: "/var/lib/foo",
+					Type:        files.TypeDir,
+				},
+			},
+			Scripts: nfpm.Scripts{
+				PostInstall: "../testdata/scripts/postinstall.sh",
+				PreRemove:   "../testdata/scripts/preremove.sh",
+			},
+			Gentoo: nfpm.Gentoo{
+				Category: "app-admin",
+			},
+		},
+	})
+}
+
+// readTar returns the entries of the tarball and the contents of its files.
+func readTar(t *testing.T, r io.Reader) ([]string, map[string][]byte) {
+	t.Helper()
+	var names []string
+	contents := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, mtime.Unix(), hdr.ModTime.Unix(), hdr.Name)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		contents[hdr.Name] = data
+	}
+	return names, contents
+}
+
+// manifest returns the Manifest listing the given files of the package.
+func manifest(contents map[string][]byte, dir string, names ...string) string {
+	var lines []string
+	for _, name := range names {
+		data := contents[dir+name]
+		b2 := blake2b.Sum512(data)
+		sha := sha512.Sum512(data)
+		lines = append(lines, fmt.Sprintf("DATA %s %d BLAKE2B %s SHA512 %s", name, len(data), hex.EncodeToString(b2[:]), hex.EncodeToString(sha[:])))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func md5Sum(data []byte) [md5.Size]byte {
+	return md5.Sum(data) // nolint: gosec
+}
+
+func TestConventionalFileName(t *testing.T) {
+	info := exampleInfo()
+	require.Equal(t, "foo-1.0.0_rc1-r2.gpkg.tar", Default.ConventionalFileName(info))
+	info.Prerelease, info.Release = "", "0"
+	require.Equal(t, "foo-1.0.0.gpkg.tar", Default.ConventionalFileName(info))
+	require.Equal(t, ".gpkg.tar", Default.ConventionalExtension())
+}
+
+func TestKeywords(t *testing.T) {
+	for arch, want := range map[string][]string{
+		"amd64":   {"amd64"},
+		"386":     {"x86"},
+		"arm7":    {"arm"},
+		"ppc64le": {"ppc64"},
+		"riscv64": {"riscv"},
+		"all":     nil,
+		"sparc":   {"sparc"},
+	} {
+		t.Run(arch, func(t *testing.T) {
+			info := exampleInfo()
+			info.Arch = arch
+			require.Equal(t, want, keywords(info))
+		})
+	}
+	info := exampleInfo()
+	info.Gentoo.Keywords = []string{"~amd64", "~arm64"}
+	require.Equal(t, []string{"~amd64", "~arm64"}, keywords(info))
+}
+
+func TestPackage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(exampleInfo(), &buf))
+	names, contents := readTar(t, &buf)
+	const dir = "foo-1.0.0_rc1-r2/"
+	require.Equal(t, []string{
+		dir,
+		dir + "gpkg-1",
+		dir + "metadata.tar.zst",
+		dir + "image.tar.zst",
+		dir + "Manifest",
+	}, names)
+	require.Equal(t, manifest(contents, dir, "gpkg-1", "metadata.tar.zst", "image.tar.zst"), string(contents[dir+"Manifest"]))
+
+	zr, err := zstd.NewReader(bytes.NewReader(contents[dir+"image.tar.zst"]))
+	require.NoError(t, err)
+	defer zr.Close()
+	names, image := readTar(t, zr)
+	require.Equal(t, []string{
+		"image/",
+		"image/etc/",
+		"image/etc/fake.conf",
+		"image/usr/",
+		"image/usr/bin/",
+		"image/usr/bin/fake",
+		"image/usr/bin/fake-link",
+		"image/var/",
+		"image/var/lib/",
+		"image/var/lib/foo/",
+	}, names)
+	fake, err := os.ReadFile("../testdata/fake")
+	require.NoError(t, err)
+	require.Equal(t, fake, image["image/usr/bin/fake"])
+
+	require.NoError(t, zr.Reset(bytes.NewReader(contents[dir+"metadata.tar.zst"])))
+	names, metadata := readTar(t, zr)
+	require.Equal(t, []string{
+		"metadata/",
+		"metadata/BUILD_TIME",
+		"metadata/CATEGORY",
+		"metadata/CONTENTS",
+		"metadata/DEFINED_PHASES",
+		"metadata/DESCRIPTION",
+		"metadata/EAPI",
+		"metadata/HOMEPAGE",
+		"metadata/KEYWORDS",
+		"metadata/LICENSE",
+		"metadata/PF",
+		"metadata/RDEPEND",
+		"metadata/SIZE",
+		"metadata/SLOT",
+		"metadata/foo-1.0.0_rc1-r2.ebuild",
+	}, names)
+	conf, err := os.ReadFile("../testdata/whatever.conf")
+	require.NoError(t, err)
+	for name, want := range map[string]string{
+		"BUILD_TIME":     fmt.Sprintf("%d\n", mtime.Unix()),
+		"CATEGORY":       "app-admin\n",
+		"DEFINED_PHASES": "postinst prerm\n",
+		"DESCRIPTION":    "Foo does things\n",
+		"EAPI":           "8\n",
+		"KEYWORDS":       "amd64\n",
+		"PF":             "foo-1.0.0_rc1-r2\n",
+		"RDEPEND":        ">=dev-libs/bar-5.0 !baz\n",
+		"SIZE":           fmt.Sprintf("%d\n", len(fake)+len(conf)),
+		"SLOT":           "0\n",
+	} {
+		require.Equal(t, want, string(metadata["metadata/"+name]), name)
+	}
+	require.Equal(t, fmt.Sprintf(`dir /etc
+obj /etc/fake.conf %[1]x %[3]d
+dir /usr
+dir /usr/bin
+obj /usr/bin/fake %[2]x %[3]d
+sym /usr/bin/fake-link -> fake %[3]d
+dir /var
+dir /var/lib
+dir /var/lib/foo
+`, md5Sum(conf), md5Sum(fake), mtime.Unix()), string(metadata["metadata/CONTENTS"]))
+
+	postinstall, err := os.ReadFile("../testdata/scripts/postinstall.sh")
+	require.NoError(t, err)
+	ebuild := string(metadata["metadata/foo-1.0.0_rc1-r2.ebuild"])
+	require.True(t, strings.HasPrefix(ebuild, `EAPI=8
+
+DESCRIPTION="Foo does things"
+HOMEPAGE="http://carlosbecker.com"
+LICENSE="MIT"
+SLOT="0"
+KEYWORDS="amd64"
+RDEPEND=">=dev-libs/bar-5.0 !baz"
+`), ebuild)
+	require.Contains(t, ebuild, "\npkg_postinst() {\n\t/bin/bash <<'NFPM_EOF' || die \"postinstall script failed\"\n"+string(postinstall)+"NFPM_EOF\n}\n")
+	require.Contains(t, ebuild, "\npkg_prerm() {\n")
+	require.NotContains(t, ebuild, "pkg_preinst")
+}
+
+func TestPackageCompression(t *testing.T) {
+	info := exampleInfo()
+	info.Gentoo.Compression = "xz"
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	_, contents := readTar(t, &buf)
+	xzr, err := xz.NewReader(bytes.NewReader(contents["foo-1.0.0_rc1-r2/image.tar.xz"]))
+	require.NoError(t, err)
+	names, _ := readTar(t, xzr)
+	require.Contains(t, names, "image/usr/bin/fake")
+}
+
+func TestPackageSigned(t *testing.T) {
+	info := exampleInfo()
+	info.Gentoo.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+	info.Gentoo.Signature.KeyPassphrase = "hunter2"
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	names, contents := readTar(t, &buf)
+	const dir = "foo-1.0.0_rc1-r2/"
+	require.Equal(t, []string{
+		dir,
+		dir + "gpkg-1",
+		dir + "metadata.tar.zst",
+		dir + "metadata.tar.zst.sig",
+		dir + "image.tar.zst",
+		dir + "image.tar.zst.sig",
+		dir + "Manifest",
+	}, names)
+
+	const pubkey = "../internal/sign/testdata/pubkey.asc"
+	for _, name := range []string{"metadata.tar.zst", "image.tar.zst"} {
+		require.NoError(t, sign.PGPVerify(bytes.NewReader(contents[dir+name]), contents[dir+name+".sig"], pubkey), name)
+	}
+	plaintext, err := sign.PGPReadMessage(contents[dir+"Manifest"], pubkey)
+	require.NoError(t, err)
+	require.Equal(t, manifest(contents, dir, "gpkg-1", "metadata.tar.zst", "metadata.tar.zst.sig", "image.tar.zst", "image.tar.zst.sig"), string(plaintext))
+}
+
+func TestPackageSignFn(t *testing.T) {
+	info := exampleInfo()
+	info.Gentoo.Signature.SignFn = func(io.Reader) ([]byte, error) {
+		return nil, errors.New("no key")
+	}
+	err := Default.Package(info, io.Discard)
+	var signErr *nfpm.ErrSigningFailure
+	require.ErrorAs(t, err, &signErr)
+}
+
+func TestPackageInvalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		update func(info *nfpm.Info)
+		err    string
+	}{
+		"version": {
+			update: func(info *nfpm.Info) { info.Version = "1.0.0-beta" },
+			err:    "invalid Gentoo version: 1.0.0-beta_rc1",
+		},
+		"revision": {
+			update: func(info *nfpm.Info) { info.Release = "two" },
+			err:    "invalid Gentoo revision: two",
+		},
+		"compression": {
+			update: func(info *nfpm.Info) { info.Gentoo.Compression = "bzip2" },
+			err:    "unknown compression algorithm: bzip2",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			info := exampleInfo()
+			tc.update(info)
+			require.EqualError(t, Default.Package(info, io.Discard), tc.err)
+		})
+	}
+}
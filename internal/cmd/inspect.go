@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/maps"
+	"github.com/spf13/cobra"
+)
+
+type inspectCmd struct {
+	cmd      *cobra.Command
+	packager string
+	format   string
+}
+
+func newInspectCmd() *inspectCmd {
+	root := &inspectCmd{}
+	cmd := &cobra.Command{
+		Use:           "inspect <package>",
+		Aliases:       []string{"info"},
+		Short:         "Shows the metadata and contents of an existing package",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return doInspect(cmd.OutOrStdout(), args[0], root.packager, root.format)
+		},
+	}
+
+	formats := nfpm.EnumerateReaders()
+	cmd.Flags().StringVarP(&root.packager, "packager", "p", "",
+		fmt.Sprintf("format of the package, guessed from its extension if empty [%s]", strings.Join(formats, "|")))
+	_ = cmd.RegisterFlagCompletionFunc("packager", cobra.FixedCompletions(
+		formats,
+		cobra.ShellCompDirectiveNoFileComp,
+	))
+	cmd.Flags().StringVarP(&root.format, "output", "o", "text", "output format [text|json]")
+	_ = cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(
+		[]string{"text", "json"},
+		cobra.ShellCompDirectiveNoFileComp,
+	))
+
+	root.cmd = cmd
+	return root
+}
+
+// inspectFile is the representation of a package entry in the inspect output.
+type inspectFile struct {
+	Path   string    `json:"path"`
+	Type   string    `json:"type"`
+	Mode   string    `json:"mode"`
+	Owner  string    `json:"owner,omitempty"`
+	Group  string    `json:"group,omitempty"`
+	Size   int64     `json:"size"`
+	MTime  time.Time `json:"mtime"`
+	Target string    `json:"target,omitempty"`
+}
+
+type inspectOutput struct {
+	Format     string               `json:"format"`
+	Info       map[string]any       `json:"info"`
+	Scripts    map[string]string    `json:"scripts,omitempty"`
+	Signatures []nfpm.SignatureInfo `json:"signatures,omitempty"`
+	Files      []inspectFile        `json:"files"`
+}
+
+func doInspect(w io.Writer, path, packager, format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid output format: %s", format)
+	}
+
+	if packager == "" {
+		var err error
+		packager, err = nfpm.FormatFromFileName(path)
+		if err != nil {
+			return fmt.Errorf("%w, please specify the packager", err)
+		}
+	}
+
+	reader, err := nfpm.GetReader(packager)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	inspection, err := reader.Read(f)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", path, err)
+	}
+
+	out, err := newInspectOutput(inspection)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(out)
+	}
+	return writeInspectText(w, out)
+}
+
+func newInspectOutput(inspection *nfpm.Inspection) (*inspectOutput, error) {
+	info := *inspection.Info
+	contents := info.Contents
+	info.Contents = nil
+
+	// going through JSON keeps the field names identical to the ones used in
+	// the configuration file.
+	bts, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(bts, &fields); err != nil {
+		return nil, err
+	}
+	fields, _ = pruneEmpty(fields).(map[string]any)
+
+	out := &inspectOutput{
+		Format:     inspection.Format,
+		Info:       fields,
+		Scripts:    inspection.Scripts,
+		Signatures: inspection.Signatures,
+		Files:      make([]inspectFile, 0, len(contents)),
+	}
+	for _, content := range contents {
+		file := inspectFile{
+			Path: content.Destination,
+			Type: content.Type,
+		}
+		if content.Type == files.TypeSymlink {
+			file.Target = content.Source
+		}
+		if fi := content.FileInfo; fi != nil {
+			file.Mode = fileMode(content).String()
+			file.Owner = fi.Owner
+			file.Group = fi.Group
+			file.Size = fi.Size
+			file.MTime = fi.MTime
+		}
+		out.Files = append(out.Files, file)
+	}
+	return out, nil
+}
+
+func fileMode(content *files.Content) fs.FileMode {
+	mode := content.FileInfo.Mode
+	switch content.Type {
+	case files.TypeDir, files.TypeImplicitDir:
+		mode |= fs.ModeDir
+	case files.TypeSymlink:
+		mode |= fs.ModeSymlink
+	}
+	return mode
+}
+
+// pruneEmpty removes the empty values from a decoded JSON document, returning
+// nil if nothing is left.
+func pruneEmpty(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if pruned := pruneEmpty(value); pruned == nil {
+				delete(v, key)
+			} else {
+				v[key] = pruned
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+		return v
+	case []any:
+		if len(v) == 0 {
+			return nil
+		}
+		return v
+	case string:
+		if v == "" || v == (time.Time{}).Format(time.RFC3339) {
+			return nil
+		}
+		return v
+	case bool:
+		if !v {
+			return nil
+		}
+		return v
+	case float64:
+		if v == 0 {
+			return nil
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// flatten turns a decoded JSON document into dotted keys and printable values.
+func flatten(prefix string, v any, out map[string]string) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flatten(key, value, out)
+		}
+	case []any:
+		scalars := make([]string, 0, len(v))
+		for i, item := range v {
+			if _, ok := item.(map[string]any); ok {
+				flatten(fmt.Sprintf("%s[%d]", prefix, i), item, out)
+				continue
+			}
+			scalars = append(scalars, fmt.Sprint(item))
+		}
+		if len(scalars) > 0 {
+			out[prefix] = strings.Join(scalars, ", ")
+		}
+	default:
+		out[prefix] = fmt.Sprint(v)
+	}
+}
+
+// the fields shown first in the text output, in this order.
+// nolint: gochecknoglobals
+var inspectHeaderFields = []string{
+	"name", "epoch", "version", "release", "arch", "platform", "maintainer",
+	"vendor", "homepage", "license", "section", "priority", "description",
+}
+
+func writeInspectText(w io.Writer, out *inspectOutput) error {
+	fields := map[string]string{}
+	flatten("", out.Info, fields)
+
+	keys := make([]string, 0, len(fields))
+	for _, key := range inspectHeaderFields {
+		if _, ok := fields[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	rest := make([]string, 0, len(fields))
+	for key := range fields {
+		if !slices.Contains(inspectHeaderFields, key) {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(tw, "format:\t%s\n", out.Format)
+	for _, key := range keys {
+		value := strings.ReplaceAll(fields[key], "\n", "\n\t")
+		fmt.Fprintf(tw, "%s:\t%s\n", key, value)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(out.Scripts) > 0 {
+		fmt.Fprintf(w, "\nscripts: %s\n", strings.Join(maps.Keys(out.Scripts), ", "))
+	}
+
+	if len(out.Signatures) > 0 {
+		fmt.Fprintln(w, "\nsignatures:")
+		for _, sig := range out.Signatures {
+			fmt.Fprintf(w, "  %s\n", strings.Join(strings.Fields(sig.Type+" "+sig.Name+" "+sig.KeyID), " "))
+		}
+	}
+
+	fmt.Fprintf(w, "\nfiles (%d):\n", len(out.Files))
+	tw = tabwriter.NewWriter(w, 0, 4, 1, ' ', 0)
+	for _, file := range out.Files {
+		path := file.Path
+		if file.Target != "" {
+			path += " -> " + file.Target
+		}
+		if file.Type != files.TypeFile && file.Type != files.TypeDir && file.Type != files.TypeSymlink {
+			path += " [" + file.Type + "]"
+		}
+		mtime := ""
+		if !file.MTime.IsZero() {
+			mtime = file.MTime.UTC().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%d\t%s\t%s\n",
+			file.Mode, file.Owner, file.Group, file.Size, mtime, path)
+	}
+	return tw.Flush()
+}
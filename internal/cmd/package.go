@@ -1,22 +1,27 @@
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/goreleaser/nfpm/v2"
 	"github.com/spf13/cobra"
 )
 
 type packageCmd struct {
-	cmd      *cobra.Command
-	config   string
-	target   string
-	packager string
+	cmd       *cobra.Command
+	config    string
+	target    string
+	packagers []string
+	arches    []string
 }
 
 func newPackageCmd() *packageCmd {
@@ -30,7 +35,7 @@ func newPackageCmd() *packageCmd {
 		Args:              cobra.NoArgs,
 		ValidArgsFunction: cobra.NoFileCompletions,
 		RunE: func(*cobra.Command, []string) error {
-			return doPackage(root.config, root.target, root.packager)
+			return doPackage(root.config, root.target, root.packagers, root.arches)
 		},
 	}
 
@@ -41,21 +46,215 @@ func newPackageCmd() *packageCmd {
 
 	pkgs := nfpm.Enumerate()
 
-	cmd.Flags().StringVarP(&root.packager, "packager", "p", "",
-		fmt.Sprintf("which packager implementation to use [%s]", strings.Join(pkgs, "|")))
+	cmd.Flags().StringSliceVarP(&root.packagers, "packager", "p", nil,
+		fmt.Sprintf("which packager implementation to use, can be repeated to build several packages [%s]", strings.Join(pkgs, "|")))
 	_ = cmd.RegisterFlagCompletionFunc("packager", cobra.FixedCompletions(
 		pkgs,
 		cobra.ShellCompDirectiveNoFileComp,
 	))
+	cmd.Flags().StringSliceVarP(&root.arches, "arch", "a", nil,
+		"architecture to build for, overriding the one in the config file, can be repeated to build several packages")
+	_ = cmd.RegisterFlagCompletionFunc("arch", cobra.NoFileCompletions)
 
 	root.cmd = cmd
 	return root
 }
 
-var errInsufficientParams = errors.New("a packager must be specified if target is a directory or blank")
+var (
+	errInsufficientParams = errors.New("a packager must be specified if target is a directory or blank")
+	errTargetNotADir      = errors.New("target must be a directory when building several packages")
+	errNoMatrixPackager   = errors.New("a packager must be specified when building several architectures")
+)
+
+// packageBuild is a single package, packager and architecture combination to
+// build.
+type packageBuild struct {
+	// name of the package, set if the config has several packages.
+	name     string
+	packager string
+	arch     string
+	info     *nfpm.Info
+	pkg      nfpm.Packager
+	target   string
+	// debugTarget is where the debug package was created, if any.
+	debugTarget string
+}
+
+func (b *packageBuild) String() string {
+	s := b.packager
+	if b.arch != "" {
+		s += "/" + b.arch
+	}
+	if b.name != "" {
+		s = b.name + " " + s
+	}
+	return s
+}
+
+func doPackage(configPath, target string, packagers, arches []string) error {
+	content, err := readConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	// the flags replace the matching field of the matrix of the config.
+	configs := &configsByArch{content: content}
+	config, err := configs.get("")
+	if err != nil {
+		return err
+	}
+	if len(packagers) == 0 {
+		packagers = config.Matrix.Packagers
+	}
+	if len(arches) == 0 {
+		arches = config.Matrix.Arches
+	}
+
+	if len(packagers) <= 1 && len(arches) <= 1 {
+		packager, arch := "", ""
+		if len(packagers) == 1 {
+			packager = packagers[0]
+		}
+		if len(arches) == 1 {
+			arch = arches[0]
+		}
+		return doPackageSingle(configs, target, packager, arch)
+	}
+	if len(arches) == 0 {
+		arches = []string{""}
+	}
+	if len(packagers) == 0 {
+		return errNoMatrixPackager
+	}
+	return doPackageMatrix(configs, target, packagers, arches)
+}
+
+// readConfig reads the config file once, so it can be parsed for every
+// package that is built.
+func readConfig(configPath string) ([]byte, error) {
+	if configPath == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(configPath) //nolint:gosec
+}
+
+// configsByArch parses the config once per architecture, with $NFPM_ARCH
+// expanding to it, and matches the globs of its contents, for the builds of
+// every packager. Config.GetPackages gives every build its own copy of the
+// info, as packagers modify the info they are given.
+type configsByArch struct {
+	content []byte
+	configs map[string]*nfpm.Config
+}
 
-// nolint:funlen
-func doPackage(configPath, target, packager string) error {
+func (c *configsByArch) get(arch string) (*nfpm.Config, error) {
+	if config, ok := c.configs[arch]; ok {
+		return config, nil
+	}
+	config, err := nfpm.ParseWithEnvMapping(bytes.NewReader(c.content), func(key string) string {
+		if key == "NFPM_ARCH" && arch != "" {
+			return arch
+		}
+		return os.Getenv(key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	config.ExpandGlobs()
+	if c.configs == nil {
+		c.configs = map[string]*nfpm.Config{}
+	}
+	c.configs[arch] = &config
+	return &config, nil
+}
+
+// newPackageBuilds returns the builds of every package of the config.
+func newPackageBuilds(config *nfpm.Config, packager, arch string) ([]*packageBuild, error) {
+	infos, err := config.GetPackages(packager)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := nfpm.Get(packager)
+	if err != nil {
+		return nil, err
+	}
+
+	builds := make([]*packageBuild, 0, len(infos))
+	for _, info := range infos {
+		for _, info := range append([]*nfpm.Info{info}, info.SplitPackages...) {
+			if arch != "" {
+				info.Arch = arch
+			}
+			nfpm.WithDefaults(info)
+		}
+		build := &packageBuild{
+			packager: packager,
+			arch:     arch,
+			info:     info,
+			pkg:      pkg,
+		}
+		if len(infos) > 1 {
+			build.name = info.Name
+		}
+		builds = append(builds, build)
+	}
+	return builds, nil
+}
+
+func (b *packageBuild) run() error {
+	var debug *nfpm.Info
+	if b.info.DebugSymbols.Split {
+		dir, err := os.MkdirTemp("", "nfpm-debug")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		debug, err = nfpm.SplitDebugSymbols(b.info, b.packager, dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := create(b.pkg, b.info, b.target); err != nil {
+		return err
+	}
+	if debug == nil {
+		return nil
+	}
+	b.debugTarget = b.debugPath(debug)
+	return create(b.pkg, debug, b.debugTarget)
+}
+
+// debugPath returns where the given debug package is created, next to the
+// package.
+func (b *packageBuild) debugPath(debug *nfpm.Info) string {
+	target := path.Join(path.Dir(b.target), b.pkg.ConventionalFileName(debug))
+	if b.packager == "deb" {
+		// debug packages are named .ddeb, so they can be kept apart from the
+		// other packages of a repository.
+		target = strings.TrimSuffix(target, ".deb") + ".ddeb"
+	}
+	return target
+}
+
+func create(pkg nfpm.Packager, info *nfpm.Info, target string) error {
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info.Target = target
+
+	if err := pkg.Package(info, f); err != nil {
+		os.Remove(target)
+		return err
+	}
+	return f.Close()
+}
+
+func doPackageSingle(configs *configsByArch, target, packager, arch string) error {
 	targetIsADirectory := false
 	stat, err := os.Stat(target)
 	if err == nil && stat.IsDir() {
@@ -72,47 +271,141 @@ func doPackage(configPath, target, packager string) error {
 		fmt.Println("guessing packager from target file extension...")
 	}
 
-	config, err := nfpm.ParseFile(configPath)
+	config, err := configs.get(arch)
 	if err != nil {
 		return err
 	}
-
-	info, err := config.Get(packager)
+	builds, err := newPackageBuilds(config, packager, arch)
 	if err != nil {
 		return err
 	}
 
-	info = nfpm.WithDefaults(info)
-
 	fmt.Printf("using %s packager...\n", packager)
-	pkg, err := nfpm.Get(packager)
-	if err != nil {
-		return err
-	}
 
+	first := builds[0]
 	if target == "" {
 		// if no target was specified create a package in
 		// current directory with a conventional file name
-		target = pkg.ConventionalFileName(info)
+		target = first.pkg.ConventionalFileName(first.info)
 	} else if targetIsADirectory {
 		// if a directory was specified as target, create
 		// a package with conventional file name there
-		target = path.Join(target, pkg.ConventionalFileName(info))
+		target = path.Join(target, first.pkg.ConventionalFileName(first.info))
+	}
+	first.target = target
+	// the other packages of the config are created next to the first one.
+	for _, build := range builds[1:] {
+		build.target = path.Join(path.Dir(target), build.pkg.ConventionalFileName(build.info))
 	}
 
-	f, err := os.Create(target)
-	if err != nil {
-		return err
+	for _, build := range builds {
+		if err := build.run(); err != nil {
+			if len(builds) > 1 {
+				return fmt.Errorf("%s: %w", build.info.Name, err)
+			}
+			return err
+		}
+
+		fmt.Printf("created package: %s\n", build.target)
+		if build.debugTarget != "" {
+			fmt.Printf("created package: %s\n", build.debugTarget)
+		}
 	}
-	defer f.Close()
+	return nil
+}
 
-	info.Target = target
+// sourcePackagers build a source package or recipe, whose file name has no
+// architecture: doPackageMatrix builds it once, for the first architecture.
+// nolint: gochecknoglobals
+var sourcePackagers = map[string]bool{
+	"srpm":     true,
+	"dsc":      true,
+	"apkbuild": true,
+	"pkgbuild": true,
+}
 
-	if err := pkg.Package(info, f); err != nil {
-		os.Remove(target)
+// doPackageMatrix builds every packager and architecture combination in
+// parallel into the target directory, using the conventional file names.
+func doPackageMatrix(configs *configsByArch, target string, packagers, arches []string) error {
+	if target == "" {
+		target = "."
+	}
+	if stat, err := os.Stat(target); err == nil && !stat.IsDir() {
+		return errTargetNotADir
+	}
+	if err := os.MkdirAll(target, 0o755); err != nil {
 		return err
 	}
 
-	fmt.Printf("created package: %s\n", target)
-	return f.Close()
+	var builds []*packageBuild
+	targets := map[string]*packageBuild{}
+	for i, arch := range arches {
+		config, err := configs.get(arch)
+		if err != nil {
+			return fmt.Errorf("%s: %w", arch, err)
+		}
+		for _, packager := range packagers {
+			if sourcePackagers[packager] && i > 0 {
+				continue
+			}
+			packageBuilds, err := newPackageBuilds(config, packager, arch)
+			if err != nil {
+				return fmt.Errorf("%s: %w", &packageBuild{packager: packager, arch: arch}, err)
+			}
+			for _, build := range packageBuilds {
+				build.target = path.Join(target, build.pkg.ConventionalFileName(build.info))
+				buildTargets := []string{build.target}
+				if build.info.DebugSymbols.Split {
+					debug, err := nfpm.DebugPackage(build.info, packager)
+					if err != nil {
+						return fmt.Errorf("%s: %w", build, err)
+					}
+					buildTargets = append(buildTargets, build.debugPath(debug))
+				}
+				for _, buildTarget := range buildTargets {
+					if other, ok := targets[buildTarget]; ok {
+						return fmt.Errorf("%s and %s would both create %s", other, build, buildTarget)
+					}
+					targets[buildTarget] = build
+				}
+				builds = append(builds, build)
+			}
+		}
+	}
+
+	fmt.Printf("building %d packages...\n", len(builds))
+
+	errs := make([]error, len(builds))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, build := range builds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[i] = build.run()
+		}()
+	}
+	wg.Wait()
+
+	var created []string
+	var failed []error
+	for i, build := range builds {
+		if errs[i] != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", build, errs[i]))
+			continue
+		}
+		created = append(created, build.target)
+		if build.debugTarget != "" {
+			created = append(created, build.debugTarget)
+		}
+	}
+	if len(created) > 0 {
+		fmt.Printf("created %d packages:\n", len(created))
+		for _, target := range created {
+			fmt.Printf("  %s\n", target)
+		}
+	}
+	return errors.Join(failed...)
 }
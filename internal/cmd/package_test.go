@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, config string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nfpm.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+	return path
+}
+
+func TestPackageMatrixDebugTargetCollision(t *testing.T) {
+	config := writeConfig(t, `
+name: foo
+arch: amd64
+version: 1.0.0
+maintainer: foo <foo@example.com>
+debug_symbols:
+  split: true
+packages:
+  - name: foo-dbg
+`)
+	target := t.TempDir()
+	err := doPackage(config, target, []string{"apk", "ipk"}, nil)
+	require.EqualError(t, err, "foo apk and foo-dbg apk would both create "+filepath.Join(target, "foo-dbg_1.0.0_x86_64.apk"))
+	entries, err := os.ReadDir(target)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestPackageSeveralArchesWithoutPackager(t *testing.T) {
+	config := writeConfig(t, `
+name: foo
+arch: amd64
+version: 1.0.0
+`)
+	err := doPackage(config, filepath.Join(t.TempDir(), "foo.deb"), nil, []string{"amd64", "arm64"})
+	require.ErrorIs(t, err, errNoMatrixPackager)
+}
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2/apk"
+	"github.com/goreleaser/nfpm/v2/arch"
+	"github.com/goreleaser/nfpm/v2/deb"
+	"github.com/goreleaser/nfpm/v2/ipk"
+	"github.com/goreleaser/nfpm/v2/rpm"
+	"github.com/spf13/cobra"
+)
+
+type repoCmd struct {
+	cmd *cobra.Command
+}
+
+func newRepoCmd() *repoCmd {
+	root := &repoCmd{}
+	cmd := &cobra.Command{
+		Use:   "repo",
+		Short: "Creates package repository indexes from existing packages",
+		Long: `Creates package repository indexes from a directory of existing packages,
+so it can be published with any static file server.`,
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: cobra.NoFileCompletions,
+	}
+
+	cmd.AddCommand(
+		newRepoDebCmd().cmd,
+		newRepoRPMCmd().cmd,
+		newRepoAPKCmd().cmd,
+		newRepoArchlinuxCmd().cmd,
+		newRepoIPKCmd().cmd,
+	)
+
+	root.cmd = cmd
+	return root
+}
+
+// repoPassphrase returns the passphrase of the signing key for the given
+// format, read from the same environment variables used when packaging.
+func repoPassphrase(format string) string {
+	if passphrase := os.Getenv("NFPM_" + strings.ToUpper(format) + "_PASSPHRASE"); passphrase != "" {
+		return passphrase
+	}
+	return os.Getenv("NFPM_PASSPHRASE")
+}
+
+func printCreated(w io.Writer, created []string) {
+	for _, name := range created {
+		fmt.Fprintf(w, "created %s\n", name)
+	}
+}
+
+type repoDebCmd struct {
+	cmd   *cobra.Command
+	opts  deb.RepoOptions
+	keyID string
+}
+
+func newRepoDebCmd() *repoDebCmd {
+	root := &repoDebCmd{}
+	cmd := &cobra.Command{
+		Use:   "deb <dir>",
+		Short: "Creates an APT repository from the deb packages in a directory",
+		Long: `Creates an APT repository from the deb packages found in the given directory
+and its subdirectories.
+
+Without --suite a flat repository is created, with the Packages and Release
+files in the given directory. With --suite the indexes are created in
+dists/<suite>/<component>/binary-<arch>, next to the pool of packages.
+
+If a key is given, the Release file is signed into InRelease and Release.gpg.
+The key passphrase is read from $NFPM_DEB_PASSPHRASE or $NFPM_PASSPHRASE.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if root.keyID != "" {
+				root.opts.KeyID = &root.keyID
+			}
+			root.opts.KeyPassphrase = repoPassphrase("deb")
+			created, err := deb.IndexRepo(args[0], root.opts)
+			if err != nil {
+				return err
+			}
+			printCreated(cmd.OutOrStdout(), created)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&root.opts.Suite, "suite", "", "suite of the repository, e.g. stable, creates a flat repository if empty")
+	cmd.Flags().StringVar(&root.opts.Codename, "codename", "", "codename of the repository, e.g. bookworm")
+	cmd.Flags().StringVar(&root.opts.Component, "component", "main", "component of the packages")
+	cmd.Flags().StringVar(&root.opts.Origin, "origin", "", "origin of the repository")
+	cmd.Flags().StringVar(&root.opts.Label, "label", "", "label of the repository")
+	cmd.Flags().StringVar(&root.opts.Description, "description", "", "description of the repository")
+	cmd.Flags().StringVarP(&root.opts.KeyFile, "key", "k", "", "PGP secret key to sign the repository with")
+	_ = cmd.MarkFlagFilename("key")
+	cmd.Flags().StringVar(&root.keyID, "key-id", "", "id of the PGP key to sign with")
+
+	root.cmd = cmd
+	return root
+}
+
+type repoRPMCmd struct {
+	cmd   *cobra.Command
+	opts  rpm.RepoOptions
+	keyID string
+}
+
+func newRepoRPMCmd() *repoRPMCmd {
+	root := &repoRPMCmd{}
+	cmd := &cobra.Command{
+		Use:   "rpm <dir>",
+		Short: "Creates a dnf/yum repository from the rpm packages in a directory",
+		Long: `Creates a dnf/yum repository from the rpm packages found in the given directory
+and its subdirectories, writing repomd.xml, primary.xml.gz, filelists.xml.gz and
+other.xml.gz into its repodata directory.
+
+If a key is given, repomd.xml is signed into repomd.xml.asc.
+The key passphrase is read from $NFPM_RPM_PASSPHRASE or $NFPM_PASSPHRASE.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if root.keyID != "" {
+				root.opts.KeyID = &root.keyID
+			}
+			root.opts.KeyPassphrase = repoPassphrase("rpm")
+			created, err := rpm.IndexRepo(args[0], root.opts)
+			if err != nil {
+				return err
+			}
+			printCreated(cmd.OutOrStdout(), created)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&root.opts.KeyFile, "key", "k", "", "PGP secret key to sign the repository with")
+	_ = cmd.MarkFlagFilename("key")
+	cmd.Flags().StringVar(&root.keyID, "key-id", "", "id of the PGP key to sign with")
+
+	root.cmd = cmd
+	return root
+}
+
+type repoAPKCmd struct {
+	cmd  *cobra.Command
+	opts apk.RepoOptions
+}
+
+func newRepoAPKCmd() *repoAPKCmd {
+	root := &repoAPKCmd{}
+	cmd := &cobra.Command{
+		Use:   "apk <dir>",
+		Short: "Creates an Alpine repository from the apk packages in a directory",
+		Long: `Creates an Alpine repository from the apk packages found in the given directory
+and its subdirectories, writing an APKINDEX.tar.gz for each architecture in
+<dir>/<arch>.
+
+As apk fetches packages as <arch>/<name>-<version>.apk, packages stored
+elsewhere are copied there.
+
+If a key is given, the indexes are signed with it.
+The key passphrase is read from $NFPM_APK_PASSPHRASE or $NFPM_PASSPHRASE.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root.opts.KeyPassphrase = repoPassphrase("apk")
+			created, err := apk.IndexRepo(args[0], root.opts)
+			if err != nil {
+				return err
+			}
+			printCreated(cmd.OutOrStdout(), created)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&root.opts.Description, "description", "", "description of the repository, e.g. v3.19")
+	cmd.Flags().StringVarP(&root.opts.KeyFile, "key", "k", "", "RSA private key to sign the indexes with")
+	_ = cmd.MarkFlagFilename("key")
+	cmd.Flags().StringVar(&root.opts.KeyName, "key-name", "", "name of the public key in /etc/apk/keys, defaults to the key file name")
+
+	root.cmd = cmd
+	return root
+}
+
+type repoArchlinuxCmd struct {
+	cmd   *cobra.Command
+	opts  arch.RepoOptions
+	keyID string
+}
+
+func newRepoArchlinuxCmd() *repoArchlinuxCmd {
+	root := &repoArchlinuxCmd{}
+	cmd := &cobra.Command{
+		Use:   "archlinux <dir>",
+		Short: "Creates a pacman repository from the Arch Linux packages in a directory",
+		Long: `Creates a pacman repository from the Arch Linux packages found in the given
+directory, writing the <name>.db and <name>.files databases next to them.
+Only the newest version of each package is indexed, as repo-add does.
+
+If a key is given, the databases are signed into <name>.db.sig and
+<name>.files.sig.
+The key passphrase is read from $NFPM_ARCHLINUX_PASSPHRASE or $NFPM_PASSPHRASE.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if root.keyID != "" {
+				root.opts.KeyID = &root.keyID
+			}
+			root.opts.KeyPassphrase = repoPassphrase("archlinux")
+			created, err := arch.IndexRepo(args[0], root.opts)
+			if err != nil {
+				return err
+			}
+			printCreated(cmd.OutOrStdout(), created)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&root.opts.Name, "name", "repo", "name of the repository, as used in pacman.conf")
+	cmd.Flags().StringVarP(&root.opts.KeyFile, "key", "k", "", "PGP secret key to sign the databases with")
+	_ = cmd.MarkFlagFilename("key")
+	cmd.Flags().StringVar(&root.keyID, "key-id", "", "id of the PGP key to sign with")
+
+	root.cmd = cmd
+	return root
+}
+
+type repoIPKCmd struct {
+	cmd *cobra.Command
+}
+
+func newRepoIPKCmd() *repoIPKCmd {
+	root := &repoIPKCmd{}
+	cmd := &cobra.Command{
+		Use:   "ipk <dir>",
+		Short: "Creates an opkg feed from the ipk packages in a directory",
+		Long: `Creates an opkg feed from the ipk packages found in the given directory and its
+subdirectories, writing the Packages and Packages.gz indexes into it.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			created, err := ipk.IndexRepo(args[0])
+			if err != nil {
+				return err
+			}
+			printCreated(cmd.OutOrStdout(), created)
+			return nil
+		},
+	}
+
+	root.cmd = cmd
+	return root
+}
@@ -6,12 +6,20 @@ import (
 
 	goversion "github.com/caarlos0/go-version"
 	"github.com/charmbracelet/fang"
-	_ "github.com/goreleaser/nfpm/v2/apk"  // apk packager
-	_ "github.com/goreleaser/nfpm/v2/arch" // archlinux packager
-	_ "github.com/goreleaser/nfpm/v2/deb"  // deb packager
-	_ "github.com/goreleaser/nfpm/v2/ipk"  // ipk packager
-	_ "github.com/goreleaser/nfpm/v2/msix" // msix packager
-	_ "github.com/goreleaser/nfpm/v2/rpm"  // rpm packager
+	_ "github.com/goreleaser/nfpm/v2/apk"      // apk packager
+	_ "github.com/goreleaser/nfpm/v2/appimage" // appimage packager
+	_ "github.com/goreleaser/nfpm/v2/arch"     // archlinux packager
+	_ "github.com/goreleaser/nfpm/v2/conda"    // conda packager
+	_ "github.com/goreleaser/nfpm/v2/deb"      // deb packager
+	_ "github.com/goreleaser/nfpm/v2/freebsd"  // freebsd packager
+	_ "github.com/goreleaser/nfpm/v2/gpkg"     // gpkg packager
+	_ "github.com/goreleaser/nfpm/v2/ipk"      // ipk packager
+	_ "github.com/goreleaser/nfpm/v2/macospkg" // macos-pkg packager
+	_ "github.com/goreleaser/nfpm/v2/msix"     // msix packager
+	_ "github.com/goreleaser/nfpm/v2/nupkg"    // nupkg packager
+	_ "github.com/goreleaser/nfpm/v2/oci"      // oci packager
+	_ "github.com/goreleaser/nfpm/v2/rpm"      // rpm packager
+	_ "github.com/goreleaser/nfpm/v2/snap"     // snap packager
 	"github.com/spf13/cobra"
 )
 
@@ -45,8 +53,8 @@ func newRootCmd(version goversion.Info, exit func(int)) *rootCmd {
 	}
 	cmd := &cobra.Command{
 		Use:               "nfpm",
-		Short:             "Packages apps on RPM, Deb, APK, Arch Linux, ipk, and MSIX formats based on a YAML configuration file",
-		Long:              `nFPM is a simple and 0-dependencies apk, arch, deb, ipk, msix, and rpm packager written in Go.`,
+		Short:             "Packages apps on RPM, Deb, APK, Arch Linux, ipk, MSIX, Chocolatey, FreeBSD, macOS, OCI, AppImage, conda, Gentoo, and snap formats based on a YAML configuration file",
+		Long:              `nFPM is a simple and 0-dependencies apk, appimage, arch, conda, deb, freebsd, gpkg, ipk, macos-pkg, msix, nupkg, oci, rpm, and snap packager written in Go.`,
 		Version:           version.String(),
 		Args:              cobra.NoArgs,
 		ValidArgsFunction: cobra.NoFileCompletions,
@@ -56,6 +64,9 @@ func newRootCmd(version goversion.Info, exit func(int)) *rootCmd {
 	cmd.AddCommand(
 		newInitCmd().cmd,
 		newPackageCmd().cmd,
+		newInspectCmd().cmd,
+		newVerifyCmd().cmd,
+		newRepoCmd().cmd,
 		newDocsCmd().cmd,
 		newSchemaCmd().cmd,
 	)
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/spf13/cobra"
+)
+
+type verifyCmd struct {
+	cmd      *cobra.Command
+	key      string
+	packager string
+}
+
+func newVerifyCmd() *verifyCmd {
+	root := &verifyCmd{}
+	cmd := &cobra.Command{
+		Use:   "verify <package>...",
+		Short: "Verifies the signatures of existing packages",
+		Long: `Verifies the signatures of existing packages against the given public key.
+
+The key is an OpenPGP public key for deb and rpm packages, a PEM RSA public key
+for apk packages and a X.509 certificate for msix packages.
+
+The digests of the zip records of msix packages are not recomputed, so their
+signatures are reported as FAIL even when all their files match.
+
+Every signature is reported as PASS or FAIL, and the command fails if any
+package is unsigned or has an invalid signature.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var failed bool
+			for _, path := range args {
+				if err := doVerify(cmd.OutOrStdout(), path, root.packager, root.key); err != nil {
+					failed = true
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: %v\n", path, err)
+				}
+			}
+			if failed {
+				return errors.New("verification failed")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&root.key, "key", "k", "", "public key or certificate to verify the signatures with")
+	_ = cmd.MarkFlagRequired("key")
+	_ = cmd.MarkFlagFilename("key")
+
+	formats := nfpm.EnumerateReaders()
+	cmd.Flags().StringVarP(&root.packager, "packager", "p", "",
+		fmt.Sprintf("format of the packages, guessed from their extension if empty [%s]", strings.Join(formats, "|")))
+	_ = cmd.RegisterFlagCompletionFunc("packager", cobra.FixedCompletions(
+		formats,
+		cobra.ShellCompDirectiveNoFileComp,
+	))
+
+	root.cmd = cmd
+	return root
+}
+
+func doVerify(w io.Writer, path, packager, key string) error {
+	if packager == "" {
+		var err error
+		packager, err = nfpm.FormatFromFileName(path)
+		if err != nil {
+			return fmt.Errorf("%w, please specify the packager", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	results, err := nfpm.Verify(f, packager, key)
+	for _, result := range results {
+		status := "PASS"
+		if !result.Valid() {
+			status = "FAIL"
+		}
+		line := strings.Join(strings.Fields(strings.Join([]string{
+			status, path, result.Type, result.Name, result.KeyID,
+		}, " ")), " ")
+		if !result.Valid() {
+			line += ": " + result.Err.Error()
+		}
+		fmt.Fprintln(w, line)
+	}
+	if err != nil && len(results) > 0 {
+		// every failure has already been reported above.
+		return nfpm.ErrInvalidSignature
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+// Package deb822 reads and writes the RFC 822-like control data format used by
+// deb and ipk packages and by their repository indexes.
+//
+// See https://manpages.debian.org/bookworm/dpkg-dev/deb822.5.en.html
+package deb822
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Field is a single "Name: Value" entry of a paragraph. Multiline values keep
+// their continuation lines, including the leading space, separated by "\n".
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Paragraph is an ordered list of fields.
+type Paragraph []Field
+
+// Get returns the value of the field with the given name, matched case
+// insensitively, or an empty string if it is not present.
+func (p Paragraph) Get(name string) string {
+	for _, f := range p {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Set replaces the value of the given field, or appends it if it is not
+// present yet.
+func (p *Paragraph) Set(name, value string) {
+	for i, f := range *p {
+		if strings.EqualFold(f.Name, name) {
+			(*p)[i].Value = value
+			return
+		}
+	}
+	*p = append(*p, Field{Name: name, Value: value})
+}
+
+// WriteTo writes the paragraph in control file format, without a trailing
+// blank line. Empty fields are skipped.
+func (p Paragraph) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, f := range p {
+		if f.Value == "" {
+			continue
+		}
+		n, err := fmt.Fprintf(w, "%s: %s\n", f.Name, f.Value)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Parse reads all paragraphs from r. Paragraphs are separated by blank lines;
+// PGP armor lines and comments are not supported.
+func Parse(r io.Reader) ([]Paragraph, error) {
+	var (
+		result  []Paragraph
+		current Paragraph
+	)
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			if len(current) > 0 {
+				result = append(result, current)
+				current = nil
+			}
+		case line[0] == ' ' || line[0] == '\t':
+			if len(current) == 0 {
+				return nil, fmt.Errorf("continuation line without a field: %q", line)
+			}
+			current[len(current)-1].Value += "\n" + line
+		default:
+			name, value, ok := strings.Cut(line, ":")
+			if !ok {
+				return nil, fmt.Errorf("malformed field: %q", line)
+			}
+			current = append(current, Field{
+				Name:  strings.TrimSpace(name),
+				Value: strings.TrimSpace(value),
+			})
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(current) > 0 {
+		result = append(result, current)
+	}
+	return result, nil
+}
+
+// ParseOne reads a single paragraph from r, as found in the control file of a
+// package.
+func ParseOne(r io.Reader) (Paragraph, error) {
+	paragraphs, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(paragraphs) == 0 {
+		return nil, fmt.Errorf("no control paragraph found")
+	}
+	return paragraphs[0], nil
+}
+
+// List splits a comma separated relationship field, e.g. Depends, into its
+// items.
+func List(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.Join(strings.Fields(item), " ")
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Multiline decodes a multiline field value, such as Description, into plain
+// text: continuation lines lose their leading space and lines consisting of a
+// single dot become empty lines.
+func Multiline(value string) string {
+	lines := strings.Split(value, "\n")
+	for i, line := range lines {
+		if i == 0 {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if line == "." {
+			line = ""
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
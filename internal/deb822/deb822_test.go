@@ -0,0 +1,62 @@
+package deb822
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testControl = `Package: foo
+Version: 1.0.0-1
+Depends: bash,  git (>= 2.0),
+ zsh
+Description: Foo does things
+ and more things
+ .
+ even more
+
+Package: bar
+Version: 2.0.0
+`
+
+func TestParse(t *testing.T) {
+	paragraphs, err := Parse(strings.NewReader(testControl))
+	require.NoError(t, err)
+	require.Len(t, paragraphs, 2)
+
+	foo := paragraphs[0]
+	require.Equal(t, "foo", foo.Get("package"))
+	require.Equal(t, []string{"bash", "git (>= 2.0)", "zsh"}, List(foo.Get("Depends")))
+	require.Equal(t, "Foo does things\nand more things\n\neven more", Multiline(foo.Get("Description")))
+	require.Empty(t, foo.Get("Section"))
+
+	require.Equal(t, "2.0.0", paragraphs[1].Get("Version"))
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse(strings.NewReader(" continuation\n"))
+	require.Error(t, err)
+	_, err = Parse(strings.NewReader("no colon\n"))
+	require.Error(t, err)
+	_, err = ParseOne(strings.NewReader("\n\n"))
+	require.Error(t, err)
+}
+
+func TestWriteTo(t *testing.T) {
+	var p Paragraph
+	p.Set("Package", "foo")
+	p.Set("Section", "")
+	p.Set("Version", "1.0.0")
+	p.Set("package", "bar")
+
+	var buf bytes.Buffer
+	_, err := p.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, "Package: bar\nVersion: 1.0.0\n", buf.String())
+
+	parsed, err := ParseOne(&buf)
+	require.NoError(t, err)
+	require.Equal(t, p[0], parsed[0])
+}
@@ -0,0 +1,461 @@
+// Package debuginfo splits the debug symbols out of ELF files, so they can be
+// shipped in a separate package.
+package debuginfo
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"path"
+	"strings"
+)
+
+// Dir is where debuggers look for the debug files of installed binaries.
+const Dir = "/usr/lib/debug"
+
+// ErrUnsupported happens when an ELF file can't be stripped in pure Go, e.g.
+// because it has more sections than fit in its header.
+var ErrUnsupported = errors.New("unsupported ELF file")
+
+// File is an ELF file split into its stripped binary and its debug file.
+type File struct {
+	// Stripped is the ELF file without its debug sections, with a
+	// .gnu_debuglink section pointing to its debug file.
+	Stripped []byte
+	// Debug is the debug file, the ELF file with its debug sections and
+	// symbols only, as objcopy --only-keep-debug makes it.
+	Debug []byte
+	// Path of the debug file, e.g.
+	// /usr/lib/debug/.build-id/ab/cdef0123.debug.
+	Path string
+	// BuildID is the hex encoded GNU build id of the file, if any.
+	BuildID string
+}
+
+// header holds the fields of the ELF header locating the program and section
+// headers, whatever the ELF class.
+type header struct {
+	is64                    bool
+	phoff, phentsize, phnum uint64
+	shoff, shentsize, shnum uint64
+	shstrndx                uint64
+}
+
+// section is a section header, whatever the ELF class.
+type section struct {
+	nameIndex uint32
+	typ       elf.SectionType
+	flags     elf.SectionFlag
+	addr      uint64
+	offset    uint64
+	size      uint64
+	link      uint32
+	info      uint32
+	align     uint64
+	entsize   uint64
+}
+
+// Split splits the ELF file installed at the given destination. The debug file
+// is placed in the build-id directory if the file has a GNU build id, next to
+// the destination in Dir otherwise, e.g. /usr/lib/debug/usr/bin/foo.debug.
+//
+// It returns nil if data is not an ELF executable or shared library with debug
+// sections.
+func Split(data []byte, destination string) (*File, error) {
+	if !bytes.HasPrefix(data, []byte(elf.ELFMAG)) {
+		return nil, nil
+	}
+	ef, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if (ef.Type != elf.ET_EXEC && ef.Type != elf.ET_DYN) || !hasDebugSections(ef) {
+		// objects and archives are left alone.
+		return nil, nil
+	}
+	buildID, err := readBuildID(ef)
+	if err != nil {
+		return nil, err
+	}
+
+	debugPath := path.Join(Dir, path.Clean("/"+destination)+".debug")
+	if len(buildID) > 2 {
+		debugPath = path.Join(Dir, ".build-id", buildID[:2], buildID[2:]+".debug")
+	}
+	debug, err := onlyKeepDebug(ef, data)
+	if err != nil {
+		return nil, err
+	}
+	stripped, err := strip(ef, data, path.Base(debugPath), crc32.ChecksumIEEE(debug))
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		Stripped: stripped,
+		Debug:    debug,
+		Path:     debugPath,
+		BuildID:  buildID,
+	}, nil
+}
+
+func isDebugSection(s *elf.SectionHeader) bool {
+	return s.Flags&elf.SHF_ALLOC == 0 &&
+		(strings.HasPrefix(s.Name, ".debug_") || strings.HasPrefix(s.Name, ".zdebug_"))
+}
+
+func hasDebugSections(ef *elf.File) bool {
+	for _, s := range ef.Sections {
+		if isDebugSection(&s.SectionHeader) {
+			return true
+		}
+	}
+	return false
+}
+
+// readBuildID returns the hex encoded descriptor of the NT_GNU_BUILD_ID note.
+func readBuildID(ef *elf.File) (string, error) {
+	const ntGNUBuildID = 3
+	for _, s := range ef.Sections {
+		if s.Type != elf.SHT_NOTE {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return "", err
+		}
+		for len(data) >= 12 {
+			nameSize := ef.ByteOrder.Uint32(data[0:])
+			descSize := ef.ByteOrder.Uint32(data[4:])
+			typ := ef.ByteOrder.Uint32(data[8:])
+			data = data[12:]
+			nameEnd := align(uint64(nameSize), 4)
+			descEnd := nameEnd + align(uint64(descSize), 4)
+			if descEnd > uint64(len(data)) {
+				break
+			}
+			name := string(bytes.TrimRight(data[:nameSize], "\x00"))
+			if name == "GNU" && typ == ntGNUBuildID {
+				return hex.EncodeToString(data[nameEnd : nameEnd+uint64(descSize)]), nil
+			}
+			data = data[descEnd:]
+		}
+	}
+	return "", nil
+}
+
+// readSections reads the ELF header and the section headers of the file.
+func readSections(ef *elf.File, data []byte) (header, []section, error) {
+	order := ef.ByteOrder
+	h := header{is64: ef.Class == elf.ELFCLASS64}
+	if h.is64 {
+		h.phoff = order.Uint64(data[0x20:])
+		h.shoff = order.Uint64(data[0x28:])
+		h.phentsize = uint64(order.Uint16(data[0x36:]))
+		h.phnum = uint64(order.Uint16(data[0x38:]))
+		h.shentsize = uint64(order.Uint16(data[0x3a:]))
+		h.shnum = uint64(order.Uint16(data[0x3c:]))
+		h.shstrndx = uint64(order.Uint16(data[0x3e:]))
+	} else {
+		h.phoff = uint64(order.Uint32(data[0x1c:]))
+		h.shoff = uint64(order.Uint32(data[0x20:]))
+		h.phentsize = uint64(order.Uint16(data[0x2a:]))
+		h.phnum = uint64(order.Uint16(data[0x2c:]))
+		h.shentsize = uint64(order.Uint16(data[0x2e:]))
+		h.shnum = uint64(order.Uint16(data[0x30:]))
+		h.shstrndx = uint64(order.Uint16(data[0x32:]))
+	}
+	if h.shnum == 0 || h.shnum != uint64(len(ef.Sections)) || h.shstrndx == 0 || h.shstrndx >= h.shnum {
+		return h, nil, fmt.Errorf("%w: extended section numbering", ErrUnsupported)
+	}
+	if h.shoff+h.shnum*h.shentsize > uint64(len(data)) {
+		return h, nil, errors.New("section headers are out of the file")
+	}
+	if h.phoff+h.phnum*h.phentsize > uint64(len(data)) {
+		return h, nil, errors.New("program headers are out of the file")
+	}
+
+	sections := make([]section, h.shnum)
+	for i, s := range ef.Sections {
+		if s.Type == elf.SHT_SYMTAB_SHNDX {
+			return h, nil, fmt.Errorf("%w: extended section indexes", ErrUnsupported)
+		}
+		if s.Type != elf.SHT_NOBITS && s.Offset+s.FileSize > uint64(len(data)) {
+			return h, nil, fmt.Errorf("section %s is out of the file", s.Name)
+		}
+		sections[i] = section{
+			// the name index isn't exposed by debug/elf.
+			nameIndex: order.Uint32(data[h.shoff+uint64(i)*h.shentsize:]),
+			typ:       s.Type,
+			flags:     s.Flags,
+			addr:      s.Addr,
+			offset:    s.Offset,
+			size:      s.FileSize,
+			link:      s.Link,
+			info:      s.Info,
+			align:     s.Addralign,
+			entsize:   s.Entsize,
+		}
+	}
+	return h, sections, nil
+}
+
+// onlyKeepDebug returns the debug file of the ELF file, as objcopy
+// --only-keep-debug does: the loaded sections are kept without their contents,
+// as NOBITS sections, and so are the segments, so debuggers can map the debug
+// sections and the symbols to the stripped file. The headers and the notes, the
+// build id in particular, are kept as is at the start of the file.
+func onlyKeepDebug(ef *elf.File, data []byte) ([]byte, error) {
+	order := ef.ByteOrder
+	h, sections, err := readSections(ef, data)
+	if err != nil {
+		return nil, err
+	}
+
+	start := max(elfHeaderSize(h.is64), h.phoff+h.phnum*h.phentsize)
+	for _, s := range sections {
+		if s.flags&elf.SHF_ALLOC != 0 && s.typ == elf.SHT_NOTE {
+			start = max(start, s.offset+s.size)
+		}
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:start])
+	for i := range sections {
+		s := &sections[i]
+		switch {
+		case i == 0 || s.typ == elf.SHT_NOBITS || s.flags&elf.SHF_ALLOC != 0 && s.typ == elf.SHT_NOTE:
+			continue
+		case s.flags&elf.SHF_ALLOC != 0:
+			s.typ = elf.SHT_NOBITS
+			s.offset = min(s.offset, uint64(out.Len()))
+			continue
+		}
+		content := data[s.offset : s.offset+s.size]
+		pad(out, s.align)
+		s.offset = uint64(out.Len())
+		out.Write(content)
+	}
+
+	newShoff, err := writeSectionHeaders(out, order, h.is64, sections)
+	if err != nil {
+		return nil, err
+	}
+	result := out.Bytes()
+	setShoff(result, order, h.is64, newShoff)
+	// the segments only have the start of the file left.
+	for i := range h.phnum {
+		entry := result[h.phoff+i*h.phentsize:]
+		if h.is64 {
+			off, filesz := order.Uint64(entry[0x08:]), order.Uint64(entry[0x20:])
+			order.PutUint64(entry[0x20:], min(filesz, start-min(off, start)))
+		} else {
+			off, filesz := uint64(order.Uint32(entry[0x04:])), uint64(order.Uint32(entry[0x10:]))
+			order.PutUint32(entry[0x10:], uint32(min(filesz, start-min(off, start))))
+		}
+	}
+	return result, nil
+}
+
+// strip removes the debug sections from the ELF file and adds a
+// .gnu_debuglink section, as objcopy --strip-debug --add-gnu-debuglink does.
+//
+// The loaded part of the file is kept as is, the sections that are not loaded
+// and the section header table are rewritten after it.
+func strip(ef *elf.File, data []byte, debugLink string, debugCRC uint32) ([]byte, error) {
+	order := ef.ByteOrder
+	h, sections, err := readSections(ef, data)
+	if err != nil {
+		return nil, err
+	}
+	is64, shnum, shstrndx := h.is64, h.shnum, h.shstrndx
+
+	// the loaded part of the file ends with its last segment or loaded section.
+	end := max(elfHeaderSize(is64), h.phoff+h.phnum*h.phentsize)
+	for _, p := range ef.Progs {
+		end = max(end, p.Off+p.Filesz)
+	}
+	for _, s := range sections {
+		if s.flags&elf.SHF_ALLOC != 0 && s.typ != elf.SHT_NOBITS {
+			end = max(end, s.offset+s.size)
+		}
+	}
+	if end > uint64(len(data)) {
+		return nil, fmt.Errorf("segments are out of the file")
+	}
+
+	newIndexes := make([]int, shnum)
+	var kept []section
+	for i, s := range ef.Sections {
+		if isDebugSection(&s.SectionHeader) || s.Name == ".gnu_debuglink" {
+			newIndexes[i] = -1
+			continue
+		}
+		newIndexes[i] = len(kept)
+		kept = append(kept, sections[i])
+	}
+	remap := func(index uint32) uint32 {
+		if index == 0 || uint64(index) >= shnum || newIndexes[index] < 0 {
+			return 0
+		}
+		return uint32(newIndexes[index])
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:end])
+
+	shstrtab := &kept[newIndexes[shstrndx]]
+	names := append([]byte{}, data[shstrtab.offset:shstrtab.offset+shstrtab.size]...)
+	debugLinkName := uint32(len(names))
+	names = append(names, ".gnu_debuglink\x00"...)
+
+	for i := range kept {
+		s := &kept[i]
+		s.link = remap(s.link)
+		if s.typ == elf.SHT_REL || s.typ == elf.SHT_RELA || s.flags&elf.SHF_INFO_LINK != 0 {
+			s.info = remap(s.info)
+		}
+		if i == 0 || s.flags&elf.SHF_ALLOC != 0 || s.typ == elf.SHT_NOBITS {
+			continue
+		}
+		content := data[s.offset : s.offset+s.size]
+		if s == shstrtab {
+			content = names
+			s.size = uint64(len(names))
+		}
+		pad(out, s.align)
+		s.offset = uint64(out.Len())
+		out.Write(content)
+	}
+
+	link := append([]byte(debugLink), 0)
+	link = append(link, make([]byte, align(uint64(len(link)), 4)-uint64(len(link)))...)
+	crc := make([]byte, 4)
+	order.PutUint32(crc, debugCRC)
+	link = append(link, crc...)
+	pad(out, 4)
+	kept = append(kept, section{
+		nameIndex: debugLinkName,
+		typ:       elf.SHT_PROGBITS,
+		offset:    uint64(out.Len()),
+		size:      uint64(len(link)),
+		align:     4,
+	})
+	out.Write(link)
+
+	newShoff, err := writeSectionHeaders(out, order, is64, kept)
+	if err != nil {
+		return nil, err
+	}
+
+	result := out.Bytes()
+	for _, s := range kept {
+		if s.typ == elf.SHT_SYMTAB || s.typ == elf.SHT_DYNSYM {
+			remapSymbols(result[s.offset:s.offset+s.size], order, is64, remap)
+		}
+	}
+	setShoff(result, order, is64, newShoff)
+	if is64 {
+		order.PutUint16(result[0x3c:], uint16(len(kept)))
+		order.PutUint16(result[0x3e:], uint16(newIndexes[shstrndx]))
+	} else {
+		order.PutUint16(result[0x30:], uint16(len(kept)))
+		order.PutUint16(result[0x32:], uint16(newIndexes[shstrndx]))
+	}
+	return result, nil
+}
+
+// writeSectionHeaders writes the section header table, aligned, and returns
+// its offset.
+func writeSectionHeaders(out *bytes.Buffer, order binary.ByteOrder, is64 bool, sections []section) (uint64, error) {
+	if is64 {
+		pad(out, 8)
+	} else {
+		pad(out, 4)
+	}
+	shoff := uint64(out.Len())
+	for _, s := range sections {
+		var err error
+		if is64 {
+			err = binary.Write(out, order, elf.Section64{
+				Name:      s.nameIndex,
+				Type:      uint32(s.typ),
+				Flags:     uint64(s.flags),
+				Addr:      s.addr,
+				Off:       s.offset,
+				Size:      s.size,
+				Link:      s.link,
+				Info:      s.info,
+				Addralign: s.align,
+				Entsize:   s.entsize,
+			})
+		} else {
+			err = binary.Write(out, order, elf.Section32{
+				Name:      s.nameIndex,
+				Type:      uint32(s.typ),
+				Flags:     uint32(s.flags),
+				Addr:      uint32(s.addr),
+				Off:       uint32(s.offset),
+				Size:      uint32(s.size),
+				Link:      s.link,
+				Info:      s.info,
+				Addralign: uint32(s.align),
+				Entsize:   uint32(s.entsize),
+			})
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return shoff, nil
+}
+
+// setShoff sets the offset of the section header table in the ELF header.
+func setShoff(data []byte, order binary.ByteOrder, is64 bool, shoff uint64) {
+	if is64 {
+		order.PutUint64(data[0x28:], shoff)
+	} else {
+		order.PutUint32(data[0x20:], uint32(shoff))
+	}
+}
+
+// remapSymbols updates the section indexes of the symbols of a symbol table.
+// Symbols defined in a removed section become absolute.
+func remapSymbols(table []byte, order binary.ByteOrder, is64 bool, remap func(uint32) uint32) {
+	size, offset := elf.Sym32Size, 14
+	if is64 {
+		size, offset = elf.Sym64Size, 6
+	}
+	for i := 0; i+size <= len(table); i += size {
+		index := order.Uint16(table[i+offset:])
+		if index == uint16(elf.SHN_UNDEF) || index >= uint16(elf.SHN_LORESERVE) {
+			continue
+		}
+		newIndex := uint16(remap(uint32(index)))
+		if newIndex == 0 {
+			newIndex = uint16(elf.SHN_ABS)
+		}
+		order.PutUint16(table[i+offset:], newIndex)
+	}
+}
+
+func elfHeaderSize(is64 bool) uint64 {
+	if is64 {
+		return 0x40
+	}
+	return 0x34
+}
+
+func align(n, alignment uint64) uint64 {
+	if alignment <= 1 {
+		return n
+	}
+	return (n + alignment - 1) / alignment * alignment
+}
+
+func pad(buf *bytes.Buffer, alignment uint64) {
+	n := uint64(buf.Len())
+	buf.Write(make([]byte, align(n, alignment)-n))
+}
@@ -0,0 +1,182 @@
+package debuginfo
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The ELF files in testdata are built on linux/amd64 with:
+//
+//	gcc -g -O1 -Wl,--build-id=sha1 -o hello hello.c
+//	gcc -g -O1 -Wl,--build-id=none -o hello-nobuildid hello.c
+//	gcc -O1 -s -o hello-stripped hello.c
+
+func TestSplit(t *testing.T) {
+	data, err := os.ReadFile("testdata/hello")
+	require.NoError(t, err)
+	f, err := Split(data, "/usr/bin/hello")
+	require.NoError(t, err)
+	require.NotNil(t, f)
+	require.Len(t, f.BuildID, 40)
+	require.Equal(t, "/usr/lib/debug/.build-id/"+f.BuildID[:2]+"/"+f.BuildID[2:]+".debug", f.Path)
+	require.Less(t, len(f.Stripped), len(data))
+	require.Less(t, len(f.Debug), len(data))
+
+	original, err := elf.NewFile(bytes.NewReader(data))
+	require.NoError(t, err)
+	stripped, err := elf.NewFile(bytes.NewReader(f.Stripped))
+	require.NoError(t, err)
+
+	var names []string
+	for _, s := range stripped.Sections {
+		require.False(t, strings.HasPrefix(s.Name, ".debug_"), s.Name)
+		names = append(names, s.Name)
+	}
+	require.Contains(t, names, ".symtab")
+	require.Equal(t, ".gnu_debuglink", names[len(names)-1])
+
+	link, err := stripped.Section(".gnu_debuglink").Data()
+	require.NoError(t, err)
+	// the name is padded to 4 bytes, followed by the checksum of the debug file.
+	require.Len(t, link, 52)
+	require.Equal(t, f.BuildID[2:]+".debug\x00\x00\x00\x00", string(link[:48]))
+	require.Equal(t, crc32.ChecksumIEEE(f.Debug), binary.LittleEndian.Uint32(link[48:]))
+
+	// the symbols are kept, and still point to the same sections.
+	symbols, err := original.Symbols()
+	require.NoError(t, err)
+	strippedSymbols, err := stripped.Symbols()
+	require.NoError(t, err)
+	require.Len(t, strippedSymbols, len(symbols))
+	for i, sym := range symbols {
+		require.Equal(t, sym.Name, strippedSymbols[i].Name)
+		require.Equal(t, sym.Value, strippedSymbols[i].Value)
+		if sym.Section > 0 && sym.Section < elf.SHN_LORESERVE {
+			require.Equal(
+				t,
+				original.Sections[sym.Section].Name,
+				stripped.Sections[strippedSymbols[i].Section].Name,
+			)
+		}
+	}
+
+	// the loaded part of the file is unchanged.
+	text, err := original.Section(".text").Data()
+	require.NoError(t, err)
+	strippedText, err := stripped.Section(".text").Data()
+	require.NoError(t, err)
+	require.Equal(t, text, strippedText)
+
+	// the debug file keeps the debug sections, the symbols and the build id,
+	// the loaded sections have no contents.
+	debug, err := elf.NewFile(bytes.NewReader(f.Debug))
+	require.NoError(t, err)
+	require.Len(t, debug.Sections, len(original.Sections))
+	for i, s := range original.Sections {
+		d := debug.Sections[i]
+		require.Equal(t, s.Name, d.Name)
+		require.Equal(t, s.Addr, d.Addr, s.Name)
+		require.Equal(t, s.Size, d.Size, s.Name)
+		if s.Flags&elf.SHF_ALLOC != 0 && s.Type != elf.SHT_NOTE {
+			require.Equal(t, elf.SHT_NOBITS, d.Type, s.Name)
+			continue
+		}
+		require.Equal(t, s.Type, d.Type, s.Name)
+		if s.Type != elf.SHT_NOBITS && s.Type != elf.SHT_NULL {
+			want, err := s.Data()
+			require.NoError(t, err)
+			got, err := d.Data()
+			require.NoError(t, err)
+			require.Equal(t, want, got, s.Name)
+		}
+	}
+	require.Len(t, debug.Progs, len(original.Progs))
+	for i, p := range debug.Progs {
+		require.Equal(t, original.Progs[i].Off, p.Off)
+		// only the headers and the notes are left.
+		if p.Filesz > 0 {
+			require.LessOrEqual(t, p.Off+p.Filesz, uint64(0x400))
+		}
+	}
+	debugSymbols, err := debug.Symbols()
+	require.NoError(t, err)
+	require.Equal(t, symbols, debugSymbols)
+	buildID, err := readBuildID(debug)
+	require.NoError(t, err)
+	require.Equal(t, f.BuildID, buildID)
+	dwarf, err := debug.DWARF()
+	require.NoError(t, err)
+	_, err = dwarf.Reader().Next()
+	require.NoError(t, err)
+
+	if objcopy, err := exec.LookPath("objcopy"); err == nil {
+		// objcopy lays the debug file out differently, but it has the same
+		// sections.
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "hello"), data, 0o644))
+		require.NoError(t, exec.Command(objcopy, "--only-keep-debug", filepath.Join(dir, "hello"), filepath.Join(dir, "hello.debug")).Run())
+		want, err := elf.Open(filepath.Join(dir, "hello.debug"))
+		require.NoError(t, err)
+		defer want.Close()
+		require.Len(t, debug.Sections, len(want.Sections))
+		for i, s := range want.Sections {
+			require.Equal(t, s.SectionHeader.Name, debug.Sections[i].Name)
+			require.Equal(t, s.Type, debug.Sections[i].Type, s.Name)
+		}
+	}
+
+	again, err := Split(f.Stripped, "/usr/bin/hello")
+	require.NoError(t, err)
+	require.Nil(t, again)
+
+	if runtime.GOOS == "linux" && runtime.GOARCH == "amd64" {
+		bin := filepath.Join(t.TempDir(), "hello")
+		require.NoError(t, os.WriteFile(bin, f.Stripped, 0o755))
+		out, err := exec.Command(bin).Output()
+		require.NoError(t, err)
+		require.Equal(t, "hello 42\n", string(out))
+	}
+}
+
+func TestSplitNoBuildID(t *testing.T) {
+	data, err := os.ReadFile("testdata/hello-nobuildid")
+	require.NoError(t, err)
+	f, err := Split(data, "/usr/bin/hello")
+	require.NoError(t, err)
+	require.NotNil(t, f)
+	require.Empty(t, f.BuildID)
+	require.Equal(t, "/usr/lib/debug/usr/bin/hello.debug", f.Path)
+
+	stripped, err := elf.NewFile(bytes.NewReader(f.Stripped))
+	require.NoError(t, err)
+	link, err := stripped.Section(".gnu_debuglink").Data()
+	require.NoError(t, err)
+	require.Equal(t, "hello.debug\x00", string(link[:12]))
+}
+
+func TestSplitNothing(t *testing.T) {
+	for _, name := range []string{"hello-stripped", "hello.c"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", name))
+			require.NoError(t, err)
+			f, err := Split(data, "/usr/bin/hello")
+			require.NoError(t, err)
+			require.Nil(t, f)
+		})
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	_, err := Split([]byte(elf.ELFMAG+"nope"), "/usr/bin/hello")
+	require.Error(t, err)
+}
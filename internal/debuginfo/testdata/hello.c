@@ -0,0 +1,8 @@
+#include <stdio.h>
+
+static int answer(void) { return 42; }
+
+int main(void) {
+	printf("hello %d\n", answer());
+	return 0;
+}
@@ -0,0 +1,152 @@
+// Package shlibs finds the shared libraries needed and provided by the ELF
+// files of a package.
+package shlibs
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"sort"
+
+	"github.com/goreleaser/nfpm/v2/files"
+)
+
+// Library is a shared library, identified by its soname, e.g. libc.so.6.
+type Library struct {
+	Soname string
+	// Class64 is set for 64-bit libraries.
+	Class64 bool
+	// Versions of the symbols needed from or defined by the library, e.g.
+	// GLIBC_2.34, sorted.
+	Versions []string
+	// Destination of a provided library in the package.
+	Destination string
+}
+
+// Libraries are the shared libraries needed and provided by the ELF files of
+// a package. The libraries provided by the package are not in Needed.
+type Libraries struct {
+	Needed   []Library
+	Provided []Library
+}
+
+type libraryKey struct {
+	soname  string
+	class64 bool
+}
+
+// Find parses the regular files of the given contents that are ELF files and
+// returns the shared libraries they need and provide. Other files are
+// ignored.
+func Find(contents files.Contents) (*Libraries, error) {
+	needed := map[libraryKey]*Library{}
+	provided := map[libraryKey]*Library{}
+	for _, content := range contents {
+		if content.Type != files.TypeFile {
+			continue
+		}
+		if err := findInFile(content, needed, provided); err != nil {
+			return nil, fmt.Errorf("cannot read ELF file %s: %w", content.Source, err)
+		}
+	}
+	for key := range provided {
+		delete(needed, key)
+	}
+	return &Libraries{
+		Needed:   sorted(needed),
+		Provided: sorted(provided),
+	}, nil
+}
+
+func findInFile(content *files.Content, needed, provided map[libraryKey]*Library) error {
+	f, err := os.Open(content.Source)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint: errcheck
+
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := io.ReadFull(f, magic); err != nil || !bytes.Equal(magic, []byte(elf.ELFMAG)) {
+		// not an ELF file.
+		return nil
+	}
+	ef, err := elf.NewFile(f)
+	if err != nil {
+		return err
+	}
+	defer ef.Close() // nolint: errcheck
+	if ef.Section(".dynamic") == nil {
+		// statically linked.
+		return nil
+	}
+	class64 := ef.Class == elf.ELFCLASS64
+
+	sonames, err := ef.DynString(elf.DT_NEEDED)
+	if err != nil {
+		return err
+	}
+	for _, soname := range sonames {
+		add(needed, libraryKey{soname, class64}, "")
+	}
+	needs, err := ef.DynamicVersionNeeds()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return err
+	}
+	for _, need := range needs {
+		lib := add(needed, libraryKey{need.Name, class64}, "")
+		for _, dep := range need.Needs {
+			lib.Versions = append(lib.Versions, dep.Dep)
+		}
+	}
+
+	sonames, err = ef.DynString(elf.DT_SONAME)
+	if err != nil {
+		return err
+	}
+	if len(sonames) == 0 {
+		// an executable or a plugin, which can't be linked against.
+		return nil
+	}
+	lib := add(provided, libraryKey{sonames[0], class64}, path.Clean(content.Destination))
+	versions, err := ef.DynamicVersions()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return err
+	}
+	for _, version := range versions {
+		// the base version is the soname itself.
+		if version.Flags&elf.VER_FLG_BASE == 0 {
+			lib.Versions = append(lib.Versions, version.Name)
+		}
+	}
+	return nil
+}
+
+func add(libs map[libraryKey]*Library, key libraryKey, destination string) *Library {
+	lib, ok := libs[key]
+	if !ok {
+		lib = &Library{Soname: key.soname, Class64: key.class64, Destination: destination}
+		libs[key] = lib
+	}
+	return lib
+}
+
+func sorted(libs map[libraryKey]*Library) []Library {
+	result := make([]Library, 0, len(libs))
+	for _, lib := range libs {
+		sort.Strings(lib.Versions)
+		lib.Versions = slices.Compact(lib.Versions)
+		result = append(result, *lib)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Soname != result[j].Soname {
+			return result[i].Soname < result[j].Soname
+		}
+		return !result[i].Class64 && result[j].Class64
+	})
+	return result
+}
@@ -0,0 +1,46 @@
+package shlibs
+
+import (
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+// The ELF files in testdata are built on linux/amd64 with:
+//
+//	gcc -shared -fPIC -Os -s -Wl,-soname,libfoo.so.1 -Wl,--version-script=foo.map -o libfoo.so.1.2.3 foo.c -lm
+//	gcc -Os -s -o bar bar.c -L. -l:libfoo.so.1.2.3
+
+func TestFind(t *testing.T) {
+	libs, err := Find(files.Contents{
+		{Source: "testdata/bar", Destination: "/usr/bin/bar", Type: files.TypeFile},
+		{Source: "testdata/libfoo.so.1.2.3", Destination: "/usr/lib/libfoo.so.1.2.3", Type: files.TypeFile},
+		{Source: "testdata/foo.c", Destination: "/usr/share/doc/foo/foo.c", Type: files.TypeFile},
+		{Source: "libfoo.so.1.2.3", Destination: "/usr/lib/libfoo.so.1", Type: files.TypeSymlink},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Library{
+		{Soname: "libc.so.6", Class64: true, Versions: []string{"GLIBC_2.2.5", "GLIBC_2.34"}},
+		{Soname: "libm.so.6", Class64: true, Versions: []string{"GLIBC_2.2.5"}},
+	}, libs.Needed)
+	require.Equal(t, []Library{
+		{Soname: "libfoo.so.1", Class64: true, Versions: []string{"FOO_1.0"}, Destination: "/usr/lib/libfoo.so.1.2.3"},
+	}, libs.Provided)
+}
+
+func TestFindNoLibraries(t *testing.T) {
+	libs, err := Find(files.Contents{
+		{Source: "testdata/foo.c", Destination: "/usr/share/doc/foo/foo.c", Type: files.TypeFile},
+	})
+	require.NoError(t, err)
+	require.Empty(t, libs.Needed)
+	require.Empty(t, libs.Provided)
+}
+
+func TestFindError(t *testing.T) {
+	_, err := Find(files.Contents{
+		{Source: "testdata/nope", Destination: "/usr/bin/nope", Type: files.TypeFile},
+	})
+	require.Error(t, err)
+}
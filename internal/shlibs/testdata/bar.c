@@ -0,0 +1,8 @@
+#include <stdio.h>
+
+double foo(double x);
+
+int main(void) {
+	printf("%f\n", foo(2));
+	return 0;
+}
@@ -0,0 +1,3 @@
+#include <math.h>
+
+double foo(double x) { return sqrt(x); }
@@ -11,6 +11,7 @@ import (
 	"unicode"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/goreleaser/nfpm/v2"
@@ -168,11 +169,57 @@ func PGPReadMessage(message []byte, armoredPubKeyFile string) (plaintext []byte,
 	}
 
 	block, _ := clearsign.Decode(message)
+	if block == nil {
+		return nil, errNoClearsignedMessage
+	}
 	_, err = block.VerifySignature(keyring, nil)
 
 	return block.Plaintext, err
 }
 
+// PGPSignatureKeyID returns the hexadecimal ID of the key that issued the given
+// signature. The signature can be binary, ASCII-armored or a clearsigned
+// message.
+func PGPSignatureKeyID(signature []byte) (string, error) {
+	var body io.Reader = bytes.NewReader(signature)
+	switch {
+	case bytes.Contains(signature, []byte("-----BEGIN PGP SIGNED MESSAGE-----")):
+		block, _ := clearsign.Decode(signature)
+		if block == nil {
+			return "", errNoSignature
+		}
+		body = block.ArmoredSignature.Body
+	case isASCII(signature):
+		block, err := armor.Decode(body)
+		if err != nil {
+			return "", fmt.Errorf("decoding armored signature: %w", err)
+		}
+		body = block.Body
+	}
+
+	reader := packet.NewReader(body)
+	for {
+		pkt, err := reader.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", errNoSignature
+			}
+			return "", fmt.Errorf("reading signature: %w", err)
+		}
+		sig, ok := pkt.(*packet.Signature)
+		if !ok {
+			continue
+		}
+		if sig.IssuerKeyId != nil {
+			return fmt.Sprintf("%016x", *sig.IssuerKeyId), nil
+		}
+		if len(sig.IssuerFingerprint) >= 8 {
+			return fmt.Sprintf("%x", sig.IssuerFingerprint[len(sig.IssuerFingerprint)-8:]), nil
+		}
+		return "", errNoSignature
+	}
+}
+
 func parseKeyID(hexKeyID *string) (uint64, error) {
 	if hexKeyID == nil || *hexKeyID == "" {
 		return 0, nil
@@ -186,9 +233,11 @@ func parseKeyID(hexKeyID *string) (uint64, error) {
 }
 
 var (
-	errMoreThanOneKey = errors.New("more than one signing key in keyring")
-	errNoKeys         = errors.New("no signing key in keyring")
-	errNoPassword     = errors.New("key is encrypted but no passphrase was provided")
+	errMoreThanOneKey       = errors.New("more than one signing key in keyring")
+	errNoKeys               = errors.New("no signing key in keyring")
+	errNoPassword           = errors.New("key is encrypted but no passphrase was provided")
+	errNoSignature          = errors.New("no signature issuer found")
+	errNoClearsignedMessage = errors.New("no clearsigned message found")
 )
 
 func readSigningKey(keyFile, passphrase string) (*openpgp.Entity, error) {
@@ -145,3 +145,30 @@ func TestIsASCII(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, isASCII(data))
 }
+
+func TestPGPSignatureKeyID(t *testing.T) {
+	data := []byte("testdata")
+	keyID := pointer.ToString("bc8acdd415bd80b3")
+
+	binary, err := PGPSignerWithKeyID("testdata/privkey.asc", pass, keyID)(data)
+	require.NoError(t, err)
+	armored, err := PGPArmoredDetachSignWithKeyID(bytes.NewReader(data), "testdata/privkey.asc", pass, keyID)
+	require.NoError(t, err)
+	clearsigned, err := PGPClearSignWithKeyID(bytes.NewReader(data), "testdata/privkey.asc", pass, keyID)
+	require.NoError(t, err)
+
+	for name, sig := range map[string][]byte{
+		"binary":      binary,
+		"armored":     armored,
+		"clearsigned": clearsigned,
+	} {
+		t.Run(name, func(t *testing.T) {
+			id, err := PGPSignatureKeyID(sig)
+			require.NoError(t, err)
+			require.Equal(t, *keyID, id)
+		})
+	}
+
+	_, err = PGPSignatureKeyID([]byte("not a signature"))
+	require.Error(t, err)
+}
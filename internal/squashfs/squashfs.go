@@ -0,0 +1,565 @@
+// Package squashfs writes squashfs 4.0 filesystems, as described in
+// https://dr-emann.github.io/squashfs/squashfs.html, without fragments,
+// extended attributes nor export table: the superblock, the data blocks of
+// the files, the inode table, the directory table and the id table.
+package squashfs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	magic          = 0x73717368
+	superblockSize = 96
+	blockLog       = 17
+	// BlockSize is the size of the data blocks.
+	BlockSize    = 1 << blockLog
+	metadataSize = 8192
+	maxNameLen   = 256
+	// the image is padded to a multiple of the size of a device block.
+	padding = 4096
+
+	flagNoFragments = 0x0010
+	flagNoXattrs    = 0x0200
+
+	// set in the header of the metadata blocks, and the sizes of the data
+	// blocks, stored uncompressed.
+	metadataUncompressed = 0x8000
+	blockUncompressed    = 1 << 24
+
+	noTable    = math.MaxUint64
+	noFragment = math.MaxUint32
+	noXattr    = math.MaxUint32
+)
+
+// the ids of the compressors.
+const (
+	compressionGzip = 1
+	compressionXz   = 4
+	compressionZstd = 6
+)
+
+// The types of the nodes, as written in their inodes and in the listings of
+// the directories.
+const (
+	TypeDir          = 1
+	TypeFile         = 2
+	TypeSymlink      = 3
+	typeExtendedDir  = 8
+	typeExtendedFile = 9
+)
+
+// Node is a file, a symlink or a directory of the filesystem.
+type Node struct {
+	// Type is one of TypeDir, TypeFile and TypeSymlink.
+	Type  uint16
+	Mode  uint16
+	MTime time.Time
+	// Data is the content of a file or the target of a symlink.
+	Data []byte
+
+	name     string
+	children []*Node
+
+	// the inode number and the reference of the inode: the start of its
+	// metadata block in the inode table and its offset in the block.
+	inode  uint32
+	block  uint32
+	offset uint16
+	// the start of the data blocks of a file and their sizes.
+	start  uint64
+	blocks []uint32
+}
+
+// NewDir returns an empty directory.
+func NewDir(mode uint16, mtime time.Time) *Node {
+	return &Node{Type: TypeDir, Mode: mode, MTime: mtime}
+}
+
+// Add adds the node under the given path, relative to the directory,
+// creating its missing parents with the mode and modification time of the
+// directory.
+func (n *Node) Add(path string, node *Node) error {
+	dir := n
+	names := strings.Split(path, "/")
+	for _, name := range names[:len(names)-1] {
+		child := dir.Child(name)
+		if child == nil {
+			child = NewDir(n.Mode, n.MTime)
+			child.name = name
+			dir.children = append(dir.children, child)
+		} else if child.Type != TypeDir {
+			return fmt.Errorf("%s: %s is not a directory", path, name)
+		}
+		dir = child
+	}
+
+	node.name = names[len(names)-1]
+	if len(node.name) > maxNameLen {
+		return fmt.Errorf("%s: name longer than %d bytes", path, maxNameLen)
+	}
+	if child := dir.Child(node.name); child != nil {
+		if child.Type == TypeDir && node.Type == TypeDir {
+			// a parent created before the directory itself.
+			child.Mode, child.MTime = node.Mode, node.MTime
+			return nil
+		}
+		return fmt.Errorf("%s is already in the contents", path)
+	}
+	dir.children = append(dir.children, node)
+	return nil
+}
+
+// Child returns the node of the directory with the given name, or nil.
+func (n *Node) Child(name string) *Node {
+	for _, child := range n.children {
+		if child.name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// Lookup returns the node under the given path, relative to the directory,
+// or nil.
+func (n *Node) Lookup(path string) *Node {
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if n = n.Child(name); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// FromContents returns a directory holding the files, the directories and
+// the symlinks of the contents under their destinations, relative to it; the
+// other contents are ignored. The directory and the contents without a
+// modification time have the given one.
+func FromContents(contents files.Contents, mtime time.Time) (*Node, error) {
+	root := NewDir(0o755, mtime)
+	for _, content := range contents {
+		dst := strings.TrimPrefix(files.NormalizeAbsoluteFilePath(content.Destination), "/")
+		if dst == "" {
+			continue
+		}
+		node := &Node{
+			Mode:  uint16(content.Mode() & 0o7777),
+			MTime: content.ModTime(),
+		}
+		if node.MTime.IsZero() {
+			node.MTime = mtime
+		}
+		switch content.Type {
+		case files.TypeDir, files.TypeImplicitDir:
+			node.Type = TypeDir
+		case files.TypeSymlink:
+			node.Type, node.Mode = TypeSymlink, 0o777
+			node.Data = []byte(content.Source)
+		case files.TypeFile, files.TypeConfig, files.TypeConfigNoReplace, files.TypeConfigMissingOK:
+			data, err := os.ReadFile(content.Source)
+			if err != nil {
+				return nil, err
+			}
+			node.Type, node.Data = TypeFile, data
+		default:
+			// ignore everything else
+			continue
+		}
+		if err := root.Add(dst, node); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// number sorts the directories by name and assigns the inode numbers, the
+// contents of each directory before the directory itself.
+func (n *Node) number(next *uint32) {
+	slices.SortFunc(n.children, func(a, b *Node) int {
+		return strings.Compare(a.name, b.name)
+	})
+	for _, child := range n.children {
+		child.number(next)
+	}
+	*next++
+	n.inode = *next
+}
+
+// compressor compresses the data and metadata blocks.
+type compressor struct {
+	id       uint16
+	compress func([]byte) ([]byte, error)
+}
+
+// newCompressor returns the compressor of the given algorithm, zstd by
+// default.
+func newCompressor(compression string) (*compressor, error) {
+	switch compression {
+	case "", "zstd":
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		if err != nil {
+			return nil, err
+		}
+		return &compressor{id: compressionZstd, compress: func(b []byte) ([]byte, error) {
+			return enc.EncodeAll(b, nil), nil
+		}}, nil
+	case "xz":
+		// the dictionary is at most as large as a block, which is what the
+		// kernel allocates to decompress them.
+		config := xz.WriterConfig{DictCap: BlockSize, CheckSum: xz.CRC32}
+		if err := config.Verify(); err != nil {
+			return nil, err
+		}
+		return &compressor{id: compressionXz, compress: func(b []byte) ([]byte, error) {
+			var buf bytes.Buffer
+			xw, err := config.NewWriter(&buf)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := xw.Write(b); err != nil {
+				return nil, err
+			}
+			if err := xw.Close(); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		}}, nil
+	case "gzip":
+		// despite its name, the gzip compressor writes zlib streams.
+		return &compressor{id: compressionGzip, compress: func(b []byte) ([]byte, error) {
+			var buf bytes.Buffer
+			zw, err := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := zw.Write(b); err != nil {
+				return nil, err
+			}
+			if err := zw.Close(); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm: %s", compression)
+	}
+}
+
+// block returns the block compressed, or as it is if compressing it does not
+// make it smaller, and whether it is compressed.
+func (c *compressor) block(b []byte) ([]byte, bool, error) {
+	compressed, err := c.compress(b)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(compressed) >= len(b) {
+		return b, false, nil
+	}
+	return compressed, true, nil
+}
+
+// metadataWriter writes a table of metadata blocks, each holding up to 8 KiB
+// of the table after a header with its size.
+type metadataWriter struct {
+	c   *compressor
+	out bytes.Buffer
+	cur []byte
+}
+
+// ref returns the reference of the next byte written: the start of its
+// block in the table and its offset in the block.
+func (m *metadataWriter) ref() (uint32, uint16) {
+	return uint32(m.out.Len()), uint16(len(m.cur))
+}
+
+func (m *metadataWriter) write(b []byte) error {
+	for len(b) > 0 {
+		n := min(len(b), metadataSize-len(m.cur))
+		m.cur = append(m.cur, b[:n]...)
+		b = b[n:]
+		if len(m.cur) == metadataSize {
+			if err := m.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flush writes the block being filled.
+func (m *metadataWriter) flush() error {
+	if len(m.cur) == 0 {
+		return nil
+	}
+	data, compressed, err := m.c.block(m.cur)
+	if err != nil {
+		return err
+	}
+	header := uint16(len(data))
+	if !compressed {
+		header |= metadataUncompressed
+	}
+	m.out.Write(binary.LittleEndian.AppendUint16(nil, header))
+	m.out.Write(data)
+	m.cur = m.cur[:0]
+	return nil
+}
+
+// writer writes the image of a filesystem, all of its contents being
+// owned by root.
+type writer struct {
+	c      *compressor
+	image  bytes.Buffer
+	inodes metadataWriter
+	dirs   metadataWriter
+}
+
+// Write writes the filesystem of the given root directory, compressed with
+// the given algorithm: zstd, the default, gzip or xz.
+func Write(w io.Writer, root *Node, compression string) error {
+	c, err := newCompressor(compression)
+	if err != nil {
+		return err
+	}
+	sw := &writer{
+		c:      c,
+		inodes: metadataWriter{c: c},
+		dirs:   metadataWriter{c: c},
+	}
+	var count uint32
+	root.number(&count)
+
+	sw.image.Write(make([]byte, superblockSize))
+	if err := sw.writeData(root); err != nil {
+		return err
+	}
+	if err := sw.writeDir(root, count+1); err != nil {
+		return err
+	}
+
+	inodeTable, err := sw.writeTable(&sw.inodes)
+	if err != nil {
+		return err
+	}
+	dirTable, err := sw.writeTable(&sw.dirs)
+	if err != nil {
+		return err
+	}
+	// a single id, root's, the index of the table pointing to its only
+	// metadata block.
+	ids := metadataWriter{c: c}
+	if err := ids.write(binary.LittleEndian.AppendUint32(nil, 0)); err != nil {
+		return err
+	}
+	idBlock, err := sw.writeTable(&ids)
+	if err != nil {
+		return err
+	}
+	idTable := uint64(sw.image.Len())
+	sw.image.Write(binary.LittleEndian.AppendUint64(nil, idBlock))
+	bytesUsed := uint64(sw.image.Len())
+	if pad := sw.image.Len() % padding; pad != 0 {
+		sw.image.Write(make([]byte, padding-pad))
+	}
+
+	sb := binary.LittleEndian.AppendUint32(nil, magic)
+	sb = binary.LittleEndian.AppendUint32(sb, count)
+	sb = binary.LittleEndian.AppendUint32(sb, unixTime(root.MTime))
+	sb = binary.LittleEndian.AppendUint32(sb, BlockSize)
+	sb = binary.LittleEndian.AppendUint32(sb, 0) // fragments
+	sb = binary.LittleEndian.AppendUint16(sb, c.id)
+	sb = binary.LittleEndian.AppendUint16(sb, blockLog)
+	sb = binary.LittleEndian.AppendUint16(sb, flagNoFragments|flagNoXattrs)
+	sb = binary.LittleEndian.AppendUint16(sb, 1) // ids
+	sb = binary.LittleEndian.AppendUint16(sb, 4) // version
+	sb = binary.LittleEndian.AppendUint16(sb, 0)
+	sb = binary.LittleEndian.AppendUint64(sb, uint64(root.block)<<16|uint64(root.offset))
+	sb = binary.LittleEndian.AppendUint64(sb, bytesUsed)
+	sb = binary.LittleEndian.AppendUint64(sb, idTable)
+	sb = binary.LittleEndian.AppendUint64(sb, noTable) // xattr ids
+	sb = binary.LittleEndian.AppendUint64(sb, inodeTable)
+	sb = binary.LittleEndian.AppendUint64(sb, dirTable)
+	sb = binary.LittleEndian.AppendUint64(sb, noTable) // fragments
+	sb = binary.LittleEndian.AppendUint64(sb, noTable) // export
+	image := sw.image.Bytes()
+	copy(image, sb)
+
+	_, err = w.Write(image)
+	return err
+}
+
+// writeTable appends the table to the image and returns its start.
+func (sw *writer) writeTable(m *metadataWriter) (uint64, error) {
+	if err := m.flush(); err != nil {
+		return 0, err
+	}
+	start := uint64(sw.image.Len())
+	sw.image.Write(m.out.Bytes())
+	return start, nil
+}
+
+// writeData writes the data blocks of the files under the node.
+func (sw *writer) writeData(n *Node) error {
+	for _, child := range n.children {
+		if err := sw.writeData(child); err != nil {
+			return err
+		}
+	}
+	if n.Type != TypeFile {
+		return nil
+	}
+	n.start = uint64(sw.image.Len())
+	for data := n.Data; len(data) > 0; {
+		size := min(len(data), BlockSize)
+		block, compressed, err := sw.c.block(data[:size])
+		if err != nil {
+			return err
+		}
+		data = data[size:]
+		sw.image.Write(block)
+		if compressed {
+			n.blocks = append(n.blocks, uint32(len(block)))
+		} else {
+			n.blocks = append(n.blocks, uint32(len(block))|blockUncompressed)
+		}
+	}
+	return nil
+}
+
+// writeDir writes the inodes of the contents of the directory, its listing
+// and its inode.
+func (sw *writer) writeDir(n *Node, parent uint32) error {
+	links := uint32(2)
+	for _, child := range n.children {
+		var err error
+		if child.Type == TypeDir {
+			links++
+			err = sw.writeDir(child, n.inode)
+		} else {
+			err = sw.writeInode(child, inodeHeader(child, child.Type))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	block, offset := sw.dirs.ref()
+	listing := dirListing(n.children)
+	if err := sw.dirs.write(listing); err != nil {
+		return err
+	}
+	// the size counts the . and .. entries the listing leaves out.
+	size := uint32(len(listing)) + 3
+	if size <= math.MaxUint16 {
+		inode := inodeHeader(n, TypeDir)
+		inode = binary.LittleEndian.AppendUint32(inode, block)
+		inode = binary.LittleEndian.AppendUint32(inode, links)
+		inode = binary.LittleEndian.AppendUint16(inode, uint16(size))
+		inode = binary.LittleEndian.AppendUint16(inode, offset)
+		inode = binary.LittleEndian.AppendUint32(inode, parent)
+		return sw.writeInode(n, inode)
+	}
+	inode := inodeHeader(n, typeExtendedDir)
+	inode = binary.LittleEndian.AppendUint32(inode, links)
+	inode = binary.LittleEndian.AppendUint32(inode, size)
+	inode = binary.LittleEndian.AppendUint32(inode, block)
+	inode = binary.LittleEndian.AppendUint32(inode, parent)
+	inode = binary.LittleEndian.AppendUint16(inode, 0) // index entries
+	inode = binary.LittleEndian.AppendUint16(inode, offset)
+	inode = binary.LittleEndian.AppendUint32(inode, noXattr)
+	return sw.writeInode(n, inode)
+}
+
+// inodeHeader returns the header common to the inodes of all types.
+func inodeHeader(n *Node, typ uint16) []byte {
+	inode := binary.LittleEndian.AppendUint16(nil, typ)
+	inode = binary.LittleEndian.AppendUint16(inode, n.Mode)
+	inode = binary.LittleEndian.AppendUint16(inode, 0) // uid index
+	inode = binary.LittleEndian.AppendUint16(inode, 0) // gid index
+	inode = binary.LittleEndian.AppendUint32(inode, unixTime(n.MTime))
+	return binary.LittleEndian.AppendUint32(inode, n.inode)
+}
+
+// writeInode writes the inode of the node, recording its reference.
+func (sw *writer) writeInode(n *Node, inode []byte) error {
+	n.block, n.offset = sw.inodes.ref()
+	switch n.Type {
+	case TypeSymlink:
+		inode = binary.LittleEndian.AppendUint32(inode, 1) // links
+		inode = binary.LittleEndian.AppendUint32(inode, uint32(len(n.Data)))
+		inode = append(inode, n.Data...)
+	case TypeFile:
+		size := uint64(len(n.Data))
+		if n.start <= math.MaxUint32 && size <= math.MaxUint32 {
+			inode = binary.LittleEndian.AppendUint32(inode, uint32(n.start))
+			inode = binary.LittleEndian.AppendUint32(inode, noFragment)
+			inode = binary.LittleEndian.AppendUint32(inode, 0) // fragment offset
+			inode = binary.LittleEndian.AppendUint32(inode, uint32(size))
+		} else {
+			// the type is the first field of the header.
+			binary.LittleEndian.PutUint16(inode, typeExtendedFile)
+			inode = binary.LittleEndian.AppendUint64(inode, n.start)
+			inode = binary.LittleEndian.AppendUint64(inode, size)
+			inode = binary.LittleEndian.AppendUint64(inode, 0) // sparse bytes
+			inode = binary.LittleEndian.AppendUint32(inode, 1) // links
+			inode = binary.LittleEndian.AppendUint32(inode, noFragment)
+			inode = binary.LittleEndian.AppendUint32(inode, 0) // fragment offset
+			inode = binary.LittleEndian.AppendUint32(inode, noXattr)
+		}
+		for _, block := range n.blocks {
+			inode = binary.LittleEndian.AppendUint32(inode, block)
+		}
+	}
+	return sw.inodes.write(inode)
+}
+
+// dirListing returns the listing of the directory: runs of up to 256 entries
+// after a header with the metadata block and the inode number their inodes
+// are relative to.
+func dirListing(children []*Node) []byte {
+	var listing []byte
+	for i := 0; i < len(children); {
+		first := children[i]
+		j := i + 1
+		for j < len(children) && j-i < 256 &&
+			children[j].block == first.block &&
+			inodeDelta(children[j], first) == int64(int16(inodeDelta(children[j], first))) {
+			j++
+		}
+		listing = binary.LittleEndian.AppendUint32(listing, uint32(j-i-1))
+		listing = binary.LittleEndian.AppendUint32(listing, first.block)
+		listing = binary.LittleEndian.AppendUint32(listing, first.inode)
+		for _, child := range children[i:j] {
+			listing = binary.LittleEndian.AppendUint16(listing, child.offset)
+			listing = binary.LittleEndian.AppendUint16(listing, uint16(int16(inodeDelta(child, first))))
+			listing = binary.LittleEndian.AppendUint16(listing, child.Type)
+			listing = binary.LittleEndian.AppendUint16(listing, uint16(len(child.name)-1))
+			listing = append(listing, child.name...)
+		}
+		i = j
+	}
+	return listing
+}
+
+func inodeDelta(n, base *Node) int64 {
+	return int64(n.inode) - int64(base.inode)
+}
+
+// unixTime returns the time as the unsigned 32 bits timestamps of the
+// filesystem.
+func unixTime(t time.Time) uint32 {
+	return uint32(min(max(t.Unix(), 0), math.MaxUint32))
+}
@@ -0,0 +1,102 @@
+package squashfs_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/squashfs"
+	"github.com/goreleaser/nfpm/v2/internal/squashfs/squashfstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSquashfs(t *testing.T) {
+	mtime := time.Date(2023, 11, 5, 23, 15, 17, 0, time.UTC)
+	for _, compression := range []string{"zstd", "gzip", "xz"} {
+		t.Run(compression, func(t *testing.T) {
+			root := squashfs.NewDir(0o755, mtime)
+			require.NoError(t, root.Add("usr/bin/foo", &squashfs.Node{Type: squashfs.TypeFile, Mode: 0o755, MTime: mtime, Data: []byte("hello\n")}))
+			require.NoError(t, root.Add("usr/bin/bar", &squashfs.Node{Type: squashfs.TypeSymlink, Mode: 0o777, MTime: mtime, Data: []byte("foo")}))
+			require.NoError(t, root.Add("usr", &squashfs.Node{Type: squashfs.TypeDir, Mode: 0o700, MTime: mtime}))
+			require.NoError(t, root.Add("empty", &squashfs.Node{Type: squashfs.TypeDir, Mode: 0o755, MTime: mtime}))
+			require.NoError(t, root.Add("empty.txt", &squashfs.Node{Type: squashfs.TypeFile, Mode: 0o644, MTime: mtime}))
+			require.EqualError(t, root.Add("usr/bin/foo", &squashfs.Node{Type: squashfs.TypeFile}), "usr/bin/foo is already in the contents")
+			require.EqualError(t, root.Add("usr/bin/foo/baz", &squashfs.Node{Type: squashfs.TypeFile}), "usr/bin/foo/baz: foo is not a directory")
+
+			var buf bytes.Buffer
+			require.NoError(t, squashfs.Write(&buf, root, compression))
+			entries := squashfstest.Read(t, buf.Bytes())
+			require.Len(t, entries, 7)
+			require.Equal(t, squashfstest.Entry{Type: squashfs.TypeDir, Mode: 0o755, MTime: uint32(mtime.Unix()), Links: 4}, entries[""])
+			require.Equal(t, squashfstest.Entry{Type: squashfs.TypeDir, Mode: 0o700, MTime: uint32(mtime.Unix()), Links: 3}, entries["usr"])
+			require.Equal(t, squashfstest.Entry{Type: squashfs.TypeDir, Mode: 0o755, MTime: uint32(mtime.Unix()), Links: 2}, entries["empty"])
+			require.Equal(t, squashfstest.Entry{Type: squashfs.TypeFile, Mode: 0o755, MTime: uint32(mtime.Unix()), Links: 1, Data: []byte("hello\n")}, entries["usr/bin/foo"])
+			require.Equal(t, squashfstest.Entry{Type: squashfs.TypeSymlink, Mode: 0o777, MTime: uint32(mtime.Unix()), Links: 1, Data: []byte("foo")}, entries["usr/bin/bar"])
+			require.Equal(t, []byte{}, entries["empty.txt"].Data)
+		})
+	}
+}
+
+func TestSquashfsLarge(t *testing.T) {
+	mtime := time.Date(2023, 11, 5, 23, 15, 17, 0, time.UTC)
+	root := squashfs.NewDir(0o755, mtime)
+	// a listing longer than the 64 KiB of the basic directories, spanning
+	// several headers and metadata blocks.
+	for i := range 5000 {
+		require.NoError(t, root.Add(fmt.Sprintf("many/file-with-a-long-name-%04d", i), &squashfs.Node{Type: squashfs.TypeFile, Mode: 0o644, MTime: mtime, Data: []byte(strings.Repeat("x", i))}))
+	}
+	// several blocks, the random one stored uncompressed.
+	random := make([]byte, squashfs.BlockSize+1000)
+	_, err := rand.Read(random)
+	require.NoError(t, err)
+	require.NoError(t, root.Add("random", &squashfs.Node{Type: squashfs.TypeFile, Mode: 0o644, MTime: mtime, Data: random}))
+	zeros := make([]byte, 3*squashfs.BlockSize)
+	require.NoError(t, root.Add("zeros", &squashfs.Node{Type: squashfs.TypeFile, Mode: 0o644, MTime: mtime, Data: zeros}))
+
+	var buf bytes.Buffer
+	require.NoError(t, squashfs.Write(&buf, root, "zstd"))
+	require.Less(t, buf.Len(), 2*len(random))
+	entries := squashfstest.Read(t, buf.Bytes())
+	require.Len(t, entries, 5004)
+	require.Equal(t, random, entries["random"].Data)
+	require.Equal(t, zeros, entries["zeros"].Data)
+	require.Equal(t, strings.Repeat("x", 4999), string(entries["many/file-with-a-long-name-4999"].Data))
+}
+
+func TestSquashfsUnknownCompression(t *testing.T) {
+	err := squashfs.Write(io.Discard, squashfs.NewDir(0o755, time.Time{}), "lz4")
+	require.EqualError(t, err, "unknown compression algorithm: lz4")
+}
+
+func TestFromContents(t *testing.T) {
+	mtime := time.Date(2023, 11, 5, 23, 15, 17, 0, time.UTC)
+	dirMTime := mtime.Add(time.Hour)
+	root, err := squashfs.FromContents(files.Contents{
+		{Source: "../../testdata/fake", Destination: "/usr/bin/fake", Type: files.TypeFile, FileInfo: &files.ContentFileInfo{Mode: 0o755}},
+		{Source: "fake", Destination: "/usr/bin/fake-link", Type: files.TypeSymlink, FileInfo: &files.ContentFileInfo{}},
+		{Destination: "/var/lib/foo", Type: files.TypeDir, FileInfo: &files.ContentFileInfo{Mode: 0o700, MTime: dirMTime}},
+		{Destination: "/var/lib/foo/state", Type: files.TypeRPMGhost, FileInfo: &files.ContentFileInfo{}},
+	}, mtime)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, squashfs.Write(&buf, root, "zstd"))
+	entries := squashfstest.Read(t, buf.Bytes())
+	require.Len(t, entries, 8)
+	fake, err := os.ReadFile("../../testdata/fake")
+	require.NoError(t, err)
+	require.Equal(t, squashfstest.Entry{Type: squashfs.TypeFile, Mode: 0o755, MTime: uint32(mtime.Unix()), Links: 1, Data: fake}, entries["usr/bin/fake"])
+	require.Equal(t, squashfstest.Entry{Type: squashfs.TypeSymlink, Mode: 0o777, MTime: uint32(mtime.Unix()), Links: 1, Data: []byte("fake")}, entries["usr/bin/fake-link"])
+	require.Equal(t, squashfstest.Entry{Type: squashfs.TypeDir, Mode: 0o700, MTime: uint32(dirMTime.Unix()), Links: 2}, entries["var/lib/foo"])
+
+	_, err = squashfs.FromContents(files.Contents{
+		{Source: "../../testdata/missing", Destination: "/usr/bin/missing", Type: files.TypeFile, FileInfo: &files.ContentFileInfo{}},
+	}, mtime)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
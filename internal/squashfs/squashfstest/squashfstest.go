@@ -0,0 +1,192 @@
+// Package squashfstest reads back the filesystems written by the squashfs
+// package, checking their structure along the way.
+package squashfstest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2/internal/squashfs"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+// the constants of the format read from the superblock, the tables and the
+// inodes.
+const (
+	magic                = 0x73717368
+	blockLog             = 17
+	padding              = 4096
+	metadataSize         = 8192
+	metadataUncompressed = 0x8000
+	blockUncompressed    = 1 << 24
+	noFragment           = 0xffffffff
+
+	compressionGzip = 1
+	compressionXz   = 4
+	compressionZstd = 6
+
+	typeExtendedDir  = 8
+	typeExtendedFile = 9
+)
+
+// Entry is a file, a symlink or a directory read back from a filesystem.
+type Entry struct {
+	Type  uint16
+	Mode  uint16
+	MTime uint32
+	Links uint32
+	// Data is the content of a file or the target of a symlink.
+	Data []byte
+}
+
+// Read returns the entries of a filesystem by path, the root
+// directory being "".
+func Read(t testing.TB, image []byte) map[string]Entry {
+	t.Helper()
+	u16 := func(b []byte) uint16 { return binary.LittleEndian.Uint16(b) }
+	u32 := func(b []byte) uint32 { return binary.LittleEndian.Uint32(b) }
+	u64 := func(b []byte) uint64 { return binary.LittleEndian.Uint64(b) }
+
+	require.Equal(t, uint32(magic), u32(image))
+	require.Equal(t, uint32(squashfs.BlockSize), u32(image[12:]))
+	require.Equal(t, uint16(blockLog), u16(image[22:]))
+	require.Equal(t, uint16(4), u16(image[28:]))
+	bytesUsed := u64(image[40:])
+	require.Zero(t, len(image)%padding)
+	require.LessOrEqual(t, bytesUsed, uint64(len(image)))
+	decompress := func(b []byte) []byte {
+		var r io.Reader
+		switch u16(image[20:]) {
+		case compressionGzip:
+			zr, err := zlib.NewReader(bytes.NewReader(b))
+			require.NoError(t, err)
+			r = zr
+		case compressionXz:
+			xr, err := xz.NewReader(bytes.NewReader(b))
+			require.NoError(t, err)
+			r = xr
+		case compressionZstd:
+			zr, err := zstd.NewReader(bytes.NewReader(b))
+			require.NoError(t, err)
+			defer zr.Close()
+			r = zr
+		}
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return data
+	}
+
+	// readTable returns the content of a table of metadata blocks, and the
+	// offsets of the blocks in it.
+	readTable := func(start, end uint64) ([]byte, map[uint32]int) {
+		var table []byte
+		offsets := map[uint32]int{}
+		for pos := start; pos < end; {
+			offsets[uint32(pos-start)] = len(table)
+			header := u16(image[pos:])
+			size := uint64(header &^ metadataUncompressed)
+			block := image[pos+2 : pos+2+size]
+			if header&metadataUncompressed == 0 {
+				block = decompress(block)
+			}
+			require.LessOrEqual(t, len(block), metadataSize)
+			table = append(table, block...)
+			pos += 2 + size
+		}
+		return table, offsets
+	}
+	idTable := u64(image[48:])
+	idBlock := u64(image[idTable:])
+	ids, _ := readTable(idBlock, idTable)
+	require.Equal(t, []byte{0, 0, 0, 0}, ids)
+	inodes, inodeOffsets := readTable(u64(image[64:]), u64(image[72:]))
+	dirs, dirOffsets := readTable(u64(image[72:]), idBlock)
+
+	entries := map[string]Entry{}
+	var numbers []uint32
+	var readInode func(name string, block uint32, offset uint16, parent uint32) uint32
+	readInode = func(name string, block uint32, offset uint16, parent uint32) uint32 {
+		start, ok := inodeOffsets[block]
+		require.True(t, ok, name)
+		inode := inodes[start+int(offset):]
+		e := Entry{Type: u16(inode), Mode: u16(inode[2:]), MTime: u32(inode[8:])}
+		number := u32(inode[12:])
+		numbers = append(numbers, number)
+		var listing []byte
+		switch e.Type {
+		case squashfs.TypeDir, typeExtendedDir:
+			var dirBlock, size uint32
+			var dirOffset uint16
+			if e.Type == squashfs.TypeDir {
+				dirBlock, e.Links, size, dirOffset = u32(inode[16:]), u32(inode[20:]), uint32(u16(inode[24:])), u16(inode[26:])
+				require.Equal(t, parent, u32(inode[28:]), name)
+			} else {
+				e.Links, size, dirBlock = u32(inode[16:]), u32(inode[20:]), u32(inode[24:])
+				require.Equal(t, parent, u32(inode[28:]), name)
+				dirOffset = u16(inode[34:])
+			}
+			e.Type = squashfs.TypeDir
+			start := dirOffsets[dirBlock] + int(dirOffset)
+			listing = dirs[start : start+int(size)-3]
+		case squashfs.TypeSymlink:
+			e.Links = u32(inode[16:])
+			e.Data = inode[24 : 24+u32(inode[20:])]
+		case squashfs.TypeFile, typeExtendedFile:
+			var pos, size uint64
+			var blocks []byte
+			if e.Type == squashfs.TypeFile {
+				pos, size = uint64(u32(inode[16:])), uint64(u32(inode[28:]))
+				require.Equal(t, uint32(noFragment), u32(inode[20:]))
+				e.Links, blocks = 1, inode[32:]
+			} else {
+				pos, size = u64(inode[16:]), u64(inode[24:])
+				e.Links, blocks = u32(inode[40:]), inode[56:]
+			}
+			e.Type = squashfs.TypeFile
+			e.Data = []byte{}
+			for i := range (size + squashfs.BlockSize - 1) / squashfs.BlockSize {
+				word := u32(blocks[4*i:])
+				block := image[pos : pos+uint64(word&^blockUncompressed)]
+				if word&blockUncompressed == 0 {
+					block = decompress(block)
+				}
+				e.Data = append(e.Data, block...)
+				pos += uint64(word &^ blockUncompressed)
+			}
+			require.Len(t, e.Data, int(size), name)
+		default:
+			require.Fail(t, "unknown inode type", "%s: %d", name, e.Type)
+		}
+		entries[name] = e
+
+		var previous string
+		for len(listing) > 0 {
+			count, block, base := u32(listing)+1, u32(listing[4:]), u32(listing[8:])
+			require.LessOrEqual(t, count, uint32(256))
+			listing = listing[12:]
+			for range count {
+				offset, delta, typ := u16(listing), int16(u16(listing[2:])), u16(listing[4:])
+				child := string(listing[8 : 9+int(u16(listing[6:]))])
+				listing = listing[8+len(child):]
+				require.Less(t, previous, child, "the entries are sorted")
+				previous = child
+				path := child
+				if name != "" {
+					path = name + "/" + child
+				}
+				require.Equal(t, uint32(int64(base)+int64(delta)), readInode(path, block, offset, number))
+				require.Equal(t, entries[path].Type, typ, path)
+			}
+		}
+		return number
+	}
+	root := u64(image[32:])
+	readInode("", uint32(root>>16), uint16(root), u32(image[4:])+1)
+	require.Len(t, numbers, int(u32(image[4:])))
+	return entries
+}
@@ -12,13 +12,14 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 	"text/template"
-	"time"
 
 	"github.com/goreleaser/nfpm/v2"
 	"github.com/goreleaser/nfpm/v2/deprecation"
 	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/maps"
 	"github.com/goreleaser/nfpm/v2/internal/modtime"
 )
 
@@ -27,6 +28,7 @@ const packagerName = "ipk"
 // nolint: gochecknoinits
 func init() {
 	nfpm.RegisterPackager(packagerName, Default)
+	nfpm.RegisterReader(packagerName, Default)
 }
 
 // nolint: gochecknoglobals
@@ -115,12 +117,22 @@ func (*IPK) SetPackagerDefaults(info *nfpm.Info) {
 
 // Package writes a new ipk package to the given writer using the given info.
 func (d *IPK) Package(info *nfpm.Info, ipk io.Writer) error {
+	defer info.Cleanup()
 	info = ensureValidArch(info)
 
 	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
 		return err
 	}
 
+	if err := nfpm.PrepareAutoDepends(info, packagerName); err != nil {
+		return err
+	}
+	if err := nfpm.PrepareRelations(info, packagerName, func(arch string) bool {
+		return arch == info.Arch || archToIPK[arch] == info.Arch
+	}); err != nil {
+		return err
+	}
+
 	// Set up some ipk specific defaults
 	d.SetPackagerDefaults(info)
 
@@ -228,41 +240,6 @@ func populateDataTar(info *nfpm.Info, tw *tar.Writer) (instSize int64, err error
 	return instSize, nil
 }
 
-// getScripts returns the scripts for the given info.
-func getScripts(info *nfpm.Info, mtime time.Time) []files.Content {
-	return []files.Content{
-		{
-			Destination: "preinst",
-			Source:      info.Scripts.PreInstall,
-			FileInfo: &files.ContentFileInfo{
-				Mode:  0o755,
-				MTime: mtime,
-			},
-		}, {
-			Destination: "postinst",
-			Source:      info.Scripts.PostInstall,
-			FileInfo: &files.ContentFileInfo{
-				Mode:  0o755,
-				MTime: mtime,
-			},
-		}, {
-			Destination: "prerm",
-			Source:      info.Scripts.PreRemove,
-			FileInfo: &files.ContentFileInfo{
-				Mode:  0o755,
-				MTime: mtime,
-			},
-		}, {
-			Destination: "postrm",
-			Source:      info.Scripts.PostRemove,
-			FileInfo: &files.ContentFileInfo{
-				Mode:  0o755,
-				MTime: mtime,
-			},
-		},
-	}
-}
-
 // populateControlTar populates the control tarball with the control files defined
 // in the info.
 func populateControlTar(info *nfpm.Info, out *tar.Writer, instSize int64) error {
@@ -285,12 +262,13 @@ func populateControlTar(info *nfpm.Info, out *tar.Writer, instSize int64) error
 		return err
 	}
 
-	scripts := getScripts(info, mtime)
-	for _, file := range scripts {
-		if file.Source != "" {
-			if _, err := writeFile(out, &file); err != nil {
-				return err
-			}
+	scripts, err := nfpm.MaintainerScripts(info, packagerName)
+	if err != nil {
+		return err
+	}
+	for _, name := range maps.Keys(scripts) {
+		if err := writeToFileWithMode(out, name, scripts[name], 0o755, mtime); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -423,8 +401,8 @@ Version: {{ if .Info.Epoch}}{{ .Info.Epoch }}:{{ end }}{{.Info.Version}}
 {{- if .Info.IPK.ABIVersion}}
 ABIVersion: {{.Info.IPK.ABIVersion}}
 {{- end}}
-{{- if .Info.IPK.Alternatives}}
-Alternatives: {{ range $index, $element := .Info.IPK.Alternatives }}{{ if $index }}, {{end}}{{ $element.Priority }}:{{ $element.LinkName}}:{{ $element.Target}}{{- end }}
+{{- with .Alternatives}}
+Alternatives: {{ range $index, $element := . }}{{ if $index }}, {{end}}{{ $element.Priority }}:{{ $element.LinkName}}:{{ $element.Target}}{{- end }}
 {{- end}}
 {{- if .Info.IPK.AutoInstalled}}
 Auto-Installed: yes
@@ -483,6 +461,20 @@ type controlData struct {
 	InstalledSize int64
 }
 
+// Alternatives returns the ipk specific alternatives of the package, followed
+// by the ones of every packager.
+func (d controlData) Alternatives() []nfpm.IPKAlternative {
+	alternatives := slices.Clone(d.Info.IPK.Alternatives)
+	for _, alternative := range d.Info.Alternatives {
+		alternatives = append(alternatives, nfpm.IPKAlternative{
+			Priority: alternative.Priority,
+			Target:   alternative.Target,
+			LinkName: alternative.LinkName,
+		})
+	}
+	return alternatives
+}
+
 func renderControl(w io.Writer, data controlData) error {
 	tmpl := template.New("control")
 	tmpl.Funcs(template.FuncMap{
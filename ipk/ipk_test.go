@@ -261,6 +261,22 @@ func TestControl(t *testing.T) {
 	require.Equal(t, string(bts), w.String())
 }
 
+func TestControlAlternatives(t *testing.T) {
+	info := exampleInfo()
+	info.IPK.Alternatives = []nfpm.IPKAlternative{
+		{Priority: 100, LinkName: "/usr/bin/vi", Target: "/usr/bin/foo-vi"},
+	}
+	info.Alternatives = []nfpm.Alternative{
+		{Priority: 50, LinkName: "/usr/bin/editor", Target: "/usr/bin/foo", Name: "editor"},
+	}
+	var w bytes.Buffer
+	require.NoError(t, renderControl(&w, controlData{
+		Info:          info,
+		InstalledSize: 10,
+	}))
+	require.Contains(t, w.String(), "\nAlternatives: 100:/usr/bin/vi:/usr/bin/foo-vi, 50:/usr/bin/editor:/usr/bin/foo\n")
+}
+
 func TestNoJoinsControl(t *testing.T) {
 	var w bytes.Buffer
 	require.NoError(t, renderControl(&w, controlData{
@@ -0,0 +1,306 @@
+package ipk
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blakesmith/ar"
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/deb822"
+)
+
+// ErrInvalidPackage happens when a file cannot be read as an ipk package.
+var ErrInvalidPackage = errors.New("invalid ipk package")
+
+// control fields that are mapped to nfpm.Info and not to IPK.Fields.
+// nolint: gochecknoglobals
+var knownControlFields = map[string]bool{
+	"architecture":   true,
+	"description":    true,
+	"maintainer":     true,
+	"package":        true,
+	"priority":       true,
+	"version":        true,
+	"abiversion":     true,
+	"alternatives":   true,
+	"auto-installed": true,
+	"conflicts":      true,
+	"depends":        true,
+	"essential":      true,
+	"homepage":       true,
+	"license":        true,
+	"installed-size": true,
+	"pre-depends":    true,
+	"provides":       true,
+	"recommends":     true,
+	"replaces":       true,
+	"section":        true,
+	"suggests":       true,
+	"tags":           true,
+	"vendor":         true,
+}
+
+// maps the control archive member names to the nfpm script names.
+// nolint: gochecknoglobals
+var controlScripts = map[string]string{
+	"preinst":  "preinstall",
+	"postinst": "postinstall",
+	"prerm":    "preremove",
+	"postrm":   "postremove",
+}
+
+// Read implements nfpm.Reader. Both the tar based packages created by nfpm
+// and the ar based packages created by older opkg-build versions are
+// supported.
+func (*IPK) Read(r io.Reader) (*nfpm.Inspection, error) {
+	members, err := readMembers(r)
+	if err != nil {
+		return nil, err
+	}
+
+	control, ok := members["control.tar.gz"]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing control.tar.gz", ErrInvalidPackage)
+	}
+	data, ok := members["data.tar.gz"]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing data.tar.gz", ErrInvalidPackage)
+	}
+
+	result := &nfpm.Inspection{
+		Format: packagerName,
+		Info: &nfpm.Info{
+			Platform: "linux",
+		},
+	}
+	if err := readControlTarball(control, result); err != nil {
+		return nil, err
+	}
+	if err := readDataTarball(data, result.Info); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readMembers reads the members of a tar or ar based package.
+func readMembers(r io.Reader) (map[string][]byte, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(8)
+	if string(magic) == "!<arch>\n" {
+		return readArMembers(br)
+	}
+	return readTarMembers(br)
+}
+
+func readTarMembers(r io.Reader) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+	members := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return members, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", hdr.Name, err)
+		}
+		members[path.Clean(hdr.Name)] = body
+	}
+}
+
+func readArMembers(r io.Reader) (map[string][]byte, error) {
+	members := map[string][]byte{}
+	reader := ar.NewReader(r)
+	for {
+		hdr, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return members, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", hdr.Name, err)
+		}
+		members[strings.TrimSuffix(strings.TrimSpace(hdr.Name), "/")] = body
+	}
+}
+
+// walkTGZ calls fn for every entry of the given gzipped tarball.
+func walkTGZ(name string, body []byte, fn func(hdr *tar.Header, r io.Reader) error) error {
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot decompress %s: %w", name, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", name, err)
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func readControlTarball(body []byte, result *nfpm.Inspection) error {
+	var control, conffiles []byte
+	err := walkTGZ("control.tar.gz", body, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", hdr.Name, err)
+		}
+		switch base := path.Base(hdr.Name); base {
+		case "control":
+			control = content
+		case "conffiles":
+			conffiles = content
+		default:
+			if script, ok := controlScripts[base]; ok {
+				if result.Scripts == nil {
+					result.Scripts = map[string]string{}
+				}
+				result.Scripts[script] = string(content)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if control == nil {
+		return fmt.Errorf("%w: missing control file", ErrInvalidPackage)
+	}
+
+	paragraph, err := deb822.ParseOne(bytes.NewReader(control))
+	if err != nil {
+		return fmt.Errorf("cannot parse control file: %w", err)
+	}
+	infoFromControl(result.Info, paragraph)
+	for _, conf := range strings.Fields(string(conffiles)) {
+		result.Info.Contents = append(result.Info.Contents, &files.Content{
+			Destination: conf,
+			Type:        files.TypeConfig,
+		})
+	}
+	return nil
+}
+
+func infoFromControl(info *nfpm.Info, control deb822.Paragraph) {
+	info.Name = control.Get("Package")
+	info.Epoch, info.Version, info.Release = splitVersion(control.Get("Version"))
+	info.Arch = control.Get("Architecture")
+	info.Description = deb822.Multiline(control.Get("Description"))
+	info.Maintainer = control.Get("Maintainer")
+	info.Priority = control.Get("Priority")
+	info.Section = control.Get("Section")
+	info.Homepage = control.Get("Homepage")
+	info.License = control.Get("License")
+	info.Vendor = control.Get("Vendor")
+	info.Conflicts = deb822.List(control.Get("Conflicts"))
+	info.Depends = deb822.List(control.Get("Depends"))
+	info.Provides = deb822.List(control.Get("Provides"))
+	info.Recommends = deb822.List(control.Get("Recommends"))
+	info.Replaces = deb822.List(control.Get("Replaces"))
+	info.Suggests = deb822.List(control.Get("Suggests"))
+	info.IPK.ABIVersion = control.Get("ABIVersion")
+	info.IPK.AutoInstalled = control.Get("Auto-Installed") == "yes"
+	info.IPK.Essential = control.Get("Essential") == "yes"
+	info.IPK.Predepends = deb822.List(control.Get("Pre-Depends"))
+	info.IPK.Tags = deb822.List(control.Get("Tags"))
+	info.IPK.Alternatives = parseAlternatives(control.Get("Alternatives"))
+
+	for _, field := range control {
+		if knownControlFields[strings.ToLower(field.Name)] {
+			continue
+		}
+		if info.IPK.Fields == nil {
+			info.IPK.Fields = map[string]string{}
+		}
+		info.IPK.Fields[field.Name] = field.Value
+	}
+}
+
+// parseAlternatives parses the "priority:link:target" entries of the
+// Alternatives control field.
+func parseAlternatives(value string) []nfpm.IPKAlternative {
+	var result []nfpm.IPKAlternative
+	for _, item := range deb822.List(value) {
+		parts := strings.SplitN(item, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		priority, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		result = append(result, nfpm.IPKAlternative{
+			Priority: priority,
+			LinkName: parts[1],
+			Target:   parts[2],
+		})
+	}
+	return result
+}
+
+// splitVersion splits an opkg version into its epoch, upstream version and
+// revision.
+func splitVersion(version string) (epoch, upstream, revision string) {
+	if e, rest, ok := strings.Cut(version, ":"); ok {
+		epoch, version = e, rest
+	}
+	if i := strings.LastIndex(version, "-"); i >= 0 {
+		version, revision = version[:i], version[i+1:]
+	}
+	return epoch, version, revision
+}
+
+func readDataTarball(body []byte, info *nfpm.Info) error {
+	conffiles := map[string]bool{}
+	for _, c := range info.Contents {
+		conffiles[c.Destination] = true
+	}
+	info.Contents = nil
+
+	err := walkTGZ("data.tar.gz", body, func(hdr *tar.Header, _ io.Reader) error {
+		content := files.FromTarHeader(hdr)
+		if content == nil {
+			return nil
+		}
+		if content.Type == files.TypeFile && conffiles[content.Destination] {
+			content.Type = files.TypeConfig
+		}
+		info.Contents = append(info.Contents, content)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Sort(info.Contents)
+	return nil
+}
@@ -0,0 +1,53 @@
+package ipk
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	info := exampleInfo()
+	info.Release = "2"
+	info.Scripts.PostInstall = "../testdata/scripts/postinstall.sh"
+	info.IPK.Alternatives = []nfpm.IPKAlternative{
+		{Priority: 100, LinkName: "/usr/bin/foo", Target: "/usr/bin/fake"},
+	}
+	info.IPK.Fields = map[string]string{"Source": "foo"}
+
+	var ipk bytes.Buffer
+	require.NoError(t, Default.Package(info, &ipk))
+
+	result, err := Default.Read(&ipk)
+	require.NoError(t, err)
+	require.Equal(t, packagerName, result.Format)
+
+	got := result.Info
+	require.Equal(t, "foo", got.Name)
+	require.Equal(t, "1.0.0", got.Version)
+	require.Equal(t, "2", got.Release)
+	require.Equal(t, "x86_64", got.Arch)
+	require.Equal(t, "nope", got.Vendor)
+	require.Equal(t, nfpm.Relations{"bash"}, got.Depends)
+	require.Equal(t, nfpm.Relations{"less"}, got.IPK.Predepends)
+	require.Equal(t, info.IPK.Alternatives, got.IPK.Alternatives)
+	require.Equal(t, info.IPK.Fields, got.IPK.Fields)
+	require.Contains(t, result.Scripts, "postinstall")
+
+	contents := map[string]*files.Content{}
+	for _, c := range got.Contents {
+		contents[c.Destination] = c
+	}
+	require.Equal(t, files.TypeFile, contents["/usr/bin/fake"].Type)
+	require.Equal(t, files.TypeConfig, contents["/etc/fake/fake2.conf"].Type)
+	require.Equal(t, files.TypeDir, contents["/var/log/whatever"].Type)
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, err := Default.Read(strings.NewReader("not an ipk"))
+	require.ErrorIs(t, err, ErrInvalidPackage)
+}
@@ -0,0 +1,159 @@
+package ipk
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5" // nolint:gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2/internal/deb822"
+)
+
+// repoPackage is an ipk found while indexing a feed.
+type repoPackage struct {
+	filename string // relative to the feed root, slash separated
+	control  deb822.Paragraph
+}
+
+// IndexRepo indexes all ipk packages found in dir, creating the Packages and
+// Packages.gz files of an opkg feed. It returns the paths of the created
+// files.
+func IndexRepo(dir string) ([]string, error) {
+	var pkgs []*repoPackage
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(name) != ".ipk" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		pkg, err := readRepoPackage(name, filepath.ToSlash(rel))
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		pkgs = append(pkgs, pkg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		a, b := pkgs[i].control, pkgs[j].control
+		if a.Get("Package") != b.Get("Package") {
+			return a.Get("Package") < b.Get("Package")
+		}
+		if a.Get("Version") != b.Get("Version") {
+			return a.Get("Version") < b.Get("Version")
+		}
+		return pkgs[i].filename < pkgs[j].filename
+	})
+
+	var packages bytes.Buffer
+	for i, pkg := range pkgs {
+		if i > 0 {
+			packages.WriteString("\n")
+		}
+		if _, err := pkg.control.WriteTo(&packages); err != nil {
+			return nil, err
+		}
+	}
+
+	var packagesGz bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&packagesGz, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gz.Write(packages.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	var created []string
+	for _, file := range []struct {
+		name    string
+		content []byte
+	}{
+		{"Packages", packages.Bytes()},
+		{"Packages.gz", packagesGz.Bytes()},
+	} {
+		target := filepath.Join(dir, file.name)
+		if err := os.WriteFile(target, file.content, 0o644); err != nil { //nolint:gosec
+			return nil, err
+		}
+		created = append(created, target)
+	}
+	return created, nil
+}
+
+// readRepoPackage reads the control file of the given ipk and adds the
+// fields needed by the Packages index to it.
+func readRepoPackage(name, filename string) (*repoPackage, error) {
+	content, err := os.ReadFile(name) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	members, err := readMembers(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	body, ok := members["control.tar.gz"]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing control.tar.gz", ErrInvalidPackage)
+	}
+
+	var control deb822.Paragraph
+	err = walkTGZ("control.tar.gz", body, func(hdr *tar.Header, r io.Reader) error {
+		if path.Base(hdr.Name) != "control" {
+			return nil
+		}
+		control, err = deb822.ParseOne(r)
+		if err != nil {
+			return fmt.Errorf("cannot parse control file: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if control == nil {
+		return nil, fmt.Errorf("%w: missing control file", ErrInvalidPackage)
+	}
+
+	// the description is conventionally the last field of a paragraph.
+	description := control.Get("Description")
+	var paragraph deb822.Paragraph
+	for _, f := range control {
+		if !strings.EqualFold(f.Name, "Description") {
+			paragraph = append(paragraph, f)
+		}
+	}
+	md5sum := md5.Sum(content) // nolint:gosec
+	sha256sum := sha256.Sum256(content)
+	paragraph = append(paragraph,
+		deb822.Field{Name: "Filename", Value: filename},
+		deb822.Field{Name: "Size", Value: fmt.Sprint(len(content))},
+		deb822.Field{Name: "MD5Sum", Value: hex.EncodeToString(md5sum[:])},
+		deb822.Field{Name: "SHA256sum", Value: hex.EncodeToString(sha256sum[:])},
+		deb822.Field{Name: "Description", Value: description},
+	)
+	return &repoPackage{
+		filename: filename,
+		control:  paragraph,
+	}, nil
+}
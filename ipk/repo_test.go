@@ -0,0 +1,67 @@
+package ipk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2/internal/deb822"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRepoIPK(t *testing.T, dir, name string) string {
+	t.Helper()
+	info := exampleInfo()
+	info.Name = name
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	target := filepath.Join(dir, Default.ConventionalFileName(info))
+	f, err := os.Create(target)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, Default.Package(info, f))
+	return target
+}
+
+func TestIndexRepo(t *testing.T) {
+	dir := t.TempDir()
+	src := writeRepoIPK(t, filepath.Join(dir, "packages"), "foo")
+	writeRepoIPK(t, dir, "bar")
+
+	created, err := IndexRepo(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "Packages"),
+		filepath.Join(dir, "Packages.gz"),
+	}, created)
+
+	packages, err := os.ReadFile(filepath.Join(dir, "Packages"))
+	require.NoError(t, err)
+	compressed, err := os.ReadFile(filepath.Join(dir, "Packages.gz"))
+	require.NoError(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	uncompressed, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, packages, uncompressed)
+
+	paragraphs, err := deb822.Parse(bytes.NewReader(packages))
+	require.NoError(t, err)
+	require.Len(t, paragraphs, 2)
+	require.Equal(t, "bar", paragraphs[0].Get("Package"))
+	foo := paragraphs[1]
+	require.Equal(t, "foo", foo.Get("Package"))
+	require.Equal(t, "packages/"+filepath.Base(src), foo.Get("Filename"))
+	require.Equal(t, "Description", foo[len(foo)-1].Name)
+
+	content, err := os.ReadFile(src)
+	require.NoError(t, err)
+	sum := sha256.Sum256(content)
+	require.Equal(t, fmt.Sprint(len(content)), foo.Get("Size"))
+	require.Equal(t, hex.EncodeToString(sum[:]), foo.Get("SHA256sum"))
+}
@@ -87,10 +87,16 @@ func writeFile(out *tar.Writer, file *files.Content) (int64, error) {
 
 // writeToFile writes a file to the tarball where the contents are an array of bytes.
 func writeToFile(out *tar.Writer, filename string, content []byte, mtime time.Time) error {
+	return writeToFileWithMode(out, filename, content, 0o644, mtime)
+}
+
+// writeToFileWithMode writes a file with the given mode to the tarball where
+// the contents are an array of bytes.
+func writeToFileWithMode(out *tar.Writer, filename string, content []byte, mode int64, mtime time.Time) error {
 	header := tar.Header{
 		Name:     files.AsExplicitRelativePath(filename),
 		Size:     int64(len(content)),
-		Mode:     0o644,
+		Mode:     mode,
 		ModTime:  mtime,
 		Typeflag: tar.TypeReg,
 		Format:   tar.FormatGNU,
@@ -0,0 +1,242 @@
+package macospkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// The bill of materials lists the paths of the payload, with their owners,
+// modes and checksums, in a BOMStore: a file of blocks, referenced by their
+// index in a table at its end, and of named variables pointing to the blocks
+// of the BOM's trees. All the integers are big endian.
+// See https://github.com/hogliux/bomutils for its description.
+const (
+	bomHeaderSize = 512
+	// bomPageSize is the size of the pages of the trees of the paths.
+	bomPageSize = 4096
+	// bomSmallPageSize is the size of the pages of the empty trees.
+	bomSmallPageSize = 128
+	// bomPathsPerPage is the number of paths a page of the tree holds, after
+	// the page header, as a pair of block indices each.
+	bomPathsPerPage = (bomPageSize - 12) / 8
+)
+
+// types of the paths in the BOM.
+const (
+	bomTypeFile    = 1
+	bomTypeDir     = 2
+	bomTypeSymlink = 3
+)
+
+// bomArchitecture is the architecture of the paths, which the BOM also uses
+// for universal binaries.
+const bomArchitecture = 3
+
+// bomStore accumulates the blocks of a BOMStore, block 0 being the null
+// block.
+type bomStore struct {
+	blocks [][]byte
+}
+
+// add adds a block and returns its index.
+func (s *bomStore) add(block []byte) uint32 {
+	s.blocks = append(s.blocks, block)
+	return uint32(len(s.blocks))
+}
+
+// set replaces the content of a block.
+func (s *bomStore) set(index uint32, block []byte) {
+	s.blocks[index-1] = block
+}
+
+// writeBom returns the bill of materials of the entries, which must come in
+// the order of the paths, each directory before the paths it holds, the first
+// one being the install location itself.
+func writeBom(entries []entry) ([]byte, error) {
+	store := &bomStore{}
+
+	ids := map[string]uint32{}
+	type pathIndices struct {
+		info, file uint32
+	}
+	indices := make([]pathIndices, 0, len(entries))
+	for i, e := range entries {
+		id := uint32(i + 1)
+		ids[e.path] = id
+
+		var parent uint32
+		if e.path != "." {
+			var ok bool
+			// path.Dir would clean the leading ./ away.
+			if parent, ok = ids[e.path[:strings.LastIndex(e.path, "/")]]; !ok {
+				return nil, fmt.Errorf("bom: parent directory of %s is missing", e.path)
+			}
+		}
+
+		info := store.add(bomPathInfo(e))
+		indices = append(indices, pathIndices{
+			info: store.add(bomUint32s(id, info)),
+			file: store.add(append(bomUint32s(parent), path.Base(e.path)+"\x00"...)),
+		})
+	}
+
+	// the paths are stored in the leaves of a tree, which are linked to
+	// their neighbours, under a root page indexing them when there are
+	// several.
+	var leaves []uint32
+	for i := 0; i == 0 || i < len(indices); i += bomPathsPerPage {
+		leaves = append(leaves, store.add(nil))
+	}
+	var root []uint32
+	for i, leaf := range leaves {
+		page := indices[i*bomPathsPerPage : min((i+1)*bomPathsPerPage, len(indices))]
+		var forward, backward uint32
+		if i+1 < len(leaves) {
+			forward = leaves[i+1]
+		}
+		if i > 0 {
+			backward = leaves[i-1]
+		}
+		pairs := make([]uint32, 0, 2*len(page))
+		for _, p := range page {
+			pairs = append(pairs, p.info, p.file)
+		}
+		store.set(leaf, bomPage(bomPageSize, true, forward, backward, pairs...))
+		root = append(root, leaf, page[len(page)-1].file)
+	}
+	paths := leaves[0]
+	if len(leaves) > 1 {
+		if len(leaves) > bomPathsPerPage {
+			return nil, fmt.Errorf("bom: too many paths: %d", len(entries))
+		}
+		paths = store.add(bomPage(bomPageSize, false, 0, 0, root...))
+	}
+
+	vars := []struct {
+		name  string
+		index uint32
+	}{
+		{"BomInfo", store.add(append(bomUint32s(1, uint32(len(entries)), 1), make([]byte, 16)...))},
+		{"Paths", store.add(bomTree(paths, bomPageSize, len(entries)))},
+		{"HLIndex", store.add(bomTree(store.add(bomPage(bomPageSize, true, 0, 0)), bomPageSize, 0))},
+		{"VIndex", store.add(append(bomUint32s(1, store.add(bomTree(store.add(bomPage(bomSmallPageSize, true, 0, 0)), bomSmallPageSize, 0)), 0), 0))},
+		{"Size64", store.add(bomTree(store.add(bomPage(bomSmallPageSize, true, 0, 0)), bomSmallPageSize, 0))},
+	}
+
+	var blocks bytes.Buffer
+	pointers := bomUint32s(uint32(len(store.blocks)+1), 0, 0)
+	for _, block := range store.blocks {
+		pointers = append(pointers, bomUint32s(uint32(bomHeaderSize+blocks.Len()), uint32(len(block)))...)
+		blocks.Write(block)
+	}
+	// the free list, empty.
+	pointers = append(pointers, bomUint32s(0)...)
+
+	varsTable := bomUint32s(uint32(len(vars)))
+	for _, v := range vars {
+		varsTable = append(varsTable, bomUint32s(v.index)...)
+		varsTable = append(varsTable, byte(len(v.name)))
+		varsTable = append(varsTable, v.name...)
+	}
+
+	varsOffset := bomHeaderSize + blocks.Len()
+	indexOffset := varsOffset + len(varsTable)
+	header := append([]byte("BOMStore"), bomUint32s(
+		1,
+		uint32(len(store.blocks)),
+		uint32(indexOffset),
+		uint32(len(pointers)),
+		uint32(varsOffset),
+		uint32(len(varsTable)),
+	)...)
+
+	var buf bytes.Buffer
+	buf.Write(header)
+	buf.Write(make([]byte, bomHeaderSize-len(header)))
+	buf.Write(blocks.Bytes())
+	buf.Write(varsTable)
+	buf.Write(pointers)
+	return buf.Bytes(), nil
+}
+
+// bomPathInfo returns the block describing a path.
+func bomPathInfo(e entry) []byte {
+	typ, size, checksum := byte(bomTypeFile), uint32(len(e.data)), cksum(e.data)
+	var link []byte
+	switch {
+	case e.mode&modeType == modeDir:
+		typ, size, checksum = bomTypeDir, 0, 0
+	case e.mode&modeType == modeSymlink:
+		typ = bomTypeSymlink
+		link = append(append([]byte{}, e.data...), 0)
+	}
+
+	b := []byte{typ, 1}
+	b = binary.BigEndian.AppendUint16(b, bomArchitecture)
+	b = binary.BigEndian.AppendUint16(b, uint16(e.mode))
+	b = append(b, bomUint32s(e.uid, e.gid, uint32(e.mtime.Unix()), size)...)
+	b = append(b, 1)
+	b = append(b, bomUint32s(checksum, uint32(len(link)))...)
+	return append(b, link...)
+}
+
+// bomTree returns the block of a tree whose root page is child.
+func bomTree(child uint32, pageSize, count int) []byte {
+	b := append([]byte("tree"), bomUint32s(1, child, uint32(pageSize), uint32(count))...)
+	return append(b, 0)
+}
+
+// bomPage returns a page of a tree, holding the pairs of block indices of its
+// paths in its leaves, and of its children and their last path otherwise.
+func bomPage(size int, leaf bool, forward, backward uint32, pairs ...uint32) []byte {
+	var isLeaf uint16
+	if leaf {
+		isLeaf = 1
+	}
+	b := binary.BigEndian.AppendUint16(nil, isLeaf)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(pairs)/2))
+	b = append(b, bomUint32s(forward, backward)...)
+	b = append(b, bomUint32s(pairs...)...)
+	return append(b, make([]byte, size-len(b))...)
+}
+
+func bomUint32s(values ...uint32) []byte {
+	b := make([]byte, 0, 4*len(values))
+	for _, v := range values {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+// nolint: gochecknoglobals
+var cksumTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// cksum returns the checksum of the data as computed by cksum(1), which the
+// BOM records for each file.
+func cksum(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = crc<<8 ^ cksumTable[byte(crc>>24)^b]
+	}
+	for n := len(data); n > 0; n >>= 8 {
+		crc = crc<<8 ^ cksumTable[byte(crc>>24)^byte(n)]
+	}
+	return ^crc
+}
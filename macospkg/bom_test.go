@@ -0,0 +1,116 @@
+package macospkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bomPaths returns the paths of a BOM, in the order of the leaves of its
+// Paths tree.
+func bomPaths(t *testing.T, bom []byte) []string {
+	t.Helper()
+	require.Equal(t, "BOMStore", string(bom[:8]))
+	u32 := func(b []byte) uint32 { return binary.BigEndian.Uint32(b) }
+	indexOffset := u32(bom[16:])
+	require.Equal(t, len(bom), int(indexOffset+u32(bom[20:])))
+	block := func(index uint32) []byte {
+		pointer := bom[indexOffset+4+8*index:]
+		return bom[u32(pointer) : u32(pointer)+u32(pointer[4:])]
+	}
+
+	vars := map[string]uint32{}
+	table := bom[u32(bom[24:]):]
+	count := u32(table)
+	table = table[4:]
+	for range count {
+		length := int(table[4])
+		vars[string(table[5:5+length])] = u32(table)
+		table = table[5+length:]
+	}
+	require.Len(t, vars, 5)
+
+	tree := block(vars["Paths"])
+	require.Equal(t, "tree", string(tree[:4]))
+	page := block(u32(tree[8:]))
+	for binary.BigEndian.Uint16(page) == 0 {
+		page = block(u32(page[12:]))
+	}
+
+	names := map[uint32]string{}
+	var paths []string
+	for {
+		for i := range int(binary.BigEndian.Uint16(page[2:])) {
+			pathInfo := block(u32(page[12+8*i:]))
+			file := block(u32(page[16+8*i:]))
+			id, parent := u32(pathInfo), u32(file)
+			name := string(bytes.TrimRight(file[4:], "\x00"))
+			if parent != 0 {
+				name = names[parent] + "/" + name
+			}
+			names[id] = name
+			paths = append(paths, name)
+		}
+		forward := u32(page[4:])
+		if forward == 0 {
+			return paths
+		}
+		page = block(forward)
+	}
+}
+
+func TestBom(t *testing.T) {
+	entries := []entry{
+		{path: ".", mode: modeDir | 0o755},
+		{path: "./bin", mode: modeDir | 0o755},
+		{path: "./bin/foo", mode: modeFile | 0o755, uid: 0, gid: 80, data: []byte("hello\n")},
+		{path: "./bin/bar", mode: modeSymlink | 0o755, data: []byte("foo")},
+	}
+	bom, err := writeBom(entries)
+	require.NoError(t, err)
+	require.Equal(t, []string{".", "./bin", "./bin/foo", "./bin/bar"}, bomPaths(t, bom))
+
+	// the info of the file, after the path info and the file name blocks
+	// of the two directories.
+	info := bomPathInfo(entries[2])
+	require.Equal(t, byte(bomTypeFile), info[0])
+	require.Equal(t, uint16(modeFile|0o755), binary.BigEndian.Uint16(info[4:]))
+	require.Equal(t, uint32(80), binary.BigEndian.Uint32(info[10:]))
+	require.Equal(t, uint32(6), binary.BigEndian.Uint32(info[18:]))
+	require.Equal(t, uint32(3015617425), binary.BigEndian.Uint32(info[23:]))
+
+	link := bomPathInfo(entries[3])
+	require.Equal(t, byte(bomTypeSymlink), link[0])
+	require.Equal(t, uint32(4), binary.BigEndian.Uint32(link[27:]))
+	require.Equal(t, "foo\x00", string(link[31:]))
+}
+
+func TestBomManyPaths(t *testing.T) {
+	entries := []entry{{path: ".", mode: modeDir | 0o755}}
+	expected := []string{"."}
+	for i := range 3 * bomPathsPerPage {
+		path := fmt.Sprintf("./file%04d", i)
+		entries = append(entries, entry{path: path, mode: modeFile | 0o644})
+		expected = append(expected, path)
+	}
+	bom, err := writeBom(entries)
+	require.NoError(t, err)
+	require.Equal(t, expected, bomPaths(t, bom))
+}
+
+func TestBomMissingParent(t *testing.T) {
+	_, err := writeBom([]entry{
+		{path: ".", mode: modeDir | 0o755},
+		{path: "./bin/foo", mode: modeFile | 0o755},
+	})
+	require.EqualError(t, err, "bom: parent directory of ./bin/foo is missing")
+}
+
+func TestCksum(t *testing.T) {
+	// as computed by cksum(1).
+	require.Equal(t, uint32(4294967295), cksum(nil))
+	require.Equal(t, uint32(3015617425), cksum([]byte("hello\n")))
+}
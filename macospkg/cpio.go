@@ -0,0 +1,36 @@
+package macospkg
+
+import (
+	"fmt"
+	"io"
+)
+
+// cpioTrailer is the name of the entry ending a cpio archive.
+const cpioTrailer = "TRAILER!!!"
+
+// writeCpio writes the entries to w as a cpio archive in the portable ASCII
+// (odc) format, the one the Installer extracts the Payload and the Scripts
+// with.
+func writeCpio(w io.Writer, entries []entry) error {
+	for i, e := range entries {
+		nlink := 1
+		if e.mode&modeDir != 0 {
+			nlink = 2
+		}
+		if err := writeCpioHeader(w, e.path, i+1, e.mode, e.uid, e.gid, nlink, e.mtime.Unix(), len(e.data)); err != nil {
+			return fmt.Errorf("cannot write header of %s: %w", e.path, err)
+		}
+		if _, err := w.Write(e.data); err != nil {
+			return fmt.Errorf("cannot write %s: %w", e.path, err)
+		}
+	}
+	return writeCpioHeader(w, cpioTrailer, 0, 0, 0, 0, 1, 0, 0)
+}
+
+// writeCpioHeader writes the header of an entry, its fields in octal, followed
+// by its NUL terminated name. The odc format has no padding.
+func writeCpioHeader(w io.Writer, name string, ino int, mode, uid, gid uint32, nlink int, mtime int64, size int) error {
+	_, err := fmt.Fprintf(w, "070707%06o%06o%06o%06o%06o%06o%06o%011o%06o%011o%s\x00",
+		0, ino, mode, uid, gid, nlink, 0, mtime, len(name)+1, size, name)
+	return err
+}
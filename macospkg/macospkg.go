@@ -0,0 +1,464 @@
+// Package macospkg implements nfpm.Packager providing macOS flat installer
+// package bindings.
+//
+// A flat package is a xar archive holding the Distribution, which describes
+// the installation to the Installer, and a component package: its
+// PackageInfo, its contents in the Payload, a gzip compressed cpio archive,
+// their bill of materials in the Bom, and its Scripts, another gzip compressed
+// cpio archive. The packages are written unsigned, productsign(1) signs them
+// afterwards.
+package macospkg
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+)
+
+const packagerName = "macos-pkg"
+
+// nolint: gochecknoinits
+func init() {
+	nfpm.RegisterPackager(packagerName, Default)
+}
+
+// nolint: gochecknoglobals
+var archToMacOS = map[string]string{
+	"all":     "universal",
+	"amd64":   "x86_64",
+	"x86_64":  "x86_64",
+	"arm64":   "arm64",
+	"aarch64": "arm64",
+}
+
+// the file type bits of the modes of the entries of the archives.
+const (
+	modeType    = 0o170000
+	modeDir     = 0o040000
+	modeFile    = 0o100000
+	modeSymlink = 0o120000
+)
+
+// the ids of the users and groups the contents may be owned by.
+// nolint: gochecknoglobals
+var accounts = map[string]uint32{
+	"root":   0,
+	"wheel":  0,
+	"daemon": 1,
+	"staff":  20,
+	"admin":  80,
+}
+
+func ensureValidArch(info *nfpm.Info) *nfpm.Info {
+	if info.MacOS.Arch != "" {
+		info.Arch = info.MacOS.Arch
+	} else if arch, ok := archToMacOS[info.Arch]; ok {
+		info.Arch = arch
+	}
+
+	return info
+}
+
+// Default macOS packager.
+// nolint: gochecknoglobals
+var Default = &MacOSPkg{}
+
+// MacOSPkg is a macOS flat installer package packager implementation.
+type MacOSPkg struct{}
+
+// ConventionalFileName returns a file name according to the conventions for
+// macOS packages: name-version-arch.pkg.
+func (*MacOSPkg) ConventionalFileName(info *nfpm.Info) string {
+	info = ensureValidArch(info)
+	return fmt.Sprintf("%s-%s-%s.pkg", info.Name, pkgVersion(info), info.Arch)
+}
+
+// ConventionalExtension returns the file name conventionally used for macOS
+// packages.
+func (*MacOSPkg) ConventionalExtension() string {
+	return ".pkg"
+}
+
+// pkgVersion returns the version of the package, with its prerelease.
+func pkgVersion(info *nfpm.Info) string {
+	if info.Prerelease != "" {
+		return info.Version + "-" + info.Prerelease
+	}
+	return info.Version
+}
+
+// entry is a file of the Payload or the Scripts, under its path relative to
+// the install location, e.g. ./bin/foo, the install location itself being ".".
+type entry struct {
+	path     string
+	mode     uint32
+	uid, gid uint32
+	mtime    time.Time
+	// data is the content of a file or the target of a symlink.
+	data []byte
+}
+
+// Package writes a new macOS package to the given writer using the given
+// info.
+func (*MacOSPkg) Package(info *nfpm.Info, w io.Writer) error {
+	info = ensureValidArch(info)
+
+	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
+		return err
+	}
+
+	mtime := modtime.Get(info.MTime)
+	location := path.Clean("/" + info.MacOS.InstallLocation)
+	entries, err := payloadEntries(info.Contents, location, mtime)
+	if err != nil {
+		return err
+	}
+	bom, err := writeBom(entries)
+	if err != nil {
+		return err
+	}
+	payload, err := newCpioGz(entries)
+	if err != nil {
+		return fmt.Errorf("cannot create Payload: %w", err)
+	}
+
+	identifier := cmp.Or(info.MacOS.Identifier, info.Name)
+	component := info.Name + ".pkg"
+	pkgInfo := packageInfo{
+		FormatVersion:   2,
+		Identifier:      identifier,
+		Version:         pkgVersion(info),
+		InstallLocation: location,
+		Auth:            "root",
+		Payload: payloadInfo{
+			NumberOfFiles: len(entries),
+			InstallKBytes: installKBytes(entries),
+		},
+	}
+
+	children := []xarFile{
+		{name: "Bom", data: bom},
+		{name: "Payload", data: payload},
+	}
+	scripts, err := scriptEntries(info, mtime)
+	if err != nil {
+		return err
+	}
+	if len(scripts) > 1 {
+		archive, err := newCpioGz(scripts)
+		if err != nil {
+			return fmt.Errorf("cannot create Scripts: %w", err)
+		}
+		children = append(children, xarFile{name: "Scripts", data: archive})
+		pkgInfo.Scripts = &pkgInfoScripts{}
+		for _, script := range scripts[1:] {
+			ref := &pkgInfoScript{File: script.path}
+			switch script.path {
+			case "./preinstall":
+				pkgInfo.Scripts.PreInstall = ref
+			case "./postinstall":
+				pkgInfo.Scripts.PostInstall = ref
+			}
+		}
+	}
+	pkgInfoXML, err := marshalXML(pkgInfo)
+	if err != nil {
+		return err
+	}
+	children = append(children, xarFile{name: "PackageInfo", data: pkgInfoXML})
+
+	distributionXML, err := marshalXML(newDistribution(info, identifier, component, pkgInfo))
+	if err != nil {
+		return err
+	}
+
+	return writeXar(w, []xarFile{
+		{name: "Distribution", data: distributionXML},
+		{name: component, children: children},
+	}, mtime)
+}
+
+// payloadEntries returns the entries of the Payload, relative to the install
+// location, sorted by path, each directory coming before the paths it holds.
+func payloadEntries(contents files.Contents, location string, mtime time.Time) ([]entry, error) {
+	root := entry{path: ".", mode: modeDir | 0o755, mtime: mtime}
+	var entries []entry
+	for _, content := range contents {
+		dst := files.NormalizeAbsoluteFilePath(content.Destination)
+		rel, ok := relativePath(dst, location)
+		if !ok {
+			if content.Type == files.TypeImplicitDir {
+				// a parent of the install location.
+				continue
+			}
+			return nil, fmt.Errorf("%s is not under the install location %s", dst, location)
+		}
+
+		uid, err := accountID(content.FileInfo.Owner)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dst, err)
+		}
+		gid, err := accountID(content.FileInfo.Group)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dst, err)
+		}
+		e := entry{
+			path:  rel,
+			mode:  uint32(content.Mode()) & 0o7777,
+			uid:   uid,
+			gid:   gid,
+			mtime: content.ModTime(),
+		}
+		if e.mtime.IsZero() {
+			e.mtime = mtime
+		}
+		switch content.Type {
+		case files.TypeDir, files.TypeImplicitDir:
+			e.mode |= modeDir
+			if rel == "." {
+				root = e
+				continue
+			}
+		case files.TypeSymlink:
+			e.mode = modeSymlink | 0o755
+			e.data = []byte(content.Source)
+		case files.TypeFile, files.TypeConfig, files.TypeConfigNoReplace, files.TypeConfigMissingOK:
+			data, err := os.ReadFile(content.Source)
+			if err != nil {
+				return nil, err
+			}
+			e.mode |= modeFile
+			e.data = data
+		default:
+			// ignore everything else
+			continue
+		}
+		if rel == "." {
+			return nil, fmt.Errorf("%s is the install location, it must be a directory", dst)
+		}
+		entries = append(entries, e)
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		return slices.Compare(strings.Split(a.path, "/"), strings.Split(b.path, "/"))
+	})
+	return append([]entry{root}, entries...), nil
+}
+
+// relativePath returns the path of dst relative to the install location, in
+// the syntax of the Payload, and whether dst is under it.
+func relativePath(dst, location string) (string, bool) {
+	switch {
+	case dst == location:
+		return ".", true
+	case location == "/":
+		return "." + dst, true
+	}
+	if rel, ok := strings.CutPrefix(dst, location+"/"); ok {
+		return "./" + rel, true
+	}
+	return "", false
+}
+
+// accountID returns the id of a user or group, given by its name or its id,
+// as the archives only record the ids.
+func accountID(name string) (uint32, error) {
+	if id, ok := accounts[name]; ok {
+		return id, nil
+	}
+	// the ids are written with 6 octal digits.
+	if id, err := strconv.ParseUint(name, 10, 18); err == nil {
+		return uint32(id), nil
+	}
+	return 0, fmt.Errorf("unknown user or group on macOS: %s", name)
+}
+
+// scriptEntries returns the entries of the Scripts: the preinstall and
+// postinstall scripts, which the Installer runs before and after installing
+// the payload.
+func scriptEntries(info *nfpm.Info, mtime time.Time) ([]entry, error) {
+	entries := []entry{{path: ".", mode: modeDir | 0o755, mtime: mtime}}
+	for _, script := range []struct {
+		name, path string
+	}{
+		{"preinstall", info.Scripts.PreInstall},
+		{"postinstall", info.Scripts.PostInstall},
+	} {
+		if script.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(script.path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{
+			path:  "./" + script.name,
+			mode:  modeFile | 0o755,
+			mtime: mtime,
+			data:  data,
+		})
+	}
+	return entries, nil
+}
+
+// newCpioGz returns the entries as a gzip compressed cpio archive.
+func newCpioGz(entries []entry) ([]byte, error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if err := writeCpio(gw, entries); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// installKBytes returns the size of the regular files of the entries in
+// kilobytes, rounded up.
+func installKBytes(entries []entry) int64 {
+	var size int64
+	for _, e := range entries {
+		if e.mode&modeType == modeFile {
+			size += int64(len(e.data))
+		}
+	}
+	return (size + 1023) / 1024
+}
+
+// packageInfo is the PackageInfo of a component package, describing its
+// payload and scripts.
+type packageInfo struct {
+	XMLName         xml.Name        `xml:"pkg-info"`
+	FormatVersion   int             `xml:"format-version,attr"`
+	Identifier      string          `xml:"identifier,attr"`
+	Version         string          `xml:"version,attr"`
+	InstallLocation string          `xml:"install-location,attr"`
+	Auth            string          `xml:"auth,attr"`
+	Payload         payloadInfo     `xml:"payload"`
+	Scripts         *pkgInfoScripts `xml:"scripts,omitempty"`
+}
+
+type payloadInfo struct {
+	NumberOfFiles int   `xml:"numberOfFiles,attr"`
+	InstallKBytes int64 `xml:"installKBytes,attr"`
+}
+
+type pkgInfoScripts struct {
+	PreInstall  *pkgInfoScript `xml:"preinstall,omitempty"`
+	PostInstall *pkgInfoScript `xml:"postinstall,omitempty"`
+}
+
+type pkgInfoScript struct {
+	File string `xml:"file,attr"`
+}
+
+// distribution is the Distribution of a product archive, as written by
+// productbuild(1): a single choice, hidden from the user, installs the
+// component package.
+type distribution struct {
+	XMLName        xml.Name            `xml:"installer-gui-script"`
+	MinSpecVersion int                 `xml:"minSpecVersion,attr"`
+	Title          string              `xml:"title"`
+	Options        distributionOptions `xml:"options"`
+	VolumeCheck    *volumeCheck        `xml:"volume-check,omitempty"`
+	ChoicesOutline struct {
+		Line choiceLine `xml:"line"`
+	} `xml:"choices-outline"`
+	Choices []choice `xml:"choice"`
+	PkgRef  pkgRef   `xml:"pkg-ref"`
+}
+
+type distributionOptions struct {
+	Customize         string `xml:"customize,attr"`
+	RequireScripts    bool   `xml:"require-scripts,attr"`
+	HostArchitectures string `xml:"hostArchitectures,attr,omitempty"`
+}
+
+type volumeCheck struct {
+	OSVersion struct {
+		Min string `xml:"min,attr"`
+	} `xml:"allowed-os-versions>os-version"`
+}
+
+type choiceLine struct {
+	Choice string       `xml:"choice,attr"`
+	Lines  []choiceLine `xml:"line"`
+}
+
+type choice struct {
+	ID      string  `xml:"id,attr"`
+	Visible *bool   `xml:"visible,attr,omitempty"`
+	PkgRef  *pkgRef `xml:"pkg-ref,omitempty"`
+}
+
+type pkgRef struct {
+	ID            string `xml:"id,attr"`
+	Version       string `xml:"version,attr,omitempty"`
+	OnConclusion  string `xml:"onConclusion,attr,omitempty"`
+	InstallKBytes int64  `xml:"installKBytes,attr,omitempty"`
+	URL           string `xml:",chardata"`
+}
+
+func newDistribution(info *nfpm.Info, identifier, component string, pkgInfo packageInfo) distribution {
+	hidden := false
+	d := distribution{
+		MinSpecVersion: 2,
+		Title:          info.Name,
+		Options: distributionOptions{
+			Customize:         "never",
+			HostArchitectures: hostArchitectures(info.Arch),
+		},
+		Choices: []choice{
+			{ID: "default"},
+			{ID: identifier, Visible: &hidden, PkgRef: &pkgRef{ID: identifier}},
+		},
+		PkgRef: pkgRef{
+			ID:            identifier,
+			Version:       pkgInfo.Version,
+			OnConclusion:  "none",
+			InstallKBytes: pkgInfo.Payload.InstallKBytes,
+			URL:           "#" + url.PathEscape(component),
+		},
+	}
+	d.ChoicesOutline.Line = choiceLine{
+		Choice: "default",
+		Lines:  []choiceLine{{Choice: identifier}},
+	}
+	if info.MacOS.MinOS != "" {
+		d.VolumeCheck = &volumeCheck{}
+		d.VolumeCheck.OSVersion.Min = info.MacOS.MinOS
+	}
+	return d
+}
+
+// hostArchitectures returns the architectures the Installer allows the
+// package to be installed on.
+func hostArchitectures(arch string) string {
+	if arch == "universal" {
+		return "arm64,x86_64"
+	}
+	return arch
+}
+
+func marshalXML(v any) ([]byte, error) {
+	b, err := xml.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
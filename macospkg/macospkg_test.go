@@ -0,0 +1,297 @@
+package macospkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha1" // nolint: gosec
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+var mtime = time.Date(2023, 11, 5, 23, 15, 17, 0, time.UTC)
+
+func exampleInfo() *nfpm.Info {
+	return nfpm.WithDefaults(&nfpm.Info{
+		Name:        "foo",
+		Arch:        "arm64",
+		Description: "Foo does things",
+		Maintainer:  "Carlos A Becker <pkg@carlosbecker.com>",
+		Version:     "v1.0.0",
+		Prerelease:  "rc1",
+		MTime:       mtime,
+		Overridables: nfpm.Overridables{
+			Contents: []*files.Content{
+				{
+					Source:      "../testdata/fake",
+					Destination: "/usr/local/bin/fake",
+					FileInfo: &files.ContentFileInfo{
+						Mode: 0o755,
+					},
+				},
+				{
+					Source:      "../testdata/whatever.conf",
+					Destination: "/usr/local/etc/fake.conf",
+					Type:        files.TypeConfig,
+				},
+				{
+					Source:      "/usr/local/bin/fake",
+					Destination: "/usr/local/bin/fake-link",
+					Type:        files.TypeSymlink,
+				},
+				{
+					Destination: "/usr/local/var/foo",
+					Type:        files.TypeDir,
+					FileInfo: &files.ContentFileInfo{
+						Owner: "root",
+						Group: "staff",
+						Mode:  0o775,
+					},
+				},
+			},
+			Scripts: nfpm.Scripts{
+				PreInstall:  "../testdata/scripts/preinstall.sh",
+				PostInstall: "../testdata/scripts/postinstall.sh",
+			},
+			MacOS: nfpm.MacOS{
+				Identifier: "com.example.foo",
+				MinOS:      "11.0",
+			},
+		},
+	})
+}
+
+// readXar returns the files of a xar archive by path, checking their
+// checksums.
+func readXar(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	require.Equal(t, uint32(xarMagic), binary.BigEndian.Uint32(data))
+	headerSize := int(binary.BigEndian.Uint16(data[4:]))
+	tocSize := int(binary.BigEndian.Uint64(data[8:]))
+	compressed := data[headerSize : headerSize+tocSize]
+	heap := data[headerSize+tocSize:]
+
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	var toc xarTOC
+	require.NoError(t, xml.NewDecoder(zr).Decode(&toc))
+	tocChecksum := sha1.Sum(compressed) // nolint: gosec
+	require.Equal(t, tocChecksum[:], heap[toc.TOC.Checksum.Offset:toc.TOC.Checksum.Offset+toc.TOC.Checksum.Size])
+
+	result := map[string][]byte{}
+	var walk func(prefix string, files []xarTOCFile)
+	walk = func(prefix string, files []xarTOCFile) {
+		for _, f := range files {
+			if f.Type == "directory" {
+				walk(prefix+f.Name+"/", f.Files)
+				continue
+			}
+			content := heap[f.Data.Offset : f.Data.Offset+f.Data.Length]
+			digest := sha1.Sum(content) // nolint: gosec
+			require.Equal(t, hex.EncodeToString(digest[:]), f.Data.ExtractedChecksum.Value)
+			result[prefix+f.Name] = content
+		}
+	}
+	walk("", toc.TOC.Files)
+	return result
+}
+
+// readCpioGz returns the entries of a gzip compressed cpio archive.
+func readCpioGz(t *testing.T, data []byte) []entry {
+	t.Helper()
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	archive, err := io.ReadAll(gr)
+	require.NoError(t, err)
+
+	field := func(header []byte, offset, size int) uint64 {
+		v, err := strconv.ParseUint(string(header[offset:offset+size]), 8, 64)
+		require.NoError(t, err)
+		return v
+	}
+	var entries []entry
+	for {
+		header := archive[:76]
+		require.Equal(t, "070707", string(header[:6]))
+		nameSize := int(field(header, 59, 6))
+		size := int(field(header, 65, 11))
+		name := string(archive[76 : 76+nameSize-1])
+		if name == cpioTrailer {
+			require.Len(t, archive, 76+nameSize)
+			return entries
+		}
+		entries = append(entries, entry{
+			path:  name,
+			mode:  uint32(field(header, 18, 6)),
+			uid:   uint32(field(header, 24, 6)),
+			gid:   uint32(field(header, 30, 6)),
+			mtime: time.Unix(int64(field(header, 48, 11)), 0).UTC(),
+			data:  archive[76+nameSize : 76+nameSize+size],
+		})
+		archive = archive[76+nameSize+size:]
+	}
+}
+
+func TestConventionalFileName(t *testing.T) {
+	for arch, expected := range map[string]string{
+		"all":   "foo-1.0.0-rc1-universal.pkg",
+		"amd64": "foo-1.0.0-rc1-x86_64.pkg",
+		"arm64": "foo-1.0.0-rc1-arm64.pkg",
+	} {
+		t.Run(arch, func(t *testing.T) {
+			info := exampleInfo()
+			info.Arch = arch
+			require.Equal(t, expected, Default.ConventionalFileName(info))
+		})
+	}
+}
+
+func TestPackage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(exampleInfo(), &buf))
+	archive := readXar(t, buf.Bytes())
+
+	names := make([]string, 0, len(archive))
+	for name := range archive {
+		names = append(names, name)
+	}
+	require.ElementsMatch(t, []string{
+		"Distribution",
+		"foo.pkg/Bom",
+		"foo.pkg/PackageInfo",
+		"foo.pkg/Payload",
+		"foo.pkg/Scripts",
+	}, names)
+
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<pkg-info format-version="2" identifier="com.example.foo" version="1.0.0-rc1" install-location="/" auth="root">
+    <payload numberOfFiles="10" installKBytes="1"></payload>
+    <scripts>
+        <preinstall file="./preinstall"></preinstall>
+        <postinstall file="./postinstall"></postinstall>
+    </scripts>
+</pkg-info>
+`, string(archive["foo.pkg/PackageInfo"]))
+
+	var dist distribution
+	require.NoError(t, xml.Unmarshal(archive["Distribution"], &dist))
+	require.Equal(t, "arm64", dist.Options.HostArchitectures)
+	require.Equal(t, "11.0", dist.VolumeCheck.OSVersion.Min)
+	require.Equal(t, "com.example.foo", dist.ChoicesOutline.Line.Lines[0].Choice)
+	require.Equal(t, "com.example.foo", dist.PkgRef.ID)
+	require.Equal(t, "1.0.0-rc1", dist.PkgRef.Version)
+	require.Equal(t, "#foo.pkg", dist.PkgRef.URL)
+
+	fake, err := os.ReadFile("../testdata/fake")
+	require.NoError(t, err)
+	payload := readCpioGz(t, archive["foo.pkg/Payload"])
+	paths := make([]string, 0, len(payload))
+	for _, e := range payload {
+		paths = append(paths, e.path)
+		require.Equal(t, mtime, e.mtime, e.path)
+		switch e.path {
+		case "./usr/local/bin/fake":
+			require.Equal(t, uint32(modeFile|0o755), e.mode)
+			require.Equal(t, fake, e.data)
+		case "./usr/local/bin/fake-link":
+			require.Equal(t, uint32(modeSymlink|0o755), e.mode)
+			require.Equal(t, "/usr/local/bin/fake", string(e.data))
+		case "./usr/local/var/foo":
+			require.Equal(t, uint32(modeDir|0o775), e.mode)
+			require.Equal(t, uint32(0), e.uid)
+			require.Equal(t, uint32(20), e.gid)
+		}
+	}
+	require.Equal(t, []string{
+		".",
+		"./usr",
+		"./usr/local",
+		"./usr/local/bin",
+		"./usr/local/bin/fake",
+		"./usr/local/bin/fake-link",
+		"./usr/local/etc",
+		"./usr/local/etc/fake.conf",
+		"./usr/local/var",
+		"./usr/local/var/foo",
+	}, paths)
+	require.Equal(t, paths, bomPaths(t, archive["foo.pkg/Bom"]))
+
+	preinstall, err := os.ReadFile("../testdata/scripts/preinstall.sh")
+	require.NoError(t, err)
+	scripts := readCpioGz(t, archive["foo.pkg/Scripts"])
+	require.Len(t, scripts, 3)
+	require.Equal(t, ".", scripts[0].path)
+	require.Equal(t, "./preinstall", scripts[1].path)
+	require.Equal(t, uint32(modeFile|0o755), scripts[1].mode)
+	require.Equal(t, preinstall, scripts[1].data)
+	require.Equal(t, "./postinstall", scripts[2].path)
+}
+
+func TestPackageWithoutScripts(t *testing.T) {
+	info := exampleInfo()
+	info.Scripts = nfpm.Scripts{}
+	info.MacOS.MinOS = ""
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	archive := readXar(t, buf.Bytes())
+	require.NotContains(t, archive, "foo.pkg/Scripts")
+	require.NotContains(t, string(archive["foo.pkg/PackageInfo"]), "<scripts>")
+	require.NotContains(t, string(archive["Distribution"]), "volume-check")
+}
+
+func TestPackageInstallLocation(t *testing.T) {
+	info := exampleInfo()
+	info.Arch = "all"
+	info.MacOS.Identifier = ""
+	info.MacOS.InstallLocation = "/usr/local/"
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	archive := readXar(t, buf.Bytes())
+	require.Contains(t, string(archive["foo.pkg/PackageInfo"]), `identifier="foo" version="1.0.0-rc1" install-location="/usr/local"`)
+	require.Contains(t, string(archive["Distribution"]), `hostArchitectures="arm64,x86_64"`)
+
+	var paths []string
+	for _, e := range readCpioGz(t, archive["foo.pkg/Payload"]) {
+		paths = append(paths, e.path)
+	}
+	require.Equal(t, []string{
+		".",
+		"./bin",
+		"./bin/fake",
+		"./bin/fake-link",
+		"./etc",
+		"./etc/fake.conf",
+		"./var",
+		"./var/foo",
+	}, paths)
+}
+
+func TestPackageOutsideInstallLocation(t *testing.T) {
+	info := exampleInfo()
+	info.MacOS.InstallLocation = "/opt/foo"
+	err := Default.Package(info, io.Discard)
+	require.ErrorContains(t, err, "/usr/local/bin/fake is not under the install location /opt/foo")
+}
+
+func TestPackageUnknownOwner(t *testing.T) {
+	info := exampleInfo()
+	info.Contents[3].FileInfo.Owner = "foo"
+	err := Default.Package(info, io.Discard)
+	require.ErrorContains(t, err, "/usr/local/var/foo: unknown user or group on macOS: foo")
+}
+
+func TestPackageNoArch(t *testing.T) {
+	info := exampleInfo()
+	info.Arch = ""
+	require.EqualError(t, Default.Package(info, io.Discard), "package arch must be provided")
+}
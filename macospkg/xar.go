@@ -0,0 +1,163 @@
+package macospkg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1" // nolint: gosec
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A xar archive starts with its header, followed by its table of contents, an
+// XML document compressed with zlib, and by the heap, starting with the
+// checksum of the table of contents, followed by the files.
+// See https://github.com/apple-oss-distributions/xar.
+const (
+	xarMagic      = 0x78617221 // xar!
+	xarHeaderSize = 28
+	xarVersion    = 1
+	// xarChecksumSHA1 is the algorithm of the checksums of the archive.
+	xarChecksumSHA1 = 1
+	xarTimeFormat   = "2006-01-02T15:04:05Z"
+)
+
+// xarFile is a file of a xar archive, a directory if it has children.
+type xarFile struct {
+	name     string
+	data     []byte
+	children []xarFile
+}
+
+type xarTOC struct {
+	XMLName xml.Name `xml:"xar"`
+	TOC     struct {
+		CreationTime string         `xml:"creation-time"`
+		Checksum     xarTOCChecksum `xml:"checksum"`
+		Files        []xarTOCFile   `xml:"file"`
+	} `xml:"toc"`
+}
+
+type xarTOCChecksum struct {
+	Style  string `xml:"style,attr"`
+	Offset int    `xml:"offset"`
+	Size   int    `xml:"size"`
+}
+
+type xarTOCFile struct {
+	ID    int          `xml:"id,attr"`
+	Data  *xarTOCData  `xml:"data,omitempty"`
+	CTime string       `xml:"ctime"`
+	MTime string       `xml:"mtime"`
+	ATime string       `xml:"atime"`
+	Group string       `xml:"group"`
+	GID   int          `xml:"gid"`
+	User  string       `xml:"user"`
+	UID   int          `xml:"uid"`
+	Mode  string       `xml:"mode"`
+	Type  string       `xml:"type"`
+	Name  string       `xml:"name"`
+	Files []xarTOCFile `xml:"file"`
+}
+
+type xarTOCData struct {
+	Length            int          `xml:"length"`
+	Offset            int          `xml:"offset"`
+	Size              int          `xml:"size"`
+	Encoding          xarStyle     `xml:"encoding"`
+	ExtractedChecksum xarTOCDigest `xml:"extracted-checksum"`
+	ArchivedChecksum  xarTOCDigest `xml:"archived-checksum"`
+}
+
+type xarStyle struct {
+	Style string `xml:"style,attr"`
+}
+
+type xarTOCDigest struct {
+	Style string `xml:"style,attr"`
+	Value string `xml:",chardata"`
+}
+
+// writeXar writes the files to w as a xar archive. The files are stored
+// uncompressed, owned by root, and the directories are listed before the
+// files they hold.
+func writeXar(w io.Writer, files []xarFile, mtime time.Time) error {
+	var toc xarTOC
+	toc.TOC.CreationTime = mtime.UTC().Format(xarTimeFormat)
+	toc.TOC.Checksum = xarTOCChecksum{Style: "sha1", Offset: 0, Size: sha1.Size}
+
+	heap := bytes.Buffer{}
+	id := 0
+	var add func(files []xarFile) []xarTOCFile
+	add = func(files []xarFile) []xarTOCFile {
+		entries := make([]xarTOCFile, 0, len(files))
+		for _, f := range files {
+			id++
+			timestamp := mtime.UTC().Format(xarTimeFormat)
+			entry := xarTOCFile{
+				ID:    id,
+				CTime: timestamp,
+				MTime: timestamp,
+				ATime: timestamp,
+				Group: "wheel",
+				User:  "root",
+				Name:  f.name,
+			}
+			if f.children != nil {
+				entry.Type = "directory"
+				entry.Mode = "0755"
+				entry.Files = add(f.children)
+			} else {
+				digest := sha1.Sum(f.data) // nolint: gosec
+				checksum := xarTOCDigest{Style: "sha1", Value: hex.EncodeToString(digest[:])}
+				entry.Type = "file"
+				entry.Mode = "0644"
+				entry.Data = &xarTOCData{
+					Length:            len(f.data),
+					Offset:            sha1.Size + heap.Len(),
+					Size:              len(f.data),
+					Encoding:          xarStyle{Style: "application/octet-stream"},
+					ExtractedChecksum: checksum,
+					ArchivedChecksum:  checksum,
+				}
+				heap.Write(f.data)
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+	toc.TOC.Files = add(files)
+
+	tocXML, err := xml.MarshalIndent(toc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal xar toc: %w", err)
+	}
+	tocXML = append([]byte(xml.Header), tocXML...)
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(tocXML); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	header := binary.BigEndian.AppendUint32(nil, xarMagic)
+	header = binary.BigEndian.AppendUint16(header, xarHeaderSize)
+	header = binary.BigEndian.AppendUint16(header, xarVersion)
+	header = binary.BigEndian.AppendUint64(header, uint64(compressed.Len()))
+	header = binary.BigEndian.AppendUint64(header, uint64(len(tocXML)))
+	header = binary.BigEndian.AppendUint32(header, xarChecksumSHA1)
+	tocChecksum := sha1.Sum(compressed.Bytes()) // nolint: gosec
+
+	for _, b := range [][]byte{header, compressed.Bytes(), tocChecksum[:], heap.Bytes()} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -22,6 +22,7 @@ const packagerName = "msix"
 // nolint: gochecknoinits
 func init() {
 	nfpm.RegisterPackager(packagerName, Default)
+	nfpm.RegisterReader(packagerName, Default)
 }
 
 // nolint: gochecknoglobals
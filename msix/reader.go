@@ -0,0 +1,198 @@
+package msix
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+)
+
+// ErrInvalidPackage happens when a file cannot be read as an MSIX package.
+var ErrInvalidPackage = errors.New("invalid msix package")
+
+const (
+	manifestName  = "AppxManifest.xml"
+	signatureName = "AppxSignature.p7x"
+
+	restrictedCapabilitiesNS = "http://schemas.microsoft.com/appx/manifest/foundation/windows10/restrictedcapabilities"
+)
+
+// package footprint files that are generated by the packager and not part of
+// the payload.
+// nolint: gochecknoglobals
+var footprintFiles = map[string]bool{
+	manifestName:          true,
+	signatureName:         true,
+	"AppxBlockMap.xml":    true,
+	"[Content_Types].xml": true,
+}
+
+type appxManifest struct {
+	Identity struct {
+		Name                  string `xml:"Name,attr"`
+		Version               string `xml:"Version,attr"`
+		Publisher             string `xml:"Publisher,attr"`
+		ProcessorArchitecture string `xml:"ProcessorArchitecture,attr"`
+		ResourceID            string `xml:"ResourceId,attr"`
+	} `xml:"Identity"`
+	Properties struct {
+		DisplayName          string `xml:"DisplayName"`
+		PublisherDisplayName string `xml:"PublisherDisplayName"`
+		Logo                 string `xml:"Logo"`
+		Description          string `xml:"Description"`
+	} `xml:"Properties"`
+	Dependencies struct {
+		TargetDeviceFamilies []struct {
+			Name             string `xml:"Name,attr"`
+			MinVersion       string `xml:"MinVersion,attr"`
+			MaxVersionTested string `xml:"MaxVersionTested,attr"`
+		} `xml:"TargetDeviceFamily"`
+	} `xml:"Dependencies"`
+	Applications []struct {
+		ID             string `xml:"Id,attr"`
+		Executable     string `xml:"Executable,attr"`
+		EntryPoint     string `xml:"EntryPoint,attr"`
+		VisualElements struct {
+			DisplayName       string `xml:"DisplayName,attr"`
+			Description       string `xml:"Description,attr"`
+			BackgroundColor   string `xml:"BackgroundColor,attr"`
+			Square150x150Logo string `xml:"Square150x150Logo,attr"`
+			Square44x44Logo   string `xml:"Square44x44Logo,attr"`
+		} `xml:"VisualElements"`
+	} `xml:"Applications>Application"`
+	Capabilities struct {
+		Capabilities []struct {
+			XMLName xml.Name
+			Name    string `xml:"Name,attr"`
+		} `xml:"Capability"`
+		DeviceCapabilities []struct {
+			Name string `xml:"Name,attr"`
+		} `xml:"DeviceCapability"`
+	} `xml:"Capabilities"`
+}
+
+// Read implements nfpm.Reader.
+func (*MSIX) Read(r io.Reader) (*nfpm.Inspection, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+
+	result := &nfpm.Inspection{
+		Format: packagerName,
+		Info: &nfpm.Info{
+			Platform: "windows",
+		},
+	}
+
+	var foundManifest bool
+	for _, f := range zr.File {
+		switch name := f.Name; {
+		case name == manifestName:
+			foundManifest = true
+			if err := readManifest(f, result.Info); err != nil {
+				return nil, err
+			}
+		case name == signatureName:
+			result.Signatures = append(result.Signatures, nfpm.SignatureInfo{
+				Type: "authenticode",
+			})
+		case footprintFiles[name], strings.HasPrefix(name, "AppxMetadata/"), strings.HasSuffix(name, "/"):
+			continue
+		default:
+			result.Info.Contents = append(result.Info.Contents, contentFromZip(f))
+		}
+	}
+	if !foundManifest {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidPackage, manifestName)
+	}
+	sort.Sort(result.Info.Contents)
+	return result, nil
+}
+
+func contentFromZip(f *zip.File) *files.Content {
+	name := f.Name
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	return &files.Content{
+		Destination: files.NormalizeAbsoluteFilePath(name),
+		Type:        files.TypeFile,
+		FileInfo: &files.ContentFileInfo{
+			Mode:  f.Mode() &^ fs.ModeType,
+			MTime: f.Modified,
+			Size:  int64(f.UncompressedSize64), // nolint: gosec
+		},
+	}
+}
+
+func readManifest(f *zip.File, info *nfpm.Info) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("cannot open %s: %w", f.Name, err)
+	}
+	defer rc.Close() // nolint: errcheck
+
+	var manifest appxManifest
+	if err := xml.NewDecoder(rc).Decode(&manifest); err != nil {
+		return fmt.Errorf("cannot parse %s: %w", f.Name, err)
+	}
+
+	info.Name = manifest.Identity.Name
+	info.Version = manifest.Identity.Version
+	info.Arch = manifest.Identity.ProcessorArchitecture
+	info.Description = manifest.Properties.Description
+	info.MSIX.Arch = manifest.Identity.ProcessorArchitecture
+	info.MSIX.Publisher = manifest.Identity.Publisher
+	info.MSIX.Identity.ResourceID = manifest.Identity.ResourceID
+	info.MSIX.Properties = nfpm.MSIXProperties{
+		DisplayName:          manifest.Properties.DisplayName,
+		PublisherDisplayName: manifest.Properties.PublisherDisplayName,
+		Logo:                 manifest.Properties.Logo,
+	}
+	for _, family := range manifest.Dependencies.TargetDeviceFamilies {
+		info.MSIX.Dependencies.TargetDeviceFamilies = append(info.MSIX.Dependencies.TargetDeviceFamilies, nfpm.MSIXTargetDeviceFamily{
+			Name:             family.Name,
+			MinVersion:       family.MinVersion,
+			MaxVersionTested: family.MaxVersionTested,
+		})
+	}
+	for _, app := range manifest.Applications {
+		info.MSIX.Applications = append(info.MSIX.Applications, nfpm.MSIXApplication{
+			ID:         app.ID,
+			Executable: app.Executable,
+			EntryPoint: app.EntryPoint,
+			VisualElements: nfpm.MSIXVisualElements{
+				DisplayName:       app.VisualElements.DisplayName,
+				Description:       app.VisualElements.Description,
+				BackgroundColor:   app.VisualElements.BackgroundColor,
+				Square150x150Logo: app.VisualElements.Square150x150Logo,
+				Square44x44Logo:   app.VisualElements.Square44x44Logo,
+			},
+		})
+	}
+	for _, capability := range manifest.Capabilities.Capabilities {
+		if capability.XMLName.Space == restrictedCapabilitiesNS {
+			info.MSIX.Capabilities.Restricted = append(info.MSIX.Capabilities.Restricted, capability.Name)
+			continue
+		}
+		info.MSIX.Capabilities.Capabilities = append(info.MSIX.Capabilities.Capabilities, capability.Name)
+	}
+	for _, capability := range manifest.Capabilities.DeviceCapabilities {
+		info.MSIX.Capabilities.DeviceCapabilities = append(info.MSIX.Capabilities.DeviceCapabilities, capability.Name)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package msix
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `<?xml version="1.0" encoding="UTF-8"?>
+<Package xmlns="http://schemas.microsoft.com/appx/manifest/foundation/windows10"
+  xmlns:uap="http://schemas.microsoft.com/appx/manifest/uap/windows10"
+  xmlns:rescap="http://schemas.microsoft.com/appx/manifest/foundation/windows10/restrictedcapabilities">
+  <Identity Name="MyCompany.TestApp" Version="1.0.0.0" Publisher="CN=TestCompany" ProcessorArchitecture="x64"/>
+  <Properties>
+    <DisplayName>Test App</DisplayName>
+    <PublisherDisplayName>TestCompany</PublisherDisplayName>
+    <Logo>app\fake.exe</Logo>
+    <Description>Test application</Description>
+  </Properties>
+  <Dependencies>
+    <TargetDeviceFamily Name="Windows.Desktop" MinVersion="10.0.17763.0" MaxVersionTested="10.0.22621.0"/>
+  </Dependencies>
+  <Applications>
+    <Application Id="App" Executable="app\fake.exe" EntryPoint="Windows.FullTrustApplication">
+      <uap:VisualElements DisplayName="Test App" Description="Test application" BackgroundColor="transparent"/>
+    </Application>
+  </Applications>
+  <Capabilities>
+    <Capability Name="internetClient"/>
+    <rescap:Capability Name="runFullTrust"/>
+  </Capabilities>
+</Package>`
+
+func testPackage(t *testing.T, entries map[string]string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return &buf
+}
+
+func TestRead(t *testing.T) {
+	pkg := testPackage(t, map[string]string{
+		"AppxManifest.xml":     testManifest,
+		"AppxBlockMap.xml":     "<BlockMap/>",
+		"[Content_Types].xml":  "<Types/>",
+		"AppxSignature.p7x":    "PKCX",
+		"app/fake.exe":         "fake",
+		"app/with%20space.txt": "txt",
+	})
+
+	result, err := Default.Read(pkg)
+	require.NoError(t, err)
+	require.Equal(t, packagerName, result.Format)
+
+	got := result.Info
+	require.Equal(t, "MyCompany.TestApp", got.Name)
+	require.Equal(t, "1.0.0.0", got.Version)
+	require.Equal(t, "x64", got.Arch)
+	require.Equal(t, "Test application", got.Description)
+	require.Equal(t, "CN=TestCompany", got.MSIX.Publisher)
+	require.Equal(t, "Test App", got.MSIX.Properties.DisplayName)
+	require.Equal(t, []nfpm.MSIXTargetDeviceFamily{{
+		Name:             "Windows.Desktop",
+		MinVersion:       "10.0.17763.0",
+		MaxVersionTested: "10.0.22621.0",
+	}}, got.MSIX.Dependencies.TargetDeviceFamilies)
+	require.Len(t, got.MSIX.Applications, 1)
+	require.Equal(t, "App", got.MSIX.Applications[0].ID)
+	require.Equal(t, "Test App", got.MSIX.Applications[0].VisualElements.DisplayName)
+	require.Equal(t, []string{"internetClient"}, got.MSIX.Capabilities.Capabilities)
+	require.Equal(t, []string{"runFullTrust"}, got.MSIX.Capabilities.Restricted)
+
+	require.Len(t, got.Contents, 2)
+	require.Equal(t, "/app/fake.exe", got.Contents[0].Destination)
+	require.Equal(t, files.TypeFile, got.Contents[0].Type)
+	require.Equal(t, int64(4), got.Contents[0].FileInfo.Size)
+	require.Equal(t, "/app/with space.txt", got.Contents[1].Destination)
+
+	require.Equal(t, []nfpm.SignatureInfo{{Type: "authenticode"}}, result.Signatures)
+}
+
+func TestReadNoManifest(t *testing.T) {
+	_, err := Default.Read(testPackage(t, map[string]string{"app/fake.exe": "fake"}))
+	require.ErrorIs(t, err, ErrInvalidPackage)
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, err := Default.Read(strings.NewReader("not an msix"))
+	require.ErrorIs(t, err, ErrInvalidPackage)
+}
@@ -0,0 +1,386 @@
+package msix
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/maps"
+)
+
+// ErrSignatureMismatch happens when the package contents do not match the
+// digests covered by its signature.
+var ErrSignatureMismatch = errors.New("signed digests do not match the package")
+
+// ErrPartialVerification happens when the files of the package match its
+// signature, but the digests of its zip records could not be checked.
+var ErrPartialVerification = errors.New("the digests of the zip records (AXPC and AXCD) are not verified")
+
+const (
+	blockMapName     = "AppxBlockMap.xml"
+	contentTypesName = "[Content_Types].xml"
+	blockSize        = 64 * 1024
+)
+
+// nolint: gochecknoglobals
+var (
+	oidSignedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidDigestAlgorithms = map[string]crypto.Hash{
+		"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+		"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+		"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+	}
+)
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue     `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue     `asn1:"optional,tag:1"`
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+type pkcs7SignerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     asn1.RawValue
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type pkcs7Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type spcIndirectDataContent struct {
+	Data          asn1.RawValue
+	MessageDigest struct {
+		DigestAlgorithm pkix.AlgorithmIdentifier
+		Digest          []byte
+	}
+}
+
+type blockMap struct {
+	Files []struct {
+		Name   string `xml:"Name,attr"`
+		Size   int64  `xml:"Size,attr"`
+		Blocks []struct {
+			Hash string `xml:"Hash,attr"`
+		} `xml:"Block"`
+	} `xml:"File"`
+}
+
+// Verify implements nfpm.Verifier. The Authenticode signature is checked
+// against the X.509 certificate (or PEM public key) in keyFile, then the
+// digests it signs are checked against the block map and the content types,
+// and finally every file is checked against the block map.
+//
+// The digests of the raw zip records (AXPC and AXCD) are not recomputed, so
+// changes to the zip structure that leave the files untouched would go
+// unnoticed: a signature whose other digests all match fails with
+// ErrPartialVerification instead of passing.
+func (*MSIX) Verify(r io.Reader, keyFile string) ([]nfpm.SignatureVerification, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+
+	entries := map[string]*zip.File{}
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+	sigFile, ok := entries[signatureName]
+	if !ok {
+		return nil, nil
+	}
+
+	result := nfpm.SignatureVerification{
+		SignatureInfo: nfpm.SignatureInfo{Type: "authenticode"},
+	}
+	result.Err = verifyPackage(zr, entries, sigFile, keyFile)
+	return []nfpm.SignatureVerification{result}, nil
+}
+
+func verifyPackage(zr *zip.Reader, entries map[string]*zip.File, sigFile *zip.File, keyFile string) error {
+	pub, err := readPublicKey(keyFile)
+	if err != nil {
+		return err
+	}
+	p7x, err := readZipFile(sigFile)
+	if err != nil {
+		return err
+	}
+	appxDigest, err := verifyPKCS7(bytes.TrimPrefix(p7x, []byte("PKCX")), pub)
+	if err != nil {
+		return err
+	}
+	digests, err := parseAppxDigest(appxDigest)
+	if err != nil {
+		return err
+	}
+
+	for tag, name := range map[string]string{
+		"AXBM": blockMapName,
+		"AXCT": contentTypesName,
+	} {
+		f, ok := entries[name]
+		if !ok {
+			return fmt.Errorf("%w: missing %s", ErrSignatureMismatch, name)
+		}
+		content, err := readZipFile(f)
+		if err != nil {
+			return err
+		}
+		if sum := sha256.Sum256(content); !bytes.Equal(sum[:], digests[tag]) {
+			return fmt.Errorf("%w: %s", ErrSignatureMismatch, name)
+		}
+	}
+
+	if err := verifyBlockMap(zr, entries[blockMapName]); err != nil {
+		return err
+	}
+	for _, tag := range []string{"AXPC", "AXCD"} {
+		if _, ok := digests[tag]; ok {
+			return ErrPartialVerification
+		}
+	}
+	return nil
+}
+
+// readPublicKey reads the public key of a PEM or DER X.509 certificate, or a
+// PEM public key.
+func readPublicKey(keyFile string) (crypto.PublicKey, error) {
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+	der := content
+	if block, _ := pem.Decode(content); block != nil {
+		if block.Type == "PUBLIC KEY" {
+			return x509.ParsePKIXPublicKey(block.Bytes)
+		}
+		der = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate: %w", err)
+	}
+	return cert.PublicKey, nil
+}
+
+// verifyPKCS7 verifies the signer of the Authenticode SignedData with the
+// given public key and returns the digest of the signed SpcIndirectData.
+func verifyPKCS7(der []byte, pub crypto.PublicKey) ([]byte, error) {
+	var info pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("parse signature: %w", err)
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("parse signature: unexpected content type %s", info.ContentType)
+	}
+	// RawValues with an explicit tag hold the tagged element, so Bytes is the
+	// DER encoding of the wrapped value.
+	var sd pkcs7SignedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("parse signed data: %w", err)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("parse signed data: expected 1 signer, got %d", len(sd.SignerInfos))
+	}
+	signer := sd.SignerInfos[0]
+
+	hash, ok := oidDigestAlgorithms[signer.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest algorithm %s", signer.DigestAlgorithm.Algorithm)
+	}
+
+	// the message digest attribute must match the signed content, i.e. the
+	// SpcIndirectDataContent without its own tag and length.
+	var content asn1.RawValue
+	if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+		return nil, fmt.Errorf("parse indirect data: %w", err)
+	}
+	h := hash.New()
+	h.Write(content.Bytes)
+	messageDigest, err := findMessageDigest(signer.AuthenticatedAttributes.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(h.Sum(nil), messageDigest) {
+		return nil, fmt.Errorf("%w: message digest", ErrSignatureMismatch)
+	}
+
+	// the signature covers the DER encoding of the attributes as a SET.
+	signed := bytes.Clone(signer.AuthenticatedAttributes.FullBytes)
+	signed[0] = 0x31
+	h = hash.New()
+	h.Write(signed)
+	if err := verifyDigest(pub, hash, h.Sum(nil), signer); err != nil {
+		return nil, err
+	}
+
+	var indirect spcIndirectDataContent
+	if _, err := asn1.Unmarshal(content.FullBytes, &indirect); err != nil {
+		return nil, fmt.Errorf("parse indirect data: %w", err)
+	}
+	return indirect.MessageDigest.Digest, nil
+}
+
+func findMessageDigest(attributes []byte) ([]byte, error) {
+	for rest := attributes; len(rest) > 0; {
+		var attr pkcs7Attribute
+		var err error
+		rest, err = asn1.Unmarshal(rest, &attr)
+		if err != nil {
+			return nil, fmt.Errorf("parse signed attributes: %w", err)
+		}
+		if !attr.Type.Equal(oidMessageDigest) {
+			continue
+		}
+		var digest []byte
+		if _, err := asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil {
+			return nil, fmt.Errorf("parse message digest: %w", err)
+		}
+		return digest, nil
+	}
+	return nil, fmt.Errorf("%w: missing message digest", ErrSignatureMismatch)
+}
+
+func verifyDigest(pub crypto.PublicKey, hash crypto.Hash, digest []byte, signer pkcs7SignerInfo) error {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest, signer.EncryptedDigest); err != nil {
+			return fmt.Errorf("verify PKCS1v15 signature: %w", err)
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, signer.EncryptedDigest) {
+			return errors.New("verify ECDSA signature: invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// parseAppxDigest splits the APPX digest blob, made of the APPX magic
+// followed by 4 bytes tags and their SHA256 digests.
+func parseAppxDigest(digest []byte) (map[string][]byte, error) {
+	rest, ok := bytes.CutPrefix(digest, []byte("APPX"))
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid APPX digest", ErrSignatureMismatch)
+	}
+	digests := map[string][]byte{}
+	for len(rest) >= 4+sha256.Size {
+		digests[string(rest[:4])] = rest[4 : 4+sha256.Size]
+		rest = rest[4+sha256.Size:]
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: invalid APPX digest", ErrSignatureMismatch)
+	}
+	return digests, nil
+}
+
+// files of the package that are not listed in the block map.
+// nolint: gochecknoglobals
+var unmappedFiles = map[string]bool{
+	signatureName:    true,
+	blockMapName:     true,
+	contentTypesName: true,
+}
+
+// verifyBlockMap checks that every file, including the manifest, is listed in
+// the block map and that the hashes of its 64KiB blocks match.
+func verifyBlockMap(zr *zip.Reader, f *zip.File) error {
+	content, err := readZipFile(f)
+	if err != nil {
+		return err
+	}
+	var bm blockMap
+	if err := xml.Unmarshal(content, &bm); err != nil {
+		return fmt.Errorf("cannot parse %s: %w", blockMapName, err)
+	}
+	listed := make(map[string]int, len(bm.Files))
+	for i, file := range bm.Files {
+		listed[file.Name] = i
+	}
+
+	for _, f := range zr.File {
+		if unmappedFiles[f.Name] || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		name := f.Name
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+		mapName := strings.ReplaceAll(name, "/", `\`)
+		i, ok := listed[mapName]
+		if !ok {
+			return fmt.Errorf("%w: %s is not in the block map", ErrSignatureMismatch, name)
+		}
+		delete(listed, mapName)
+
+		content, err := readZipFile(f)
+		if err != nil {
+			return err
+		}
+		entry := bm.Files[i]
+		if int64(len(content)) != entry.Size || len(entry.Blocks) != (len(content)+blockSize-1)/blockSize {
+			return fmt.Errorf("%w: %s", ErrSignatureMismatch, name)
+		}
+		for j, block := range entry.Blocks {
+			chunk := content[j*blockSize : min((j+1)*blockSize, len(content))]
+			sum := sha256.Sum256(chunk)
+			if block.Hash != base64.StdEncoding.EncodeToString(sum[:]) {
+				return fmt.Errorf("%w: %s", ErrSignatureMismatch, name)
+			}
+		}
+	}
+	if missing := maps.Keys(listed); len(missing) > 0 {
+		return fmt.Errorf("%w: %s is missing", ErrSignatureMismatch, missing[0])
+	}
+	return nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s: %w", f.Name, err)
+	}
+	defer rc.Close() // nolint: errcheck
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", f.Name, err)
+	}
+	return content, nil
+}
@@ -0,0 +1,185 @@
+package msix
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// nolint: gochecknoglobals
+var oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+
+// testSigner creates a self-signed certificate, writes it to a PEM file and
+// returns its path along with the private key.
+func testSigner(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "TestCompany"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return path, key
+}
+
+func marshal(t *testing.T, v any) []byte {
+	t.Helper()
+	der, err := asn1.Marshal(v)
+	require.NoError(t, err)
+	return der
+}
+
+// testSignature builds an AppxSignature.p7x signing the given APPX digest.
+func testSignature(t *testing.T, key *rsa.PrivateKey, appxDigest []byte) []byte {
+	t.Helper()
+	var indirect spcIndirectDataContent
+	indirect.Data = asn1.RawValue{FullBytes: marshal(t, asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 30})}
+	indirect.MessageDigest.DigestAlgorithm = pkix.AlgorithmIdentifier{Algorithm: oidSHA256}
+	indirect.MessageDigest.Digest = appxDigest
+	indirectDER := marshal(t, indirect)
+	var content asn1.RawValue
+	_, err := asn1.Unmarshal(indirectDER, &content)
+	require.NoError(t, err)
+	contentDigest := sha256.Sum256(content.Bytes)
+
+	attrs := marshal(t, pkcs7Attribute{
+		Type:   oidMessageDigest,
+		Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: marshal(t, contentDigest[:])},
+	})
+	signedAttrs := sha256.Sum256(marshal(t, asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: attrs}))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, signedAttrs[:])
+	require.NoError(t, err)
+
+	sd := pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{FullBytes: marshal(t, []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}})},
+		ContentInfo: pkcs7ContentInfo{
+			ContentType: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4},
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: indirectDER},
+		},
+		SignerInfos: []pkcs7SignerInfo{{
+			Version:                   1,
+			IssuerAndSerialNumber:     asn1.RawValue{FullBytes: marshal(t, struct{ Serial int }{1})},
+			DigestAlgorithm:           pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			AuthenticatedAttributes:   asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs},
+			DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}},
+			EncryptedDigest:           sig,
+		}},
+	}
+	info := pkcs7ContentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: marshal(t, sd)},
+	}
+	return append([]byte("PKCX"), marshal(t, info)...)
+}
+
+// testSignedPackage builds a signed package with the given payload, calling
+// tamper on the entries right before writing the zip.
+func testSignedPackage(t *testing.T, key *rsa.PrivateKey, payload map[string]string, tamper func(map[string]string)) *bytes.Buffer {
+	t.Helper()
+	entries := map[string]string{manifestName: testManifest}
+	for name, content := range payload {
+		entries[name] = content
+	}
+
+	var bm strings.Builder
+	bm.WriteString(`<BlockMap HashMethod="http://www.w3.org/2001/04/xmlenc#sha256">`)
+	for name, content := range entries {
+		sum := sha256.Sum256([]byte(content))
+		fmt.Fprintf(&bm, `<File Name="%s" Size="%d"><Block Hash="%s"/></File>`,
+			strings.ReplaceAll(name, "/", `\`), len(content), base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	bm.WriteString(`</BlockMap>`)
+	entries[blockMapName] = bm.String()
+	entries[contentTypesName] = "<Types/>"
+
+	bmDigest := sha256.Sum256([]byte(entries[blockMapName]))
+	ctDigest := sha256.Sum256([]byte(entries[contentTypesName]))
+	zeros := make([]byte, sha256.Size)
+	appx := bytes.Join([][]byte{
+		[]byte("APPX"),
+		[]byte("AXPC"), zeros,
+		[]byte("AXCD"), zeros,
+		[]byte("AXCT"), ctDigest[:],
+		[]byte("AXBM"), bmDigest[:],
+	}, nil)
+	entries[signatureName] = string(testSignature(t, key, appx))
+
+	if tamper != nil {
+		tamper(entries)
+	}
+	return testPackage(t, entries)
+}
+
+func TestVerify(t *testing.T) {
+	certFile, key := testSigner(t)
+	pkg := testSignedPackage(t, key, map[string]string{"app/fake.exe": "fake"}, nil)
+
+	results, err := Default.Verify(pkg, certFile)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "authenticode", results[0].Type)
+	require.ErrorIs(t, results[0].Err, ErrPartialVerification)
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	_, key := testSigner(t)
+	otherCertFile, _ := testSigner(t)
+	pkg := testSignedPackage(t, key, map[string]string{"app/fake.exe": "fake"}, nil)
+
+	results, err := Default.Verify(pkg, otherCertFile)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Error(t, results[0].Err)
+}
+
+func TestVerifyTampered(t *testing.T) {
+	certFile, key := testSigner(t)
+	for name, tamper := range map[string]func(map[string]string){
+		"file":      func(entries map[string]string) { entries["app/fake.exe"] = "evil" },
+		"manifest":  func(entries map[string]string) { entries[manifestName] += " " },
+		"extra":     func(entries map[string]string) { entries["app/evil.exe"] = "evil" },
+		"missing":   func(entries map[string]string) { delete(entries, "app/fake.exe") },
+		"block map": func(entries map[string]string) { entries[blockMapName] += " " },
+	} {
+		t.Run(name, func(t *testing.T) {
+			pkg := testSignedPackage(t, key, map[string]string{"app/fake.exe": "fake"}, tamper)
+			results, err := Default.Verify(pkg, certFile)
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			require.ErrorIs(t, results[0].Err, ErrSignatureMismatch)
+		})
+	}
+}
+
+func TestVerifyUnsigned(t *testing.T) {
+	pkg := testPackage(t, map[string]string{
+		manifestName:   testManifest,
+		"app/fake.exe": "fake",
+	})
+	results, err := Default.Verify(pkg, "cert.pem")
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
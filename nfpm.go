@@ -3,11 +3,15 @@
 package nfpm
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
+	"path/filepath"
+	"reflect"
 	"slices"
 	"sort"
 	"strings"
@@ -133,36 +137,184 @@ type PackagerWithExtension interface {
 type Config struct {
 	Info           `yaml:",inline" json:",inline"`
 	Overrides      map[string]*Overridables `yaml:"overrides,omitempty" json:"overrides,omitempty" jsonschema:"title=overrides,description=override some fields when packaging with a specific packager"`
+	Matrix         Matrix                   `yaml:"matrix,omitempty" json:"matrix,omitempty" jsonschema:"title=matrix,description=packagers and architectures to build\\, unless given on the command line"`
+	Packages       []Package                `yaml:"packages,omitempty" json:"packages,omitempty" jsonschema:"title=packages,description=additional packages built from this config\\, e.g. foo-devel"`
 	envMappingFunc func(string) string
 }
 
+// Package is an additional package built from the same config, e.g. foo-devel
+// next to foo. It inherits the top-level info, except for the contents,
+// relations and scripts, which it declares on its own, and for the fields
+// identifying, describing or running the top-level package. Its other
+// overridable fields are merged into the top-level ones.
+type Package struct {
+	Name         string `yaml:"name" json:"name" jsonschema:"title=package name"`
+	Description  string `yaml:"description,omitempty" json:"description,omitempty" jsonschema:"title=package description"`
+	Overridables `yaml:",inline" json:",inline"`
+	Overrides    map[string]*Overridables `yaml:"overrides,omitempty" json:"overrides,omitempty" jsonschema:"title=overrides,description=override some fields when packaging with a specific packager"`
+}
+
+// Matrix lists the packagers and architectures to build in a single run. Every
+// combination of them is built.
+type Matrix struct {
+	Packagers []string `yaml:"packagers,omitempty" json:"packagers,omitempty" jsonschema:"title=packagers to build,example=deb,example=rpm"`
+	Arches    []string `yaml:"arches,omitempty" json:"arches,omitempty" jsonschema:"title=architectures to build,description=overrides arch and is available as $NFPM_ARCH in the configuration,example=amd64,example=arm64"`
+}
+
 // Get returns the Info struct for the given packager format. Overrides
 // for the given format are merged into the final struct.
 func (c *Config) Get(format string) (info *Info, err error) {
-	info = &Info{}
-	// make a deep copy of info
-	if err = mergo.Merge(info, c.Info, mergo.WithOverride); err != nil {
-		return nil, fmt.Errorf("failed to merge config into info: %w", err)
-	}
+	// make a deep copy of info, the packagers modify it.
+	info = deepCopy(&c.Info)
 	override, ok := c.Overrides[format]
 	if !ok || override == nil {
 		// no overrides, or an empty override clause (e.g. "overrides:\n  deb:")
 		return info, nil
 	}
-	if err = mergo.Merge(&info.Overridables, override, mergo.WithOverride); err != nil {
+	if err = mergo.Merge(&info.Overridables, deepCopy(override), mergo.WithOverride); err != nil {
 		return nil, fmt.Errorf("failed to merge overrides into info: %w", err)
 	}
 
-	var contents []*files.Content
-	for _, f := range info.Contents {
-		if f.Packager == format || f.Packager == "" {
-			contents = append(contents, f)
+	info.Contents = contentsFor(info.Contents, format)
+	return info, nil
+}
+
+// GetPackages returns the Info of every package of the config for the given
+// packager format: the top-level package, followed by the ones in Packages.
+//
+// The packages are built from the same base: they share the archlinux pkgbase
+// of the top-level package, and the source packages and recipes (srpm, dsc,
+// apkbuild and pkgbuild) build all of them from a single one, holding the
+// others in its SplitPackages.
+func (c *Config) GetPackages(format string) ([]*Info, error) {
+	info, err := c.Get(format)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Packages) == 0 {
+		return []*Info{info}, nil
+	}
+	if err := c.validatePackageNames(); err != nil {
+		return nil, err
+	}
+	info.ArchLinux.Pkgbase = cmp.Or(info.ArchLinux.Pkgbase, info.Name)
+	infos := []*Info{info}
+	for _, pkg := range c.Packages {
+		split, err := c.getPackage(pkg, format)
+		if err != nil {
+			return nil, fmt.Errorf("package %s: %w", pkg.Name, err)
 		}
+		split.ArchLinux.Pkgbase = info.ArchLinux.Pkgbase
+		infos = append(infos, split)
+	}
+	switch format {
+	case "srpm", "dsc", "apkbuild", "pkgbuild":
+		info.SplitPackages = infos[1:]
+		return infos[:1], nil
+	}
+	return infos, nil
+}
+
+func (c *Config) getPackage(pkg Package, format string) (*Info, error) {
+	info, err := c.Get(format)
+	if err != nil {
+		return nil, err
+	}
+	info.Name = pkg.Name
+	info.Description = cmp.Or(pkg.Description, info.Description)
+	info.Overridables = info.Overridables.inheritable()
+	if err = mergo.Merge(&info.Overridables, deepCopy(&pkg.Overridables), mergo.WithOverride); err != nil {
+		return nil, fmt.Errorf("failed to merge package into info: %w", err)
 	}
-	info.Contents = contents
+	if override := pkg.Overrides[format]; override != nil {
+		if err = mergo.Merge(&info.Overridables, deepCopy(override), mergo.WithOverride); err != nil {
+			return nil, fmt.Errorf("failed to merge overrides into info: %w", err)
+		}
+	}
+	info.Contents = contentsFor(info.Contents, format)
 	return info, nil
 }
 
+// contentsFor returns the contents that apply to the given packager format.
+func contentsFor(contents files.Contents, format string) files.Contents {
+	var result []*files.Content
+	for _, f := range contents {
+		if f.Packager == format || f.Packager == "" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// ExpandGlobs matches the globs of the contents of the config once, with
+// files.ExpandGlobs, so the packages of several packagers can be built from
+// the config without matching them again.
+func (c *Config) ExpandGlobs() {
+	c.Contents = files.ExpandGlobs(c.Contents, c.DisableGlobbing)
+	for _, override := range c.Overrides {
+		if override != nil {
+			override.Contents = files.ExpandGlobs(override.Contents, c.DisableGlobbing)
+		}
+	}
+	for i := range c.Packages {
+		pkg := &c.Packages[i]
+		pkg.Contents = files.ExpandGlobs(pkg.Contents, c.DisableGlobbing)
+		for _, override := range pkg.Overrides {
+			if override != nil {
+				override.Contents = files.ExpandGlobs(override.Contents, c.DisableGlobbing)
+			}
+		}
+	}
+}
+
+// deepCopy returns a copy of v sharing no pointer, slice or map with it, so it
+// can be modified on its own.
+func deepCopy[T any](v *T) *T {
+	return copyValue(reflect.ValueOf(v)).Interface().(*T)
+}
+
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(copyValue(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			c.SetMapIndex(iter.Key(), copyValue(iter.Value()))
+		}
+		return c
+	case reflect.Struct:
+		// the unexported fields are copied as they are.
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				c.Field(i).Set(copyValue(v.Field(i)))
+			}
+		}
+		return c
+	default:
+		return v
+	}
+}
+
 // Validate ensures that the config is well typed.
 func (c *Config) Validate() error {
 	if err := Validate(&c.Info); err != nil {
@@ -173,6 +325,36 @@ func (c *Config) Validate() error {
 			return err
 		}
 	}
+	for _, format := range c.Matrix.Packagers {
+		if _, err := Get(format); err != nil {
+			return err
+		}
+	}
+	if err := c.validatePackageNames(); err != nil {
+		return err
+	}
+	for _, pkg := range c.Packages {
+		for format := range pkg.Overrides {
+			if _, err := Get(format); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validatePackageNames ensures every package of the config has its own name.
+func (c *Config) validatePackageNames() error {
+	names := []string{c.Name}
+	for _, pkg := range c.Packages {
+		if pkg.Name == "" {
+			return ErrFieldEmpty{"packages.name"}
+		}
+		if slices.Contains(names, pkg.Name) {
+			return fmt.Errorf("package %s is declared more than once", pkg.Name)
+		}
+		names = append(names, pkg.Name)
+	}
 	return nil
 }
 
@@ -210,27 +392,18 @@ func (c *Config) expandEnvVars() {
 	c.Prerelease = os.Expand(c.Prerelease, c.envMappingFunc)
 	c.Platform = os.Expand(c.Platform, c.envMappingFunc)
 	c.Arch = os.Expand(c.Arch, c.envMappingFunc)
-	for or := range c.Overrides {
-		if c.Overrides[or] == nil {
-			continue
+	for _, override := range c.Overrides {
+		c.expandEnvVarsOverridables(override)
+	}
+	for i := range c.Packages {
+		c.Packages[i].Name = os.Expand(c.Packages[i].Name, c.envMappingFunc)
+		c.Packages[i].Description = os.Expand(c.Packages[i].Description, c.envMappingFunc)
+		c.expandEnvVarsOverridables(&c.Packages[i].Overridables)
+		for _, override := range c.Packages[i].Overrides {
+			c.expandEnvVarsOverridables(override)
 		}
-		c.Overrides[or].Conflicts = c.expandEnvVarsStringSlice(c.Overrides[or].Conflicts)
-		c.Overrides[or].Depends = c.expandEnvVarsStringSlice(c.Overrides[or].Depends)
-		c.Overrides[or].Replaces = c.expandEnvVarsStringSlice(c.Overrides[or].Replaces)
-		c.Overrides[or].Recommends = c.expandEnvVarsStringSlice(c.Overrides[or].Recommends)
-		c.Overrides[or].Provides = c.expandEnvVarsStringSlice(c.Overrides[or].Provides)
-		c.Overrides[or].Suggests = c.expandEnvVarsStringSlice(c.Overrides[or].Suggests)
-		c.Overrides[or].RPM.Requires.Post = c.expandEnvVarsStringSlice(c.Overrides[or].RPM.Requires.Post)
-		c.Overrides[or].Contents = c.expandEnvVarsContents(c.Overrides[or].Contents)
-	}
-	c.Conflicts = c.expandEnvVarsStringSlice(c.Conflicts)
-	c.Depends = c.expandEnvVarsStringSlice(c.Depends)
-	c.Replaces = c.expandEnvVarsStringSlice(c.Replaces)
-	c.Recommends = c.expandEnvVarsStringSlice(c.Recommends)
-	c.Provides = c.expandEnvVarsStringSlice(c.Provides)
-	c.Suggests = c.expandEnvVarsStringSlice(c.Suggests)
-	c.RPM.Requires.Post = c.expandEnvVarsStringSlice(c.RPM.Requires.Post)
-	c.Contents = c.expandEnvVarsContents(c.Contents)
+	}
+	c.expandEnvVarsOverridables(&c.Overridables)
 
 	// Basic metadata fields
 	c.Name = os.Expand(c.Name, c.envMappingFunc)
@@ -243,15 +416,18 @@ func (c *Config) expandEnvVars() {
 	c.Deb.Signature.KeyFile = os.Expand(c.Deb.Signature.KeyFile, c.envMappingFunc)
 	c.RPM.Signature.KeyFile = os.Expand(c.RPM.Signature.KeyFile, c.envMappingFunc)
 	c.APK.Signature.KeyFile = os.Expand(c.APK.Signature.KeyFile, c.envMappingFunc)
+	c.Gentoo.Signature.KeyFile = os.Expand(c.Gentoo.Signature.KeyFile, c.envMappingFunc)
 	c.Deb.Signature.KeyID = pointer.ToString(os.Expand(pointer.GetString(c.Deb.Signature.KeyID), c.envMappingFunc))
 	c.RPM.Signature.KeyID = pointer.ToString(os.Expand(pointer.GetString(c.RPM.Signature.KeyID), c.envMappingFunc))
 	c.APK.Signature.KeyID = pointer.ToString(os.Expand(pointer.GetString(c.APK.Signature.KeyID), c.envMappingFunc))
+	c.Gentoo.Signature.KeyID = pointer.ToString(os.Expand(pointer.GetString(c.Gentoo.Signature.KeyID), c.envMappingFunc))
 
 	// Package signing passphrase
 	generalPassphrase := os.Expand("$NFPM_PASSPHRASE", c.envMappingFunc)
 	c.Deb.Signature.KeyPassphrase = generalPassphrase
 	c.RPM.Signature.KeyPassphrase = generalPassphrase
 	c.APK.Signature.KeyPassphrase = generalPassphrase
+	c.Gentoo.Signature.KeyPassphrase = generalPassphrase
 
 	debPassphrase := os.Expand("$NFPM_DEB_PASSPHRASE", c.envMappingFunc)
 	if debPassphrase != "" {
@@ -268,6 +444,11 @@ func (c *Config) expandEnvVars() {
 		c.APK.Signature.KeyPassphrase = apkPassphrase
 	}
 
+	gpkgPassphrase := os.Expand("$NFPM_GPKG_PASSPHRASE", c.envMappingFunc)
+	if gpkgPassphrase != "" {
+		c.Gentoo.Signature.KeyPassphrase = gpkgPassphrase
+	}
+
 	// RPM specific
 	c.RPM.Packager = os.Expand(c.RPM.Packager, c.envMappingFunc)
 
@@ -292,6 +473,21 @@ func (c *Config) expandEnvVars() {
 	}
 }
 
+// expandEnvVarsOverridables expands the relations and contents of o.
+func (c *Config) expandEnvVarsOverridables(o *Overridables) {
+	if o == nil {
+		return
+	}
+	o.Conflicts = c.expandEnvVarsStringSlice(o.Conflicts)
+	o.Depends = c.expandEnvVarsStringSlice(o.Depends)
+	o.Replaces = c.expandEnvVarsStringSlice(o.Replaces)
+	o.Recommends = c.expandEnvVarsStringSlice(o.Recommends)
+	o.Provides = c.expandEnvVarsStringSlice(o.Provides)
+	o.Suggests = c.expandEnvVarsStringSlice(o.Suggests)
+	o.RPM.Requires.Post = c.expandEnvVarsStringSlice(o.RPM.Requires.Post)
+	o.Contents = c.expandEnvVarsContents(o.Contents)
+}
+
 // Info contains information about a single package.
 type Info struct {
 	Overridables    `yaml:",inline" json:",inline"`
@@ -315,6 +511,15 @@ type Info struct {
 	DisableGlobbing bool      `yaml:"disable_globbing,omitempty" json:"disable_globbing,omitempty" jsonschema:"title=whether to disable file globbing,default=false"`
 	MTime           time.Time `yaml:"mtime,omitempty" json:"mtime,omitempty" jsonschema:"title=time to set into the files generated by nFPM"`
 	Target          string    `yaml:"-" json:"-"`
+	// SplitPackages are the other packages built from the same source
+	// package, set by Config.GetPackages for srpm, dsc, apkbuild and pkgbuild.
+	SplitPackages []*Info `yaml:"-" json:"-"`
+
+	// tempDirs are the temporary directories holding the files generated by
+	// PrepareForPackager, removed by Cleanup.
+	tempDirs []string
+	// debugSymbolsSplit is set by SplitDebugSymbols.
+	debugSymbolsSplit bool
 }
 
 func (i *Info) Validate() error {
@@ -357,21 +562,78 @@ func (i *Info) parseSemver() {
 
 // Overridables contain the field which are overridable in a package.
 type Overridables struct {
-	Replaces   []string       `yaml:"replaces,omitempty" json:"replaces,omitempty" jsonschema:"title=replaces directive,example=nfpm"`
-	Provides   []string       `yaml:"provides,omitempty" json:"provides,omitempty" jsonschema:"title=provides directive,example=nfpm"`
-	Depends    []string       `yaml:"depends,omitempty" json:"depends,omitempty" jsonschema:"title=depends directive,example=nfpm"`
-	Recommends []string       `yaml:"recommends,omitempty" json:"recommends,omitempty" jsonschema:"title=recommends directive,example=nfpm"`
-	Suggests   []string       `yaml:"suggests,omitempty" json:"suggests,omitempty" jsonschema:"title=suggests directive,example=nfpm"`
-	Conflicts  []string       `yaml:"conflicts,omitempty" json:"conflicts,omitempty" jsonschema:"title=conflicts directive,example=nfpm"`
-	Contents   files.Contents `yaml:"contents,omitempty" json:"contents,omitempty" jsonschema:"title=files to add to the package"`
-	Umask      os.FileMode    `yaml:"umask,omitempty" json:"umask,omitempty" jsonschema:"title=umask for file contents,example=112"`
-	Scripts    Scripts        `yaml:"scripts,omitempty" json:"scripts,omitempty" jsonschema:"title=scripts to execute"`
-	RPM        RPM            `yaml:"rpm,omitempty" json:"rpm,omitempty" jsonschema:"title=rpm-specific settings"`
-	Deb        Deb            `yaml:"deb,omitempty" json:"deb,omitempty" jsonschema:"title=deb-specific settings"`
-	APK        APK            `yaml:"apk,omitempty" json:"apk,omitempty" jsonschema:"title=apk-specific settings"`
-	ArchLinux  ArchLinux      `yaml:"archlinux,omitempty" json:"archlinux,omitempty" jsonschema:"title=archlinux-specific settings"`
-	IPK        IPK            `yaml:"ipk,omitempty" json:"ipk,omitempty" jsonschema:"title=ipk-specific settings"`
-	MSIX       MSIX           `yaml:"msix,omitempty" json:"msix,omitempty" jsonschema:"title=msix-specific settings"`
+	Replaces     Relations      `yaml:"replaces,omitempty" json:"replaces,omitempty" jsonschema:"title=replaces directive,example=nfpm"`
+	Provides     Relations      `yaml:"provides,omitempty" json:"provides,omitempty" jsonschema:"title=provides directive,example=nfpm"`
+	Depends      Relations      `yaml:"depends,omitempty" json:"depends,omitempty" jsonschema:"title=depends directive,example=nfpm"`
+	Recommends   Relations      `yaml:"recommends,omitempty" json:"recommends,omitempty" jsonschema:"title=recommends directive,example=nfpm"`
+	Suggests     Relations      `yaml:"suggests,omitempty" json:"suggests,omitempty" jsonschema:"title=suggests directive,example=nfpm"`
+	Conflicts    Relations      `yaml:"conflicts,omitempty" json:"conflicts,omitempty" jsonschema:"title=conflicts directive,example=nfpm"`
+	AutoDepends  AutoDepends    `yaml:"auto_depends,omitempty" json:"auto_depends,omitempty" jsonschema:"title=shared library dependencies detection"`
+	DebugSymbols DebugSymbols   `yaml:"debug_symbols,omitempty" json:"debug_symbols,omitempty" jsonschema:"title=debug symbols splitting"`
+	Systemd      Systemd        `yaml:"systemd,omitempty" json:"systemd,omitempty" jsonschema:"title=systemd units"`
+	Users        []User         `yaml:"users,omitempty" json:"users,omitempty" jsonschema:"title=system users to create"`
+	Groups       []Group        `yaml:"groups,omitempty" json:"groups,omitempty" jsonschema:"title=system groups to create"`
+	Alternatives []Alternative  `yaml:"alternatives,omitempty" json:"alternatives,omitempty" jsonschema:"title=alternatives"`
+	Contents     files.Contents `yaml:"contents,omitempty" json:"contents,omitempty" jsonschema:"title=files to add to the package"`
+	Umask        os.FileMode    `yaml:"umask,omitempty" json:"umask,omitempty" jsonschema:"title=umask for file contents,example=112"`
+	Scripts      Scripts        `yaml:"scripts,omitempty" json:"scripts,omitempty" jsonschema:"title=scripts to execute"`
+	RPM          RPM            `yaml:"rpm,omitempty" json:"rpm,omitempty" jsonschema:"title=rpm-specific settings"`
+	Deb          Deb            `yaml:"deb,omitempty" json:"deb,omitempty" jsonschema:"title=deb-specific settings"`
+	APK          APK            `yaml:"apk,omitempty" json:"apk,omitempty" jsonschema:"title=apk-specific settings"`
+	ArchLinux    ArchLinux      `yaml:"archlinux,omitempty" json:"archlinux,omitempty" jsonschema:"title=archlinux-specific settings"`
+	IPK          IPK            `yaml:"ipk,omitempty" json:"ipk,omitempty" jsonschema:"title=ipk-specific settings"`
+	MSIX         MSIX           `yaml:"msix,omitempty" json:"msix,omitempty" jsonschema:"title=msix-specific settings"`
+	FreeBSD      FreeBSD        `yaml:"freebsd,omitempty" json:"freebsd,omitempty" jsonschema:"title=freebsd-specific settings"`
+	MacOS        MacOS          `yaml:"macos,omitempty" json:"macos,omitempty" jsonschema:"title=macos-specific settings"`
+	OCI          OCI            `yaml:"oci,omitempty" json:"oci,omitempty" jsonschema:"title=oci-specific settings"`
+	AppImage     AppImage       `yaml:"appimage,omitempty" json:"appimage,omitempty" jsonschema:"title=appimage-specific settings"`
+	Nupkg        Nupkg          `yaml:"nupkg,omitempty" json:"nupkg,omitempty" jsonschema:"title=nupkg-specific settings"`
+	Conda        Conda          `yaml:"conda,omitempty" json:"conda,omitempty" jsonschema:"title=conda-specific settings"`
+	Gentoo       Gentoo         `yaml:"gentoo,omitempty" json:"gentoo,omitempty" jsonschema:"title=gpkg-specific settings"`
+	Snap         Snap           `yaml:"snap,omitempty" json:"snap,omitempty" jsonschema:"title=snap-specific settings"`
+}
+
+// inheritable returns the fields a package of Config.Packages inherits from
+// the top-level package: everything but the contents, systemd units, users,
+// groups, alternatives, relations and scripts, as well as the fields
+// identifying, describing or running the top-level package: the identifiers,
+// titles and summaries, the apps, entrypoints and desktop entries.
+func (o Overridables) inheritable() Overridables {
+	o.Replaces, o.Provides, o.Depends = nil, nil, nil
+	o.Recommends, o.Suggests, o.Conflicts = nil, nil, nil
+	o.Contents = nil
+	o.Systemd = Systemd{}
+	o.Users, o.Groups = nil, nil
+	o.Alternatives = nil
+	o.Scripts = Scripts{}
+	o.RPM.Scripts = RPMScripts{}
+	o.RPM.Requires = RPMRequires{}
+	o.Deb.Scripts = DebScripts{}
+	o.Deb.Triggers = DebTriggers{}
+	o.Deb.Breaks, o.Deb.Predepends = nil, nil
+	o.APK.Scripts = APKScripts{}
+	o.ArchLinux.Scripts = ArchLinuxScripts{}
+	o.IPK.Predepends, o.IPK.Alternatives = nil, nil
+	o.Conda.Depends = nil
+	// the identifiers default to the name of each package, they must not be
+	// shared, nor the titles and summaries describing the top-level package.
+	o.Nupkg.ID, o.Nupkg.Title = "", ""
+	o.MacOS.Identifier = ""
+	o.MSIX.Identity, o.MSIX.Properties = MSIXIdentity{}, MSIXProperties{}
+	o.RPM.Summary = ""
+	o.Snap.Title, o.Snap.Summary = "", ""
+	// the apps, the entrypoints and the desktop entries run the programs of
+	// the package, and the update information finds its AppImages.
+	o.Snap.Apps, o.Snap.Plugs = nil, nil
+	o.OCI.Tag, o.OCI.Entrypoint, o.OCI.Cmd = "", nil, nil
+	o.MSIX.Applications = nil
+	o.AppImage.Exec, o.AppImage.Desktop, o.AppImage.Icon = "", "", ""
+	o.AppImage.UpdateInformation = ""
+	// the maps are merged into, they must not be shared.
+	o.AutoDepends.Packages = maps.Clone(o.AutoDepends.Packages)
+	o.Deb.Fields = maps.Clone(o.Deb.Fields)
+	o.IPK.Fields = maps.Clone(o.IPK.Fields)
+	return o
 }
 
 type ArchLinux struct {
@@ -409,7 +671,7 @@ type RPMScripts struct {
 
 // RPMRequires represents qualified RPM Requires dependencies.
 type RPMRequires struct {
-	Post []string `yaml:"post,omitempty" json:"post,omitempty" jsonschema:"title=post requires directive,example=nfpm"`
+	Post Relations `yaml:"post,omitempty" json:"post,omitempty" jsonschema:"title=post requires directive,example=nfpm"`
 }
 
 type PackageSignature struct {
@@ -420,6 +682,8 @@ type PackageSignature struct {
 	// SignFn, if set, will be called with the package-specific data to sign.
 	// For deb and rpm packages, data is the full package content.
 	// For apk packages, data is the SHA1 digest of control tgz.
+	// For gpkg packages, data is each of the metadata and image tarballs,
+	// and the Manifest, whose signature must be a cleartext one.
 	//
 	// This allows for signing implementations other than using a local file
 	// (for example using a remote signer like KMS).
@@ -453,11 +717,11 @@ type Deb struct {
 	ArchVariant string            `yaml:"arch_variant,omitempty" json:"arch_variant,omitempty" jsonschema:"title=target architecture variant in deb nomenclature,example=amd64v3"`
 	Scripts     DebScripts        `yaml:"scripts,omitempty" json:"scripts,omitempty" jsonschema:"title=scripts"`
 	Triggers    DebTriggers       `yaml:"triggers,omitempty" json:"triggers,omitempty" jsonschema:"title=triggers"`
-	Breaks      []string          `yaml:"breaks,omitempty" json:"breaks,omitempty" jsonschema:"title=breaks"`
+	Breaks      Relations         `yaml:"breaks,omitempty" json:"breaks,omitempty" jsonschema:"title=breaks"`
 	Signature   DebSignature      `yaml:"signature,omitempty" json:"signature,omitempty" jsonschema:"title=signature"`
 	Compression string            `yaml:"compression,omitempty" json:"compression,omitempty" jsonschema:"title=compression algorithm to be used,enum=gzip,enum=xz,enum=zstd,enum=none,default=gzip:-1"`
 	Fields      map[string]string `yaml:"fields,omitempty" json:"fields,omitempty" jsonschema:"title=fields"`
-	Predepends  []string          `yaml:"predepends,omitempty" json:"predepends,omitempty" jsonschema:"title=predepends directive,example=nfpm"`
+	Predepends  Relations         `yaml:"predepends,omitempty" json:"predepends,omitempty" jsonschema:"title=predepends directive,example=nfpm"`
 }
 
 type DebSignature struct {
@@ -496,7 +760,7 @@ type IPK struct {
 	AutoInstalled bool              `yaml:"auto_installed,omitempty" json:"auto_installed,omitempty" jsonschema:"title=auto installed,default=false"`
 	Essential     bool              `yaml:"essential,omitempty" json:"essential,omitempty" jsonschema:"title=whether package is essential,default=false"`
 	Fields        map[string]string `yaml:"fields,omitempty" json:"fields,omitempty" jsonschema:"title=fields"`
-	Predepends    []string          `yaml:"predepends,omitempty" json:"predepends,omitempty" jsonschema:"title=predepends directive,example=nfpm"`
+	Predepends    Relations         `yaml:"predepends,omitempty" json:"predepends,omitempty" jsonschema:"title=predepends directive,example=nfpm"`
 	Tags          []string          `yaml:"tags,omitempty" json:"tags,omitempty" jsonschema:"title=tags"`
 }
 
@@ -573,12 +837,137 @@ type MSIXSignature struct {
 	KeyPassphrase string `yaml:"-" json:"-"` // populated from NFPM_MSIX_PASSPHRASE env var
 }
 
+// FreeBSD is custom configs that are only available on FreeBSD packages.
+type FreeBSD struct {
+	Arch        string `yaml:"arch,omitempty" json:"arch,omitempty" jsonschema:"title=architecture in freebsd nomenclature"`
+	ABI         string `yaml:"abi,omitempty" json:"abi,omitempty" jsonschema:"title=ABI the package runs on,description=defaults to FreeBSD:*:<arch>,example=FreeBSD:14:amd64"`
+	Origin      string `yaml:"origin,omitempty" json:"origin,omitempty" jsonschema:"title=origin of the package in the ports tree,description=defaults to misc/<name>,example=sysutils/foo"`
+	Compression string `yaml:"compression,omitempty" json:"compression,omitempty" jsonschema:"title=compression algorithm to be used,enum=zstd,enum=xz,enum=gzip,enum=none,default=zstd"`
+}
+
+// MacOS is custom configs that are only available on macOS packages.
+type MacOS struct {
+	Arch            string `yaml:"arch,omitempty" json:"arch,omitempty" jsonschema:"title=architecture in macos nomenclature"`
+	Identifier      string `yaml:"identifier,omitempty" json:"identifier,omitempty" jsonschema:"title=identifier of the package,description=defaults to the package name,example=com.example.foo"`
+	MinOS           string `yaml:"min_os,omitempty" json:"min_os,omitempty" jsonschema:"title=minimum macOS version the package installs on,example=11.0"`
+	InstallLocation string `yaml:"install_location,omitempty" json:"install_location,omitempty" jsonschema:"title=directory the contents are installed under,default=/"`
+}
+
+// OCI is custom configs that are only available on OCI images.
+type OCI struct {
+	Arch       string            `yaml:"arch,omitempty" json:"arch,omitempty" jsonschema:"title=platform architecture in oci nomenclature,example=arm/v7"`
+	Base       string            `yaml:"base,omitempty" json:"base,omitempty" jsonschema:"title=OCI image layout directory of the base image"`
+	Tag        string            `yaml:"tag,omitempty" json:"tag,omitempty" jsonschema:"title=tag of the image,description=defaults to the version"`
+	Entrypoint []string          `yaml:"entrypoint,omitempty" json:"entrypoint,omitempty" jsonschema:"title=entrypoint of the image"`
+	Cmd        []string          `yaml:"cmd,omitempty" json:"cmd,omitempty" jsonschema:"title=default arguments of the entrypoint"`
+	Env        []string          `yaml:"env,omitempty" json:"env,omitempty" jsonschema:"title=environment variables,example=PATH=/usr/local/bin:/usr/bin:/bin"`
+	WorkingDir string            `yaml:"working_dir,omitempty" json:"working_dir,omitempty" jsonschema:"title=working directory of the entrypoint"`
+	User       string            `yaml:"user,omitempty" json:"user,omitempty" jsonschema:"title=user the entrypoint runs as,example=1000:1000"`
+	Labels     map[string]string `yaml:"labels,omitempty" json:"labels,omitempty" jsonschema:"title=labels of the image"`
+}
+
+// AppImage is custom configs that are only available on AppImages.
+type AppImage struct {
+	Arch              string   `yaml:"arch,omitempty" json:"arch,omitempty" jsonschema:"title=architecture in appimage nomenclature"`
+	Runtime           string   `yaml:"runtime,omitempty" json:"runtime,omitempty" jsonschema:"title=type-2 runtime prepended to the image,example=./runtime-x86_64"`
+	Exec              string   `yaml:"exec,omitempty" json:"exec,omitempty" jsonschema:"title=program run by the generated AppRun,description=defaults to /usr/bin/<name>"`
+	Icon              string   `yaml:"icon,omitempty" json:"icon,omitempty" jsonschema:"title=PNG or SVG icon of the application"`
+	Desktop           string   `yaml:"desktop,omitempty" json:"desktop,omitempty" jsonschema:"title=desktop entry of the application,description=generated when not set"`
+	Categories        []string `yaml:"categories,omitempty" json:"categories,omitempty" jsonschema:"title=categories of the generated desktop entry,default=Utility"`
+	Terminal          bool     `yaml:"terminal,omitempty" json:"terminal,omitempty" jsonschema:"title=whether the application runs in a terminal,default=false"`
+	Compression       string   `yaml:"compression,omitempty" json:"compression,omitempty" jsonschema:"title=compression algorithm to be used,enum=zstd,enum=gzip,default=zstd"`
+	UpdateInformation string   `yaml:"update_information,omitempty" json:"update_information,omitempty" jsonschema:"title=update information embedded in the runtime,example=gh-releases-zsync|foo|bar|latest|foo-*x86_64.AppImage.zsync"`
+	Zsync             bool     `yaml:"zsync,omitempty" json:"zsync,omitempty" jsonschema:"title=write a .zsync file next to the AppImage,default=false"`
+}
+
+// Nupkg is custom configs that are only available on Chocolatey (.nupkg)
+// packages.
+type Nupkg struct {
+	ID         string   `yaml:"id,omitempty" json:"id,omitempty" jsonschema:"title=package id,description=defaults to the name"`
+	Title      string   `yaml:"title,omitempty" json:"title,omitempty" jsonschema:"title=human friendly title of the package"`
+	Authors    string   `yaml:"authors,omitempty" json:"authors,omitempty" jsonschema:"title=authors of the software,description=defaults to the name of the maintainer or to the vendor"`
+	Tags       []string `yaml:"tags,omitempty" json:"tags,omitempty" jsonschema:"title=tags of the package"`
+	IconURL    string   `yaml:"icon_url,omitempty" json:"icon_url,omitempty" jsonschema:"title=URL of the icon of the package"`
+	InstallDir string   `yaml:"install_dir,omitempty" json:"install_dir,omitempty" jsonschema:"title=directory the contents are unpacked to,description=a PowerShell string defaulting to the tools directory of the package"`
+}
+
+// Conda is custom configs that are only available on conda packages.
+type Conda struct {
+	Subdir  string   `yaml:"subdir,omitempty" json:"subdir,omitempty" jsonschema:"title=subdirectory of the channel,description=defaults to the platform and the architecture,example=linux-64,example=noarch"`
+	Build   string   `yaml:"build,omitempty" json:"build,omitempty" jsonschema:"title=build string,description=defaults to the build number,example=h1234567_0"`
+	Depends []string `yaml:"depends,omitempty" json:"depends,omitempty" jsonschema:"title=conda match specs added to the dependencies as they are,example=python >=3.8\\,<4"`
+}
+
+// Gentoo is custom configs that are only available on Gentoo binary packages
+// (GPKG).
+type Gentoo struct {
+	Category    string           `yaml:"category,omitempty" json:"category,omitempty" jsonschema:"title=category of the package,default=app-misc"`
+	Slot        string           `yaml:"slot,omitempty" json:"slot,omitempty" jsonschema:"title=slot of the package,default=0"`
+	Keywords    []string         `yaml:"keywords,omitempty" json:"keywords,omitempty" jsonschema:"title=keywords of the package,description=defaults to the stable keyword of the architecture,example=~amd64"`
+	Compression string           `yaml:"compression,omitempty" json:"compression,omitempty" jsonschema:"title=compression algorithm to be used,enum=zstd,enum=xz,enum=gzip,default=zstd"`
+	Signature   PackageSignature `yaml:"signature,omitempty" json:"signature,omitempty" jsonschema:"title=gpkg signature"`
+}
+
+// Snap is custom configs that are only available on snaps.
+type Snap struct {
+	Arch        string             `yaml:"arch,omitempty" json:"arch,omitempty" jsonschema:"title=architecture in snap nomenclature"`
+	Title       string             `yaml:"title,omitempty" json:"title,omitempty" jsonschema:"title=human readable name of the snap"`
+	Summary     string             `yaml:"summary,omitempty" json:"summary,omitempty" jsonschema:"title=summary of the snap,description=defaults to the first line of the description"`
+	Base        string             `yaml:"base,omitempty" json:"base,omitempty" jsonschema:"title=base snap providing the runtime,default=core24"`
+	Grade       string             `yaml:"grade,omitempty" json:"grade,omitempty" jsonschema:"title=quality grade of the snap,enum=stable,enum=devel,default=stable"`
+	Confinement string             `yaml:"confinement,omitempty" json:"confinement,omitempty" jsonschema:"title=confinement of the snap,enum=strict,enum=classic,enum=devmode,default=strict"`
+	Plugs       []string           `yaml:"plugs,omitempty" json:"plugs,omitempty" jsonschema:"title=plugs of all the apps and hooks,example=network"`
+	Apps        map[string]SnapApp `yaml:"apps,omitempty" json:"apps,omitempty" jsonschema:"title=apps of the snap, by name"`
+}
+
+// SnapApp is an app of a snap, a command exposed to the users or a service.
+type SnapApp struct {
+	Command string   `yaml:"command" json:"command" jsonschema:"title=command running the app,description=relative to the root of the snap,example=bin/foo"`
+	Daemon  string   `yaml:"daemon,omitempty" json:"daemon,omitempty" jsonschema:"title=type of the service the app is,enum=simple,enum=forking,enum=oneshot,enum=notify,enum=dbus"`
+	Plugs   []string `yaml:"plugs,omitempty" json:"plugs,omitempty" jsonschema:"title=plugs of the app,example=home"`
+}
+
+// AutoDepends configures the detection of the shared libraries needed and
+// provided by the ELF files of the package.
+type AutoDepends struct {
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty" jsonschema:"title=detect shared library dependencies,default=false"`
+	// Packages providing the needed shared libraries, by soname, for the
+	// formats without soname based dependencies: deb, ipk, archlinux and
+	// freebsd.
+	Packages map[string]string `yaml:"packages,omitempty" json:"packages,omitempty" jsonschema:"title=packages providing shared libraries by soname"`
+}
+
+// DebugSymbols configures the splitting of the debug symbols of the ELF files
+// of the package into a separate debug package.
+type DebugSymbols struct {
+	Split bool `yaml:"split,omitempty" json:"split,omitempty" jsonschema:"title=split debug symbols into a debug package,default=false"`
+}
+
 // Scripts contains information about maintainer scripts for packages.
 type Scripts struct {
-	PreInstall  string `yaml:"preinstall,omitempty" json:"preinstall,omitempty" jsonschema:"title=pre install"`
-	PostInstall string `yaml:"postinstall,omitempty" json:"postinstall,omitempty" jsonschema:"title=post install"`
-	PreRemove   string `yaml:"preremove,omitempty" json:"preremove,omitempty" jsonschema:"title=pre remove"`
-	PostRemove  string `yaml:"postremove,omitempty" json:"postremove,omitempty" jsonschema:"title=post remove"`
+	PreInstall     string `yaml:"preinstall,omitempty" json:"preinstall,omitempty" jsonschema:"title=pre install"`
+	PostInstall    string `yaml:"postinstall,omitempty" json:"postinstall,omitempty" jsonschema:"title=post install"`
+	PreRemove      string `yaml:"preremove,omitempty" json:"preremove,omitempty" jsonschema:"title=pre remove"`
+	PostRemove     string `yaml:"postremove,omitempty" json:"postremove,omitempty" jsonschema:"title=post remove"`
+	PreUpgrade     string `yaml:"preupgrade,omitempty" json:"preupgrade,omitempty" jsonschema:"title=pre upgrade,description=runs before the package is upgraded"`
+	PostUpgrade    string `yaml:"postupgrade,omitempty" json:"postupgrade,omitempty" jsonschema:"title=post upgrade,description=runs after the package is upgraded"`
+	OnFirstInstall string `yaml:"on_first_install,omitempty" json:"on_first_install,omitempty" jsonschema:"title=on first install,description=runs after the package is installed for the first time"`
+}
+
+// Cleanup removes the files generated by PrepareForPackager for the info and
+// its split packages, and drops them from the contents, so the info can be
+// prepared again. Packagers call it once the package is written.
+func (i *Info) Cleanup() {
+	for _, split := range i.SplitPackages {
+		split.Cleanup()
+	}
+	for _, dir := range i.tempDirs {
+		i.Contents = slices.DeleteFunc(i.Contents, func(content *files.Content) bool {
+			return filepath.Dir(content.Source) == dir
+		})
+		_ = os.RemoveAll(dir)
+	}
+	i.tempDirs = nil
 }
 
 // ErrFieldEmpty happens when some required field is empty.
@@ -601,12 +990,28 @@ func PrepareForPackager(info *Info, packager string) (err error) {
 		((packager == "deb" && info.Deb.Arch == "") ||
 			(packager == "rpm" && info.RPM.Arch == "") ||
 			(packager == "apk" && info.APK.Arch == "") ||
-			(packager == "msix" && info.MSIX.Arch == "")) {
+			(packager == "msix" && info.MSIX.Arch == "") ||
+			(packager == "freebsd" && info.FreeBSD.Arch == "") ||
+			(packager == "macos-pkg" && info.MacOS.Arch == "") ||
+			(packager == "oci" && info.OCI.Arch == "") ||
+			(packager == "appimage" && info.AppImage.Arch == "")) {
 		return ErrFieldEmpty{"arch"}
 	}
 	if info.Version == "" {
 		return ErrFieldEmpty{"version"}
 	}
+	if info.DebugSymbols.Split && !info.debugSymbolsSplit {
+		return ErrDebugSymbolsNotSplit
+	}
+
+	if scriptsPackagers[packager] {
+		if info.Contents, err = withSystemdUnits(info.Contents, info.Systemd.Units); err != nil {
+			return err
+		}
+		if info.Contents, err = withSysusers(info, info.Contents); err != nil {
+			return err
+		}
+	}
 
 	info.Contents, err = files.PrepareForPackager(
 		info.Contents,
@@ -615,8 +1020,11 @@ func PrepareForPackager(info *Info, packager string) (err error) {
 		info.DisableGlobbing,
 		info.MTime,
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return validateOwnership(info, info.Contents)
 }
 
 // Validate the given Info and returns an error if it is invalid. Validate will
@@ -631,9 +1039,21 @@ func Validate(info *Info) (err error) {
 	if info.Version == "" {
 		return ErrFieldEmpty{"version"}
 	}
+	if err := validateSystemdUnits(info.Systemd.Units); err != nil {
+		return err
+	}
+	if err := validateAccounts(info); err != nil {
+		return err
+	}
+	if err := validateOwnership(info, info.Contents); err != nil {
+		return err
+	}
+	if err := validateAlternatives(info.Alternatives); err != nil {
+		return err
+	}
 
 	for packager := range packagers {
-		_, err := files.PrepareForPackager(
+		contents, err := files.PrepareForPackager(
 			info.Contents,
 			info.Umask,
 			packager,
@@ -643,6 +1063,9 @@ func Validate(info *Info) (err error) {
 		if err != nil {
 			return err
 		}
+		if err := validateOwnership(info, contents); err != nil {
+			return err
+		}
 	}
 
 	return nil
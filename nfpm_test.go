@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/mail"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
@@ -380,6 +381,7 @@ func TestOptionsFromEnvironment(t *testing.T) {
 		debPass         = "password123"
 		rpmPass         = "secret"
 		apkPass         = "foobar"
+		gpkgPass        = "correct horse"
 		platform        = "linux"
 		arch            = "amd64"
 		release         = "3"
@@ -463,6 +465,7 @@ maintainer: '"$GIT_COMMITTER_NAME" <$GIT_COMMITTER_EMAIL>'
 		require.Equal(t, globalPass, info.Deb.Signature.KeyPassphrase)
 		require.Equal(t, globalPass, info.RPM.Signature.KeyPassphrase)
 		require.Equal(t, globalPass, info.APK.Signature.KeyPassphrase)
+		require.Equal(t, globalPass, info.Gentoo.Signature.KeyPassphrase)
 	})
 
 	t.Run("specific passphrases", func(t *testing.T) {
@@ -470,11 +473,13 @@ maintainer: '"$GIT_COMMITTER_NAME" <$GIT_COMMITTER_EMAIL>'
 		t.Setenv("NFPM_DEB_PASSPHRASE", debPass)
 		t.Setenv("NFPM_RPM_PASSPHRASE", rpmPass)
 		t.Setenv("NFPM_APK_PASSPHRASE", apkPass)
+		t.Setenv("NFPM_GPKG_PASSPHRASE", gpkgPass)
 		info, err := nfpm.Parse(strings.NewReader("name: foo"))
 		require.NoError(t, err)
 		require.Equal(t, debPass, info.Deb.Signature.KeyPassphrase)
 		require.Equal(t, rpmPass, info.RPM.Signature.KeyPassphrase)
 		require.Equal(t, apkPass, info.APK.Signature.KeyPassphrase)
+		require.Equal(t, gpkgPass, info.Gentoo.Signature.KeyPassphrase)
 	})
 
 	t.Run("packager", func(t *testing.T) {
@@ -585,7 +590,7 @@ func TestOverrides(t *testing.T) {
 		t.Run(format, func(t *testing.T) {
 			pkg, err := config.Get(format)
 			require.NoError(t, err)
-			require.Equal(t, []string{format + "_depend"}, pkg.Depends)
+			require.Equal(t, nfpm.Relations{format + "_depend"}, pkg.Depends)
 			for _, f := range pkg.Contents {
 				switch f.Packager {
 				case format:
@@ -608,6 +613,71 @@ func TestOverrides(t *testing.T) {
 	})
 }
 
+func TestGetCopy(t *testing.T) {
+	nfpm.RegisterPackager("deb", &fakePackager{})
+	nfpm.RegisterPackager("rpm", &fakePackager{})
+
+	config, err := nfpm.ParseFile("./testdata/overrides.yaml")
+	require.NoError(t, err)
+	config.ExpandGlobs()
+	deb, err := config.Get("deb")
+	require.NoError(t, err)
+	rpm, err := config.Get("rpm")
+	require.NoError(t, err)
+
+	// preparing an info doesn't modify the config, nor the other infos.
+	require.NoError(t, nfpm.PrepareForPackager(deb, "deb"))
+	deb.Depends = append(deb.Depends[:0], "modified")
+	for _, content := range rpm.Contents {
+		require.Nil(t, content.FileInfo, content.Destination)
+	}
+	require.Equal(t, nfpm.Relations{"rpm_depend"}, rpm.Depends)
+	require.Equal(t, nfpm.Relations{"deb_depend"}, config.Overrides["deb"].Depends)
+	for _, content := range config.Contents {
+		require.Nil(t, content.FileInfo, content.Destination)
+	}
+	require.Equal(t, "testdata/whatever.conf", config.Contents[0].Source)
+
+	require.NoError(t, nfpm.PrepareForPackager(rpm, "rpm"))
+	var destinations []string
+	for _, content := range rpm.Contents {
+		if content.Type == files.TypeConfig {
+			destinations = append(destinations, content.Destination)
+		}
+	}
+	require.Equal(t, []string{"/etc/foo/whatever.conf", "/rpm/path.conf"}, destinations)
+}
+
+func TestMatrix(t *testing.T) {
+	nfpm.RegisterPackager("deb", &fakePackager{})
+	nfpm.RegisterPackager("rpm", &fakePackager{})
+
+	config, err := nfpm.ParseWithEnvMapping(strings.NewReader(`
+name: foo
+version: v1.0.0
+contents:
+- src: ./testdata/contents.yaml
+  dst: /usr/share/foo/${NFPM_ARCH}.yaml
+  expand: true
+matrix:
+  packagers: [deb, rpm]
+  arches: [amd64, arm64]
+`), func(s string) string {
+		if s == "NFPM_ARCH" {
+			return "arm64"
+		}
+		return ""
+	})
+	require.NoError(t, err)
+	require.NoError(t, config.Validate())
+	require.Equal(t, []string{"deb", "rpm"}, config.Matrix.Packagers)
+	require.Equal(t, []string{"amd64", "arm64"}, config.Matrix.Arches)
+	require.Equal(t, "/usr/share/foo/arm64.yaml", config.Contents[0].Destination)
+
+	config.Matrix.Packagers = append(config.Matrix.Packagers, "TestMatrixDoesNotExist")
+	require.EqualError(t, config.Validate(), "no packager registered for the format TestMatrixDoesNotExist")
+}
+
 type fakePackager struct{}
 
 func (*fakePackager) ConventionalFileName(_ *nfpm.Info) string {
@@ -625,3 +695,339 @@ func TestErrSigningFailureUnwrap(t *testing.T) {
 	require.ErrorIs(t, err, inner)
 	require.Equal(t, inner, errors.Unwrap(err))
 }
+
+func TestPackages(t *testing.T) {
+	nfpm.RegisterPackager("deb", &fakePackager{})
+	nfpm.RegisterPackager("srpm", &fakePackager{})
+	nfpm.RegisterPackager("dsc", &fakePackager{})
+	nfpm.RegisterPackager("apkbuild", &fakePackager{})
+	nfpm.RegisterPackager("pkgbuild", &fakePackager{})
+
+	config, err := nfpm.Parse(strings.NewReader(`
+name: foo
+version: v1.0.0
+maintainer: me
+rpm:
+  compression: zstd
+deb:
+  fields:
+    Bugs: https://example.com
+nupkg:
+  id: Foo.App
+macos:
+  identifier: com.example.foo
+oci:
+  tag: latest
+  entrypoint: ["/usr/bin/foo"]
+  cmd: ["--help"]
+snap:
+  plugs:
+    - network
+  apps:
+    foo:
+      command: bin/foo
+depends:
+  - foo-common
+contents:
+- src: ./testdata/contents.yaml
+  dst: /usr/bin/foo
+scripts:
+  postinstall: ./testdata/scripts/postinstall.sh
+overrides:
+  deb:
+    depends:
+      - foo-common-deb
+packages:
+  - name: foo-common
+    contents:
+    - src: ./testdata/contents.yaml
+      dst: /usr/share/foo/common.yaml
+  - name: foo-devel
+    description: headers of foo
+    depends:
+      - foo (= 1.0.0)
+    deb:
+      fields:
+        Multi-Arch: same
+    contents:
+    - src: ./testdata/contents.yaml
+      dst: /usr/include/foo.h
+    overrides:
+      deb:
+        depends:
+          - foo-deb
+`))
+	require.NoError(t, err)
+	require.NoError(t, config.Validate())
+
+	infos, err := config.GetPackages("deb")
+	require.NoError(t, err)
+	require.Len(t, infos, 3)
+
+	main, common, devel := infos[0], infos[1], infos[2]
+	require.Equal(t, "foo", main.Name)
+	require.Equal(t, nfpm.Relations{"foo-common-deb"}, main.Depends)
+	require.Equal(t, "/usr/bin/foo", main.Contents[0].Destination)
+	require.Equal(t, map[string]string{"Bugs": "https://example.com"}, main.Deb.Fields)
+	require.Equal(t, "Foo.App", main.Nupkg.ID)
+	require.Equal(t, "com.example.foo", main.MacOS.Identifier)
+	require.Len(t, main.Snap.Apps, 1)
+	require.Equal(t, []string{"network"}, main.Snap.Plugs)
+	require.Equal(t, "latest", main.OCI.Tag)
+	require.Equal(t, []string{"/usr/bin/foo"}, main.OCI.Entrypoint)
+
+	require.Equal(t, "foo-common", common.Name)
+	require.Equal(t, "1.0.0", common.Version)
+	require.Equal(t, "me", common.Maintainer)
+	require.Equal(t, "zstd", common.RPM.Compression)
+	require.Empty(t, common.Depends)
+	require.Empty(t, common.Scripts.PostInstall)
+	require.Len(t, common.Contents, 1)
+	require.Equal(t, "/usr/share/foo/common.yaml", common.Contents[0].Destination)
+	require.Empty(t, common.Nupkg.ID)
+	require.Empty(t, common.MacOS.Identifier)
+	require.Empty(t, common.Snap.Apps)
+	require.Empty(t, common.Snap.Plugs)
+	require.Empty(t, common.OCI.Tag)
+	require.Empty(t, common.OCI.Entrypoint)
+	require.Empty(t, common.OCI.Cmd)
+
+	require.Equal(t, "foo-devel", devel.Name)
+	require.Equal(t, "headers of foo", devel.Description)
+	require.Equal(t, nfpm.Relations{"foo-deb"}, devel.Depends)
+	require.Equal(t, map[string]string{
+		"Bugs":       "https://example.com",
+		"Multi-Arch": "same",
+	}, devel.Deb.Fields)
+
+	for _, info := range infos {
+		require.Equal(t, "foo", info.ArchLinux.Pkgbase)
+	}
+
+	for _, format := range []string{"srpm", "dsc", "apkbuild", "pkgbuild"} {
+		infos, err = config.GetPackages(format)
+		require.NoError(t, err)
+		require.Len(t, infos, 1)
+		require.Len(t, infos[0].SplitPackages, 2)
+		require.Equal(t, nfpm.Relations{"foo (= 1.0.0)"}, infos[0].SplitPackages[1].Depends)
+	}
+}
+
+func TestPackagesInheritance(t *testing.T) {
+	nfpm.RegisterPackager("deb", &fakePackager{})
+
+	// whether the packages inherit each setting of the top-level package, by
+	// path in Overridables; the settings not listed are walked field by field.
+	inherited := map[string]bool{
+		"Replaces":     false,
+		"Provides":     false,
+		"Depends":      false,
+		"Recommends":   false,
+		"Suggests":     false,
+		"Conflicts":    false,
+		"AutoDepends":  true,
+		"DebugSymbols": true,
+		"Systemd":      false,
+		"Users":        false,
+		"Groups":       false,
+		"Alternatives": false,
+		"Contents":     false,
+		"Umask":        true,
+		"Scripts":      false,
+
+		"RPM.Arch":        true,
+		"RPM.BuildHost":   true,
+		"RPM.Scripts":     false,
+		"RPM.Requires":    false,
+		"RPM.Group":       true,
+		"RPM.Summary":     false,
+		"RPM.Compression": true,
+		"RPM.Signature":   true,
+		"RPM.Packager":    true,
+		"RPM.Prefixes":    true,
+
+		"Deb.Arch":        true,
+		"Deb.ArchVariant": true,
+		"Deb.Scripts":     false,
+		"Deb.Triggers":    false,
+		"Deb.Breaks":      false,
+		"Deb.Signature":   true,
+		"Deb.Compression": true,
+		"Deb.Fields":      true,
+		"Deb.Predepends":  false,
+
+		"APK.Arch":      true,
+		"APK.Signature": true,
+		"APK.Scripts":   false,
+
+		"ArchLinux.Pkgbase":  true,
+		"ArchLinux.Arch":     true,
+		"ArchLinux.Packager": true,
+		"ArchLinux.Scripts":  false,
+
+		"IPK.ABIVersion":    true,
+		"IPK.Alternatives":  false,
+		"IPK.Arch":          true,
+		"IPK.AutoInstalled": true,
+		"IPK.Essential":     true,
+		"IPK.Fields":        true,
+		"IPK.Predepends":    false,
+		"IPK.Tags":          true,
+
+		"MSIX.Arch":         true,
+		"MSIX.Publisher":    true,
+		"MSIX.Identity":     false,
+		"MSIX.Properties":   false,
+		"MSIX.Applications": false,
+		"MSIX.Dependencies": true,
+		"MSIX.Capabilities": true,
+		"MSIX.Signature":    true,
+
+		"FreeBSD.Arch":        true,
+		"FreeBSD.ABI":         true,
+		"FreeBSD.Origin":      true,
+		"FreeBSD.Compression": true,
+
+		"MacOS.Arch":            true,
+		"MacOS.Identifier":      false,
+		"MacOS.MinOS":           true,
+		"MacOS.InstallLocation": true,
+
+		"OCI.Arch":       true,
+		"OCI.Base":       true,
+		"OCI.Tag":        false,
+		"OCI.Entrypoint": false,
+		"OCI.Cmd":        false,
+		"OCI.Env":        true,
+		"OCI.WorkingDir": true,
+		"OCI.User":       true,
+		"OCI.Labels":     true,
+
+		"AppImage.Arch":              true,
+		"AppImage.Runtime":           true,
+		"AppImage.Exec":              false,
+		"AppImage.Icon":              false,
+		"AppImage.Desktop":           false,
+		"AppImage.Categories":        true,
+		"AppImage.Terminal":          true,
+		"AppImage.Compression":       true,
+		"AppImage.UpdateInformation": false,
+		"AppImage.Zsync":             true,
+
+		"Nupkg.ID":         false,
+		"Nupkg.Title":      false,
+		"Nupkg.Authors":    true,
+		"Nupkg.Tags":       true,
+		"Nupkg.IconURL":    true,
+		"Nupkg.InstallDir": true,
+
+		"Conda.Subdir":  true,
+		"Conda.Build":   true,
+		"Conda.Depends": false,
+
+		"Gentoo.Category":    true,
+		"Gentoo.Slot":        true,
+		"Gentoo.Keywords":    true,
+		"Gentoo.Compression": true,
+		"Gentoo.Signature":   true,
+
+		"Snap.Arch":        true,
+		"Snap.Title":       false,
+		"Snap.Summary":     false,
+		"Snap.Base":        true,
+		"Snap.Grade":       true,
+		"Snap.Confinement": true,
+		"Snap.Plugs":       false,
+		"Snap.Apps":        false,
+	}
+
+	config := &nfpm.Config{Info: nfpm.Info{Name: "foo", Version: "v1.0.0"}}
+	fill(reflect.ValueOf(&config.Overridables).Elem())
+	config.Packages = []nfpm.Package{{Name: "foo-doc"}}
+	infos, err := config.GetPackages("deb")
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+
+	var walk func(path string, top, split reflect.Value)
+	walk = func(path string, top, split reflect.Value) {
+		for i := range top.NumField() {
+			field := top.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.TrimPrefix(path+"."+field.Name, ".")
+			inherit, ok := inherited[name]
+			switch {
+			case ok && inherit:
+				require.Equal(t, top.Field(i).Interface(), split.Field(i).Interface(), "%s must be inherited", name)
+			case ok:
+				require.True(t, split.Field(i).IsZero(), "%s must not be inherited", name)
+			case field.Type.Kind() == reflect.Struct:
+				walk(name, top.Field(i), split.Field(i))
+			default:
+				t.Errorf("%s must be declared inherited or not", name)
+			}
+		}
+	}
+	walk("", reflect.ValueOf(config.Overridables), reflect.ValueOf(infos[1].Overridables))
+}
+
+// fill sets every settable field of v to a non-zero value.
+func fill(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem())
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0))
+	case reflect.Map:
+		key, elem := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+		fill(key)
+		fill(elem)
+		v.Set(reflect.MakeMapWithSize(v.Type(), 1))
+		v.SetMapIndex(key, elem)
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i))
+			}
+		}
+	}
+}
+
+func TestPackagesNone(t *testing.T) {
+	config, err := nfpm.Parse(strings.NewReader("name: foo\nversion: v1.0.0\n"))
+	require.NoError(t, err)
+	infos, err := config.GetPackages("deb")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.Empty(t, infos[0].ArchLinux.Pkgbase)
+}
+
+func TestPackagesValidate(t *testing.T) {
+	for yaml, expected := range map[string]string{
+		"packages:\n  - description: nope":    "package packages.name must be provided",
+		"packages:\n  - name: foo":            "package foo is declared more than once",
+		"packages:\n  - name: a\n  - name: a": "package a is declared more than once",
+	} {
+		config, err := nfpm.Parse(strings.NewReader("name: foo\nversion: v1.0.0\n" + yaml))
+		require.NoError(t, err)
+		require.EqualError(t, config.Validate(), expected)
+	}
+}
+
+func TestPackagesDuplicate(t *testing.T) {
+	config, err := nfpm.Parse(strings.NewReader("name: foo\nversion: v1.0.0\npackages:\n  - name: foo\n"))
+	require.NoError(t, err)
+	_, err = config.GetPackages("deb")
+	require.EqualError(t, err, "package foo is declared more than once")
+}
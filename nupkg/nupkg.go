@@ -0,0 +1,362 @@
+// Package nupkg implements nfpm.Packager providing Chocolatey bindings.
+//
+// A Chocolatey package is a NuGet package, a zip file holding the nuspec
+// manifest and the PowerShell scripts Chocolatey runs. The contents are
+// zipped in the package, and unpacked by the generated install script.
+package nupkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+)
+
+const packagerName = "nupkg"
+
+// nolint: gochecknoinits
+func init() {
+	nfpm.RegisterPackager(packagerName, Default)
+}
+
+// nolint: gochecknoglobals
+var (
+	idPattern      = regexp.MustCompile(`^\w+([.-]\w+)*$`)
+	versionPattern = regexp.MustCompile(`^\d+(\.\d+){0,3}(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+)
+
+// Default Chocolatey packager.
+// nolint: gochecknoglobals
+var Default = &Nupkg{}
+
+// Nupkg is a Chocolatey packager implementation.
+type Nupkg struct{}
+
+// ConventionalFileName returns a file name according to the conventions for
+// NuGet packages: id.version.nupkg.
+func (*Nupkg) ConventionalFileName(info *nfpm.Info) string {
+	return fmt.Sprintf("%s.%s.nupkg", packageID(info), packageVersion(info))
+}
+
+// ConventionalExtension returns the file name conventionally used for NuGet
+// packages.
+func (*Nupkg) ConventionalExtension() string {
+	return ".nupkg"
+}
+
+// packageID returns the id of the package, its name by default.
+func packageID(info *nfpm.Info) string {
+	return cmp.Or(info.Nupkg.ID, info.Name)
+}
+
+// packageVersion returns the version of the package, with its prerelease.
+func packageVersion(info *nfpm.Info) string {
+	if info.Prerelease != "" {
+		return info.Version + "-" + info.Prerelease
+	}
+	return info.Version
+}
+
+// Package writes a new Chocolatey package to the given writer using the
+// given info.
+func (*Nupkg) Package(info *nfpm.Info, w io.Writer) error {
+	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
+		return err
+	}
+	id, version := packageID(info), packageVersion(info)
+	if len(id) > 100 || !idPattern.MatchString(id) {
+		return fmt.Errorf("invalid NuGet package id: %s", id)
+	}
+	if !versionPattern.MatchString(version) {
+		return fmt.Errorf("invalid NuGet version: %s", version)
+	}
+	if info.Description == "" {
+		return fmt.Errorf("package %s must be provided", "description")
+	}
+
+	if err := nfpm.PrepareRelations(info, packagerName, func(arch string) bool {
+		return arch == info.Arch
+	}); err != nil {
+		return err
+	}
+
+	manifest, err := nuspec(info, id, version)
+	if err != nil {
+		return err
+	}
+	install, err := installScript(info, id)
+	if err != nil {
+		return err
+	}
+	uninstall, err := uninstallScript(info, id)
+	if err != nil {
+		return err
+	}
+	mtime := modtime.Get(info.MTime)
+	payload, err := payloadZip(info, mtime)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	for _, entry := range []struct {
+		name   string
+		data   []byte
+		method uint16
+	}{
+		{"_rels/.rels", relationships(id), zip.Deflate},
+		{id + ".nuspec", manifest, zip.Deflate},
+		{"tools/chocolateyInstall.ps1", install, zip.Deflate},
+		{"tools/chocolateyUninstall.ps1", uninstall, zip.Deflate},
+		// the payload is already compressed.
+		{"tools/" + id + ".zip", payload, zip.Store},
+		{"[Content_Types].xml", contentTypes(), zip.Deflate},
+	} {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     entry.name,
+			Method:   entry.method,
+			Modified: mtime,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(entry.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+type nuspecPackage struct {
+	XMLName  xml.Name       `xml:"http://schemas.microsoft.com/packaging/2015/06/nuspec.xsd package"`
+	Metadata nuspecMetadata `xml:"metadata"`
+}
+
+type nuspecMetadata struct {
+	ID                       string              `xml:"id"`
+	Version                  string              `xml:"version"`
+	Title                    string              `xml:"title,omitempty"`
+	Authors                  string              `xml:"authors"`
+	ProjectURL               string              `xml:"projectUrl,omitempty"`
+	IconURL                  string              `xml:"iconUrl,omitempty"`
+	License                  *nuspecLicense      `xml:"license,omitempty"`
+	LicenseURL               string              `xml:"licenseUrl,omitempty"`
+	RequireLicenseAcceptance bool                `xml:"requireLicenseAcceptance"`
+	Description              string              `xml:"description"`
+	Tags                     string              `xml:"tags,omitempty"`
+	Dependencies             *nuspecDependencies `xml:"dependencies,omitempty"`
+}
+
+type nuspecLicense struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type nuspecDependencies struct {
+	Dependencies []nuspecDependency `xml:"dependency"`
+}
+
+type nuspecDependency struct {
+	ID      string `xml:"id,attr"`
+	Version string `xml:"version,attr,omitempty"`
+}
+
+// nuspec returns the manifest of the package, its relations being already
+// formatted as NuGet version ranges.
+func nuspec(info *nfpm.Info, id, version string) ([]byte, error) {
+	metadata := nuspecMetadata{
+		ID:          id,
+		Version:     version,
+		Title:       info.Nupkg.Title,
+		Authors:     authors(info),
+		ProjectURL:  info.Homepage,
+		IconURL:     info.Nupkg.IconURL,
+		Description: strings.TrimSpace(info.Description),
+		Tags:        strings.Join(info.Nupkg.Tags, " "),
+	}
+	if info.License != "" {
+		// the license is an SPDX expression, the URL being the one NuGet
+		// writes for the clients which do not know about expressions.
+		metadata.License = &nuspecLicense{Type: "expression", Value: info.License}
+		metadata.LicenseURL = "https://licenses.nuget.org/" + url.PathEscape(info.License)
+	}
+
+	if len(info.Depends) > 0 {
+		metadata.Dependencies = &nuspecDependencies{}
+		for _, depend := range info.Depends {
+			// the id and the version range of the dependency.
+			id, version, _ := strings.Cut(depend, " ")
+			metadata.Dependencies.Dependencies = append(metadata.Dependencies.Dependencies, nuspecDependency{ID: id, Version: version})
+		}
+	}
+
+	data, err := xml.MarshalIndent(nuspecPackage{Metadata: metadata}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// authors returns the authors of the package: the name of the maintainer,
+// the vendor or the name of the package.
+func authors(info *nfpm.Info) string {
+	if info.Nupkg.Authors != "" {
+		return info.Nupkg.Authors
+	}
+	if address, err := mail.ParseAddress(info.Maintainer); err == nil && address.Name != "" {
+		return address.Name
+	}
+	return cmp.Or(info.Maintainer, info.Vendor, info.Name)
+}
+
+// installScript returns the chocolateyInstall.ps1 of the package, which
+// unpacks the payload into the install directory between the preinstall and
+// postinstall scripts.
+func installScript(info *nfpm.Info, id string) ([]byte, error) {
+	installDir := "$toolsDir"
+	if info.Nupkg.InstallDir != "" {
+		installDir = psQuote(info.Nupkg.InstallDir)
+	}
+	var b bytes.Buffer
+	b.WriteString("$ErrorActionPreference = 'Stop'\n\n")
+	b.WriteString("$toolsDir = Split-Path -Parent $MyInvocation.MyCommand.Definition\n")
+	fmt.Fprintf(&b, "$installDir = %s\n", installDir)
+	fmt.Fprintf(&b, "$zip = Join-Path $toolsDir '%s.zip'\n", id)
+	if err := writeScript(&b, info.Scripts.PreInstall); err != nil {
+		return nil, err
+	}
+	b.WriteString("\nGet-ChocolateyUnzip -FileFullPath $zip -Destination $installDir -PackageName $env:ChocolateyPackageName\n")
+	b.WriteString("Remove-Item -Force $zip\n")
+	if err := writeScript(&b, info.Scripts.PostInstall); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// uninstallScript returns the chocolateyUninstall.ps1 of the package, which
+// removes the files unpacked by the install script between the preremove and
+// postremove scripts.
+func uninstallScript(info *nfpm.Info, id string) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("$ErrorActionPreference = 'Stop'\n")
+	if err := writeScript(&b, info.Scripts.PreRemove); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&b, "\nUninstall-ChocolateyZipPackage -PackageName $env:ChocolateyPackageName -ZipFileName '%s.zip'\n", id)
+	if err := writeScript(&b, info.Scripts.PostRemove); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeScript appends the PowerShell script at the given path, if any.
+func writeScript(b *bytes.Buffer, path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	b.WriteString("\n")
+	b.Write(data)
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		b.WriteString("\n")
+	}
+	return nil
+}
+
+// psQuote quotes s as a double-quoted PowerShell string, in which the
+// variables, e.g. $env:ProgramFiles, are expanded.
+func psQuote(s string) string {
+	return `"` + strings.NewReplacer("`", "``", `"`, "`\"").Replace(s) + `"`
+}
+
+// payloadZip returns the zip file holding the contents, under their
+// destinations relative to the install directory.
+func payloadZip(info *nfpm.Info, mtime time.Time) ([]byte, error) {
+	contents := slices.Clone(info.Contents)
+	slices.SortFunc(contents, func(a, b *files.Content) int {
+		return strings.Compare(a.Destination, b.Destination)
+	})
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, content := range contents {
+		name := strings.TrimPrefix(files.NormalizeAbsoluteFilePath(content.Destination), "/")
+		if name == "" {
+			continue
+		}
+		header := &zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: content.ModTime(),
+		}
+		if header.Modified.IsZero() {
+			header.Modified = mtime
+		}
+		var data []byte
+		switch content.Type {
+		case files.TypeDir, files.TypeImplicitDir:
+			header.Name += "/"
+			header.Method = zip.Store
+		case files.TypeSymlink:
+			log.Printf("warning: nupkg does not support symlinks, skipping %s", content.Destination)
+			continue
+		case files.TypeFile, files.TypeConfig, files.TypeConfigNoReplace, files.TypeConfigMissingOK:
+			var err error
+			if data, err = os.ReadFile(content.Source); err != nil {
+				return nil, err
+			}
+		default:
+			// ignore everything else
+			continue
+		}
+		f, err := zw.CreateHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// relationships returns the _rels/.rels of the Open Packaging Conventions,
+// pointing to the manifest of the package.
+func relationships(id string) []byte {
+	return []byte(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Type="http://schemas.microsoft.com/packaging/2010/07/manifest" Target="/` + id + `.nuspec" Id="R0" />
+</Relationships>
+`)
+}
+
+// contentTypes returns the [Content_Types].xml of the Open Packaging
+// Conventions, with the types NuGet gives to the files of the package.
+func contentTypes() []byte {
+	return []byte(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+  <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml" />
+  <Default Extension="nuspec" ContentType="application/octet" />
+  <Default Extension="ps1" ContentType="application/octet" />
+  <Default Extension="zip" ContentType="application/octet" />
+</Types>
+`)
+}
@@ -0,0 +1,209 @@
+package nupkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+var mtime = time.Date(2023, 11, 5, 23, 15, 17, 0, time.UTC)
+
+func exampleInfo() *nfpm.Info {
+	return nfpm.WithDefaults(&nfpm.Info{
+		Name:        "foo",
+		Arch:        "amd64",
+		Description: "Foo does things\nand more things",
+		Maintainer:  "Carlos A Becker <pkg@carlosbecker.com>",
+		Version:     "v1.0.0",
+		Prerelease:  "rc-1",
+		Homepage:    "http://carlosbecker.com",
+		License:     "MIT",
+		MTime:       mtime,
+		Overridables: nfpm.Overridables{
+			Depends: []string{
+				"bar (>= 2.0)",
+				"baz",
+				"qux [arm64]",
+			},
+			Contents: []*files.Content{
+				{
+					Source:      "../testdata/fake",
+					Destination: "/bin/fake.exe",
+				},
+				{
+					Source:      "../testdata/whatever.conf",
+					Destination: "/etc/fake.conf",
+					Type:        files.TypeConfig,
+				},
+				{
+					Source:      "/bin/fake.exe",
+					Destination: "/bin/fake-link.exe",
+					Type:        files.TypeSymlink,
+				},
+				{
+					Destination: "/data",
+					Type:        files.TypeDir,
+				},
+			},
+			Nupkg: nfpm.Nupkg{
+				Tags: []string{"foo", "cli"},
+			},
+		},
+	})
+}
+
+// readZip returns the files of the zip file by name.
+func readZip(t *testing.T, data []byte) ([]string, map[string][]byte) {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	var names []string
+	contents := map[string][]byte{}
+	for _, f := range r.File {
+		require.Equal(t, mtime.Unix(), f.Modified.Unix(), f.Name)
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		names = append(names, f.Name)
+		contents[f.Name] = content
+	}
+	return names, contents
+}
+
+func TestConventionalFileName(t *testing.T) {
+	info := exampleInfo()
+	require.Equal(t, "foo.1.0.0-rc-1.nupkg", Default.ConventionalFileName(info))
+	info.Nupkg.ID = "Foo.Tool"
+	info.Prerelease = ""
+	require.Equal(t, "Foo.Tool.1.0.0.nupkg", Default.ConventionalFileName(info))
+	require.Equal(t, ".nupkg", Default.ConventionalExtension())
+}
+
+func TestPackage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(exampleInfo(), &buf))
+	names, contents := readZip(t, buf.Bytes())
+	require.Equal(t, []string{
+		"_rels/.rels",
+		"foo.nuspec",
+		"tools/chocolateyInstall.ps1",
+		"tools/chocolateyUninstall.ps1",
+		"tools/foo.zip",
+		"[Content_Types].xml",
+	}, names)
+	require.Contains(t, string(contents["_rels/.rels"]), `Target="/foo.nuspec"`)
+
+	var manifest nuspecPackage
+	require.NoError(t, xml.Unmarshal(contents["foo.nuspec"], &manifest))
+	require.Equal(t, "http://schemas.microsoft.com/packaging/2015/06/nuspec.xsd", manifest.XMLName.Space)
+	require.Equal(t, nuspecMetadata{
+		ID:          "foo",
+		Version:     "1.0.0-rc-1",
+		Authors:     "Carlos A Becker",
+		ProjectURL:  "http://carlosbecker.com",
+		License:     &nuspecLicense{Type: "expression", Value: "MIT"},
+		LicenseURL:  "https://licenses.nuget.org/MIT",
+		Description: "Foo does things\nand more things",
+		Tags:        "foo cli",
+		Dependencies: &nuspecDependencies{Dependencies: []nuspecDependency{
+			{ID: "bar", Version: "2.0"},
+			{ID: "baz"},
+		}},
+	}, manifest.Metadata)
+
+	require.Equal(t, `$ErrorActionPreference = 'Stop'
+
+$toolsDir = Split-Path -Parent $MyInvocation.MyCommand.Definition
+$installDir = $toolsDir
+$zip = Join-Path $toolsDir 'foo.zip'
+
+Get-ChocolateyUnzip -FileFullPath $zip -Destination $installDir -PackageName $env:ChocolateyPackageName
+Remove-Item -Force $zip
+`, string(contents["tools/chocolateyInstall.ps1"]))
+	require.Equal(t, `$ErrorActionPreference = 'Stop'
+
+Uninstall-ChocolateyZipPackage -PackageName $env:ChocolateyPackageName -ZipFileName 'foo.zip'
+`, string(contents["tools/chocolateyUninstall.ps1"]))
+
+	names, payload := readZip(t, contents["tools/foo.zip"])
+	require.Equal(t, []string{"bin/", "bin/fake.exe", "data/", "etc/", "etc/fake.conf"}, names)
+	fake, err := os.ReadFile("../testdata/fake")
+	require.NoError(t, err)
+	require.Equal(t, fake, payload["bin/fake.exe"])
+}
+
+func TestPackageScripts(t *testing.T) {
+	info := exampleInfo()
+	info.Nupkg.InstallDir = `$env:ProgramFiles\"foo"`
+	info.Scripts.PreInstall = "../testdata/scripts/preinstall.sh"
+	info.Scripts.PostRemove = "../testdata/scripts/postremove.sh"
+	preinstall, err := os.ReadFile(info.Scripts.PreInstall)
+	require.NoError(t, err)
+	postremove, err := os.ReadFile(info.Scripts.PostRemove)
+	require.NoError(t, err)
+
+	install, err := installScript(info, "foo")
+	require.NoError(t, err)
+	require.Contains(t, string(install), "$installDir = \"$env:ProgramFiles\\`\"foo`\"\"\n")
+	require.Contains(t, string(install), "\n"+string(preinstall)+"\nGet-ChocolateyUnzip ")
+	uninstall, err := uninstallScript(info, "foo")
+	require.NoError(t, err)
+	require.Contains(t, string(uninstall), "'foo.zip'\n\n"+string(postremove))
+
+	info.Scripts.PostInstall = "../testdata/scripts/nope.ps1"
+	_, err = installScript(info, "foo")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPackageAuthors(t *testing.T) {
+	info := exampleInfo()
+	require.Equal(t, "Carlos A Becker", authors(info))
+	info.Maintainer = "pkg@carlosbecker.com"
+	require.Equal(t, "pkg@carlosbecker.com", authors(info))
+	info.Maintainer, info.Vendor = "", "Becker Software"
+	require.Equal(t, "Becker Software", authors(info))
+	info.Vendor = ""
+	require.Equal(t, "foo", authors(info))
+	info.Nupkg.Authors = "Foo Authors"
+	require.Equal(t, "Foo Authors", authors(info))
+}
+
+func TestPackageInvalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		update func(info *nfpm.Info)
+		err    string
+	}{
+		"id": {
+			update: func(info *nfpm.Info) { info.Nupkg.ID = "foo bar" },
+			err:    "invalid NuGet package id: foo bar",
+		},
+		"version": {
+			update: func(info *nfpm.Info) { info.Version = "1.0.0.0.1" },
+			err:    "invalid NuGet version: 1.0.0.0.1-rc-1",
+		},
+		"description": {
+			update: func(info *nfpm.Info) { info.Description = "" },
+			err:    "package description must be provided",
+		},
+		"alternatives": {
+			update: func(info *nfpm.Info) { info.Depends = []string{"bar | baz"} },
+			err:    "nupkg does not support alternative relations: bar | baz",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			info := exampleInfo()
+			tc.update(info)
+			require.EqualError(t, Default.Package(info, io.Discard), tc.err)
+		})
+	}
+}
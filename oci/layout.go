@@ -0,0 +1,228 @@
+package oci
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// media types of the indexes and manifests written by docker.
+const (
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// nolint: gochecknoglobals
+var digestPattern = regexp.MustCompile(`^(sha256|sha512):[a-f0-9]+$`)
+
+// baseImage is the image the image of the package is built on top of, read
+// from an OCI image layout directory.
+type baseImage struct {
+	dir      string
+	manifest manifest
+	config   imageConfig
+}
+
+// readBase reads the image of the given platform from the OCI image layout
+// directory, or its only image. Nested indexes are followed.
+func readBase(dir, arch string) (*baseImage, error) {
+	base := &baseImage{dir: dir}
+	var idx index
+	if err := readJSON(filepath.Join(dir, "index.json"), &idx); err != nil {
+		return nil, err
+	}
+	for {
+		desc, err := selectManifest(idx.Manifests, arch)
+		if err != nil {
+			return nil, err
+		}
+		path, err := base.checkedBlobPath(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		switch desc.MediaType {
+		case mediaTypeIndex, mediaTypeDockerManifestList:
+			idx = index{}
+			if err := readJSON(path, &idx); err != nil {
+				return nil, err
+			}
+			continue
+		case mediaTypeManifest, mediaTypeDockerManifest:
+			if err := readJSON(path, &base.manifest); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported media type: %s", desc.MediaType)
+		}
+		break
+	}
+
+	for _, desc := range append([]descriptor{base.manifest.Config}, base.manifest.Layers...) {
+		if _, err := base.checkedBlobPath(desc.Digest); err != nil {
+			return nil, err
+		}
+	}
+	if err := readJSON(base.blobPath(base.manifest.Config.Digest), &base.config); err != nil {
+		return nil, err
+	}
+	if arch != "all" && base.config.platform() != arch {
+		return nil, fmt.Errorf("the platform of the base image is %s, not %s", base.config.platform(), arch)
+	}
+	return base, nil
+}
+
+// selectManifest returns the manifest of the given platform, or the only one.
+func selectManifest(manifests []descriptor, arch string) (descriptor, error) {
+	if len(manifests) == 1 {
+		return manifests[0], nil
+	}
+	for _, desc := range manifests {
+		if desc.Platform == nil || desc.Platform.OS != "linux" {
+			continue
+		}
+		p := imageConfig{Architecture: desc.Platform.Architecture, Variant: desc.Platform.Variant}
+		if p.platform() == arch {
+			return desc, nil
+		}
+	}
+	if len(manifests) == 0 {
+		return descriptor{}, errors.New("no image found")
+	}
+	return descriptor{}, fmt.Errorf("no image found for linux/%s", arch)
+}
+
+// blobPath returns the path of the blob with the given digest.
+func (b *baseImage) blobPath(digest string) string {
+	algorithm, hex, _ := strings.Cut(digest, ":")
+	return filepath.Join(b.dir, "blobs", algorithm, hex)
+}
+
+// checkedBlobPath returns the path of the blob with the given digest, which
+// must be valid, as it is a part of the path.
+func (b *baseImage) checkedBlobPath(digest string) (string, error) {
+	if !digestPattern.MatchString(digest) {
+		return "", fmt.Errorf("invalid digest: %q", digest)
+	}
+	return b.blobPath(digest), nil
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// layoutWriter writes the tarball of an OCI image layout, creating the
+// directories of the blobs as needed and skipping the blobs already written.
+type layoutWriter struct {
+	tw      *tar.Writer
+	mtime   time.Time
+	written map[string]bool
+}
+
+func newLayoutWriter(w io.Writer, mtime time.Time) *layoutWriter {
+	return &layoutWriter{
+		tw:      tar.NewWriter(w),
+		mtime:   mtime,
+		written: map[string]bool{},
+	}
+}
+
+// writeFile writes a file of the layout.
+func (lw *layoutWriter) writeFile(name string, data []byte) error {
+	if err := lw.writeHeader(name, int64(len(data))); err != nil {
+		return err
+	}
+	if _, err := lw.tw.Write(data); err != nil {
+		return fmt.Errorf("cannot write %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeBlob writes a blob of the layout.
+func (lw *layoutWriter) writeBlob(digest string, data []byte) error {
+	name, ok, err := lw.blobName(digest)
+	if !ok || err != nil {
+		return err
+	}
+	return lw.writeFile(name, data)
+}
+
+// copyBlob writes a blob of the layout from the given file.
+func (lw *layoutWriter) copyBlob(digest, path string) error {
+	name, ok, err := lw.blobName(digest)
+	if !ok || err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint: errcheck
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := lw.writeHeader(name, stat.Size()); err != nil {
+		return err
+	}
+	if _, err := io.Copy(lw.tw, f); err != nil {
+		return fmt.Errorf("cannot write %s: %w", name, err)
+	}
+	return nil
+}
+
+// blobName returns the name of the blob in the layout, creating its
+// directories, and false if it was already written.
+func (lw *layoutWriter) blobName(digest string) (string, bool, error) {
+	if lw.written[digest] {
+		return "", false, nil
+	}
+	lw.written[digest] = true
+	algorithm, hex, _ := strings.Cut(digest, ":")
+	for _, dir := range []string{"blobs/", "blobs/" + algorithm + "/"} {
+		if lw.written[dir] {
+			continue
+		}
+		lw.written[dir] = true
+		if err := lw.tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     dir,
+			Mode:     0o755,
+			ModTime:  lw.mtime,
+		}); err != nil {
+			return "", false, fmt.Errorf("cannot write header of %s: %w", dir, err)
+		}
+	}
+	return "blobs/" + algorithm + "/" + hex, true, nil
+}
+
+func (lw *layoutWriter) writeHeader(name string, size int64) error {
+	if err := lw.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     size,
+		ModTime:  lw.mtime,
+	}); err != nil {
+		return fmt.Errorf("cannot write header of %s: %w", name, err)
+	}
+	return nil
+}
+
+// Close writes the end of the tarball.
+func (lw *layoutWriter) Close() error {
+	return lw.tw.Close()
+}
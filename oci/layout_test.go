@@ -0,0 +1,118 @@
+package oci
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+// baseLayout writes the layout of a base image and returns its directory.
+func baseLayout(t *testing.T) string {
+	t.Helper()
+	info := exampleInfo()
+	info.Name = "base"
+	info.Contents = files.Contents{
+		{
+			Source:      "../testdata/whatever.conf",
+			Destination: "/etc/base.conf",
+		},
+	}
+	info.Users = nil
+	info.OCI = nfpm.OCI{
+		Entrypoint: []string{"/bin/sh"},
+		Cmd:        []string{"-c", "true"},
+		Env:        []string{"PATH=/usr/bin:/bin", "FOO=base"},
+		WorkingDir: "/srv",
+		Labels:     map[string]string{"base": "yes"},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	return writeLayout(t, buf.Bytes())
+}
+
+func TestPackageWithBase(t *testing.T) {
+	dir := baseLayout(t)
+	info := exampleInfo()
+	info.Arch = "all"
+	info.OCI.Base = dir
+	info.OCI.Cmd = nil
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	img := readImage(t, buf.Bytes())
+
+	require.Len(t, img.manifest.Layers, 2)
+	base, _, baseDiffID := readLayer(t, img, 0)
+	require.Equal(t, "etc/base.conf", base[len(base)-1].Name)
+	_, _, diffID := readLayer(t, img, 1)
+
+	config := img.config
+	require.Equal(t, "arm", config.Architecture)
+	require.Equal(t, "v7", config.Variant)
+	require.Equal(t, []string{baseDiffID, diffID}, config.RootFS.DiffIDs)
+	require.Len(t, config.History, 2)
+	require.Equal(t, []string{"PATH=/usr/bin:/bin", "FOO=bar"}, config.Config.Env)
+	// the entrypoint of the package resets the command of the base image.
+	require.Equal(t, []string{"/usr/bin/fake"}, config.Config.Entrypoint)
+	require.Empty(t, config.Config.Cmd)
+	require.Equal(t, "/srv", config.Config.WorkingDir)
+	require.Equal(t, "yes", config.Config.Labels["base"])
+	require.Equal(t, "foo", config.Config.Labels["org.opencontainers.image.title"])
+}
+
+func TestPackageWithBaseOtherPlatform(t *testing.T) {
+	info := exampleInfo()
+	info.Arch = "amd64"
+	info.OCI.Base = baseLayout(t)
+	err := Default.Package(info, &bytes.Buffer{})
+	require.EqualError(t, err, "cannot read base image: the platform of the base image is arm/v7, not amd64")
+}
+
+func TestReadBaseIndex(t *testing.T) {
+	dir := baseLayout(t)
+	var idx index
+	require.NoError(t, readJSON(filepath.Join(dir, "index.json"), &idx))
+	image := idx.Manifests[0]
+
+	// a nested index listing the image along with another platform.
+	other := image
+	other.Platform = &platform{OS: "linux", Architecture: "amd64"}
+	nested, err := json.Marshal(index{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeIndex,
+		Manifests:     []descriptor{other, image},
+	})
+	require.NoError(t, err)
+	nestedDesc := newDescriptor(mediaTypeIndex, nested)
+	require.NoError(t, os.WriteFile((&baseImage{dir: dir}).blobPath(nestedDesc.Digest), nested, 0o644))
+	top, err := json.Marshal(index{SchemaVersion: 2, Manifests: []descriptor{nestedDesc}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.json"), top, 0o644))
+
+	base, err := readBase(dir, "arm/v7")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/bin/sh"}, base.config.Config.Entrypoint)
+
+	_, err = readBase(dir, "riscv64")
+	require.EqualError(t, err, "no image found for linux/riscv64")
+}
+
+func TestReadBaseInvalidDigest(t *testing.T) {
+	dir := t.TempDir()
+	idx, err := json.Marshal(index{
+		SchemaVersion: 2,
+		Manifests: []descriptor{{
+			MediaType: mediaTypeManifest,
+			Digest:    "sha256:../../etc/passwd",
+		}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.json"), idx, 0o644))
+	_, err = readBase(dir, "amd64")
+	require.EqualError(t, err, `invalid digest: "sha256:../../etc/passwd"`)
+}
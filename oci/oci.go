@@ -0,0 +1,459 @@
+// Package oci implements nfpm.Packager providing OCI image bindings.
+//
+// The contents of the package are written as a single image layer, on top of
+// the layers of an optional base image, and the image is written as a tarball
+// of an OCI image layout: the oci-layout and index.json files, and the
+// manifest, config and layers of the image as blobs named after their digest.
+// See https://github.com/opencontainers/image-spec.
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+)
+
+const packagerName = "oci"
+
+// media types of the image.
+const (
+	mediaTypeIndex    = "application/vnd.oci.image.index.v1+json"
+	mediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	mediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+// annotationRefName is the annotation of the index holding the tag of the
+// image.
+const annotationRefName = "org.opencontainers.image.ref.name"
+
+// nolint: gochecknoinits
+func init() {
+	nfpm.RegisterPackager(packagerName, Default)
+}
+
+// archToOCI maps the architectures to the platforms of the images, as
+// architecture/variant.
+// nolint: gochecknoglobals
+var archToOCI = map[string]string{
+	"386":      "386",
+	"i386":     "386",
+	"i686":     "386",
+	"amd64":    "amd64",
+	"x86_64":   "amd64",
+	"arm5":     "arm/v5",
+	"arm6":     "arm/v6",
+	"arm7":     "arm/v7",
+	"arm64":    "arm64",
+	"aarch64":  "arm64",
+	"ppc64le":  "ppc64le",
+	"riscv64":  "riscv64",
+	"s390x":    "s390x",
+	"mips64le": "mips64le",
+}
+
+// nolint: gochecknoglobals
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+func ensureValidArch(info *nfpm.Info) *nfpm.Info {
+	if info.OCI.Arch != "" {
+		info.Arch = info.OCI.Arch
+	} else if arch, ok := archToOCI[info.Arch]; ok {
+		info.Arch = arch
+	}
+
+	return info
+}
+
+// Default OCI packager.
+// nolint: gochecknoglobals
+var Default = &OCI{}
+
+// OCI is an OCI image packager implementation.
+type OCI struct{}
+
+// ConventionalFileName returns a file name for the tarball of the image
+// layout: name-version-arch.oci.tar.
+func (*OCI) ConventionalFileName(info *nfpm.Info) string {
+	info = ensureValidArch(info)
+	return fmt.Sprintf("%s-%s-%s.oci.tar", info.Name, version(info), strings.ReplaceAll(info.Arch, "/", ""))
+}
+
+// ConventionalExtension returns the file name conventionally used for the
+// tarballs of OCI image layouts.
+func (*OCI) ConventionalExtension() string {
+	return ".oci.tar"
+}
+
+// version returns the version of the package, with its prerelease.
+func version(info *nfpm.Info) string {
+	if info.Prerelease != "" {
+		return info.Version + "-" + info.Prerelease
+	}
+	return info.Version
+}
+
+// Package writes the tarball of a new OCI image layout holding the image of
+// the package to the given writer.
+func (*OCI) Package(info *nfpm.Info, w io.Writer) error {
+	info = ensureValidArch(info)
+
+	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
+		return err
+	}
+
+	var base *baseImage
+	if info.OCI.Base != "" {
+		var err error
+		if base, err = readBase(info.OCI.Base, info.Arch); err != nil {
+			return fmt.Errorf("cannot read base image: %w", err)
+		}
+		if info.Arch == "all" {
+			info.Arch = base.config.platform()
+		}
+	}
+	if info.Arch == "all" {
+		return fmt.Errorf("the platform of the image cannot be all, set oci.arch or a base image")
+	}
+
+	mtime := modtime.Get(info.MTime)
+	layer, diffID, err := createLayer(info, mtime)
+	if err != nil {
+		return err
+	}
+	layerDesc := newDescriptor(mediaTypeLayer, layer)
+
+	config := newConfig(info, base, diffID, mtime)
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	configDesc := newDescriptor(mediaTypeConfig, configJSON)
+
+	m := manifest{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeManifest,
+		Config:        configDesc,
+	}
+	if base != nil {
+		m.Layers = append(m.Layers, base.manifest.Layers...)
+	}
+	m.Layers = append(m.Layers, layerDesc)
+	manifestJSON, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	manifestDesc := newDescriptor(mediaTypeManifest, manifestJSON)
+	manifestDesc.Annotations = map[string]string{
+		annotationRefName: cmp.Or(info.OCI.Tag, invalidTagChars.ReplaceAllString(version(info), "_")),
+	}
+	manifestDesc.Platform = &platform{OS: config.OS, Architecture: config.Architecture, Variant: config.Variant}
+
+	indexJSON, err := json.Marshal(index{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeIndex,
+		Manifests:     []descriptor{manifestDesc},
+	})
+	if err != nil {
+		return err
+	}
+
+	lw := newLayoutWriter(w, mtime)
+	if err := lw.writeFile("oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+		return err
+	}
+	if err := lw.writeFile("index.json", indexJSON); err != nil {
+		return err
+	}
+	if base != nil {
+		for _, layer := range base.manifest.Layers {
+			if err := lw.copyBlob(layer.Digest, base.blobPath(layer.Digest)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, blob := range []struct {
+		digest string
+		data   []byte
+	}{
+		{layerDesc.Digest, layer},
+		{configDesc.Digest, configJSON},
+		{manifestDesc.Digest, manifestJSON},
+	} {
+		if err := lw.writeBlob(blob.digest, blob.data); err != nil {
+			return err
+		}
+	}
+	return lw.Close()
+}
+
+// descriptor references a blob of the image layout.
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *platform         `json:"platform,omitempty"`
+}
+
+func newDescriptor(mediaType string, data []byte) descriptor {
+	return descriptor{
+		MediaType: mediaType,
+		Digest:    digest(data),
+		Size:      int64(len(data)),
+	}
+}
+
+type platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+type index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Manifests     []descriptor `json:"manifests"`
+}
+
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
+}
+
+// imageConfig is the configuration of an image, describing how to run it and
+// the layers of its root filesystem.
+type imageConfig struct {
+	Created      string          `json:"created,omitempty"`
+	Author       string          `json:"author,omitempty"`
+	Architecture string          `json:"architecture"`
+	Variant      string          `json:"variant,omitempty"`
+	OS           string          `json:"os"`
+	Config       containerConfig `json:"config"`
+	RootFS       rootFS          `json:"rootfs"`
+	History      []history       `json:"history,omitempty"`
+}
+
+type containerConfig struct {
+	User         string              `json:"User,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	Volumes      map[string]struct{} `json:"Volumes,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	StopSignal   string              `json:"StopSignal,omitempty"`
+}
+
+type rootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+type history struct {
+	Created    string `json:"created,omitempty"`
+	CreatedBy  string `json:"created_by,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	EmptyLayer bool   `json:"empty_layer,omitempty"`
+}
+
+// platform returns the platform of the image, as architecture/variant.
+func (c imageConfig) platform() string {
+	if c.Variant != "" {
+		return c.Architecture + "/" + c.Variant
+	}
+	return c.Architecture
+}
+
+// newConfig returns the configuration of the image, extending the one of the
+// base image, if any, the way a Dockerfile would: the environment and the
+// labels are merged, and setting the entrypoint resets the command.
+func newConfig(info *nfpm.Info, base *baseImage, diffID string, mtime time.Time) imageConfig {
+	created := mtime.UTC().Format(time.RFC3339)
+	config := imageConfig{
+		RootFS: rootFS{Type: "layers"},
+	}
+	if base != nil {
+		config = base.config
+	}
+	config.Created = created
+	config.Author = info.Maintainer
+	config.OS = "linux"
+	config.Architecture, config.Variant, _ = strings.Cut(info.Arch, "/")
+	config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, diffID)
+	config.History = append(config.History, history{
+		Created:   created,
+		CreatedBy: "nfpm",
+		Comment:   info.Name + " " + version(info),
+	})
+
+	c := &config.Config
+	for _, env := range info.OCI.Env {
+		name, _, _ := strings.Cut(env, "=")
+		c.Env = slices.DeleteFunc(c.Env, func(e string) bool {
+			return strings.HasPrefix(e, name+"=")
+		})
+		c.Env = append(c.Env, env)
+	}
+	if len(info.OCI.Entrypoint) > 0 {
+		c.Entrypoint, c.Cmd = info.OCI.Entrypoint, info.OCI.Cmd
+	} else if len(info.OCI.Cmd) > 0 {
+		c.Cmd = info.OCI.Cmd
+	}
+	c.WorkingDir = cmp.Or(info.OCI.WorkingDir, c.WorkingDir)
+	c.User = cmp.Or(info.OCI.User, c.User)
+
+	labels := map[string]string{
+		"org.opencontainers.image.title":       info.Name,
+		"org.opencontainers.image.version":     version(info),
+		"org.opencontainers.image.description": strings.TrimSpace(info.Description),
+		"org.opencontainers.image.licenses":    info.License,
+		"org.opencontainers.image.url":         info.Homepage,
+		"org.opencontainers.image.vendor":      info.Vendor,
+		"org.opencontainers.image.authors":     info.Maintainer,
+	}
+	for key, value := range info.OCI.Labels {
+		labels[key] = value
+	}
+	for key, value := range labels {
+		if value == "" {
+			continue
+		}
+		if c.Labels == nil {
+			c.Labels = map[string]string{}
+		}
+		c.Labels[key] = value
+	}
+	return config
+}
+
+// createLayer returns the layer holding the contents, a gzip compressed
+// tarball, and the digest of the uncompressed tarball, its diff id.
+func createLayer(info *nfpm.Info, mtime time.Time) ([]byte, string, error) {
+	ids := accountIDs(info)
+
+	contents := slices.Clone(info.Contents)
+	slices.SortFunc(contents, func(a, b *files.Content) int {
+		return slices.Compare(
+			strings.Split(strings.Trim(a.Destination, "/"), "/"),
+			strings.Split(strings.Trim(b.Destination, "/"), "/"),
+		)
+	})
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	diffID := sha256.New()
+	tw := tar.NewWriter(io.MultiWriter(gw, diffID))
+	for _, content := range contents {
+		header := &tar.Header{
+			Name:    strings.TrimPrefix(files.NormalizeAbsoluteFilePath(content.Destination), "/"),
+			Mode:    int64(content.Mode() & 0o7777),
+			Uname:   content.FileInfo.Owner,
+			Gname:   content.FileInfo.Group,
+			ModTime: content.ModTime().Truncate(time.Second),
+			Format:  tar.FormatPAX,
+		}
+		if header.ModTime.IsZero() {
+			header.ModTime = mtime
+		}
+		var ok bool
+		if header.Uid, ok = ids.users[header.Uname]; !ok {
+			return nil, "", fmt.Errorf("%s: unknown id of user %s, declare it in users with its uid", content.Destination, header.Uname)
+		}
+		if header.Gid, ok = ids.groups[header.Gname]; !ok {
+			return nil, "", fmt.Errorf("%s: unknown id of group %s, declare it in groups with its gid", content.Destination, header.Gname)
+		}
+
+		var data []byte
+		var err error
+		switch content.Type {
+		case files.TypeDir, files.TypeImplicitDir:
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+		case files.TypeSymlink:
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = content.Source
+			header.Mode = 0o777
+		case files.TypeFile, files.TypeConfig, files.TypeConfigNoReplace, files.TypeConfigMissingOK:
+			header.Typeflag = tar.TypeReg
+			if data, err = os.ReadFile(content.Source); err != nil {
+				return nil, "", err
+			}
+			header.Size = int64(len(data))
+		default:
+			// ignore everything else
+			continue
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, "", fmt.Errorf("cannot write header of %s: %w", header.Name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, "", fmt.Errorf("cannot write %s: %w", header.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, "", err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), fmt.Sprintf("sha256:%x", diffID.Sum(nil)), nil
+}
+
+// ids maps the names of the users and groups to their ids, which the layers
+// record, as the image may not know about them.
+type ids struct {
+	users, groups map[string]int
+}
+
+// accountIDs returns the ids of root, of the users and groups the package
+// declares with their ids, the group of a user having its id, and of the
+// numeric owners of the contents.
+func accountIDs(info *nfpm.Info) ids {
+	result := ids{
+		users:  map[string]int{"root": 0},
+		groups: map[string]int{"root": 0},
+	}
+	for _, user := range info.Users {
+		if user.UID != 0 {
+			result.users[user.Name] = user.UID
+			result.groups[user.Name] = user.UID
+		}
+	}
+	for _, group := range info.Groups {
+		if group.GID != 0 {
+			result.groups[group.Name] = group.GID
+		}
+	}
+	for _, content := range info.Contents {
+		if id, err := strconv.Atoi(content.FileInfo.Owner); err == nil && id >= 0 {
+			result.users[content.FileInfo.Owner] = id
+		}
+		if id, err := strconv.Atoi(content.FileInfo.Group); err == nil && id >= 0 {
+			result.groups[content.FileInfo.Group] = id
+		}
+	}
+	return result
+}
+
+func digest(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
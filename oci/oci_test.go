@@ -0,0 +1,295 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+var mtime = time.Date(2023, 11, 5, 23, 15, 17, 0, time.UTC)
+
+func exampleInfo() *nfpm.Info {
+	return nfpm.WithDefaults(&nfpm.Info{
+		Name:        "foo",
+		Arch:        "arm7",
+		Description: "Foo does things",
+		Maintainer:  "Carlos A Becker <pkg@carlosbecker.com>",
+		Version:     "v1.0.0",
+		Prerelease:  "rc1",
+		License:     "MIT",
+		MTime:       mtime,
+		Overridables: nfpm.Overridables{
+			Contents: []*files.Content{
+				{
+					Source:      "../testdata/fake",
+					Destination: "/usr/bin/fake",
+					FileInfo: &files.ContentFileInfo{
+						Mode: 0o755,
+					},
+				},
+				{
+					Source:      "../testdata/whatever.conf",
+					Destination: "/etc/fake/fake.conf",
+					Type:        files.TypeConfig,
+				},
+				{
+					Source:      "/usr/bin/fake",
+					Destination: "/usr/bin/fake-link",
+					Type:        files.TypeSymlink,
+				},
+				{
+					Destination: "/var/lib/foo",
+					Type:        files.TypeDir,
+					FileInfo: &files.ContentFileInfo{
+						Owner: "foo",
+						Group: "foo",
+						Mode:  0o750,
+					},
+				},
+			},
+			Users: []nfpm.User{
+				{Name: "foo", UID: 1234},
+			},
+			OCI: nfpm.OCI{
+				Entrypoint: []string{"/usr/bin/fake"},
+				Cmd:        []string{"--help"},
+				Env:        []string{"FOO=bar"},
+				User:       "foo",
+				Labels: map[string]string{
+					"org.opencontainers.image.licenses": "Apache-2.0",
+				},
+			},
+		},
+	})
+}
+
+// image is an image read back from the tarball of its layout.
+type image struct {
+	files    map[string][]byte
+	index    index
+	manifest manifest
+	config   imageConfig
+}
+
+func readImage(t *testing.T, layout []byte) image {
+	t.Helper()
+	img := image{files: map[string][]byte{}}
+	tr := tar.NewReader(bytes.NewReader(layout))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, mtime.Unix(), hdr.ModTime.Unix())
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		img.files[hdr.Name] = data
+	}
+
+	blob := func(desc descriptor) []byte {
+		data, ok := img.files["blobs/sha256/"+desc.Digest[len("sha256:"):]]
+		require.True(t, ok, desc.Digest)
+		require.Equal(t, digest(data), desc.Digest)
+		require.Equal(t, desc.Size, int64(len(data)))
+		return data
+	}
+	require.JSONEq(t, `{"imageLayoutVersion":"1.0.0"}`, string(img.files["oci-layout"]))
+	require.NoError(t, json.Unmarshal(img.files["index.json"], &img.index))
+	require.Len(t, img.index.Manifests, 1)
+	require.NoError(t, json.Unmarshal(blob(img.index.Manifests[0]), &img.manifest))
+	require.NoError(t, json.Unmarshal(blob(img.manifest.Config), &img.config))
+	for _, layer := range img.manifest.Layers {
+		blob(layer)
+	}
+	return img
+}
+
+// readLayer returns the entries of a layer of the image, and its diff id.
+func readLayer(t *testing.T, img image, i int) ([]*tar.Header, map[string][]byte, string) {
+	t.Helper()
+	desc := img.manifest.Layers[i]
+	gr, err := gzip.NewReader(bytes.NewReader(img.files["blobs/sha256/"+desc.Digest[len("sha256:"):]]))
+	require.NoError(t, err)
+	data, err := io.ReadAll(gr)
+	require.NoError(t, err)
+
+	var headers []*tar.Header
+	contents := map[string][]byte{}
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		headers = append(headers, hdr)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		contents[hdr.Name] = content
+	}
+	return headers, contents, fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+func TestConventionalFileName(t *testing.T) {
+	for arch, expected := range map[string]string{
+		"amd64":   "foo-1.0.0-rc1-amd64.oci.tar",
+		"aarch64": "foo-1.0.0-rc1-arm64.oci.tar",
+		"arm7":    "foo-1.0.0-rc1-armv7.oci.tar",
+	} {
+		t.Run(arch, func(t *testing.T) {
+			info := exampleInfo()
+			info.Arch = arch
+			require.Equal(t, expected, Default.ConventionalFileName(info))
+		})
+	}
+}
+
+func TestPackage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(exampleInfo(), &buf))
+	img := readImage(t, buf.Bytes())
+
+	desc := img.index.Manifests[0]
+	require.Equal(t, mediaTypeManifest, desc.MediaType)
+	require.Equal(t, "1.0.0-rc1", desc.Annotations[annotationRefName])
+	require.Equal(t, &platform{OS: "linux", Architecture: "arm", Variant: "v7"}, desc.Platform)
+
+	require.Equal(t, mediaTypeConfig, img.manifest.Config.MediaType)
+	require.Len(t, img.manifest.Layers, 1)
+	require.Equal(t, mediaTypeLayer, img.manifest.Layers[0].MediaType)
+
+	config := img.config
+	require.Equal(t, "2023-11-05T23:15:17Z", config.Created)
+	require.Equal(t, "arm", config.Architecture)
+	require.Equal(t, "v7", config.Variant)
+	require.Equal(t, "linux", config.OS)
+	require.Equal(t, []string{"/usr/bin/fake"}, config.Config.Entrypoint)
+	require.Equal(t, []string{"--help"}, config.Config.Cmd)
+	require.Equal(t, []string{"FOO=bar"}, config.Config.Env)
+	require.Equal(t, "foo", config.Config.User)
+	require.Equal(t, map[string]string{
+		"org.opencontainers.image.title":       "foo",
+		"org.opencontainers.image.version":     "1.0.0-rc1",
+		"org.opencontainers.image.description": "Foo does things",
+		"org.opencontainers.image.licenses":    "Apache-2.0",
+		"org.opencontainers.image.authors":     "Carlos A Becker <pkg@carlosbecker.com>",
+	}, config.Config.Labels)
+	require.Len(t, config.History, 1)
+	require.Equal(t, "foo 1.0.0-rc1", config.History[0].Comment)
+
+	headers, contents, diffID := readLayer(t, img, 0)
+	require.Equal(t, []string{diffID}, config.RootFS.DiffIDs)
+
+	fake, err := os.ReadFile("../testdata/fake")
+	require.NoError(t, err)
+	var names []string
+	for _, hdr := range headers {
+		names = append(names, hdr.Name)
+		require.Equal(t, mtime.Unix(), hdr.ModTime.Unix(), hdr.Name)
+		switch hdr.Name {
+		case "usr/bin/fake":
+			require.Equal(t, int64(0o755), hdr.Mode)
+			require.Equal(t, fake, contents[hdr.Name])
+		case "usr/bin/fake-link":
+			require.Equal(t, byte(tar.TypeSymlink), hdr.Typeflag)
+			require.Equal(t, "/usr/bin/fake", hdr.Linkname)
+		case "var/lib/foo/":
+			require.Equal(t, byte(tar.TypeDir), hdr.Typeflag)
+			require.Equal(t, int64(0o750), hdr.Mode)
+			require.Equal(t, 1234, hdr.Uid)
+			require.Equal(t, 1234, hdr.Gid)
+		default:
+			require.Equal(t, 0, hdr.Uid, hdr.Name)
+		}
+	}
+	require.Equal(t, []string{
+		"etc/",
+		"etc/fake/",
+		"etc/fake/fake.conf",
+		"usr/",
+		"usr/bin/",
+		"usr/bin/fake",
+		"usr/bin/fake-link",
+		"var/",
+		"var/lib/",
+		"var/lib/foo/",
+	}, names)
+}
+
+func TestPackageReproducible(t *testing.T) {
+	var a, b bytes.Buffer
+	require.NoError(t, Default.Package(exampleInfo(), &a))
+	require.NoError(t, Default.Package(exampleInfo(), &b))
+	require.Equal(t, a.Bytes(), b.Bytes())
+}
+
+func TestPackageTag(t *testing.T) {
+	info := exampleInfo()
+	info.Version = "1.0.0+build"
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	require.Equal(t, "1.0.0_build-rc1", readImage(t, buf.Bytes()).index.Manifests[0].Annotations[annotationRefName])
+
+	info = exampleInfo()
+	info.OCI.Tag = "latest"
+	buf.Reset()
+	require.NoError(t, Default.Package(info, &buf))
+	require.Equal(t, "latest", readImage(t, buf.Bytes()).index.Manifests[0].Annotations[annotationRefName])
+}
+
+func TestPackageUnknownOwner(t *testing.T) {
+	info := exampleInfo()
+	info.Users = nil
+	err := Default.Package(info, io.Discard)
+	require.EqualError(t, err, "/var/lib/foo/: unknown id of user foo, declare it in users with its uid")
+}
+
+func TestPackageNumericOwner(t *testing.T) {
+	info := exampleInfo()
+	info.Users = nil
+	info.Contents[3].FileInfo.Owner = "1000"
+	info.Contents[3].FileInfo.Group = "100"
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+	headers, _, _ := readLayer(t, readImage(t, buf.Bytes()), 0)
+	require.Equal(t, "var/lib/foo/", headers[len(headers)-1].Name)
+	require.Equal(t, 1000, headers[len(headers)-1].Uid)
+	require.Equal(t, 100, headers[len(headers)-1].Gid)
+}
+
+func TestPackageAllWithoutBase(t *testing.T) {
+	info := exampleInfo()
+	info.Arch = "all"
+	err := Default.Package(info, io.Discard)
+	require.EqualError(t, err, "the platform of the image cannot be all, set oci.arch or a base image")
+}
+
+// writeLayout extracts the tarball of an image layout into a directory.
+func writeLayout(t *testing.T, layout []byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	img := readImage(t, layout)
+	for name, data := range img.files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+	}
+	return dir
+}
@@ -0,0 +1,130 @@
+package nfpm
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// nolint: gochecknoglobals
+var readers = map[string]Reader{}
+
+// Reader represents any package reader implementation. It is the counterpart
+// of a Packager: it decodes an existing package back into the metadata and
+// contents nfpm would have used to create it.
+type Reader interface {
+	Read(r io.Reader) (*Inspection, error)
+}
+
+// Inspection is the decoded representation of an existing package.
+type Inspection struct {
+	// Format is the packager format the package was read as, e.g. deb.
+	Format string `json:"format"`
+	// Info holds the package metadata and its contents. Content sources are
+	// only set for symlinks, in which case they hold the link target.
+	Info *Info `json:"info"`
+	// Scripts maps the nfpm script name (e.g. preinstall, postupgrade) to the
+	// body of the script found in the package.
+	Scripts map[string]string `json:"scripts,omitempty"`
+	// Signatures lists the signatures embedded in the package.
+	Signatures []SignatureInfo `json:"signatures,omitempty"`
+}
+
+// SignatureInfo describes a signature embedded in a package.
+type SignatureInfo struct {
+	// Type is the signature scheme, e.g. debsign, dpkg-sig, pgp or rsa.
+	Type string `json:"type"`
+	// Name is the format-specific name of the signature, e.g. the debsign
+	// role or the apk key name.
+	Name string `json:"name,omitempty"`
+	// KeyID is the hexadecimal ID of the signing key, when it can be
+	// determined from the signature alone.
+	KeyID string `json:"key_id,omitempty"`
+}
+
+// RegisterReader registers a new reader for the given format.
+func RegisterReader(format string, r Reader) {
+	lock.Lock()
+	defer lock.Unlock()
+	readers[format] = r
+}
+
+// EnumerateReaders lists the formats that have a registered reader.
+func EnumerateReaders() []string {
+	lock.Lock()
+	defer lock.Unlock()
+
+	list := make([]string, 0, len(readers))
+	for key := range readers {
+		list = append(list, key)
+	}
+
+	sort.Strings(list)
+	return list
+}
+
+// ErrNoReader happens when no reader is registered for the given format.
+type ErrNoReader struct {
+	format string
+}
+
+func (e ErrNoReader) Error() string {
+	return fmt.Sprintf("no reader registered for the format %s", e.format)
+}
+
+// GetReader gets a reader for the given format.
+func GetReader(format string) (Reader, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	r, ok := readers[format]
+	if !ok {
+		return nil, ErrNoReader{format}
+	}
+	return r, nil
+}
+
+// ErrUnknownFormat happens when the format of a package file cannot be
+// guessed from its name.
+type ErrUnknownFormat struct {
+	name string
+}
+
+func (e ErrUnknownFormat) Error() string {
+	return fmt.Sprintf("could not guess the package format of %s", e.name)
+}
+
+// FormatFromFileName guesses the packager format of the given file name based
+// on the conventional extensions of the registered packagers. The longest
+// matching extension wins, so foo.src.rpm is detected as srpm and not rpm.
+func FormatFromFileName(name string) (string, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	base := strings.ToLower(filepath.Base(name))
+	formats := make([]string, 0, len(packagers))
+	for format := range packagers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+
+	var (
+		found string
+		ext   string
+	)
+	for _, format := range formats {
+		p, ok := packagers[format].(PackagerWithExtension)
+		if !ok {
+			continue
+		}
+		candidate := p.ConventionalExtension()
+		if strings.HasSuffix(base, candidate) && len(candidate) > len(ext) {
+			found, ext = format, candidate
+		}
+	}
+	if found == "" {
+		return "", ErrUnknownFormat{name}
+	}
+	return found, nil
+}
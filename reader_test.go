@@ -0,0 +1,53 @@
+package nfpm_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetReader(t *testing.T) {
+	format := "TestGetReader"
+	got, err := nfpm.GetReader(format)
+	require.EqualError(t, err, "no reader registered for the format "+format)
+	require.Nil(t, got)
+
+	reader := &fakeReader{}
+	nfpm.RegisterReader(format, reader)
+	got, err = nfpm.GetReader(format)
+	require.NoError(t, err)
+	require.Equal(t, reader, got)
+}
+
+func TestFormatFromFileName(t *testing.T) {
+	nfpm.RegisterPackager("TestFormatFromFileName", &fakeExtPackager{ext: ".fake"})
+	nfpm.RegisterPackager("TestFormatFromFileNameSource", &fakeExtPackager{ext: ".src.fake"})
+
+	format, err := nfpm.FormatFromFileName("dist/foo_1.0.0_amd64.fake")
+	require.NoError(t, err)
+	require.Equal(t, "TestFormatFromFileName", format)
+
+	format, err = nfpm.FormatFromFileName("dist/FOO-1.0.0.SRC.FAKE")
+	require.NoError(t, err)
+	require.Equal(t, "TestFormatFromFileNameSource", format)
+
+	_, err = nfpm.FormatFromFileName("foo.unknown")
+	require.EqualError(t, err, "could not guess the package format of foo.unknown")
+}
+
+type fakeReader struct{}
+
+func (*fakeReader) Read(_ io.Reader) (*nfpm.Inspection, error) {
+	return &nfpm.Inspection{}, nil
+}
+
+type fakeExtPackager struct {
+	fakePackager
+	ext string
+}
+
+func (p *fakeExtPackager) ConventionalExtension() string {
+	return p.ext
+}
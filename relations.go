@@ -0,0 +1,415 @@
+package nfpm
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"go.yaml.in/yaml/v3"
+)
+
+// Relations is a list of relationships to other packages, e.g. the
+// dependencies of a package.
+//
+// In the configuration file each relation is either a string or a structured
+// Relation, which is stored in the Debian syntax, e.g. foo (>= 1.2) | bar.
+// Relations in the Debian syntax are rendered by each packager in the syntax
+// of its format, any other string is passed to the package unchanged.
+type Relations []string
+
+// Relation is a structured relationship to another package.
+type Relation struct {
+	Name    string `yaml:"name" json:"name"`
+	Op      string `yaml:"op,omitempty" json:"op,omitempty"`
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+	// Architectures the relation applies to, in the nomenclature of the arch
+	// field, e.g. amd64, or, prefixed with a !, the ones it doesn't apply to.
+	// Applies to every architecture if empty.
+	Arch []string `yaml:"arch,omitempty" json:"arch,omitempty"`
+	// Alternatives that satisfy the relation as well, e.g. foo | bar.
+	Or []Relation `yaml:"or,omitempty" json:"or,omitempty"`
+}
+
+// relationOps are the version comparison operators of a relation.
+// nolint: gochecknoglobals
+var relationOps = []string{"<", "<=", "=", ">=", ">"}
+
+// relationName matches the names of packages, which may be prefixed with their
+// category or origin for gpkg and freebsd, e.g. dev-libs/foo.
+const relationName = `[A-Za-z0-9][A-Za-z0-9+._:/-]*`
+
+// nolint: gochecknoglobals
+var (
+	relationNameRegexp = regexp.MustCompile(`^` + relationName + `$`)
+	relationRegexp     = regexp.MustCompile(
+		`^(` + relationName + `)` +
+			`(?:\s*\(\s*(<<|<=|=|>=|>>|<|>)\s*([^\s()]+)\s*\))?` + // (op version)
+			`(?:\s*\[\s*([^\[\]]+?)\s*\])?$`, // [arch ...]
+	)
+)
+
+// UnmarshalYAML implements yaml.Unmarshaler, accepting both strings and
+// structured relations as the items of the list.
+func (r *Relations) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: relations must be a list", node.Line)
+	}
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			var s string
+			if err := item.Decode(&s); err != nil {
+				return err
+			}
+			*r = append(*r, s)
+			continue
+		}
+		if err := checkRelationFields(item, true); err != nil {
+			return err
+		}
+		var relation Relation
+		if err := item.Decode(&relation); err != nil {
+			return err
+		}
+		if err := relation.validate(); err != nil {
+			return fmt.Errorf("line %d: %w", item.Line, err)
+		}
+		*r = append(*r, relation.String())
+	}
+	return nil
+}
+
+// checkRelationFields rejects unknown fields, as the decoder of the
+// configuration file does.
+func checkRelationFields(node *yaml.Node, alternatives bool) error {
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+		switch key.Value {
+		case "name", "op", "version", "arch":
+		case "or":
+			if !alternatives {
+				return fmt.Errorf("line %d: alternatives can't have alternatives", key.Line)
+			}
+			for _, alt := range node.Content[i+1].Content {
+				if err := checkRelationFields(alt, false); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("line %d: field %s not found in type nfpm.Relation", key.Line, key.Value)
+		}
+	}
+	return nil
+}
+
+// JSONSchemaExtend implements jsonschema.Extender.
+func (Relations) JSONSchemaExtend(schema *jsonschema.Schema) {
+	schema.Items = &jsonschema.Schema{
+		OneOf: []*jsonschema.Schema{
+			{Type: "string"},
+			relationSchema(true),
+		},
+	}
+}
+
+func relationSchema(alternatives bool) *jsonschema.Schema {
+	ops := make([]any, 0, len(relationOps))
+	for _, op := range relationOps {
+		ops = append(ops, op)
+	}
+	props := jsonschema.NewProperties()
+	props.Set("name", &jsonschema.Schema{Type: "string", Title: "package name"})
+	props.Set("op", &jsonschema.Schema{Type: "string", Title: "version comparison operator", Enum: ops})
+	props.Set("version", &jsonschema.Schema{Type: "string", Title: "version to compare with"})
+	props.Set("arch", &jsonschema.Schema{
+		Type:  "array",
+		Items: &jsonschema.Schema{Type: "string"},
+		Title: "architectures the relation applies to, defaults to all",
+	})
+	if alternatives {
+		props.Set("or", &jsonschema.Schema{
+			Type:  "array",
+			Items: relationSchema(false),
+			Title: "alternatives that satisfy the relation as well",
+		})
+	}
+	return &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           props,
+		AdditionalProperties: jsonschema.FalseSchema,
+		Required:             []string{"name"},
+	}
+}
+
+func (r Relation) validate() error {
+	if r.Name == "" {
+		return ErrFieldEmpty{"relation name"}
+	}
+	if !relationNameRegexp.MatchString(r.Name) {
+		return fmt.Errorf("invalid relation name: %q", r.Name)
+	}
+	if r.Op != "" && r.Version == "" {
+		return fmt.Errorf("relation %s: op requires a version", r.Name)
+	}
+	if r.Op != "" && !slices.Contains(relationOps, r.Op) {
+		return fmt.Errorf("relation %s: invalid op %q, must be one of %s", r.Name, r.Op, strings.Join(relationOps, " "))
+	}
+	if strings.ContainsAny(r.Version, " ()[]|") {
+		return fmt.Errorf("relation %s: invalid version: %q", r.Name, r.Version)
+	}
+	for _, alt := range r.Or {
+		if len(alt.Or) > 0 {
+			return fmt.Errorf("relation %s: alternatives can't have alternatives", r.Name)
+		}
+		if err := alt.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the relation in the Debian syntax, which is how relations are
+// stored in Relations.
+func (r Relation) String() string {
+	alts := make([]string, 0, len(r.Or)+1)
+	for _, alt := range append([]Relation{r}, r.Or...) {
+		s := alt.Name
+		if alt.Version != "" {
+			op := alt.Op
+			switch op {
+			case "":
+				op = "="
+			case "<":
+				op = "<<"
+			case ">":
+				op = ">>"
+			}
+			s += fmt.Sprintf(" (%s %s)", op, alt.Version)
+		}
+		if len(alt.Arch) > 0 {
+			s += " [" + strings.Join(alt.Arch, " ") + "]"
+		}
+		alts = append(alts, s)
+	}
+	return strings.Join(alts, " | ")
+}
+
+// parseRelation parses a relation in the Debian syntax. It returns false if
+// the string is not in that syntax.
+func parseRelation(s string) (Relation, bool) {
+	var relation Relation
+	for i, alt := range strings.Split(s, "|") {
+		m := relationRegexp.FindStringSubmatch(strings.TrimSpace(alt))
+		if m == nil {
+			return Relation{}, false
+		}
+		op := m[2]
+		switch op {
+		case "<<":
+			op = "<"
+		case ">>":
+			op = ">"
+		case "<":
+			// obsolete Debian syntax, meaning <=.
+			op = "<="
+		case ">":
+			op = ">="
+		}
+		r := Relation{
+			Name:    m[1],
+			Op:      op,
+			Version: m[3],
+			Arch:    strings.Fields(m[4]),
+		}
+		if i == 0 {
+			relation = r
+		} else {
+			relation.Or = append(relation.Or, r)
+		}
+	}
+	return relation, true
+}
+
+// filterArch removes the alternatives that don't apply to the architecture,
+// returning false if none is left.
+func (r Relation) filterArch(matchArch func(arch string) bool) (Relation, bool) {
+	var alts []Relation
+	for _, alt := range append([]Relation{r}, r.Or...) {
+		if appliesToArch(alt.Arch, matchArch) {
+			alt.Arch = nil
+			alts = append(alts, alt)
+		}
+	}
+	if len(alts) == 0 {
+		return Relation{}, false
+	}
+	relation := alts[0]
+	relation.Or = alts[1:]
+	return relation, true
+}
+
+// appliesToArch reports whether a relation restricted to the given
+// architectures applies to the architecture. As in Debian, architectures
+// prefixed with a ! exclude the relation from them instead.
+func appliesToArch(arches []string, matchArch func(arch string) bool) bool {
+	if len(arches) == 0 {
+		return true
+	}
+	for _, arch := range arches {
+		if excluded, ok := strings.CutPrefix(arch, "!"); ok {
+			if matchArch(excluded) {
+				return false
+			}
+			continue
+		}
+		if matchArch(arch) {
+			return true
+		}
+	}
+	return strings.HasPrefix(arches[0], "!")
+}
+
+// format renders the relation in the syntax of the given packager.
+func (r Relation) format(packager string) (string, error) {
+	alts := append([]Relation{r}, r.Or...)
+	switch packager {
+	case "deb", "ipk":
+		return r.String(), nil
+	case "rpm":
+		items := make([]string, 0, len(alts))
+		for _, alt := range alts {
+			items = append(items, strings.TrimSpace(alt.Name+" "+alt.Op+" "+alt.Version))
+		}
+		if len(items) == 1 {
+			return items[0], nil
+		}
+		// a rich dependency, supported since rpm 4.13.
+		return "(" + strings.Join(items, " or ") + ")", nil
+	case "apk", "archlinux", "freebsd":
+		if len(r.Or) > 0 {
+			return "", fmt.Errorf("%s does not support alternative relations: %s", packager, r)
+		}
+		return r.Name + r.Op + r.Version, nil
+	case "nupkg":
+		if len(r.Or) > 0 {
+			return "", fmt.Errorf("%s does not support alternative relations: %s", packager, r)
+		}
+		// the id and the version range of a NuGet dependency, a bare version
+		// being the minimum one.
+		switch r.Op {
+		case "":
+			return r.Name, nil
+		case ">=":
+			return r.Name + " " + r.Version, nil
+		case ">":
+			return r.Name + " (" + r.Version + ",)", nil
+		case "=":
+			return r.Name + " [" + r.Version + "]", nil
+		case "<=":
+			return r.Name + " (," + r.Version + "]", nil
+		default:
+			return r.Name + " (," + r.Version + ")", nil
+		}
+	case "conda":
+		if len(r.Or) > 0 {
+			return "", fmt.Errorf("%s does not support alternative relations: %s", packager, r)
+		}
+		// a match spec, in which = matches the versions starting with the
+		// given one.
+		switch r.Op {
+		case "":
+			return r.Name, nil
+		case "=":
+			return r.Name + " ==" + r.Version, nil
+		default:
+			return r.Name + " " + r.Op + r.Version, nil
+		}
+	case "gpkg":
+		// a package dependency specification, alternatives being an any-of
+		// group.
+		items := make([]string, 0, len(alts))
+		for _, alt := range alts {
+			if alt.Op == "" {
+				items = append(items, alt.Name)
+				continue
+			}
+			items = append(items, alt.Op+alt.Name+"-"+alt.Version)
+		}
+		if len(items) == 1 {
+			return items[0], nil
+		}
+		return "|| ( " + strings.Join(items, " ") + " )", nil
+	default:
+		return "", fmt.Errorf("relations are not supported by %s", packager)
+	}
+}
+
+// FormatRelations renders the relations in the Debian syntax in the syntax of
+// the given packager, one of deb, ipk, rpm, apk, archlinux, freebsd, nupkg,
+// conda or gpkg, and removes the ones that don't apply to the architecture. Any other
+// relation is returned unchanged.
+func FormatRelations(relations []string, packager string, matchArch func(arch string) bool) (Relations, error) {
+	var result Relations
+	for _, s := range relations {
+		relation, ok := parseRelation(s)
+		if !ok {
+			result = append(result, s)
+			continue
+		}
+		if relation, ok = relation.filterArch(matchArch); !ok {
+			continue
+		}
+		formatted, err := relation.format(packager)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, formatted)
+	}
+	return result, nil
+}
+
+// PrepareRelations renders all the relations of the given info, e.g.
+// its dependencies, with FormatRelations. Alternatives are only allowed in
+// the dependencies, recommendations and suggestions.
+func PrepareRelations(info *Info, packager string, matchArch func(arch string) bool) error {
+	type field struct {
+		name         string
+		relations    *Relations
+		alternatives bool
+	}
+	fields := []field{
+		{"replaces", &info.Replaces, false},
+		{"provides", &info.Provides, false},
+		{"depends", &info.Depends, true},
+		{"recommends", &info.Recommends, true},
+		{"suggests", &info.Suggests, true},
+		{"conflicts", &info.Conflicts, false},
+	}
+	switch packager {
+	case "deb":
+		fields = append(fields,
+			field{"deb.breaks", &info.Deb.Breaks, false},
+			field{"deb.predepends", &info.Deb.Predepends, true},
+		)
+	case "ipk":
+		fields = append(fields, field{"ipk.predepends", &info.IPK.Predepends, true})
+	case "rpm":
+		fields = append(fields, field{"rpm.requires.post", &info.RPM.Requires.Post, false})
+	}
+	for _, f := range fields {
+		if !f.alternatives {
+			for _, s := range *f.relations {
+				if relation, ok := parseRelation(s); ok && len(relation.Or) > 0 {
+					return fmt.Errorf("%s does not allow alternative relations: %s", f.name, s)
+				}
+			}
+		}
+		formatted, err := FormatRelations(*f.relations, packager, matchArch)
+		if err != nil {
+			return err
+		}
+		*f.relations = formatted
+	}
+	return nil
+}
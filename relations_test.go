@@ -0,0 +1,155 @@
+package nfpm_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRelations(t *testing.T) {
+	config, err := nfpm.Parse(strings.NewReader(`
+name: foo
+depends:
+  - bash
+  - name: libfoo
+    op: ">="
+    version: "1.2"
+  - name: mawk
+    or:
+      - name: gawk
+        op: "<"
+        version: "5"
+  - name: libbar
+    version: "2.0"
+    arch: [amd64, arm64]
+`))
+	require.NoError(t, err)
+	require.Equal(t, nfpm.Relations{
+		"bash",
+		"libfoo (>= 1.2)",
+		"mawk | gawk (<< 5)",
+		"libbar (= 2.0) [amd64 arm64]",
+	}, config.Depends)
+}
+
+func TestParseRelationsErrors(t *testing.T) {
+	for name, yaml := range map[string]string{
+		"unknown field":   "depends:\n  - {name: foo, nope: 1}",
+		"no name":         "depends:\n  - {version: '1.0'}",
+		"invalid name":    "depends:\n  - {name: 'foo (>= 1)'}",
+		"invalid op":      "depends:\n  - {name: foo, op: '~', version: '1.0'}",
+		"op no version":   "depends:\n  - {name: foo, op: '>='}",
+		"nested or":       "depends:\n  - {name: foo, or: [{name: bar, or: [{name: baz}]}]}",
+		"not a list":      "depends: foo",
+		"invalid version": "depends:\n  - {name: foo, version: '1 | bar'}",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := nfpm.Parse(strings.NewReader(yaml))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestFormatRelations(t *testing.T) {
+	relations := []string{
+		"bash",
+		"libfoo (>= 1.2)",
+		"mawk | gawk (<< 5)",
+		"libbar (= 2.0) [amd64 arm64]",
+		"libbaz [!amd64]",
+		"/bin/sh",
+		"foo >= 1.0",
+	}
+	matchArch := func(arch string) bool { return arch == "amd64" }
+
+	for packager, expected := range map[string]nfpm.Relations{
+		"deb": {"bash", "libfoo (>= 1.2)", "mawk | gawk (<< 5)", "libbar (= 2.0)", "/bin/sh", "foo >= 1.0"},
+		"ipk": {"bash", "libfoo (>= 1.2)", "mawk | gawk (<< 5)", "libbar (= 2.0)", "/bin/sh", "foo >= 1.0"},
+		"rpm": {"bash", "libfoo >= 1.2", "(mawk or gawk < 5)", "libbar = 2.0", "/bin/sh", "foo >= 1.0"},
+	} {
+		t.Run(packager, func(t *testing.T) {
+			got, err := nfpm.FormatRelations(relations, packager, matchArch)
+			require.NoError(t, err)
+			require.Equal(t, expected, got)
+		})
+	}
+
+	for _, packager := range []string{"apk", "archlinux", "freebsd"} {
+		t.Run(packager, func(t *testing.T) {
+			_, err := nfpm.FormatRelations(relations, packager, matchArch)
+			require.ErrorContains(t, err, "does not support alternative relations")
+
+			got, err := nfpm.FormatRelations([]string{"libfoo (>= 1.2)", "libbaz [!arm64]", "bar (<< 2)"}, packager, matchArch)
+			require.NoError(t, err)
+			require.Equal(t, nfpm.Relations{"libfoo>=1.2", "libbaz", "bar<2"}, got)
+		})
+	}
+
+	t.Run("nupkg", func(t *testing.T) {
+		_, err := nfpm.FormatRelations(relations, "nupkg", matchArch)
+		require.ErrorContains(t, err, "does not support alternative relations")
+
+		got, err := nfpm.FormatRelations([]string{
+			"foo", "libfoo (>= 1.2)", "bar (<< 2)", "baz (= 1.0)", "qux (<= 3)", "quux (>> 4)", "corge [1.0,2.0)",
+		}, "nupkg", matchArch)
+		require.NoError(t, err)
+		require.Equal(t, nfpm.Relations{"foo", "libfoo 1.2", "bar (,2)", "baz [1.0]", "qux (,3]", "quux (4,)", "corge [1.0,2.0)"}, got)
+	})
+
+	t.Run("conda", func(t *testing.T) {
+		_, err := nfpm.FormatRelations(relations, "conda", matchArch)
+		require.ErrorContains(t, err, "does not support alternative relations")
+
+		got, err := nfpm.FormatRelations([]string{
+			"foo", "libfoo (>= 1.2)", "bar (<< 2)", "baz (= 1.0)", "python >=3.8,<4",
+		}, "conda", matchArch)
+		require.NoError(t, err)
+		require.Equal(t, nfpm.Relations{"foo", "libfoo >=1.2", "bar <2", "baz ==1.0", "python >=3.8,<4"}, got)
+	})
+
+	t.Run("gpkg", func(t *testing.T) {
+		got, err := nfpm.FormatRelations([]string{
+			"dev-libs/foo", "dev-libs/libfoo (>= 1.2)", "bar (<< 2)", "baz (= 1.0)", "qux | quux (>> 4)", "dev-libs/corge:2",
+		}, "gpkg", matchArch)
+		require.NoError(t, err)
+		require.Equal(t, nfpm.Relations{"dev-libs/foo", ">=dev-libs/libfoo-1.2", "<bar-2", "=baz-1.0", "|| ( qux >quux-4 )", "dev-libs/corge:2"}, got)
+	})
+
+	_, err := nfpm.FormatRelations(relations, "msix", matchArch)
+	require.Error(t, err)
+}
+
+func TestFormatRelationsArch(t *testing.T) {
+	got, err := nfpm.FormatRelations([]string{
+		"foo [arm64] | bar [amd64]",
+		"baz [arm64]",
+	}, "deb", func(arch string) bool { return arch == "amd64" })
+	require.NoError(t, err)
+	require.Equal(t, nfpm.Relations{"bar"}, got)
+}
+
+func TestPrepareRelations(t *testing.T) {
+	info := &nfpm.Info{
+		Overridables: nfpm.Overridables{
+			Depends: []string{"foo (>= 1.0)"},
+			Deb: nfpm.Deb{
+				Breaks: []string{"bar | baz"},
+			},
+			RPM: nfpm.RPM{
+				Requires: nfpm.RPMRequires{
+					Post: []string{"qux (<< 2)"},
+				},
+			},
+		},
+	}
+	matchArch := func(string) bool { return true }
+	require.NoError(t, nfpm.PrepareRelations(info, "rpm", matchArch))
+	require.Equal(t, nfpm.Relations{"foo >= 1.0"}, info.Depends)
+	require.Equal(t, nfpm.Relations{"qux < 2"}, info.RPM.Requires.Post)
+	// deb specific relations are left alone by other packagers.
+	require.Equal(t, nfpm.Relations{"bar | baz"}, info.Deb.Breaks)
+	require.NoError(t, nfpm.PrepareRelations(info, "apk", matchArch))
+	require.ErrorContains(t, nfpm.PrepareRelations(info, "deb", matchArch), "deb.breaks does not allow alternative relations")
+}
@@ -0,0 +1,292 @@
+package rpm
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/sassoftware/go-rpmutils"
+)
+
+// header tags not defined by go-rpmutils.
+// https://github.com/rpm-software-management/rpm/blob/master/include/rpm/rpmtag.h
+const (
+	tagPretrans       = 1151
+	tagPosttrans      = 1152
+	tagRecommendName  = 5046
+	tagRecommendVer   = 5047
+	tagRecommendFlags = 5048
+	tagSuggestName    = 5049
+	tagSuggestVer     = 5050
+	tagSuggestFlags   = 5051
+	tagPrefixes       = 1098
+	tagFileLangs      = 1097
+)
+
+// rpmSenseRPMLib marks the rpmlib() requirements added by rpm itself.
+const rpmSenseRPMLib = 1 << 24
+
+// file type bits of the rpm file modes.
+const (
+	modeTypeMask = 0o170000
+	modeDir      = 0o040000
+	modeSymlink  = 0o120000
+)
+
+// scriptTags maps the rpm script tags to the nfpm script names.
+// nolint: gochecknoglobals
+var scriptTags = []struct {
+	tag  int
+	name string
+}{
+	{tagPretrans, "pretrans"},
+	{rpmutils.PREIN, "preinstall"},
+	{rpmutils.POSTIN, "postinstall"},
+	{rpmutils.PREUN, "preremove"},
+	{rpmutils.POSTUN, "postremove"},
+	{tagPosttrans, "posttrans"},
+	{rpmutils.VERIFYSCRIPT, "verify"},
+}
+
+// Read implements nfpm.Reader.
+func (r *RPM) Read(rd io.Reader) (*nfpm.Inspection, error) {
+	pkg, err := rpmutils.ReadRpm(rd)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+	hdr := pkg.Header
+
+	result := &nfpm.Inspection{
+		Format: r.format.String(),
+		Info:   infoFromHeader(hdr),
+	}
+
+	for _, script := range scriptTags {
+		body := headerString(hdr, script.tag)
+		if body == "" {
+			continue
+		}
+		if result.Scripts == nil {
+			result.Scripts = map[string]string{}
+		}
+		result.Scripts[script.name] = body
+	}
+
+	for _, sig := range []struct {
+		tag     int
+		sigType string
+	}{
+		{rpmutils.SIG_PGP, "pgp"},
+		{rpmutils.SIG_RSA, "rsa"},
+	} {
+		body, err := hdr.GetBytes(sig.tag)
+		if err != nil || len(body) == 0 {
+			continue
+		}
+		keyID, _ := sign.PGPSignatureKeyID(body)
+		result.Signatures = append(result.Signatures, nfpm.SignatureInfo{
+			Type:  sig.sigType,
+			KeyID: keyID,
+		})
+	}
+
+	contents, err := contentsFromHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+	result.Info.Contents = contents
+	return result, nil
+}
+
+func headerString(hdr *rpmutils.RpmHeader, tag int) string {
+	values, err := hdr.GetStrings(tag)
+	if err != nil || len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func headerStrings(hdr *rpmutils.RpmHeader, tag int) []string {
+	values, err := hdr.GetStrings(tag)
+	if err != nil {
+		return nil
+	}
+	return values
+}
+
+func infoFromHeader(hdr *rpmutils.RpmHeader) *nfpm.Info {
+	info := &nfpm.Info{
+		Name:        headerString(hdr, rpmutils.NAME),
+		Version:     headerString(hdr, rpmutils.VERSION),
+		Release:     headerString(hdr, rpmutils.RELEASE),
+		Platform:    headerString(hdr, rpmutils.OS),
+		Arch:        headerString(hdr, rpmutils.ARCH),
+		Description: headerString(hdr, rpmutils.DESCRIPTION),
+		Vendor:      headerString(hdr, rpmutils.VENDOR),
+		Homepage:    headerString(hdr, rpmutils.URL),
+		License:     headerString(hdr, rpmutils.LICENSE),
+		Maintainer:  headerString(hdr, rpmutils.PACKAGER),
+	}
+	if epochs, err := hdr.GetUint32s(rpmutils.EPOCH); err == nil && len(epochs) == 1 {
+		info.Epoch = strconv.FormatUint(uint64(epochs[0]), 10)
+	}
+	if buildTimes, err := hdr.GetUint32s(rpmutils.BUILDTIME); err == nil && len(buildTimes) == 1 {
+		info.MTime = time.Unix(int64(buildTimes[0]), 0).UTC()
+	}
+	info.RPM.Summary = headerString(hdr, rpmutils.SUMMARY)
+	info.RPM.Group = headerString(hdr, rpmutils.GROUP)
+	info.RPM.BuildHost = headerString(hdr, rpmutils.BUILDHOST)
+	info.RPM.Packager = info.Maintainer
+	info.RPM.Prefixes = headerStrings(hdr, tagPrefixes)
+
+	info.Provides = relations(hdr, rpmutils.PROVIDENAME, rpmutils.PROVIDEFLAGS, rpmutils.PROVIDEVERSION)
+	info.Conflicts = relations(hdr, rpmutils.CONFLICTNAME, rpmutils.CONFLICTFLAGS, rpmutils.CONFLICTVERSION)
+	info.Replaces = relations(hdr, rpmutils.OBSOLETENAME, rpmutils.OBSOLETEFLAGS, rpmutils.OBSOLETEVERSION)
+	info.Recommends = relations(hdr, tagRecommendName, tagRecommendFlags, tagRecommendVer)
+	info.Suggests = relations(hdr, tagSuggestName, tagSuggestFlags, tagSuggestVer)
+
+	names := headerStrings(hdr, rpmutils.REQUIRENAME)
+	flags, _ := hdr.GetUint32s(rpmutils.REQUIREFLAGS)
+	versions := headerStrings(hdr, rpmutils.REQUIREVERSION)
+	for i, name := range names {
+		flag := valueAt(flags, i)
+		if flag&rpmSenseRPMLib != 0 {
+			continue
+		}
+		rel := formatRelation(name, flag, stringAt(versions, i))
+		if flag&rpmSenseScriptPost != 0 {
+			info.RPM.Requires.Post = append(info.RPM.Requires.Post, rel)
+			continue
+		}
+		info.Depends = append(info.Depends, rel)
+	}
+	return info
+}
+
+// relations renders the parallel name/flags/version tags of a dependency
+// set back into nfpm's "name op version" notation.
+func relations(hdr *rpmutils.RpmHeader, nameTag, flagsTag, versionTag int) []string {
+	names := headerStrings(hdr, nameTag)
+	flags, _ := hdr.GetUint32s(flagsTag)
+	versions := headerStrings(hdr, versionTag)
+
+	result := make([]string, 0, len(names))
+	for i, name := range names {
+		result = append(result, formatRelation(name, valueAt(flags, i), stringAt(versions, i)))
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+func formatRelation(name string, flags uint32, version string) string {
+	if version == "" {
+		return name
+	}
+	var op string
+	if flags&rpmutils.RPMSENSE_LESS != 0 {
+		op += "<"
+	}
+	if flags&rpmutils.RPMSENSE_GREATER != 0 {
+		op += ">"
+	}
+	if flags&rpmutils.RPMSENSE_EQUAL != 0 {
+		op += "="
+	}
+	if op == "" {
+		return name
+	}
+	return name + " " + op + " " + version
+}
+
+func valueAt(values []uint32, i int) uint32 {
+	if i < len(values) {
+		return values[i]
+	}
+	return 0
+}
+
+func stringAt(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+func contentsFromHeader(hdr *rpmutils.RpmHeader) (files.Contents, error) {
+	fileInfos, err := hdr.GetFiles()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rpm files: %w", err)
+	}
+	langs := headerStrings(hdr, tagFileLangs)
+
+	contents := make(files.Contents, 0, len(fileInfos))
+	for i, fi := range fileInfos {
+		mode := fi.Mode()
+		content := &files.Content{
+			Destination: files.NormalizeAbsoluteFilePath(fi.Name()),
+			Type:        fileType(mode, fi.Flags()),
+			FileInfo: &files.ContentFileInfo{
+				Owner: fi.UserName(),
+				Group: fi.GroupName(),
+				Mode:  fileMode(mode),
+				MTime: time.Unix(int64(fi.Mtime()), 0).UTC(),
+				Lang:  stringAt(langs, i),
+				Size:  fi.Size(),
+			},
+		}
+		if content.Type == files.TypeSymlink {
+			content.Source = fi.Linkname()
+		}
+		contents = append(contents, content)
+	}
+	sort.Sort(contents)
+	return contents, nil
+}
+
+func fileType(mode, flags int) string {
+	switch {
+	case mode&modeTypeMask == modeDir:
+		return files.TypeDir
+	case mode&modeTypeMask == modeSymlink:
+		return files.TypeSymlink
+	case flags&rpmutils.RPMFILE_GHOST != 0:
+		return files.TypeRPMGhost
+	case flags&rpmutils.RPMFILE_CONFIG != 0 && flags&rpmutils.RPMFILE_NOREPLACE != 0:
+		return files.TypeConfigNoReplace
+	case flags&rpmutils.RPMFILE_CONFIG != 0 && flags&rpmutils.RPMFILE_MISSINGOK != 0:
+		return files.TypeConfigMissingOK
+	case flags&rpmutils.RPMFILE_CONFIG != 0:
+		return files.TypeConfig
+	case flags&rpmutils.RPMFILE_DOC != 0:
+		return files.TypeRPMDoc
+	case flags&rpmutils.RPMFILE_LICENSE != 0:
+		return files.TypeRPMLicense
+	case flags&rpmutils.RPMFILE_README != 0:
+		return files.TypeRPMReadme
+	default:
+		return files.TypeFile
+	}
+}
+
+// fileMode converts the permission bits of an rpm file mode to fs.FileMode.
+func fileMode(mode int) fs.FileMode {
+	m := fs.FileMode(mode & 0o777)
+	if mode&0o4000 != 0 {
+		m |= fs.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		m |= fs.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		m |= fs.ModeSticky
+	}
+	return m
+}
@@ -0,0 +1,71 @@
+package rpm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	info := exampleInfo()
+	info.Epoch = "2"
+	info.RPM.Summary = "Foo"
+	info.RPM.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+	info.RPM.Signature.KeyPassphrase = "hunter2"
+
+	var rpm bytes.Buffer
+	require.NoError(t, DefaultRPM.Package(info, &rpm))
+
+	result, err := DefaultRPM.Read(&rpm)
+	require.NoError(t, err)
+	require.Equal(t, "rpm", result.Format)
+
+	got := result.Info
+	require.Equal(t, "foo", got.Name)
+	require.Equal(t, "2", got.Epoch)
+	require.Equal(t, "1.0.0", got.Version)
+	require.Equal(t, "1", got.Release)
+	require.Equal(t, "x86_64", got.Arch)
+	require.Equal(t, "linux", got.Platform)
+	require.Equal(t, "Foo", got.RPM.Summary)
+	require.Equal(t, "foo", got.RPM.Group)
+	require.Equal(t, "barhost", got.RPM.BuildHost)
+	require.Equal(t, info.Description, got.Description)
+	require.Equal(t, info.License, got.License)
+	require.Contains(t, got.Depends, "bash")
+	require.Contains(t, got.Recommends, "git")
+	require.Contains(t, got.Suggests, "bash")
+	require.Contains(t, got.Replaces, "svn")
+	require.Contains(t, got.Conflicts, "zsh")
+	for _, dep := range got.Depends {
+		require.False(t, strings.HasPrefix(dep, "rpmlib("), dep)
+	}
+
+	contents := map[string]*files.Content{}
+	for _, c := range got.Contents {
+		contents[c.Destination] = c
+	}
+	require.Equal(t, files.TypeFile, contents["/usr/bin/fake"].Type)
+	require.Equal(t, files.TypeConfig, contents["/etc/fake/fake.conf"].Type)
+	require.Equal(t, files.TypeDir, contents["/var/log/whatever"].Type)
+
+	for _, script := range []string{
+		"pretrans", "preinstall", "postinstall", "preremove",
+		"postremove", "posttrans", "verify",
+	} {
+		require.Contains(t, result.Scripts, script)
+	}
+
+	require.Len(t, result.Signatures, 2)
+	for _, sig := range result.Signatures {
+		require.NotEmpty(t, sig.KeyID)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, err := DefaultRPM.Read(strings.NewReader("not an rpm"))
+	require.ErrorIs(t, err, ErrInvalidPackage)
+}
@@ -0,0 +1,546 @@
+package rpm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/sassoftware/go-rpmutils"
+)
+
+// repodata namespaces.
+// https://github.com/rpm-software-management/createrepo_c/blob/master/src/xml_dump.h
+const (
+	xmlnsCommon    = "http://linux.duke.edu/metadata/common"
+	xmlnsRPM       = "http://linux.duke.edu/metadata/rpm"
+	xmlnsFilelists = "http://linux.duke.edu/metadata/filelists"
+	xmlnsOther     = "http://linux.duke.edu/metadata/other"
+	xmlnsRepo      = "http://linux.duke.edu/metadata/repo"
+)
+
+// dependency senses that mark a requirement as needed by the install
+// scriptlets.
+const rpmSensePrereq = 1<<6 | 1<<9 | rpmSenseScriptPost
+
+// RepoOptions configures the repository metadata created by IndexRepo.
+type RepoOptions struct {
+	// Date used as the revision and timestamps of repomd.xml, defaults to
+	// $SOURCE_DATE_EPOCH or now.
+	Date time.Time
+
+	// PGP secret key used to create repomd.xml.asc, can be ASCII-armored. The
+	// repository is not signed if empty.
+	KeyFile       string
+	KeyID         *string
+	KeyPassphrase string
+}
+
+type repoVersion struct {
+	Epoch   string `xml:"epoch,attr"`
+	Version string `xml:"ver,attr"`
+	Release string `xml:"rel,attr"`
+}
+
+type repoChecksum struct {
+	Type  string `xml:"type,attr"`
+	PkgID string `xml:"pkgid,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type repoEntry struct {
+	Name    string `xml:"name,attr"`
+	Flags   string `xml:"flags,attr,omitempty"`
+	Epoch   string `xml:"epoch,attr,omitempty"`
+	Version string `xml:"ver,attr,omitempty"`
+	Release string `xml:"rel,attr,omitempty"`
+	Pre     string `xml:"pre,attr,omitempty"`
+}
+
+type repoEntries struct {
+	Entries []repoEntry `xml:"rpm:entry"`
+}
+
+type repoFileEntry struct {
+	Type string `xml:"type,attr,omitempty"`
+	Path string `xml:",chardata"`
+}
+
+type repoHeaderRange struct {
+	Start int `xml:"start,attr"`
+	End   int `xml:"end,attr"`
+}
+
+type repoFormat struct {
+	License     string          `xml:"rpm:license"`
+	Vendor      string          `xml:"rpm:vendor"`
+	Group       string          `xml:"rpm:group"`
+	BuildHost   string          `xml:"rpm:buildhost"`
+	SourceRPM   string          `xml:"rpm:sourcerpm"`
+	HeaderRange repoHeaderRange `xml:"rpm:header-range"`
+	Provides    *repoEntries    `xml:"rpm:provides,omitempty"`
+	Requires    *repoEntries    `xml:"rpm:requires,omitempty"`
+	Conflicts   *repoEntries    `xml:"rpm:conflicts,omitempty"`
+	Obsoletes   *repoEntries    `xml:"rpm:obsoletes,omitempty"`
+	Suggests    *repoEntries    `xml:"rpm:suggests,omitempty"`
+	Recommends  *repoEntries    `xml:"rpm:recommends,omitempty"`
+	Files       []repoFileEntry `xml:"file"`
+}
+
+type repoTime struct {
+	File  int64 `xml:"file,attr"`
+	Build int64 `xml:"build,attr"`
+}
+
+type repoSize struct {
+	Package   int64 `xml:"package,attr"`
+	Installed int64 `xml:"installed,attr"`
+	Archive   int64 `xml:"archive,attr"`
+}
+
+type repoLocation struct {
+	Href string `xml:"href,attr"`
+}
+
+type repoPrimaryPackage struct {
+	Type        string       `xml:"type,attr"`
+	Name        string       `xml:"name"`
+	Arch        string       `xml:"arch"`
+	Version     repoVersion  `xml:"version"`
+	Checksum    repoChecksum `xml:"checksum"`
+	Summary     string       `xml:"summary"`
+	Description string       `xml:"description"`
+	Packager    string       `xml:"packager"`
+	URL         string       `xml:"url"`
+	Time        repoTime     `xml:"time"`
+	Size        repoSize     `xml:"size"`
+	Location    repoLocation `xml:"location"`
+	Format      repoFormat   `xml:"format"`
+}
+
+type repoPrimary struct {
+	XMLName  xml.Name             `xml:"metadata"`
+	Xmlns    string               `xml:"xmlns,attr"`
+	XmlnsRPM string               `xml:"xmlns:rpm,attr"`
+	Count    int                  `xml:"packages,attr"`
+	Packages []repoPrimaryPackage `xml:"package"`
+}
+
+type repoFilelistsPackage struct {
+	PkgID   string          `xml:"pkgid,attr"`
+	Name    string          `xml:"name,attr"`
+	Arch    string          `xml:"arch,attr"`
+	Version repoVersion     `xml:"version"`
+	Files   []repoFileEntry `xml:"file"`
+}
+
+type repoFilelists struct {
+	XMLName  xml.Name               `xml:"filelists"`
+	Xmlns    string                 `xml:"xmlns,attr"`
+	Count    int                    `xml:"packages,attr"`
+	Packages []repoFilelistsPackage `xml:"package"`
+}
+
+type repoChangelog struct {
+	Author string `xml:"author,attr"`
+	Date   int64  `xml:"date,attr"`
+	Text   string `xml:",chardata"`
+}
+
+type repoOtherPackage struct {
+	PkgID      string          `xml:"pkgid,attr"`
+	Name       string          `xml:"name,attr"`
+	Arch       string          `xml:"arch,attr"`
+	Version    repoVersion     `xml:"version"`
+	Changelogs []repoChangelog `xml:"changelog"`
+}
+
+type repoOther struct {
+	XMLName  xml.Name           `xml:"otherdata"`
+	Xmlns    string             `xml:"xmlns,attr"`
+	Count    int                `xml:"packages,attr"`
+	Packages []repoOtherPackage `xml:"package"`
+}
+
+type repoData struct {
+	Type         string       `xml:"type,attr"`
+	Checksum     repoChecksum `xml:"checksum"`
+	OpenChecksum repoChecksum `xml:"open-checksum"`
+	Location     repoLocation `xml:"location"`
+	Timestamp    int64        `xml:"timestamp"`
+	Size         int          `xml:"size"`
+	OpenSize     int          `xml:"open-size"`
+}
+
+type repoMD struct {
+	XMLName  xml.Name   `xml:"repomd"`
+	Xmlns    string     `xml:"xmlns,attr"`
+	XmlnsRPM string     `xml:"xmlns:rpm,attr"`
+	Revision int64      `xml:"revision"`
+	Data     []repoData `xml:"data"`
+}
+
+// IndexRepo indexes all rpm packages found in dir, creating the repodata
+// directory with repomd.xml, primary.xml.gz, filelists.xml.gz and
+// other.xml.gz and, if a key is given, the repomd.xml.asc signature. It
+// returns the paths of the created files.
+func IndexRepo(dir string, opts RepoOptions) ([]string, error) {
+	primary := repoPrimary{Xmlns: xmlnsCommon, XmlnsRPM: xmlnsRPM}
+	filelists := repoFilelists{Xmlns: xmlnsFilelists}
+	other := repoOther{Xmlns: xmlnsOther}
+
+	var names []string
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(name) == ".rpm" {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		pkg, err := readRepoPackage(name, filepath.ToSlash(rel))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		primary.Packages = append(primary.Packages, pkg.primary)
+		filelists.Packages = append(filelists.Packages, pkg.filelists)
+		other.Packages = append(other.Packages, pkg.other)
+	}
+	primary.Count = len(primary.Packages)
+	filelists.Count = len(filelists.Packages)
+	other.Count = len(other.Packages)
+
+	date := opts.Date
+	if date.IsZero() {
+		date = modtime.Get(modtime.FromEnv())
+	}
+	repomd := repoMD{
+		Xmlns:    xmlnsRepo,
+		XmlnsRPM: xmlnsRPM,
+		Revision: date.Unix(),
+	}
+
+	files := map[string][]byte{}
+	for _, data := range []struct {
+		name  string
+		value any
+	}{
+		{"primary", primary},
+		{"filelists", filelists},
+		{"other", other},
+	} {
+		content, err := marshalXML(data.value)
+		if err != nil {
+			return nil, err
+		}
+		compressed, err := gzipBytes(content)
+		if err != nil {
+			return nil, err
+		}
+		location := path.Join("repodata", data.name+".xml.gz")
+		files[location] = compressed
+		repomd.Data = append(repomd.Data, repoData{
+			Type:         data.name,
+			Checksum:     repoChecksum{Type: "sha256", Value: sha256Hex(compressed)},
+			OpenChecksum: repoChecksum{Type: "sha256", Value: sha256Hex(content)},
+			Location:     repoLocation{Href: location},
+			Timestamp:    date.Unix(),
+			Size:         len(compressed),
+			OpenSize:     len(content),
+		})
+	}
+
+	content, err := marshalXML(repomd)
+	if err != nil {
+		return nil, err
+	}
+	files["repodata/repomd.xml"] = content
+
+	if opts.KeyFile != "" {
+		sig, err := sign.PGPArmoredDetachSignWithKeyID(bytes.NewReader(content), opts.KeyFile, opts.KeyPassphrase, opts.KeyID)
+		if err != nil {
+			return nil, &nfpm.ErrSigningFailure{Err: err}
+		}
+		files["repodata/repomd.xml.asc"] = sig
+	}
+
+	locations := make([]string, 0, len(files))
+	for location := range files {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+
+	if err := os.MkdirAll(filepath.Join(dir, "repodata"), 0o755); err != nil {
+		return nil, err
+	}
+	created := make([]string, 0, len(files))
+	for _, location := range locations {
+		name := filepath.Join(dir, filepath.FromSlash(location))
+		if err := os.WriteFile(name, files[location], 0o644); err != nil { //nolint:gosec
+			return nil, err
+		}
+		created = append(created, name)
+	}
+	return created, nil
+}
+
+// repoPackage holds the entries of a package in each of the metadata files.
+type repoPackage struct {
+	primary   repoPrimaryPackage
+	filelists repoFilelistsPackage
+	other     repoOtherPackage
+}
+
+func readRepoPackage(name, location string) (*repoPackage, error) {
+	content, err := os.ReadFile(name) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := rpmutils.ReadRpm(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+	hdr := pkg.Header
+
+	pkgID := sha256Hex(content)
+	arch := headerString(hdr, rpmutils.ARCH)
+	if !hdr.HasTag(rpmutils.SOURCERPM) {
+		arch = "src"
+	}
+	version := repoVersion{
+		Epoch:   "0",
+		Version: headerString(hdr, rpmutils.VERSION),
+		Release: headerString(hdr, rpmutils.RELEASE),
+	}
+	if epochs, err := hdr.GetUint32s(rpmutils.EPOCH); err == nil && len(epochs) == 1 {
+		version.Epoch = strconv.FormatUint(uint64(epochs[0]), 10)
+	}
+
+	fileInfos, err := hdr.GetFiles()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rpm files: %w", err)
+	}
+	var allFiles, primaryFiles []repoFileEntry
+	for _, fi := range fileInfos {
+		entry := repoFileEntry{Path: fi.Name()}
+		switch {
+		case fi.Mode()&modeTypeMask == modeDir:
+			entry.Type = "dir"
+		case fi.Flags()&rpmutils.RPMFILE_GHOST != 0:
+			entry.Type = "ghost"
+		}
+		allFiles = append(allFiles, entry)
+		if isPrimaryFile(entry.Path) {
+			primaryFiles = append(primaryFiles, entry)
+		}
+	}
+
+	var buildTime int64
+	if buildTimes, err := hdr.GetUint32s(rpmutils.BUILDTIME); err == nil && len(buildTimes) == 1 {
+		buildTime = int64(buildTimes[0])
+	}
+	installedSize, _ := hdr.InstalledSize()
+	archiveSize, _ := hdr.PayloadSize()
+	headerRange := hdr.GetRange()
+
+	requires := repoRelations(hdr, rpmutils.REQUIRENAME, rpmutils.REQUIREFLAGS, rpmutils.REQUIREVERSION)
+	if requires != nil {
+		filtered := requires.Entries[:0]
+		for _, entry := range requires.Entries {
+			if !strings.HasPrefix(entry.Name, "rpmlib(") {
+				filtered = append(filtered, entry)
+			}
+		}
+		requires.Entries = filtered
+		if len(filtered) == 0 {
+			requires = nil
+		}
+	}
+
+	return &repoPackage{
+		primary: repoPrimaryPackage{
+			Type:        "rpm",
+			Name:        headerString(hdr, rpmutils.NAME),
+			Arch:        arch,
+			Version:     version,
+			Checksum:    repoChecksum{Type: "sha256", PkgID: "YES", Value: pkgID},
+			Summary:     headerString(hdr, rpmutils.SUMMARY),
+			Description: headerString(hdr, rpmutils.DESCRIPTION),
+			Packager:    headerString(hdr, rpmutils.PACKAGER),
+			URL:         headerString(hdr, rpmutils.URL),
+			Time:        repoTime{File: stat.ModTime().Unix(), Build: buildTime},
+			Size: repoSize{
+				Package:   int64(len(content)),
+				Installed: installedSize,
+				Archive:   archiveSize,
+			},
+			Location: repoLocation{Href: location},
+			Format: repoFormat{
+				License:     headerString(hdr, rpmutils.LICENSE),
+				Vendor:      headerString(hdr, rpmutils.VENDOR),
+				Group:       headerString(hdr, rpmutils.GROUP),
+				BuildHost:   headerString(hdr, rpmutils.BUILDHOST),
+				SourceRPM:   headerString(hdr, rpmutils.SOURCERPM),
+				HeaderRange: repoHeaderRange{Start: headerRange.Start, End: headerRange.End},
+				Provides:    repoRelations(hdr, rpmutils.PROVIDENAME, rpmutils.PROVIDEFLAGS, rpmutils.PROVIDEVERSION),
+				Requires:    requires,
+				Conflicts:   repoRelations(hdr, rpmutils.CONFLICTNAME, rpmutils.CONFLICTFLAGS, rpmutils.CONFLICTVERSION),
+				Obsoletes:   repoRelations(hdr, rpmutils.OBSOLETENAME, rpmutils.OBSOLETEFLAGS, rpmutils.OBSOLETEVERSION),
+				Suggests:    repoRelations(hdr, tagSuggestName, tagSuggestFlags, tagSuggestVer),
+				Recommends:  repoRelations(hdr, tagRecommendName, tagRecommendFlags, tagRecommendVer),
+				Files:       primaryFiles,
+			},
+		},
+		filelists: repoFilelistsPackage{
+			PkgID:   pkgID,
+			Name:    headerString(hdr, rpmutils.NAME),
+			Arch:    arch,
+			Version: version,
+			Files:   allFiles,
+		},
+		other: repoOtherPackage{
+			PkgID:      pkgID,
+			Name:       headerString(hdr, rpmutils.NAME),
+			Arch:       arch,
+			Version:    version,
+			Changelogs: repoChangelogs(hdr),
+		},
+	}, nil
+}
+
+// isPrimaryFile reports whether a file is listed in primary.xml, as done by
+// createrepo, so that dependencies on common paths can be resolved without
+// downloading filelists.xml.
+func isPrimaryFile(name string) bool {
+	return strings.Contains(name, "bin/") ||
+		strings.HasPrefix(name, "/etc/") ||
+		name == "/usr/lib/sendmail"
+}
+
+func repoRelations(hdr *rpmutils.RpmHeader, nameTag, flagsTag, versionTag int) *repoEntries {
+	names := headerStrings(hdr, nameTag)
+	if len(names) == 0 {
+		return nil
+	}
+	flags, _ := hdr.GetUint32s(flagsTag)
+	versions := headerStrings(hdr, versionTag)
+
+	result := &repoEntries{}
+	for i, name := range names {
+		flag := valueAt(flags, i)
+		entry := repoEntry{Name: name}
+		if flag&rpmSensePrereq != 0 {
+			entry.Pre = "1"
+		}
+		if version := stringAt(versions, i); version != "" {
+			entry.Flags = repoFlags(flag)
+			entry.Epoch, entry.Version, entry.Release = splitEVR(version)
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	return result
+}
+
+func repoFlags(flags uint32) string {
+	switch flags & (rpmutils.RPMSENSE_LESS | rpmutils.RPMSENSE_GREATER | rpmutils.RPMSENSE_EQUAL) {
+	case rpmutils.RPMSENSE_LESS:
+		return "LT"
+	case rpmutils.RPMSENSE_GREATER:
+		return "GT"
+	case rpmutils.RPMSENSE_EQUAL:
+		return "EQ"
+	case rpmutils.RPMSENSE_LESS | rpmutils.RPMSENSE_EQUAL:
+		return "LE"
+	case rpmutils.RPMSENSE_GREATER | rpmutils.RPMSENSE_EQUAL:
+		return "GE"
+	default:
+		return ""
+	}
+}
+
+// splitEVR splits an [epoch:]version[-release] string, defaulting the epoch
+// to 0.
+func splitEVR(evr string) (epoch, version, release string) {
+	epoch = "0"
+	if e, rest, ok := strings.Cut(evr, ":"); ok {
+		epoch, evr = e, rest
+	}
+	version, release, _ = strings.Cut(evr, "-")
+	return epoch, version, release
+}
+
+func repoChangelogs(hdr *rpmutils.RpmHeader) []repoChangelog {
+	times, _ := hdr.GetUint32s(rpmutils.CHANGELOGTIME)
+	names := headerStrings(hdr, rpmutils.CHANGELOGNAME)
+	texts := headerStrings(hdr, rpmutils.CHANGELOGTEXT)
+
+	result := make([]repoChangelog, 0, len(times))
+	for i, t := range times {
+		result = append(result, repoChangelog{
+			Author: stringAt(names, i),
+			Date:   int64(t),
+			Text:   stringAt(texts, i),
+		})
+	}
+	return result
+}
+
+func marshalXML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func gzipBytes(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gz.Write(content); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
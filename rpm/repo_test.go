@@ -0,0 +1,123 @@
+package rpm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRepoRPM(t *testing.T, dir, arch string) string {
+	t.Helper()
+	info := exampleInfo()
+	info.Arch = arch
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	target := filepath.Join(dir, DefaultRPM.ConventionalFileName(info))
+	f, err := os.Create(target)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, DefaultRPM.Package(info, f))
+	return target
+}
+
+// readRepoXML decompresses and decodes a metadata file, returning its raw
+// content too, as the rpm namespaced elements cannot be decoded back.
+func readRepoXML(t *testing.T, name string, v any) string {
+	t.Helper()
+	f, err := os.Open(name)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	content, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.NoError(t, xml.Unmarshal(content, v))
+	return string(content)
+}
+
+func TestIndexRepo(t *testing.T) {
+	dir := t.TempDir()
+	writeRepoRPM(t, dir, "amd64")
+	writeRepoRPM(t, filepath.Join(dir, "arm64"), "arm64")
+
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	created, err := IndexRepo(dir, RepoOptions{Date: date})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "repodata", "filelists.xml.gz"),
+		filepath.Join(dir, "repodata", "other.xml.gz"),
+		filepath.Join(dir, "repodata", "primary.xml.gz"),
+		filepath.Join(dir, "repodata", "repomd.xml"),
+	}, created)
+
+	var primary repoPrimary
+	content := readRepoXML(t, filepath.Join(dir, "repodata", "primary.xml.gz"), &primary)
+	require.Equal(t, 2, primary.Count)
+	require.Len(t, primary.Packages, 2)
+	pkg := primary.Packages[0]
+	require.Equal(t, "foo", pkg.Name)
+	require.Equal(t, "aarch64", pkg.Arch)
+	require.Equal(t, "arm64/"+filepath.Base(pkg.Location.Href), pkg.Location.Href)
+	require.Equal(t, "x86_64", primary.Packages[1].Arch)
+	require.NotEmpty(t, pkg.Checksum.Value)
+	require.Contains(t, content, `<rpm:entry name="bash"></rpm:entry>`)
+	require.NotContains(t, content, "rpmlib(")
+
+	var filelists repoFilelists
+	readRepoXML(t, filepath.Join(dir, "repodata", "filelists.xml.gz"), &filelists)
+	require.Len(t, filelists.Packages, 2)
+	require.Equal(t, pkg.Checksum.Value, filelists.Packages[0].PkgID)
+	require.NotEmpty(t, filelists.Packages[0].Files)
+
+	var other repoOther
+	readRepoXML(t, filepath.Join(dir, "repodata", "other.xml.gz"), &other)
+	require.Len(t, other.Packages, 2)
+
+	repomdContent, err := os.ReadFile(filepath.Join(dir, "repodata", "repomd.xml"))
+	require.NoError(t, err)
+	var repomd repoMD
+	require.NoError(t, xml.Unmarshal(repomdContent, &repomd))
+	require.Equal(t, date.Unix(), repomd.Revision)
+	require.Len(t, repomd.Data, 3)
+	for _, data := range repomd.Data {
+		compressed, err := os.ReadFile(filepath.Join(dir, data.Location.Href))
+		require.NoError(t, err)
+		require.Equal(t, sha256Hex(compressed), data.Checksum.Value)
+		require.Equal(t, len(compressed), data.Size)
+	}
+}
+
+func TestIndexRepoSigned(t *testing.T) {
+	dir := t.TempDir()
+	writeRepoRPM(t, dir, "amd64")
+
+	_, err := IndexRepo(dir, RepoOptions{
+		KeyFile:       "../internal/sign/testdata/privkey.asc",
+		KeyPassphrase: "hunter2",
+	})
+	require.NoError(t, err)
+
+	repomd, err := os.ReadFile(filepath.Join(dir, "repodata", "repomd.xml"))
+	require.NoError(t, err)
+	sig, err := os.ReadFile(filepath.Join(dir, "repodata", "repomd.xml.asc"))
+	require.NoError(t, err)
+	require.NoError(t, sign.PGPVerify(bytes.NewReader(repomd), sig, "../internal/sign/testdata/pubkey.asc"))
+}
+
+func TestSplitEVR(t *testing.T) {
+	for evr, expected := range map[string][3]string{
+		"1.0":       {"0", "1.0", ""},
+		"1.0-2":     {"0", "1.0", "2"},
+		"3:1.0-2.1": {"3", "1.0", "2.1"},
+	} {
+		epoch, version, release := splitEVR(evr)
+		require.Equal(t, expected, [3]string{epoch, version, release}, evr)
+	}
+}
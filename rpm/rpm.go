@@ -19,6 +19,8 @@ import (
 func init() {
 	nfpm.RegisterPackager(formatRPM.String(), DefaultRPM)
 	nfpm.RegisterPackager(formatSRPM.String(), DefaultSRPM)
+	nfpm.RegisterReader(formatRPM.String(), DefaultRPM)
+	nfpm.RegisterReader(formatSRPM.String(), DefaultSRPM)
 }
 
 // DefaultRPM RPM packager.
@@ -119,18 +121,37 @@ const contentPackager = "rpm"
 
 // Package writes a new RPM package to the given writer using the given info.
 func (r *RPM) Package(info *nfpm.Info, w io.Writer) (err error) {
-	info = setDefaults(info)
-
-	if err = nfpm.PrepareForPackager(info, contentPackager); err != nil {
+	defer info.Cleanup()
+	if err := prepare(info); err != nil {
 		return err
 	}
 
 	if r.format == formatSRPM {
+		for _, split := range info.SplitPackages {
+			if err := prepare(split); err != nil {
+				return fmt.Errorf("%s: %w", split.Name, err)
+			}
+		}
 		return r.packageSRPM(info, w)
 	}
 	return r.packageRPM(info, w)
 }
 
+func prepare(info *nfpm.Info) error {
+	info = setDefaults(info)
+
+	if err := nfpm.PrepareForPackager(info, contentPackager); err != nil {
+		return err
+	}
+
+	if err := nfpm.PrepareAutoDepends(info, contentPackager); err != nil {
+		return err
+	}
+	return nfpm.PrepareRelations(info, contentPackager, func(arch string) bool {
+		return arch == info.Arch || archToRPM[arch] == info.Arch
+	})
+}
+
 func formatVersion(info *nfpm.Info) string {
 	version := info.Version
 
@@ -26,7 +26,6 @@ const (
 	tagRequireFlags   = 1048
 	tagRequireName    = 1049
 	tagRequireVersion = 1050
-	tagFileLangs      = 1097
 )
 
 func exampleInfo() *nfpm.Info {
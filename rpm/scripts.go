@@ -19,7 +19,9 @@ type scriptBodies struct {
 }
 
 // readScripts reads every configured script file into memory once, so both the
-// binary builder and the source-package spec generator can consume them.
+// binary builder and the source-package spec generator can consume them. The
+// portable upgrade scripts are combined with the install scripts by
+// nfpm.MaintainerScripts.
 func readScripts(info *nfpm.Info) (scriptBodies, error) {
 	read := func(path string) (string, error) {
 		if path == "" {
@@ -32,23 +34,17 @@ func readScripts(info *nfpm.Info) (scriptBodies, error) {
 		return string(data), nil
 	}
 
-	var (
-		s   scriptBodies
-		err error
-	)
-	if s.preTrans, err = read(info.RPM.Scripts.PreTrans); err != nil {
-		return s, err
-	}
-	if s.preIn, err = read(info.Scripts.PreInstall); err != nil {
-		return s, err
-	}
-	if s.preUn, err = read(info.Scripts.PreRemove); err != nil {
-		return s, err
-	}
-	if s.postIn, err = read(info.Scripts.PostInstall); err != nil {
+	var s scriptBodies
+	scripts, err := nfpm.MaintainerScripts(info, contentPackager)
+	if err != nil {
 		return s, err
 	}
-	if s.postUn, err = read(info.Scripts.PostRemove); err != nil {
+	s.preIn = string(scripts["pre"])
+	s.postIn = string(scripts["post"])
+	s.preUn = string(scripts["preun"])
+	s.postUn = string(scripts["postun"])
+
+	if s.preTrans, err = read(info.RPM.Scripts.PreTrans); err != nil {
 		return s, err
 	}
 	if s.postTrans, err = read(info.RPM.Scripts.PostTrans); err != nil {
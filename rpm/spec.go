@@ -12,7 +12,8 @@ import (
 // generateSpec synthesizes a self-contained, rebuildable RPM spec from the
 // package metadata. %install extracts the bundled source tarball into the
 // buildroot, and %files re-declares every path with its attributes, so
-// `rpmbuild --rebuild` reproduces the binary RPM.
+// `rpmbuild --rebuild` reproduces the binary RPM, and those of the split
+// packages declared with %package.
 func generateSpec(info *nfpm.Info, sourceName string) (string, error) {
 	summary := defaultTo(info.RPM.Summary, strings.Split(info.Description, "\n")[0])
 	summary = defaultTo(summary, info.Name)
@@ -49,35 +50,24 @@ func generateSpec(info *nfpm.Info, sourceName string) (string, error) {
 		writeField("Prefix", prefix)
 	}
 
-	// Dependencies are declared explicitly; do not let rpmbuild scan the prebuilt
-	// payload for additional ones.
-	b.WriteString("AutoReqProv: no\n")
-	for _, dep := range info.Depends {
-		writeField("Requires", dep)
-	}
-	for _, dep := range info.RPM.Requires.Post {
-		writeField("Requires(post)", dep)
-	}
-	for _, dep := range info.Provides {
-		writeField("Provides", dep)
-	}
-	for _, dep := range info.Conflicts {
-		writeField("Conflicts", dep)
-	}
-	for _, dep := range info.Replaces {
-		writeField("Obsoletes", dep)
-	}
-	for _, dep := range info.Recommends {
-		writeField("Recommends", dep)
-	}
-	for _, dep := range info.Suggests {
-		writeField("Suggests", dep)
-	}
+	writeRelations(writeField, info)
 
 	fmt.Fprintf(&b, "Source0: %s\n", sourceName)
 
 	fmt.Fprintf(&b, "\n%%description\n%s\n", escapeSpecText(description))
 
+	// split packages are built from the same source package.
+	for _, split := range info.SplitPackages {
+		splitSummary := defaultTo(split.RPM.Summary, strings.Split(split.Description, "\n")[0])
+		splitSummary = defaultTo(splitSummary, split.Name)
+		fmt.Fprintf(&b, "\n%%package -n %s\n", escapeSpecText(split.Name))
+		writeField("Summary", splitSummary)
+		writeField("Group", split.RPM.Group)
+		writeRelations(writeField, split)
+		fmt.Fprintf(&b, "\n%%description -n %s\n%s\n",
+			escapeSpecText(split.Name), escapeSpecText(defaultTo(split.Description, splitSummary)))
+	}
+
 	b.WriteString("\n%prep\n")
 	b.WriteString("\n%build\n")
 
@@ -86,20 +76,21 @@ func generateSpec(info *nfpm.Info, sourceName string) (string, error) {
 	b.WriteString("mkdir -p %{buildroot}\n")
 	b.WriteString("tar -C %{buildroot} -xf %{SOURCE0}\n")
 
-	scripts, err := readScripts(info)
-	if err != nil {
+	if err := writeScriptSections(&b, info, ""); err != nil {
 		return "", err
 	}
-	writeScriptSection(&b, "pre", scripts.preIn)
-	writeScriptSection(&b, "post", scripts.postIn)
-	writeScriptSection(&b, "preun", scripts.preUn)
-	writeScriptSection(&b, "postun", scripts.postUn)
-	writeScriptSection(&b, "pretrans", scripts.preTrans)
-	writeScriptSection(&b, "posttrans", scripts.postTrans)
-	writeScriptSection(&b, "verifyscript", scripts.verify)
+	for _, split := range info.SplitPackages {
+		if err := writeScriptSections(&b, split, " -n "+escapeSpecText(split.Name)); err != nil {
+			return "", err
+		}
+	}
 
 	b.WriteString("\n%files\n")
 	writeFilesSection(&b, info)
+	for _, split := range info.SplitPackages {
+		fmt.Fprintf(&b, "\n%%files -n %s\n", escapeSpecText(split.Name))
+		writeFilesSection(&b, split)
+	}
 
 	changelog, err := renderSpecChangelog(info)
 	if err != nil {
@@ -110,6 +101,51 @@ func generateSpec(info *nfpm.Info, sourceName string) (string, error) {
 	return b.String(), nil
 }
 
+// writeRelations declares the relations of a package in its preamble.
+func writeRelations(writeField func(key, value string), info *nfpm.Info) {
+	// Dependencies are declared explicitly; do not let rpmbuild scan the prebuilt
+	// payload for additional ones.
+	writeField("AutoReqProv", "no")
+	for _, dep := range info.Depends {
+		writeField("Requires", dep)
+	}
+	for _, dep := range info.RPM.Requires.Post {
+		writeField("Requires(post)", dep)
+	}
+	for _, dep := range info.Provides {
+		writeField("Provides", dep)
+	}
+	for _, dep := range info.Conflicts {
+		writeField("Conflicts", dep)
+	}
+	for _, dep := range info.Replaces {
+		writeField("Obsoletes", dep)
+	}
+	for _, dep := range info.Recommends {
+		writeField("Recommends", dep)
+	}
+	for _, dep := range info.Suggests {
+		writeField("Suggests", dep)
+	}
+}
+
+// writeScriptSections writes the scriptlets of a package, whose sections are
+// suffixed with the given -n option for split packages.
+func writeScriptSections(b *strings.Builder, info *nfpm.Info, suffix string) error {
+	scripts, err := readScripts(info)
+	if err != nil {
+		return err
+	}
+	writeScriptSection(b, "pre"+suffix, scripts.preIn)
+	writeScriptSection(b, "post"+suffix, scripts.postIn)
+	writeScriptSection(b, "preun"+suffix, scripts.preUn)
+	writeScriptSection(b, "postun"+suffix, scripts.postUn)
+	writeScriptSection(b, "pretrans"+suffix, scripts.preTrans)
+	writeScriptSection(b, "posttrans"+suffix, scripts.postTrans)
+	writeScriptSection(b, "verifyscript"+suffix, scripts.verify)
+	return nil
+}
+
 func writeScriptSection(b *strings.Builder, section, body string) {
 	if body == "" {
 		return
@@ -125,12 +125,14 @@ func writePayloadTar(w io.Writer, info *nfpm.Info) error {
 	tw := tar.NewWriter(gz)
 
 	mtime := modtime.Get(info.MTime)
-	for _, content := range info.Contents {
-		if content.Packager != "" && content.Packager != contentPackager {
-			continue
-		}
-		if err := addTarEntry(tw, content, mtime); err != nil {
-			return err
+	for _, info := range append([]*nfpm.Info{info}, info.SplitPackages...) {
+		for _, content := range info.Contents {
+			if content.Packager != "" && content.Packager != contentPackager {
+				continue
+			}
+			if err := addTarEntry(tw, content, mtime); err != nil {
+				return err
+			}
 		}
 	}
 
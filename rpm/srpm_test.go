@@ -1,9 +1,13 @@
 package rpm
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/goreleaser/nfpm/v2"
@@ -246,3 +250,64 @@ func TestSRPMGenerateSpecEscapesPercent(t *testing.T) {
 	require.Contains(t, spec, "%global debug_package %{nil}")
 	require.Contains(t, spec, "tar -C %{buildroot} -xf %{SOURCE0}")
 }
+
+func TestSRPMGenerateSpecSplitPackages(t *testing.T) {
+	info := nfpm.WithDefaults(&nfpm.Info{
+		Name:        "foo",
+		Arch:        "amd64",
+		Version:     "1.0.0",
+		Description: "foo does things",
+		Overridables: nfpm.Overridables{
+			Contents: []*files.Content{
+				{Source: "../testdata/fake", Destination: "/usr/bin/foo"},
+			},
+		},
+		SplitPackages: []*nfpm.Info{
+			nfpm.WithDefaults(&nfpm.Info{
+				Name:        "foo-devel",
+				Arch:        "amd64",
+				Version:     "1.0.0",
+				Description: "headers of foo",
+				Overridables: nfpm.Overridables{
+					Depends: []string{"foo (= 1.0.0)"},
+					Contents: []*files.Content{
+						{Source: "../testdata/whatever.conf", Destination: "/usr/include/foo.h"},
+					},
+					Scripts: nfpm.Scripts{
+						PostInstall: "../testdata/scripts/postinstall.sh",
+					},
+				},
+			}),
+		},
+	})
+	require.NoError(t, prepare(info))
+	require.NoError(t, prepare(info.SplitPackages[0]))
+
+	spec, err := generateSpec(info, "foo-1.0.0.tar.gz")
+	require.NoError(t, err)
+	require.Contains(t, spec, "\n%package -n foo-devel\nSummary: headers of foo\nAutoReqProv: no\nRequires: foo = 1.0.0\n")
+	require.Contains(t, spec, "\n%description -n foo-devel\nheaders of foo\n")
+	require.Contains(t, spec, "\n%post -n foo-devel\n")
+	_, develFiles, ok := strings.Cut(spec, "\n%files -n foo-devel\n")
+	require.True(t, ok)
+	require.Contains(t, develFiles, `"/usr/include/foo.h"`)
+	require.NotContains(t, develFiles, `"/usr/bin/foo"`)
+	require.Less(t, strings.Index(spec, "%package -n foo-devel"), strings.Index(spec, "%prep"))
+
+	var payload bytes.Buffer
+	require.NoError(t, writePayloadTar(&payload, info))
+	gz, err := gzip.NewReader(&payload)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	require.Contains(t, names, "usr/bin/foo")
+	require.Contains(t, names, "usr/include/foo.h")
+}
@@ -0,0 +1,112 @@
+package rpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/sassoftware/go-rpmutils"
+)
+
+// ErrInvalidPackage happens when a file cannot be read as an rpm package.
+var ErrInvalidPackage = errors.New("invalid rpm package")
+
+const (
+	leadSize        = 96
+	headerIntroSize = 16
+)
+
+// nolint: gochecknoglobals
+var headerMagic = []byte{0x8e, 0xad, 0xe8}
+
+// Verify implements nfpm.Verifier. The header-only RSA signature and the
+// header and payload PGP signature are both checked against the given
+// OpenPGP public key, after the digests of the header and the payload.
+func (r *RPM) Verify(rd io.Reader, keyFile string) ([]nfpm.SignatureVerification, error) {
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := rpmutils.ReadRpm(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+	header, payload, err := splitRPM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// checks the digests without any key, so a corrupted payload is reported
+	// even for signatures that only cover the header.
+	_, _, digestErr := rpmutils.Verify(bytes.NewReader(data), nil)
+
+	var results []nfpm.SignatureVerification
+	for _, sig := range []struct {
+		tag     int
+		sigType string
+		message func() io.Reader
+	}{
+		{rpmutils.SIG_PGP, "pgp", func() io.Reader {
+			return io.MultiReader(bytes.NewReader(header), bytes.NewReader(payload))
+		}},
+		{rpmutils.SIG_RSA, "rsa", func() io.Reader {
+			return bytes.NewReader(header)
+		}},
+	} {
+		body, err := pkg.Header.GetBytes(sig.tag)
+		if err != nil || len(body) == 0 {
+			continue
+		}
+		result := nfpm.SignatureVerification{
+			SignatureInfo: nfpm.SignatureInfo{Type: sig.sigType},
+		}
+		result.KeyID, _ = sign.PGPSignatureKeyID(body)
+		if digestErr != nil {
+			result.Err = digestErr
+		} else {
+			result.Err = sign.PGPVerify(sig.message(), body, keyFile)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// splitRPM returns the raw bytes of the main header and of the payload of an
+// rpm, which are the signed parts of the package.
+func splitRPM(data []byte) (header, payload []byte, err error) {
+	if len(data) < leadSize {
+		return nil, nil, fmt.Errorf("%w: truncated lead", ErrInvalidPackage)
+	}
+	sigSize, err := headerSize(data[leadSize:])
+	if err != nil {
+		return nil, nil, err
+	}
+	// the signature header is padded to a multiple of 8 bytes.
+	start := leadSize + sigSize + (8-sigSize%8)%8
+	if start > len(data) {
+		return nil, nil, fmt.Errorf("%w: truncated signature header", ErrInvalidPackage)
+	}
+	size, err := headerSize(data[start:])
+	if err != nil {
+		return nil, nil, err
+	}
+	if start+size > len(data) {
+		return nil, nil, fmt.Errorf("%w: truncated header", ErrInvalidPackage)
+	}
+	return data[start : start+size], data[start+size:], nil
+}
+
+// headerSize returns the size in bytes of the header at the start of data.
+func headerSize(data []byte) (int, error) {
+	if len(data) < headerIntroSize || !bytes.Equal(data[:3], headerMagic) {
+		return 0, fmt.Errorf("%w: bad header magic", ErrInvalidPackage)
+	}
+	entries := binary.BigEndian.Uint32(data[8:12])
+	size := binary.BigEndian.Uint32(data[12:16])
+	return headerIntroSize + int(entries)*16 + int(size), nil
+}
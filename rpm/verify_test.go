@@ -0,0 +1,50 @@
+package rpm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	info := exampleInfo()
+	info.RPM.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+	info.RPM.Signature.KeyPassphrase = "hunter2"
+
+	var rpm bytes.Buffer
+	require.NoError(t, DefaultRPM.Package(info, &rpm))
+	data := rpm.Bytes()
+
+	results, err := DefaultRPM.Verify(bytes.NewReader(data), "../internal/sign/testdata/pubkey.asc")
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	for _, result := range results {
+		require.NoError(t, result.Err, result.Type)
+	}
+
+	// flipping a byte of the payload must invalidate every signature.
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)-10] ^= 0xff
+	results, err = DefaultRPM.Verify(bytes.NewReader(tampered), "../internal/sign/testdata/pubkey.asc")
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	for _, result := range results {
+		require.Error(t, result.Err, result.Type)
+	}
+}
+
+func TestVerifyUnsigned(t *testing.T) {
+	var rpm bytes.Buffer
+	require.NoError(t, DefaultRPM.Package(exampleInfo(), &rpm))
+
+	results, err := DefaultRPM.Verify(&rpm, "../internal/sign/testdata/pubkey.asc")
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	_, err := DefaultRPM.Verify(strings.NewReader("not an rpm"), "../internal/sign/testdata/pubkey.asc")
+	require.ErrorIs(t, err, ErrInvalidPackage)
+}
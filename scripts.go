@@ -0,0 +1,329 @@
+package nfpm
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// scriptPart is a portable script that is run by a maintainer script of a
+// packager when its condition holds.
+type scriptPart struct {
+	name string
+	path string
+	// content is the body of a script generated by nfpm, read from path
+	// otherwise.
+	content []byte
+	// condition is a shell condition, the script always runs if it is empty.
+	condition string
+}
+
+// scriptsPackagers are the packagers with maintainer scripts, which install
+// the systemd units and the users and groups of the package.
+// nolint: gochecknoglobals
+var scriptsPackagers = map[string]bool{
+	"deb":       true,
+	"rpm":       true,
+	"apk":       true,
+	"archlinux": true,
+	"ipk":       true,
+}
+
+// shells are the interpreters whose scripts can be combined into a single
+// maintainer script.
+// nolint: gochecknoglobals
+var shells = map[string]bool{
+	"sh":   true,
+	"ash":  true,
+	"bash": true,
+	"dash": true,
+	"ksh":  true,
+	"zsh":  true,
+}
+
+// MaintainerScripts returns the maintainer scripts of the package for the
+// given packager, keyed by their name in the package, e.g. preinst for deb,
+// pre for rpm, .pre-upgrade for apk, pre_upgrade for archlinux and
+// pre-install for freebsd.
+//
+// The portable preupgrade, postupgrade and on_first_install scripts are
+// combined with the install scripts into wrappers that tell upgrades apart
+// from installs the way the package manager does: deb passes upgrade or the
+// previously configured version as arguments, rpm passes the number of
+// installed versions, opkg sets PKG_UPGRADE to 1 and FreeBSD's pkg to true,
+// and apk and pacman run separate upgrade scripts, where the APK and ArchLinux
+// specific scripts take precedence. A script that runs unconditionally on its
+// own is kept as is.
+//
+// The scripts also create the users and groups of the package before its
+// contents are installed, install its alternatives on deb and rpm, and handle
+// its systemd units, see SystemdUnit, except on freebsd.
+func MaintainerScripts(info *Info, packager string) (map[string][]byte, error) {
+	if err := validateSystemdUnits(info.Systemd.Units); err != nil {
+		return nil, err
+	}
+	if err := validateAccounts(info); err != nil {
+		return nil, err
+	}
+	if err := validateAlternatives(info.Alternatives); err != nil {
+		return nil, err
+	}
+	s := info.Scripts
+	units := info.Systemd.Units
+	accounts := accountsPart(info)
+	var parts map[string][]scriptPart
+	switch packager {
+	case "deb", "ipk":
+		upgrade := `[ "$1" = upgrade ]`
+		firstInstall := `[ "$1" = configure ] && [ -z "$2" ]`
+		reconfigure := `[ "$1" = configure ] && [ -n "$2" ]`
+		removal := `[ "$1" = remove ]`
+		installAlternatives := alternativesPart(info.Alternatives, false, `[ "$1" = configure ]`)
+		removeAlternatives := alternativesPart(info.Alternatives, true, `[ "$1" = remove ] || [ "$1" = deconfigure ]`)
+		if packager == "ipk" {
+			upgrade = `[ "${PKG_UPGRADE:-0}" = 1 ]`
+			firstInstall = `[ "${PKG_UPGRADE:-0}" != 1 ]`
+			reconfigure = upgrade
+			removal = firstInstall
+			// opkg handles the alternatives declared in the control file.
+			installAlternatives, removeAlternatives = scriptPart{}, scriptPart{}
+		}
+		parts = map[string][]scriptPart{
+			"preinst": {
+				accounts,
+				{name: "preinstall", path: s.PreInstall},
+				{name: "preupgrade", path: s.PreUpgrade, condition: upgrade},
+			},
+			"postinst": {
+				{name: "postinstall", path: s.PostInstall},
+				{name: "postupgrade", path: s.PostUpgrade, condition: reconfigure},
+				{name: "on_first_install", path: s.OnFirstInstall, condition: firstInstall},
+				installAlternatives,
+				systemdPart(units, systemdInstall, firstInstall),
+				systemdPart(units, systemdUpgrade, reconfigure),
+			},
+			"prerm": {
+				systemdPart(units, systemdRemove, removal),
+				removeAlternatives,
+				{name: "preremove", path: s.PreRemove},
+			},
+			"postrm": {
+				{name: "postremove", path: s.PostRemove},
+				systemdPart(units, systemdReload, removal),
+			},
+		}
+	case "rpm":
+		parts = map[string][]scriptPart{
+			"pre": {
+				accounts,
+				{name: "preinstall", path: s.PreInstall},
+				{name: "preupgrade", path: s.PreUpgrade, condition: `[ "$1" -ge 2 ]`},
+			},
+			"post": {
+				{name: "postinstall", path: s.PostInstall},
+				{name: "postupgrade", path: s.PostUpgrade, condition: `[ "$1" -ge 2 ]`},
+				{name: "on_first_install", path: s.OnFirstInstall, condition: `[ "$1" -eq 1 ]`},
+				alternativesPart(info.Alternatives, false, ""),
+				systemdPart(units, systemdInstall, `[ "$1" -eq 1 ]`),
+				systemdPart(units, systemdUpgrade, `[ "$1" -ge 2 ]`),
+			},
+			"preun": {
+				systemdPart(units, systemdRemove, `[ "$1" -eq 0 ]`),
+				{name: "preremove", path: s.PreRemove},
+			},
+			"postun": {
+				{name: "postremove", path: s.PostRemove},
+				alternativesPart(info.Alternatives, true, `[ "$1" -eq 0 ]`),
+				systemdPart(units, systemdReload, `[ "$1" -eq 0 ]`),
+			},
+		}
+	case "apk":
+		parts = map[string][]scriptPart{
+			".pre-install": {
+				accounts,
+				{name: "preinstall", path: s.PreInstall},
+			},
+			".pre-upgrade": {
+				accounts,
+				{name: "preupgrade", path: cmp.Or(info.APK.Scripts.PreUpgrade, s.PreUpgrade)},
+			},
+			".post-install": {
+				{name: "postinstall", path: s.PostInstall},
+				{name: "on_first_install", path: s.OnFirstInstall},
+				systemdPart(units, systemdInstall, ""),
+			},
+			".post-upgrade": {
+				{name: "postupgrade", path: cmp.Or(info.APK.Scripts.PostUpgrade, s.PostUpgrade)},
+				systemdPart(units, systemdUpgrade, ""),
+			},
+			".pre-deinstall": {
+				systemdPart(units, systemdRemove, ""),
+				{name: "preremove", path: s.PreRemove},
+			},
+			".post-deinstall": {
+				{name: "postremove", path: s.PostRemove},
+				systemdPart(units, systemdReload, ""),
+			},
+		}
+	case "archlinux":
+		parts = map[string][]scriptPart{
+			"pre_install": {
+				accounts,
+				{name: "preinstall", path: s.PreInstall},
+			},
+			"pre_upgrade": {
+				accounts,
+				{name: "preupgrade", path: cmp.Or(info.ArchLinux.Scripts.PreUpgrade, s.PreUpgrade)},
+			},
+			"post_install": {
+				{name: "postinstall", path: s.PostInstall},
+				{name: "on_first_install", path: s.OnFirstInstall},
+				systemdPart(units, systemdInstall, ""),
+			},
+			"post_upgrade": {
+				{name: "postupgrade", path: cmp.Or(info.ArchLinux.Scripts.PostUpgrade, s.PostUpgrade)},
+				systemdPart(units, systemdUpgrade, ""),
+			},
+			"pre_remove": {
+				systemdPart(units, systemdRemove, ""),
+				{name: "preremove", path: s.PreRemove},
+			},
+			"post_remove": {
+				{name: "postremove", path: s.PostRemove},
+				systemdPart(units, systemdReload, ""),
+			},
+		}
+	case "freebsd":
+		upgrade := `[ "${PKG_UPGRADE:-}" = true ]`
+		parts = map[string][]scriptPart{
+			"pre-install": {
+				pwAccountsPart(info),
+				{name: "preinstall", path: s.PreInstall},
+				{name: "preupgrade", path: s.PreUpgrade, condition: upgrade},
+			},
+			"post-install": {
+				{name: "postinstall", path: s.PostInstall},
+				{name: "postupgrade", path: s.PostUpgrade, condition: upgrade},
+				{name: "on_first_install", path: s.OnFirstInstall, condition: `[ "${PKG_UPGRADE:-}" != true ]`},
+			},
+			"pre-deinstall": {
+				{name: "preremove", path: s.PreRemove},
+			},
+			"post-deinstall": {
+				{name: "postremove", path: s.PostRemove},
+			},
+		}
+	default:
+		return nil, fmt.Errorf("scripts are not supported by %s", packager)
+	}
+
+	scripts := map[string][]byte{}
+	for name, parts := range parts {
+		script, err := combineScripts(parts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if script != nil {
+			scripts[name] = script
+		}
+	}
+	return scripts, nil
+}
+
+// combineScripts returns a script that runs every part whose condition holds,
+// in order, stopping at the first one that fails. It returns nil if none of
+// the scripts is set.
+func combineScripts(parts []scriptPart) ([]byte, error) {
+	var bodies [][]byte
+	var used []scriptPart
+	for _, part := range parts {
+		body := part.content
+		if part.path != "" {
+			var err error
+			if body, err = os.ReadFile(part.path); err != nil {
+				return nil, err
+			}
+		}
+		if body == nil {
+			continue
+		}
+		bodies = append(bodies, body)
+		used = append(used, part)
+	}
+	if len(used) == 0 {
+		return nil, nil
+	}
+	if len(used) == 1 && used[0].condition == "" && used[0].content == nil {
+		return bodies[0], nil
+	}
+
+	shebang := ""
+	for i, body := range bodies {
+		line, rest, _ := bytes.Cut(body, []byte("\n"))
+		if !bytes.HasPrefix(line, []byte("#!")) {
+			continue
+		}
+		bodies[i] = rest
+		if err := checkShell(string(line)); err != nil {
+			return nil, fmt.Errorf("cannot combine %s: %w", used[i].name, err)
+		}
+		if shebang != "" && shebang != string(line) {
+			return nil, fmt.Errorf("cannot combine scripts with different interpreters: %s and %s", shebang, line)
+		}
+		shebang = string(line)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(cmp.Or(shebang, "#!/bin/sh"))
+	b.WriteString("\n# generated by nfpm\n")
+	// every script runs in a subshell, so exit only ends the script itself.
+	for i, part := range used {
+		fmt.Fprintf(&b, "\nnfpm_%s() (\n", part.name)
+		b.Write(bodies[i])
+		if !bytes.HasSuffix(bodies[i], []byte("\n")) {
+			b.WriteString("\n")
+		}
+		b.WriteString(")\n")
+	}
+	b.WriteString("\n")
+	for _, part := range used {
+		// the status is checked explicitly, as set -e is ignored on the left
+		// of ||.
+		call := fmt.Sprintf("nfpm_%s \"$@\"\nnfpm_status=$?\n[ \"$nfpm_status\" -eq 0 ] || exit \"$nfpm_status\"\n", part.name)
+		if part.condition != "" {
+			call = fmt.Sprintf("if %s; then\n%sfi\n", part.condition, indent(call))
+		}
+		b.WriteString(call)
+	}
+	return b.Bytes(), nil
+}
+
+// checkShell checks that a shebang runs a shell.
+func checkShell(shebang string) error {
+	fields := strings.Fields(strings.TrimPrefix(shebang, "#!"))
+	if len(fields) == 0 {
+		return fmt.Errorf("invalid shebang: %s", shebang)
+	}
+	// e.g. #!/usr/bin/env bash or #!/bin/busybox sh
+	interpreter := path.Base(fields[0])
+	if (interpreter == "env" || interpreter == "busybox") && len(fields) > 1 {
+		interpreter = path.Base(fields[1])
+	}
+	if !shells[interpreter] {
+		return fmt.Errorf("%s is not a shell", interpreter)
+	}
+	return nil
+}
+
+func indent(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if line != "" && line != "\n" {
+			lines[i] = "\t" + line
+		}
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,207 @@
+package nfpm_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScript(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// runScript runs a maintainer script with the given arguments and returns
+// its output and exit code.
+func runScript(t *testing.T, script []byte, env []string, args ...string) (string, int) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	path := writeScript(t, "script", string(script))
+	cmd := exec.Command("sh", append([]string{path}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out.String(), exitErr.ExitCode()
+	}
+	require.NoError(t, err)
+	return out.String(), 0
+}
+
+func upgradeScripts(t *testing.T) nfpm.Scripts {
+	t.Helper()
+	return nfpm.Scripts{
+		PreInstall:     writeScript(t, "preinstall.sh", "#!/bin/sh\necho preinstall \"$@\"\n"),
+		PostInstall:    writeScript(t, "postinstall.sh", "#!/bin/sh\necho postinstall\nexit 0\n"),
+		PreUpgrade:     writeScript(t, "preupgrade.sh", "echo preupgrade\n"),
+		PostUpgrade:    writeScript(t, "postupgrade.sh", "#!/bin/sh\necho postupgrade"),
+		OnFirstInstall: writeScript(t, "firstinstall.sh", "#!/bin/sh\necho on_first_install\n"),
+		PreRemove:      writeScript(t, "preremove.sh", "#!/bin/sh\necho preremove\n"),
+	}
+}
+
+func TestMaintainerScriptsUnchanged(t *testing.T) {
+	info := &nfpm.Info{}
+	info.Scripts.PreInstall = "./testdata/scripts/preinstall.sh"
+	info.Scripts.PostRemove = "./testdata/scripts/postremove.sh"
+
+	for _, packager := range []string{"deb", "rpm", "apk", "archlinux", "ipk"} {
+		t.Run(packager, func(t *testing.T) {
+			scripts, err := nfpm.MaintainerScripts(info, packager)
+			require.NoError(t, err)
+			require.Len(t, scripts, 2)
+			for _, script := range scripts {
+				require.NotContains(t, string(script), "generated by nfpm")
+			}
+		})
+	}
+
+	scripts, err := nfpm.MaintainerScripts(info, "deb")
+	require.NoError(t, err)
+	preinstall, err := os.ReadFile(info.Scripts.PreInstall)
+	require.NoError(t, err)
+	require.Equal(t, preinstall, scripts["preinst"])
+}
+
+func TestMaintainerScriptsDeb(t *testing.T) {
+	info := &nfpm.Info{}
+	info.Scripts = upgradeScripts(t)
+	scripts, err := nfpm.MaintainerScripts(info, "deb")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"preinst", "postinst", "prerm"}, keys(scripts))
+
+	out, _ := runScript(t, scripts["preinst"], nil, "install")
+	require.Equal(t, "preinstall install\n", out)
+	out, _ = runScript(t, scripts["preinst"], nil, "upgrade", "1.0.0")
+	require.Equal(t, "preinstall upgrade 1.0.0\npreupgrade\n", out)
+	out, _ = runScript(t, scripts["postinst"], nil, "configure", "")
+	require.Equal(t, "postinstall\non_first_install\n", out)
+	out, _ = runScript(t, scripts["postinst"], nil, "configure", "1.0.0")
+	require.Equal(t, "postinstall\npostupgrade\n", out)
+}
+
+func TestMaintainerScriptsRPM(t *testing.T) {
+	info := &nfpm.Info{}
+	info.Scripts = upgradeScripts(t)
+	scripts, err := nfpm.MaintainerScripts(info, "rpm")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"pre", "post", "preun"}, keys(scripts))
+
+	out, _ := runScript(t, scripts["pre"], nil, "1")
+	require.Equal(t, "preinstall 1\n", out)
+	out, _ = runScript(t, scripts["pre"], nil, "2")
+	require.Equal(t, "preinstall 2\npreupgrade\n", out)
+	out, _ = runScript(t, scripts["post"], nil, "1")
+	require.Equal(t, "postinstall\non_first_install\n", out)
+	out, _ = runScript(t, scripts["post"], nil, "2")
+	require.Equal(t, "postinstall\npostupgrade\n", out)
+}
+
+func TestMaintainerScriptsIPK(t *testing.T) {
+	info := &nfpm.Info{}
+	info.Scripts = upgradeScripts(t)
+	scripts, err := nfpm.MaintainerScripts(info, "ipk")
+	require.NoError(t, err)
+
+	out, _ := runScript(t, scripts["postinst"], nil, "configure")
+	require.Equal(t, "postinstall\non_first_install\n", out)
+	out, _ = runScript(t, scripts["postinst"], []string{"PKG_UPGRADE=1"}, "configure")
+	require.Equal(t, "postinstall\npostupgrade\n", out)
+}
+
+func TestMaintainerScriptsFreeBSD(t *testing.T) {
+	info := &nfpm.Info{}
+	info.Scripts = upgradeScripts(t)
+	scripts, err := nfpm.MaintainerScripts(info, "freebsd")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"pre-install", "post-install", "pre-deinstall"}, keys(scripts))
+
+	out, _ := runScript(t, scripts["pre-install"], nil)
+	require.Equal(t, "preinstall\n", out)
+	out, _ = runScript(t, scripts["pre-install"], []string{"PKG_UPGRADE=true"})
+	require.Equal(t, "preinstall\npreupgrade\n", out)
+	out, _ = runScript(t, scripts["post-install"], nil)
+	require.Equal(t, "postinstall\non_first_install\n", out)
+	out, _ = runScript(t, scripts["post-install"], []string{"PKG_UPGRADE=true"})
+	require.Equal(t, "postinstall\npostupgrade\n", out)
+}
+
+func TestMaintainerScriptsSeparateUpgradeScripts(t *testing.T) {
+	info := &nfpm.Info{}
+	info.Scripts = upgradeScripts(t)
+	info.APK.Scripts.PostUpgrade = writeScript(t, "apk.sh", "echo apk\n")
+	info.ArchLinux.Scripts.PreUpgrade = writeScript(t, "arch.sh", "echo arch\n")
+
+	scripts, err := nfpm.MaintainerScripts(info, "apk")
+	require.NoError(t, err)
+	require.Equal(t, "echo preupgrade\n", string(scripts[".pre-upgrade"]))
+	require.Equal(t, "echo apk\n", string(scripts[".post-upgrade"]))
+	out, _ := runScript(t, scripts[".post-install"], nil)
+	require.Equal(t, "postinstall\non_first_install\n", out)
+
+	scripts, err = nfpm.MaintainerScripts(info, "archlinux")
+	require.NoError(t, err)
+	require.Equal(t, "echo arch\n", string(scripts["pre_upgrade"]))
+	require.Equal(t, "#!/bin/sh\necho postupgrade", string(scripts["post_upgrade"]))
+	out, _ = runScript(t, scripts["post_install"], nil)
+	require.Equal(t, "postinstall\non_first_install\n", out)
+}
+
+func TestMaintainerScriptsFailure(t *testing.T) {
+	info := &nfpm.Info{}
+	info.Scripts.PostInstall = writeScript(t, "postinstall.sh", "#!/bin/sh\nset -e\nfalse\necho postinstall\n")
+	info.Scripts.OnFirstInstall = writeScript(t, "firstinstall.sh", "echo on_first_install\n")
+	scripts, err := nfpm.MaintainerScripts(info, "rpm")
+	require.NoError(t, err)
+
+	out, code := runScript(t, scripts["post"], nil, "1")
+	require.Equal(t, 1, code)
+	require.Empty(t, out)
+}
+
+func TestMaintainerScriptsInterpreters(t *testing.T) {
+	info := &nfpm.Info{}
+	info.Scripts.PreInstall = writeScript(t, "preinstall.sh", "#!/usr/bin/env bash\necho preinstall\n")
+	info.Scripts.PreUpgrade = writeScript(t, "preupgrade.sh", "echo preupgrade\n")
+	scripts, err := nfpm.MaintainerScripts(info, "deb")
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(scripts["preinst"], []byte("#!/usr/bin/env bash\n")))
+
+	info.Scripts.PreUpgrade = writeScript(t, "preupgrade.sh", "#!/bin/sh\necho preupgrade\n")
+	_, err = nfpm.MaintainerScripts(info, "deb")
+	require.EqualError(t, err, "preinst: cannot combine scripts with different interpreters: #!/usr/bin/env bash and #!/bin/sh")
+
+	info.Scripts.PreUpgrade = writeScript(t, "preupgrade.py", "#!/usr/bin/python3\nprint('preupgrade')\n")
+	_, err = nfpm.MaintainerScripts(info, "deb")
+	require.EqualError(t, err, "preinst: cannot combine preupgrade: python3 is not a shell")
+}
+
+func TestMaintainerScriptsErrors(t *testing.T) {
+	info := &nfpm.Info{}
+	_, err := nfpm.MaintainerScripts(info, "msix")
+	require.EqualError(t, err, "scripts are not supported by msix")
+
+	info.Scripts.PreRemove = "./testdata/scripts/nope.sh"
+	_, err = nfpm.MaintainerScripts(info, "deb")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func keys(m map[string][]byte) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,271 @@
+// Package snap implements nfpm.Packager providing snap bindings.
+//
+// A snap is a squashfs filesystem holding the contents under their
+// destinations, relative to the root of the snap, and the meta directory:
+// meta/snap.yaml, describing the snap and its apps, and the hooks snapd runs
+// once the snap is installed or before it is removed.
+package snap
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/squashfs"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	packagerName = "snap"
+	// maxSummaryLen is the maximum length of the summary, in characters.
+	maxSummaryLen = 128
+)
+
+// nolint: gochecknoinits
+func init() {
+	nfpm.RegisterPackager(packagerName, Default)
+}
+
+// nolint: gochecknoglobals
+var archToSnap = map[string]string{
+	"amd64":   "amd64",
+	"x86_64":  "amd64",
+	"386":     "i386",
+	"i386":    "i386",
+	"arm64":   "arm64",
+	"aarch64": "arm64",
+	"arm6":    "armhf",
+	"arm7":    "armhf",
+	"ppc64le": "ppc64el",
+	"riscv64": "riscv64",
+	"s390x":   "s390x",
+}
+
+// nolint: gochecknoglobals
+var (
+	// namePattern matches the names of snaps, which also need a letter.
+	namePattern   = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	letterPattern = regexp.MustCompile(`[a-z]`)
+	// versionPattern matches the versions of snaps.
+	versionPattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9:.+~-]{0,30}[a-zA-Z0-9+~])?$`)
+	// appNamePattern matches the names of the apps.
+	appNamePattern = regexp.MustCompile(`^[a-zA-Z0-9](-?[a-zA-Z0-9])*$`)
+	// commandPattern matches the commands of the apps.
+	commandPattern = regexp.MustCompile(`^[A-Za-z0-9/. _#:$-]+$`)
+)
+
+func ensureValidArch(info *nfpm.Info) *nfpm.Info {
+	if info.Snap.Arch != "" {
+		info.Arch = info.Snap.Arch
+	} else if arch, ok := archToSnap[info.Arch]; ok {
+		info.Arch = arch
+	}
+
+	return info
+}
+
+// Default snap packager.
+// nolint: gochecknoglobals
+var Default = &Snap{}
+
+// Snap is a snap packager implementation.
+type Snap struct{}
+
+// ConventionalFileName returns a file name according to the conventions for
+// snaps: name_version_arch.snap.
+func (*Snap) ConventionalFileName(info *nfpm.Info) string {
+	info = ensureValidArch(info)
+	return fmt.Sprintf("%s_%s_%s.snap", info.Name, snapVersion(info), info.Arch)
+}
+
+// ConventionalExtension returns the file name conventionally used for snaps.
+func (*Snap) ConventionalExtension() string {
+	return ".snap"
+}
+
+// snapVersion returns the version of the snap, with its prerelease.
+func snapVersion(info *nfpm.Info) string {
+	if info.Prerelease != "" {
+		return info.Version + "-" + info.Prerelease
+	}
+	return info.Version
+}
+
+// snapYAML is meta/snap.yaml.
+type snapYAML struct {
+	Name          string             `yaml:"name"`
+	Version       string             `yaml:"version"`
+	Title         string             `yaml:"title,omitempty"`
+	Summary       string             `yaml:"summary,omitempty"`
+	Description   string             `yaml:"description,omitempty"`
+	License       string             `yaml:"license,omitempty"`
+	Architectures []string           `yaml:"architectures"`
+	Base          string             `yaml:"base,omitempty"`
+	Grade         string             `yaml:"grade"`
+	Confinement   string             `yaml:"confinement"`
+	Plugs         map[string]any     `yaml:"plugs,omitempty"`
+	Apps          map[string]snapApp `yaml:"apps,omitempty"`
+}
+
+type snapApp struct {
+	Command string   `yaml:"command"`
+	Daemon  string   `yaml:"daemon,omitempty"`
+	Plugs   []string `yaml:"plugs,omitempty"`
+}
+
+// Package writes a new snap to the given writer using the given info.
+func (*Snap) Package(info *nfpm.Info, w io.Writer) error {
+	info = ensureValidArch(info)
+
+	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
+		return err
+	}
+	if !namePattern.MatchString(info.Name) || len(info.Name) > 40 || !letterPattern.MatchString(info.Name) {
+		return fmt.Errorf("invalid snap name: %s", info.Name)
+	}
+	if !versionPattern.MatchString(snapVersion(info)) {
+		return fmt.Errorf("invalid snap version: %s", snapVersion(info))
+	}
+	summary := info.Snap.Summary
+	if summary == "" {
+		summary, _, _ = strings.Cut(strings.TrimSpace(info.Description), "\n")
+		summary = strings.TrimSpace(summary)
+	}
+	if utf8.RuneCountInString(summary) > maxSummaryLen {
+		return fmt.Errorf("the snap summary must be at most %d characters long, set snap.summary", maxSummaryLen)
+	}
+	grade := cmp.Or(info.Snap.Grade, "stable")
+	if grade != "stable" && grade != "devel" {
+		return fmt.Errorf("unknown snap grade: %s", grade)
+	}
+	confinement := cmp.Or(info.Snap.Confinement, "strict")
+	if !slices.Contains([]string{"strict", "classic", "devmode"}, confinement) {
+		return fmt.Errorf("unknown snap confinement: %s", confinement)
+	}
+
+	mtime := modtime.Get(info.MTime)
+	root, err := snapDir(info, mtime)
+	if err != nil {
+		return err
+	}
+
+	meta := snapYAML{
+		Name:          info.Name,
+		Version:       snapVersion(info),
+		Title:         info.Snap.Title,
+		Summary:       summary,
+		Description:   strings.TrimSpace(info.Description),
+		License:       info.License,
+		Architectures: []string{info.Arch},
+		Base:          cmp.Or(info.Snap.Base, "core24"),
+		Grade:         grade,
+		Confinement:   confinement,
+	}
+	for _, plug := range info.Snap.Plugs {
+		if meta.Plugs == nil {
+			meta.Plugs = map[string]any{}
+		}
+		meta.Plugs[plug] = nil
+	}
+	for name, app := range info.Snap.Apps {
+		if !appNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid snap app name: %s", name)
+		}
+		if !slices.Contains([]string{"", "simple", "forking", "oneshot", "notify", "dbus"}, app.Daemon) {
+			return fmt.Errorf("unknown daemon type of the %s app: %s", name, app.Daemon)
+		}
+		command := strings.TrimPrefix(app.Command, "/")
+		if !commandPattern.MatchString(command) {
+			return fmt.Errorf("invalid command of the %s app: %s", name, app.Command)
+		}
+		// the program is the first word of the command.
+		program, _, _ := strings.Cut(command, " ")
+		if node := root.Lookup(program); node == nil || node.Type == squashfs.TypeDir {
+			return fmt.Errorf("%s, the command of the %s app, is not in the contents", program, name)
+		}
+		if meta.Apps == nil {
+			meta.Apps = map[string]snapApp{}
+		}
+		meta.Apps[name] = snapApp{Command: command, Daemon: app.Daemon, Plugs: app.Plugs}
+	}
+
+	var metadata bytes.Buffer
+	enc := yaml.NewEncoder(&metadata)
+	enc.SetIndent(2)
+	if err := enc.Encode(meta); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if err := root.Add("meta/snap.yaml", &squashfs.Node{Type: squashfs.TypeFile, Mode: 0o644, MTime: mtime, Data: metadata.Bytes()}); err != nil {
+		return err
+	}
+	if err := addHooks(root, info, mtime); err != nil {
+		return err
+	}
+
+	// snaps are compressed with xz, as snap pack does and the store
+	// expects.
+	return squashfs.Write(w, root, "xz")
+}
+
+// snapDir returns the root directory of the snap, holding the contents under
+// their destinations. The meta directory is reserved to the metadata.
+func snapDir(info *nfpm.Info, mtime time.Time) (*squashfs.Node, error) {
+	for _, content := range info.Contents {
+		dst := strings.TrimPrefix(files.NormalizeAbsoluteFilePath(content.Destination), "/")
+		if content.Type != files.TypeImplicitDir && (dst == "meta" || strings.HasPrefix(dst, "meta/")) {
+			return nil, fmt.Errorf("%s: the meta directory is reserved to the metadata of the snap", content.Destination)
+		}
+	}
+	return squashfs.FromContents(info.Contents, mtime)
+}
+
+// addHooks adds the hooks running the scripts: the postinstall script runs
+// in the install hook, once the snap is installed, the preremove script in
+// the remove hook, before it is removed, and the preupgrade and postupgrade
+// scripts in the pre-refresh and post-refresh hooks, around a refresh. snapd
+// has no hook running before the snap is installed or after it is removed,
+// so those scripts are rejected.
+func addHooks(root *squashfs.Node, info *nfpm.Info, mtime time.Time) error {
+	for name, path := range map[string]string{
+		"preinstall":       info.Scripts.PreInstall,
+		"postremove":       info.Scripts.PostRemove,
+		"on_first_install": info.Scripts.OnFirstInstall,
+	} {
+		if path != "" {
+			return fmt.Errorf("%s script is not supported by snap", name)
+		}
+	}
+	for _, hook := range []struct {
+		name, path string
+	}{
+		{"install", info.Scripts.PostInstall},
+		{"remove", info.Scripts.PreRemove},
+		{"pre-refresh", info.Scripts.PreUpgrade},
+		{"post-refresh", info.Scripts.PostUpgrade},
+	} {
+		if hook.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(hook.path)
+		if err != nil {
+			return err
+		}
+		if err := root.Add("meta/hooks/"+hook.name, &squashfs.Node{Type: squashfs.TypeFile, Mode: 0o755, MTime: mtime, Data: data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package snap
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/squashfs"
+	"github.com/goreleaser/nfpm/v2/internal/squashfs/squashfstest"
+	"github.com/stretchr/testify/require"
+)
+
+var mtime = time.Date(2023, 11, 5, 23, 15, 17, 0, time.UTC)
+
+func exampleInfo() *nfpm.Info {
+	return nfpm.WithDefaults(&nfpm.Info{
+		Name:        "fake",
+		Arch:        "amd64",
+		Description: "Fake does things\nand more things",
+		Version:     "v1.0.0",
+		Prerelease:  "rc1",
+		License:     "MIT",
+		MTime:       mtime,
+		Overridables: nfpm.Overridables{
+			Depends: []string{"bash"},
+			Contents: []*files.Content{
+				{
+					Source:      "../testdata/fake",
+					Destination: "/bin/fake",
+					FileInfo: &files.ContentFileInfo{
+						Mode: 0o755,
+					},
+				},
+				{
+					Source:      "../testdata/whatever.conf",
+					Destination: "/etc/fake.conf",
+					Type:        files.TypeConfig,
+				},
+				{
+					Source:      "fake",
+					Destination: "/bin/fake-link",
+					Type:        files.TypeSymlink,
+				},
+			},
+			Scripts: nfpm.Scripts{
+				PostInstall: "../testdata/scripts/postinstall.sh",
+				PreRemove:   "../testdata/scripts/preremove.sh",
+				PreUpgrade:  "../testdata/scripts/preupgrade.sh",
+				PostUpgrade: "../testdata/scripts/postupgrade.sh",
+			},
+			Snap: nfpm.Snap{
+				Plugs: []string{"network"},
+				Apps: map[string]nfpm.SnapApp{
+					"fake": {
+						Command: "/bin/fake --verbose",
+						Plugs:   []string{"home"},
+					},
+					"fake-daemon": {
+						Command: "bin/fake-link",
+						Daemon:  "simple",
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestConventionalFileName(t *testing.T) {
+	for arch, expected := range map[string]string{
+		"amd64":   "fake_1.0.0-rc1_amd64.snap",
+		"386":     "fake_1.0.0-rc1_i386.snap",
+		"arm64":   "fake_1.0.0-rc1_arm64.snap",
+		"arm7":    "fake_1.0.0-rc1_armhf.snap",
+		"ppc64le": "fake_1.0.0-rc1_ppc64el.snap",
+		"all":     "fake_1.0.0-rc1_all.snap",
+	} {
+		t.Run(arch, func(t *testing.T) {
+			info := exampleInfo()
+			info.Arch = arch
+			require.Equal(t, expected, Default.ConventionalFileName(info))
+		})
+	}
+	require.Equal(t, ".snap", Default.ConventionalExtension())
+}
+
+func TestPackage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(exampleInfo(), &buf))
+	// xz compression.
+	require.Equal(t, []byte{4, 0}, buf.Bytes()[20:22])
+	entries := squashfstest.Read(t, buf.Bytes())
+
+	var paths []string
+	for path, e := range entries {
+		paths = append(paths, path)
+		require.Equal(t, uint32(mtime.Unix()), e.MTime, path)
+	}
+	require.ElementsMatch(t, []string{
+		"",
+		"bin",
+		"bin/fake",
+		"bin/fake-link",
+		"etc",
+		"etc/fake.conf",
+		"meta",
+		"meta/hooks",
+		"meta/hooks/install",
+		"meta/hooks/post-refresh",
+		"meta/hooks/pre-refresh",
+		"meta/hooks/remove",
+		"meta/snap.yaml",
+	}, paths)
+
+	fake, err := os.ReadFile("../testdata/fake")
+	require.NoError(t, err)
+	require.Equal(t, fake, entries["bin/fake"].Data)
+	require.Equal(t, uint16(0o755), entries["bin/fake"].Mode)
+	require.Equal(t, uint16(squashfs.TypeSymlink), entries["bin/fake-link"].Type)
+	require.Equal(t, "fake", string(entries["bin/fake-link"].Data))
+
+	postinstall, err := os.ReadFile("../testdata/scripts/postinstall.sh")
+	require.NoError(t, err)
+	require.Equal(t, postinstall, entries["meta/hooks/install"].Data)
+	require.Equal(t, uint16(0o755), entries["meta/hooks/install"].Mode)
+	preremove, err := os.ReadFile("../testdata/scripts/preremove.sh")
+	require.NoError(t, err)
+	require.Equal(t, preremove, entries["meta/hooks/remove"].Data)
+	preupgrade, err := os.ReadFile("../testdata/scripts/preupgrade.sh")
+	require.NoError(t, err)
+	require.Equal(t, preupgrade, entries["meta/hooks/pre-refresh"].Data)
+	postupgrade, err := os.ReadFile("../testdata/scripts/postupgrade.sh")
+	require.NoError(t, err)
+	require.Equal(t, postupgrade, entries["meta/hooks/post-refresh"].Data)
+
+	require.Equal(t, `name: fake
+version: 1.0.0-rc1
+summary: Fake does things
+description: |-
+  Fake does things
+  and more things
+license: MIT
+architectures:
+  - amd64
+base: core24
+grade: stable
+confinement: strict
+plugs:
+  network: null
+apps:
+  fake:
+    command: bin/fake --verbose
+    plugs:
+      - home
+  fake-daemon:
+    command: bin/fake-link
+    daemon: simple
+`, string(entries["meta/snap.yaml"].Data))
+}
+
+func TestPackageReproducible(t *testing.T) {
+	var a, b bytes.Buffer
+	require.NoError(t, Default.Package(exampleInfo(), &a))
+	require.NoError(t, Default.Package(exampleInfo(), &b))
+	require.Equal(t, a.Bytes(), b.Bytes())
+}
+
+func TestPackageInvalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		update func(info *nfpm.Info)
+		err    string
+	}{
+		"name": {
+			update: func(info *nfpm.Info) { info.Name = "Fake" },
+			err:    "invalid snap name: Fake",
+		},
+		"digits": {
+			update: func(info *nfpm.Info) { info.Name = "1-2" },
+			err:    "invalid snap name: 1-2",
+		},
+		"version": {
+			update: func(info *nfpm.Info) { info.Prerelease = "rc1_" },
+			err:    "invalid snap version: 1.0.0-rc1_",
+		},
+		"summary": {
+			update: func(info *nfpm.Info) { info.Snap.Summary = string(bytes.Repeat([]byte("x"), 129)) },
+			err:    "the snap summary must be at most 128 characters long, set snap.summary",
+		},
+		"grade": {
+			update: func(info *nfpm.Info) { info.Snap.Grade = "beta" },
+			err:    "unknown snap grade: beta",
+		},
+		"confinement": {
+			update: func(info *nfpm.Info) { info.Snap.Confinement = "none" },
+			err:    "unknown snap confinement: none",
+		},
+		"meta": {
+			update: func(info *nfpm.Info) {
+				info.Contents = append(info.Contents, &files.Content{Source: "../testdata/fake", Destination: "/meta/snap.yaml"})
+			},
+			err: "/meta/snap.yaml: the meta directory is reserved to the metadata of the snap",
+		},
+		"app name": {
+			update: func(info *nfpm.Info) { info.Snap.Apps = map[string]nfpm.SnapApp{"fake_app": {Command: "bin/fake"}} },
+			err:    "invalid snap app name: fake_app",
+		},
+		"daemon": {
+			update: func(info *nfpm.Info) {
+				info.Snap.Apps = map[string]nfpm.SnapApp{"fake": {Command: "bin/fake", Daemon: "always"}}
+			},
+			err: "unknown daemon type of the fake app: always",
+		},
+		"command": {
+			update: func(info *nfpm.Info) { info.Snap.Apps = map[string]nfpm.SnapApp{"fake": {Command: "bin/fake | tee"}} },
+			err:    "invalid command of the fake app: bin/fake | tee",
+		},
+		"preinstall": {
+			update: func(info *nfpm.Info) { info.Scripts.PreInstall = "../testdata/scripts/preinstall.sh" },
+			err:    "preinstall script is not supported by snap",
+		},
+		"postremove": {
+			update: func(info *nfpm.Info) { info.Scripts.PostRemove = "../testdata/scripts/postremove.sh" },
+			err:    "postremove script is not supported by snap",
+		},
+		"on first install": {
+			update: func(info *nfpm.Info) { info.Scripts.OnFirstInstall = "../testdata/scripts/postinstall.sh" },
+			err:    "on_first_install script is not supported by snap",
+		},
+		"missing command": {
+			update: func(info *nfpm.Info) { info.Snap.Apps = map[string]nfpm.SnapApp{"fake": {Command: "usr/bin/fake"}} },
+			err:    "usr/bin/fake, the command of the fake app, is not in the contents",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			info := exampleInfo()
+			tc.update(info)
+			require.EqualError(t, Default.Package(info, io.Discard), tc.err)
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package nfpm
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/goreleaser/nfpm/v2/files"
+)
+
+// systemdUnitsDir is where the systemd units of the packages are installed.
+const systemdUnitsDir = "/usr/lib/systemd/system"
+
+// nolint: gochecknoglobals
+var systemdUnitName = regexp.MustCompile(`^[A-Za-z0-9:_.\\@-]+\.[a-z]+$`)
+
+// Systemd contains the systemd units of the package.
+type Systemd struct {
+	Units []SystemdUnit `yaml:"units,omitempty" json:"units,omitempty" jsonschema:"title=systemd units to install"`
+}
+
+// SystemdUnit is a systemd unit installed into systemdUnitsDir, which the
+// maintainer scripts reload, enable, start, restart and stop as the package
+// is installed, upgraded and removed, like debhelper's dh_installsystemd and
+// rpm's %systemd_post macros do.
+type SystemdUnit struct {
+	Src              string `yaml:"src" json:"src" jsonschema:"title=unit file,example=./foo.service"`
+	Name             string `yaml:"name,omitempty" json:"name,omitempty" jsonschema:"title=unit name,description=defaults to the file name of src,example=foo.service"`
+	Enable           bool   `yaml:"enable,omitempty" json:"enable,omitempty" jsonschema:"title=enable the unit on first install,default=false"`
+	Start            bool   `yaml:"start,omitempty" json:"start,omitempty" jsonschema:"title=start the unit on first install,default=false"`
+	RestartOnUpgrade bool   `yaml:"restart_on_upgrade,omitempty" json:"restart_on_upgrade,omitempty" jsonschema:"title=restart the unit on upgrades if it is running,default=false"`
+}
+
+// unitName returns the name of the unit, which defaults to the file name of
+// its source.
+func (u SystemdUnit) unitName() string {
+	return cmp.Or(u.Name, filepath.Base(u.Src))
+}
+
+func validateSystemdUnits(units []SystemdUnit) error {
+	for _, unit := range units {
+		if unit.Src == "" {
+			return ErrFieldEmpty{"systemd.units.src"}
+		}
+		if !systemdUnitName.MatchString(unit.unitName()) {
+			return fmt.Errorf("invalid systemd unit name: %s", unit.unitName())
+		}
+	}
+	return nil
+}
+
+// withSystemdUnits adds the systemd units to the contents, unless they are
+// already there.
+func withSystemdUnits(contents files.Contents, units []SystemdUnit) (files.Contents, error) {
+	if err := validateSystemdUnits(units); err != nil {
+		return nil, err
+	}
+	for _, unit := range units {
+		destination := path.Join(systemdUnitsDir, unit.unitName())
+		if containsDestination(contents, destination) {
+			continue
+		}
+		contents = append(contents, &files.Content{
+			Source:      unit.Src,
+			Destination: destination,
+			Type:        files.TypeFile,
+			FileInfo: &files.ContentFileInfo{
+				Mode: 0o644,
+			},
+		})
+	}
+	return contents, nil
+}
+
+type systemdStage int
+
+const (
+	// systemdInstall reloads systemd, enables and starts the units after
+	// the package is installed for the first time.
+	systemdInstall systemdStage = iota
+	// systemdUpgrade reloads systemd and restarts the units after the
+	// package is upgraded.
+	systemdUpgrade
+	// systemdRemove stops and disables the units before the package is
+	// removed.
+	systemdRemove
+	// systemdReload reloads systemd after the package is removed.
+	systemdReload
+)
+
+func (s systemdStage) String() string {
+	return [...]string{"install", "upgrade", "remove", "reload"}[s]
+}
+
+// systemdPart returns the part of the maintainer script handling the units at
+// the given stage, which is skipped if there are no units.
+func systemdPart(units []SystemdUnit, stage systemdStage, condition string) scriptPart {
+	if len(units) == 0 {
+		return scriptPart{}
+	}
+	return scriptPart{
+		name:      "systemd_" + stage.String(),
+		content:   systemdScript(units, stage),
+		condition: condition,
+	}
+}
+
+func systemdScript(units []SystemdUnit, stage systemdStage) []byte {
+	var b bytes.Buffer
+	b.WriteString("command -v systemctl >/dev/null 2>&1 || exit 0\n")
+	// units are only reloaded, started and stopped if systemd is running,
+	// e.g. not while building an image.
+	running := func(lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		b.WriteString("if [ -d /run/systemd/system ]; then\n")
+		for _, line := range lines {
+			b.WriteString("\t" + line + "\n")
+		}
+		b.WriteString("fi\n")
+	}
+
+	var lines []string
+	switch stage {
+	case systemdInstall, systemdUpgrade:
+		running([]string{"systemctl daemon-reload || true"})
+		for _, unit := range units {
+			if stage == systemdInstall && unit.Enable {
+				fmt.Fprintf(&b, "systemctl enable %s || true\n", unit.unitName())
+			}
+			switch {
+			case stage == systemdInstall && unit.Start:
+				lines = append(lines, fmt.Sprintf("systemctl start %s || exit $?", unit.unitName()))
+			case stage == systemdUpgrade && unit.RestartOnUpgrade:
+				lines = append(lines, fmt.Sprintf("systemctl try-restart %s || exit $?", unit.unitName()))
+			}
+		}
+		running(lines)
+	case systemdRemove:
+		for _, unit := range units {
+			lines = append(lines, fmt.Sprintf("systemctl stop %s || true", unit.unitName()))
+		}
+		running(lines)
+		for _, unit := range units {
+			if unit.Enable {
+				fmt.Fprintf(&b, "systemctl disable %s || true\n", unit.unitName())
+			}
+		}
+	case systemdReload:
+		running([]string{"systemctl daemon-reload || true"})
+	}
+	return b.Bytes()
+}
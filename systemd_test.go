@@ -0,0 +1,130 @@
+package nfpm_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+func systemdInfo(t *testing.T) *nfpm.Info {
+	t.Helper()
+	return &nfpm.Info{
+		Name:    "foo",
+		Arch:    "amd64",
+		Version: "1.0.0",
+		Overridables: nfpm.Overridables{
+			Systemd: nfpm.Systemd{
+				Units: []nfpm.SystemdUnit{
+					{
+						Src:              writeScript(t, "foo.service", "[Service]\nExecStart=/usr/bin/foo\n"),
+						Enable:           true,
+						Start:            true,
+						RestartOnUpgrade: true,
+					},
+					{
+						Src:  writeScript(t, "unit", "[Timer]\nOnCalendar=daily\n"),
+						Name: "foo-cleanup.timer",
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestSystemdContents(t *testing.T) {
+	info := systemdInfo(t)
+	require.NoError(t, nfpm.PrepareForPackager(info, "deb"))
+	// preparing the contents again does not add the units twice.
+	require.NoError(t, nfpm.PrepareForPackager(info, "deb"))
+
+	var units []string
+	for _, content := range info.Contents {
+		if content.Type != files.TypeFile {
+			continue
+		}
+		units = append(units, content.Destination)
+		require.Equal(t, os.FileMode(0o644), content.FileInfo.Mode)
+	}
+	require.Equal(t, []string{
+		"/usr/lib/systemd/system/foo-cleanup.timer",
+		"/usr/lib/systemd/system/foo.service",
+	}, units)
+}
+
+func TestSystemdContentsUnsupported(t *testing.T) {
+	info := systemdInfo(t)
+	info.Systemd.Units[0].Src = "./nope"
+	require.NoError(t, nfpm.PrepareForPackager(info, "msix"))
+	require.Empty(t, info.Contents)
+}
+
+func TestSystemdValidate(t *testing.T) {
+	info := systemdInfo(t)
+	info.Systemd.Units[1].Name = "foo bar.service"
+	require.EqualError(t, nfpm.Validate(info), "invalid systemd unit name: foo bar.service")
+	_, err := nfpm.MaintainerScripts(info, "deb")
+	require.EqualError(t, err, "invalid systemd unit name: foo bar.service")
+
+	info.Systemd.Units[1] = nfpm.SystemdUnit{}
+	require.EqualError(t, nfpm.PrepareForPackager(info, "rpm"), "package systemd.units.src must be provided")
+}
+
+func TestSystemdScripts(t *testing.T) {
+	info := systemdInfo(t)
+	scripts, err := nfpm.MaintainerScripts(info, "deb")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"postinst", "prerm", "postrm"}, keys(scripts))
+
+	postinst := string(scripts["postinst"])
+	require.Contains(t, postinst, "systemctl enable foo.service || true\n")
+	require.Contains(t, postinst, "\tsystemctl start foo.service || exit $?\n")
+	require.Contains(t, postinst, "\tsystemctl try-restart foo.service || exit $?\n")
+	require.NotContains(t, postinst, "foo-cleanup.timer")
+	require.Contains(t, postinst, "if [ \"$1\" = configure ] && [ -z \"$2\" ]; then\n\tnfpm_systemd_install \"$@\"\n")
+	require.Contains(t, postinst, "if [ \"$1\" = configure ] && [ -n \"$2\" ]; then\n\tnfpm_systemd_upgrade \"$@\"\n")
+
+	prerm := string(scripts["prerm"])
+	require.Contains(t, prerm, "\tsystemctl stop foo.service || true\n\tsystemctl stop foo-cleanup.timer || true\n")
+	require.Contains(t, prerm, "systemctl disable foo.service || true\n")
+	require.NotContains(t, prerm, "disable foo-cleanup.timer")
+	require.Contains(t, string(scripts["postrm"]), "if [ \"$1\" = remove ]; then\n\tnfpm_systemd_reload")
+}
+
+func TestSystemdScriptsMerge(t *testing.T) {
+	info := systemdInfo(t)
+	info.Scripts.PostInstall = writeScript(t, "postinstall.sh", "#!/bin/sh\necho postinstall\n")
+	info.Scripts.PreRemove = writeScript(t, "preremove.sh", "#!/bin/sh\necho preremove\n")
+
+	// a fake systemctl logs how it is called.
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(
+		filepath.Join(bin, "systemctl"),
+		[]byte("#!/bin/sh\necho systemctl \"$@\"\n"),
+		0o755, // nolint: gosec
+	))
+	env := []string{"PATH=" + bin + string(os.PathListSeparator) + os.Getenv("PATH")}
+
+	scripts, err := nfpm.MaintainerScripts(info, "rpm")
+	require.NoError(t, err)
+	out, _ := runScript(t, scripts["post"], env, "1")
+	require.Contains(t, out, "postinstall\nsystemctl enable foo.service\n")
+	out, _ = runScript(t, scripts["post"], env, "2")
+	require.True(t, strings.HasPrefix(out, "postinstall\n"))
+	require.NotContains(t, out, "enable")
+	out, _ = runScript(t, scripts["preun"], env, "0")
+	require.Contains(t, out, "systemctl disable foo.service\npreremove\n")
+	out, _ = runScript(t, scripts["preun"], env, "1")
+	require.Equal(t, "preremove\n", out)
+
+	scripts, err = nfpm.MaintainerScripts(info, "archlinux")
+	require.NoError(t, err)
+	require.Contains(t, string(scripts["post_upgrade"]), "try-restart foo.service")
+	require.NotContains(t, string(scripts["post_upgrade"]), "enable")
+	require.Contains(t, string(scripts["post_install"]), "nfpm_postinstall")
+	require.Contains(t, string(scripts["post_install"]), "systemctl enable foo.service")
+}
@@ -0,0 +1,320 @@
+package nfpm
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2/files"
+)
+
+// sysusersDir is where the sysusers.d configuration of the packages is
+// installed.
+const sysusersDir = "/usr/lib/sysusers.d"
+
+// standardAccounts are the users and groups that exist on most systems, and
+// that the contents can be owned by without declaring them.
+// nolint: gochecknoglobals
+var standardAccounts = map[string]bool{
+	"root":            true,
+	"daemon":          true,
+	"bin":             true,
+	"sys":             true,
+	"adm":             true,
+	"tty":             true,
+	"disk":            true,
+	"lp":              true,
+	"mail":            true,
+	"man":             true,
+	"games":           true,
+	"wheel":           true,
+	"staff":           true,
+	"users":           true,
+	"nobody":          true,
+	"nogroup":         true,
+	"utmp":            true,
+	"audio":           true,
+	"video":           true,
+	"input":           true,
+	"kmem":            true,
+	"shadow":          true,
+	"systemd-journal": true,
+}
+
+// nolint: gochecknoglobals
+var accountName = regexp.MustCompile(`^[a-z_][a-z0-9_-]*$`)
+
+// User is a system user created before the contents of the package are
+// installed, along with the group of the same name.
+type User struct {
+	Name        string   `yaml:"name" json:"name" jsonschema:"title=user name,example=foo"`
+	UID         int      `yaml:"uid,omitempty" json:"uid,omitempty" jsonschema:"title=user id,description=allocated by the system if not set"`
+	Description string   `yaml:"description,omitempty" json:"description,omitempty" jsonschema:"title=user description,example=foo daemon"`
+	Home        string   `yaml:"home,omitempty" json:"home,omitempty" jsonschema:"title=home directory,default=/"`
+	Shell       string   `yaml:"shell,omitempty" json:"shell,omitempty" jsonschema:"title=login shell,description=defaults to nologin"`
+	Groups      []string `yaml:"groups,omitempty" json:"groups,omitempty" jsonschema:"title=supplementary groups of the user"`
+}
+
+// Group is a system group created before the contents of the package are
+// installed.
+type Group struct {
+	Name string `yaml:"name" json:"name" jsonschema:"title=group name,example=foo"`
+	GID  int    `yaml:"gid,omitempty" json:"gid,omitempty" jsonschema:"title=group id,description=allocated by the system if not set"`
+}
+
+func validateAccounts(info *Info) error {
+	for _, group := range info.Groups {
+		if !accountName.MatchString(group.Name) {
+			return fmt.Errorf("invalid group name: %q", group.Name)
+		}
+	}
+	for _, user := range info.Users {
+		if !accountName.MatchString(user.Name) {
+			return fmt.Errorf("invalid user name: %q", user.Name)
+		}
+		if strings.ContainsAny(user.Description, "\"'\\\n") {
+			return fmt.Errorf("user %s: description cannot contain quotes, backslashes or new lines", user.Name)
+		}
+		for _, p := range []string{user.Home, user.Shell} {
+			if p != "" && (!path.IsAbs(p) || strings.ContainsAny(p, " \t\"'\\\n")) {
+				return fmt.Errorf("user %s: invalid path: %q", user.Name, p)
+			}
+		}
+		for _, group := range user.Groups {
+			if !accountName.MatchString(group) {
+				return fmt.Errorf("user %s: invalid group name: %q", user.Name, group)
+			}
+		}
+	}
+	return nil
+}
+
+// validateOwnership checks that the contents are owned by the users and
+// groups of the package, or standard ones, if it declares any.
+func validateOwnership(info *Info, contents files.Contents) error {
+	if len(info.Users) == 0 && len(info.Groups) == 0 {
+		return nil
+	}
+	users := map[string]bool{}
+	groups := map[string]bool{}
+	for _, user := range info.Users {
+		users[user.Name] = true
+		groups[user.Name] = true
+	}
+	for _, group := range info.Groups {
+		groups[group.Name] = true
+	}
+
+	var errs []error
+	for _, content := range contents {
+		// implicit directories are owned like the contents they hold.
+		if content.FileInfo == nil || content.Type == files.TypeImplicitDir {
+			continue
+		}
+		if owner := content.FileInfo.Owner; owner != "" && !users[owner] && !standardAccounts[owner] {
+			errs = append(errs, fmt.Errorf("%s is owned by undeclared user %s", content.Destination, owner))
+		}
+		if group := content.FileInfo.Group; group != "" && !groups[group] && !standardAccounts[group] {
+			errs = append(errs, fmt.Errorf("%s is owned by undeclared group %s", content.Destination, group))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sysusers returns the sysusers.d configuration creating the users and groups
+// of the package.
+func sysusers(info *Info) []byte {
+	id := func(id int) string {
+		if id == 0 {
+			return "-"
+		}
+		return strconv.Itoa(id)
+	}
+	var b bytes.Buffer
+	for _, group := range info.Groups {
+		fmt.Fprintf(&b, "g %s %s\n", group.Name, id(group.GID))
+	}
+	for _, user := range info.Users {
+		description := "-"
+		if user.Description != "" {
+			description = `"` + user.Description + `"`
+		}
+		home := user.Home
+		if home == "" {
+			home = "-"
+		}
+		shell := user.Shell
+		if shell == "" {
+			shell = "-"
+		}
+		fmt.Fprintf(&b, "u %s %s %s %s %s\n", user.Name, id(user.UID), description, home, shell)
+	}
+	for _, user := range info.Users {
+		for _, group := range user.Groups {
+			fmt.Fprintf(&b, "m %s %s\n", user.Name, group)
+		}
+	}
+	return b.Bytes()
+}
+
+// withSysusers adds the sysusers.d configuration of the users and groups of
+// the package to the contents, unless it is already there. The configuration
+// is written to a private temporary directory, removed by Info.Cleanup.
+func withSysusers(info *Info, contents files.Contents) (files.Contents, error) {
+	if err := validateAccounts(info); err != nil {
+		return nil, err
+	}
+	if len(info.Users) == 0 && len(info.Groups) == 0 {
+		return contents, nil
+	}
+	destination := path.Join(sysusersDir, info.Name+".conf")
+	if containsDestination(contents, destination) {
+		return contents, nil
+	}
+
+	conf := sysusers(info)
+	dir, err := os.MkdirTemp("", "nfpm-sysusers")
+	if err != nil {
+		return nil, fmt.Errorf("cannot write sysusers.d configuration: %w", err)
+	}
+	source := filepath.Join(dir, info.Name+".conf")
+	if err := os.WriteFile(source, conf, 0o600); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("cannot write sysusers.d configuration: %w", err)
+	}
+	info.tempDirs = append(info.tempDirs, dir)
+	return append(contents, &files.Content{
+		Source:      source,
+		Destination: destination,
+		Type:        files.TypeFile,
+		FileInfo: &files.ContentFileInfo{
+			Mode: 0o644,
+		},
+	}), nil
+}
+
+// accountsPart returns the part of the maintainer script creating the users
+// and groups of the package with systemd-sysusers, or with useradd and
+// groupadd, or busybox's adduser and addgroup, where it is missing. It is
+// skipped if there are no users and groups.
+func accountsPart(info *Info) scriptPart {
+	if len(info.Users) == 0 && len(info.Groups) == 0 {
+		return scriptPart{}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("if command -v systemd-sysusers >/dev/null 2>&1; then\n")
+	fmt.Fprintf(&b, "\tsystemd-sysusers --replace=%s - <<'NFPM_SYSUSERS' || exit $?\n", path.Join(sysusersDir, info.Name+".conf"))
+	b.Write(sysusers(info))
+	b.WriteString("NFPM_SYSUSERS\n")
+	b.WriteString("\texit 0\n")
+	b.WriteString("fi\n")
+
+	addGroup := func(name string, gid int) {
+		groupadd, addgroup := "groupadd -r", "addgroup -S"
+		if gid != 0 {
+			groupadd += " -g " + strconv.Itoa(gid)
+			addgroup += " -g " + strconv.Itoa(gid)
+		}
+		fmt.Fprintf(&b, "if ! grep -q '^%s:' /etc/group; then\n", name)
+		fmt.Fprintf(&b, "\tif command -v groupadd >/dev/null 2>&1; then %s %s; else %s %s; fi || exit $?\n", groupadd, name, addgroup, name)
+		b.WriteString("fi\n")
+	}
+	for _, group := range info.Groups {
+		addGroup(group.Name, group.GID)
+	}
+	for _, user := range info.Users {
+		addGroup(user.Name, user.UID)
+
+		home := user.Home
+		if home == "" {
+			home = "/"
+		}
+		shell := "\"$(command -v nologin || echo /sbin/nologin)\""
+		if user.Shell != "" {
+			shell = user.Shell
+		}
+		useradd := fmt.Sprintf("useradd -r -g %s -d %s -M -s %s", user.Name, home, shell)
+		adduser := fmt.Sprintf("adduser -S -D -H -G %s -h %s -s %s", user.Name, home, shell)
+		if user.Description != "" {
+			useradd += fmt.Sprintf(" -c '%s'", user.Description)
+			adduser += fmt.Sprintf(" -g '%s'", user.Description)
+		}
+		if user.UID != 0 {
+			useradd += " -u " + strconv.Itoa(user.UID)
+			adduser += " -u " + strconv.Itoa(user.UID)
+		}
+		fmt.Fprintf(&b, "if ! id %s >/dev/null 2>&1; then\n", user.Name)
+		fmt.Fprintf(&b, "\tif command -v useradd >/dev/null 2>&1; then %s %s; else %s %s; fi || exit $?\n", useradd, user.Name, adduser, user.Name)
+		b.WriteString("fi\n")
+	}
+	for _, user := range info.Users {
+		for _, group := range user.Groups {
+			fmt.Fprintf(&b, "if ! id -nG %s | grep -qw %s; then\n", user.Name, group)
+			fmt.Fprintf(&b, "\tif command -v usermod >/dev/null 2>&1; then usermod -a -G %s %s; else addgroup %s %s; fi || exit $?\n", group, user.Name, user.Name, group)
+			b.WriteString("fi\n")
+		}
+	}
+
+	return scriptPart{
+		name:    "create_accounts",
+		content: b.Bytes(),
+	}
+}
+
+// pwAccountsPart returns the part of the maintainer script creating the users
+// and groups of the package with FreeBSD's pw. It is skipped if there are no
+// users and groups.
+func pwAccountsPart(info *Info) scriptPart {
+	if len(info.Users) == 0 && len(info.Groups) == 0 {
+		return scriptPart{}
+	}
+
+	var b bytes.Buffer
+	addGroup := func(name string, gid int) {
+		groupadd := "pw groupadd -n " + name
+		if gid != 0 {
+			groupadd += " -g " + strconv.Itoa(gid)
+		}
+		fmt.Fprintf(&b, "if ! pw groupshow %s >/dev/null 2>&1; then\n", name)
+		fmt.Fprintf(&b, "\t%s || exit $?\n", groupadd)
+		b.WriteString("fi\n")
+	}
+	for _, group := range info.Groups {
+		addGroup(group.Name, group.GID)
+	}
+	for _, user := range info.Users {
+		addGroup(user.Name, user.UID)
+
+		useradd := fmt.Sprintf("pw useradd -n %s -g %s -d %s -s %s",
+			user.Name, user.Name, cmp.Or(user.Home, "/"), cmp.Or(user.Shell, "/usr/sbin/nologin"))
+		if user.Description != "" {
+			useradd += fmt.Sprintf(" -c '%s'", user.Description)
+		}
+		if user.UID != 0 {
+			useradd += " -u " + strconv.Itoa(user.UID)
+		}
+		fmt.Fprintf(&b, "if ! pw usershow %s >/dev/null 2>&1; then\n", user.Name)
+		fmt.Fprintf(&b, "\t%s || exit $?\n", useradd)
+		b.WriteString("fi\n")
+	}
+	for _, user := range info.Users {
+		for _, group := range user.Groups {
+			fmt.Fprintf(&b, "if ! id -nG %s | grep -qw %s; then\n", user.Name, group)
+			fmt.Fprintf(&b, "\tpw groupmod %s -m %s || exit $?\n", group, user.Name)
+			b.WriteString("fi\n")
+		}
+	}
+
+	return scriptPart{
+		name:    "create_accounts",
+		content: b.Bytes(),
+	}
+}
@@ -0,0 +1,196 @@
+package nfpm_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/stretchr/testify/require"
+)
+
+func usersInfo() *nfpm.Info {
+	return &nfpm.Info{
+		Name:    "foo",
+		Arch:    "amd64",
+		Version: "1.0.0",
+		Overridables: nfpm.Overridables{
+			Users: []nfpm.User{
+				{
+					Name:        "foo",
+					UID:         990,
+					Description: "foo daemon",
+					Home:        "/var/lib/foo",
+					Groups:      []string{"foo-data", "video"},
+				},
+				{Name: "foo-worker", Shell: "/bin/sh"},
+			},
+			Groups: []nfpm.Group{{Name: "foo-data"}},
+			Contents: files.Contents{
+				{
+					Source:      "./testdata/whatever.conf",
+					Destination: "/etc/foo/foo.conf",
+					FileInfo: &files.ContentFileInfo{
+						Owner: "foo",
+						Group: "foo-data",
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestSysusers(t *testing.T) {
+	info := usersInfo()
+	require.NoError(t, nfpm.PrepareForPackager(info, "rpm"))
+	require.NoError(t, nfpm.PrepareForPackager(info, "rpm"))
+
+	var conf *files.Content
+	for _, content := range info.Contents {
+		if content.Destination == "/usr/lib/sysusers.d/foo.conf" {
+			require.Nil(t, conf)
+			conf = content
+		}
+	}
+	require.NotNil(t, conf)
+	require.Equal(t, os.FileMode(0o644), conf.FileInfo.Mode)
+	bts, err := os.ReadFile(conf.Source)
+	require.NoError(t, err)
+	require.Equal(t, strings.Join([]string{
+		"g foo-data -",
+		`u foo 990 "foo daemon" /var/lib/foo -`,
+		"u foo-worker - - - /bin/sh",
+		"m foo foo-data",
+		"m foo video",
+		"",
+	}, "\n"), string(bts))
+
+	dir, err := os.Stat(filepath.Dir(conf.Source))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o700), dir.Mode().Perm())
+
+	info.Cleanup()
+	require.NoFileExists(t, conf.Source)
+	require.NoDirExists(t, filepath.Dir(conf.Source))
+	for _, content := range info.Contents {
+		require.NotEqual(t, "/usr/lib/sysusers.d/foo.conf", content.Destination)
+	}
+}
+
+func TestSysusersNone(t *testing.T) {
+	info := usersInfo()
+	info.Users, info.Groups = nil, nil
+	info.Contents[0].FileInfo = nil
+	require.NoError(t, nfpm.PrepareForPackager(info, "deb"))
+	for _, content := range info.Contents {
+		require.NotEqual(t, "/usr/lib/sysusers.d/foo.conf", content.Destination)
+	}
+}
+
+func TestSysusersUnsupported(t *testing.T) {
+	info := usersInfo()
+	require.NoError(t, nfpm.PrepareForPackager(info, "msix"))
+	for _, content := range info.Contents {
+		require.NotEqual(t, "/usr/lib/sysusers.d/foo.conf", content.Destination)
+	}
+}
+
+func TestUndeclaredOwner(t *testing.T) {
+	info := usersInfo()
+	info.Contents[0].FileInfo = &files.ContentFileInfo{
+		Owner: "bar",
+		Group: "baz",
+	}
+	info.Contents = append(info.Contents, &files.Content{
+		Source:      "./testdata/whatever.conf",
+		Destination: "/etc/foo/root.conf",
+		FileInfo: &files.ContentFileInfo{
+			Owner: "root",
+			Group: "nogroup",
+		},
+	})
+	expected := "/etc/foo/foo.conf is owned by undeclared user bar\n/etc/foo/foo.conf is owned by undeclared group baz"
+	require.EqualError(t, nfpm.Validate(info), expected)
+	require.EqualError(t, nfpm.PrepareForPackager(info, "deb"), expected)
+}
+
+func TestInvalidAccounts(t *testing.T) {
+	for expected, modify := range map[string]func(info *nfpm.Info){
+		`invalid user name: "Foo"`: func(info *nfpm.Info) {
+			info.Users[0].Name = "Foo"
+		},
+		`invalid group name: "foo data"`: func(info *nfpm.Info) {
+			info.Groups[0].Name = "foo data"
+		},
+		`user foo: invalid group name: "$(reboot)"`: func(info *nfpm.Info) {
+			info.Users[0].Groups = []string{"$(reboot)"}
+		},
+		"user foo: description cannot contain quotes, backslashes or new lines": func(info *nfpm.Info) {
+			info.Users[0].Description = `the "foo" daemon`
+		},
+		`user foo: invalid path: "var/lib/foo"`: func(info *nfpm.Info) {
+			info.Users[0].Home = "var/lib/foo"
+		},
+	} {
+		t.Run(expected, func(t *testing.T) {
+			info := usersInfo()
+			modify(info)
+			require.EqualError(t, nfpm.Validate(info), expected)
+			require.EqualError(t, nfpm.PrepareForPackager(info, "apk"), expected)
+			_, err := nfpm.MaintainerScripts(info, "apk")
+			require.EqualError(t, err, expected)
+		})
+	}
+}
+
+func TestAccountsScripts(t *testing.T) {
+	info := usersInfo()
+	info.Scripts.PreInstall = writeScript(t, "preinstall.sh", "#!/bin/sh\necho preinstall\n")
+
+	for packager, names := range map[string][]string{
+		"deb":       {"preinst"},
+		"ipk":       {"preinst"},
+		"rpm":       {"pre"},
+		"apk":       {".pre-install", ".pre-upgrade"},
+		"archlinux": {"pre_install", "pre_upgrade"},
+	} {
+		t.Run(packager, func(t *testing.T) {
+			scripts, err := nfpm.MaintainerScripts(info, packager)
+			require.NoError(t, err)
+			require.Len(t, scripts, len(names))
+			for _, name := range names {
+				script := string(scripts[name])
+				require.Contains(t, script, "systemd-sysusers --replace=/usr/lib/sysusers.d/foo.conf - <<'NFPM_SYSUSERS' || exit $?\ng foo-data -\n")
+				// the accounts are created before the scripts of the package run.
+				if strings.Contains(script, "nfpm_preinstall \"$@\"") {
+					require.Less(t, strings.Index(script, "nfpm_create_accounts \"$@\""), strings.Index(script, "nfpm_preinstall \"$@\""))
+				}
+			}
+		})
+	}
+
+	scripts, err := nfpm.MaintainerScripts(info, "deb")
+	require.NoError(t, err)
+	preinst := string(scripts["preinst"])
+	require.Contains(t, preinst, "if command -v groupadd >/dev/null 2>&1; then groupadd -r foo-data; else addgroup -S foo-data; fi || exit $?\n")
+	require.Contains(t, preinst, "if command -v groupadd >/dev/null 2>&1; then groupadd -r -g 990 foo; else addgroup -S -g 990 foo; fi || exit $?\n")
+	require.Contains(t, preinst, "useradd -r -g foo -d /var/lib/foo -M -s \"$(command -v nologin || echo /sbin/nologin)\" -c 'foo daemon' -u 990 foo;")
+	require.Contains(t, preinst, "adduser -S -D -H -G foo-worker -h / -s /bin/sh foo-worker;")
+	require.Contains(t, preinst, "if ! id -nG foo | grep -qw video; then\n")
+	require.Contains(t, preinst, "usermod -a -G video foo; else addgroup foo video;")
+}
+
+func TestAccountsScriptsFreeBSD(t *testing.T) {
+	info := usersInfo()
+	scripts, err := nfpm.MaintainerScripts(info, "freebsd")
+	require.NoError(t, err)
+	preinstall := string(scripts["pre-install"])
+	require.NotContains(t, preinstall, "systemd-sysusers")
+	require.Contains(t, preinstall, "if ! pw groupshow foo-data >/dev/null 2>&1; then\n\tpw groupadd -n foo-data || exit $?\nfi\n")
+	require.Contains(t, preinstall, "\tpw groupadd -n foo -g 990 || exit $?\n")
+	require.Contains(t, preinstall, "if ! pw usershow foo >/dev/null 2>&1; then\n\tpw useradd -n foo -g foo -d /var/lib/foo -s /usr/sbin/nologin -c 'foo daemon' -u 990 || exit $?\nfi\n")
+	require.Contains(t, preinstall, "\tpw useradd -n foo-worker -g foo-worker -d / -s /bin/sh || exit $?\n")
+	require.Contains(t, preinstall, "if ! id -nG foo | grep -qw video; then\n\tpw groupmod video -m foo || exit $?\nfi\n")
+}
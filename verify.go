@@ -0,0 +1,88 @@
+package nfpm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Verifier is implemented by the readers that can check the signatures
+// embedded in a package.
+type Verifier interface {
+	// Verify checks every signature of the package against the public key in
+	// keyFile. The kind of key depends on the format: an OpenPGP public key
+	// for deb and rpm, a PEM RSA public key for apk and a X.509 certificate
+	// for msix.
+	Verify(r io.Reader, keyFile string) ([]SignatureVerification, error)
+}
+
+// SignatureVerification is the result of the verification of a single
+// signature of a package.
+type SignatureVerification struct {
+	SignatureInfo
+	// Err is the reason why the signature is not valid, nil if it is.
+	Err error `json:"-"`
+}
+
+// Valid reports whether the signature was successfully verified.
+func (v SignatureVerification) Valid() bool {
+	return v.Err == nil
+}
+
+// ErrUnsigned happens when the verified package has no signature at all.
+var ErrUnsigned = errors.New("package is not signed")
+
+// ErrInvalidSignature happens when at least one of the signatures of a
+// package could not be verified.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// ErrNoVerifier happens when the reader of the given format cannot verify
+// signatures.
+type ErrNoVerifier struct {
+	format string
+}
+
+func (e ErrNoVerifier) Error() string {
+	return fmt.Sprintf("the format %s does not support signature verification", e.format)
+}
+
+// GetVerifier gets a signature verifier for the given format.
+func GetVerifier(format string) (Verifier, error) {
+	r, err := GetReader(format)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := r.(Verifier)
+	if !ok {
+		return nil, ErrNoVerifier{format}
+	}
+	return v, nil
+}
+
+// Verify checks all the signatures of the package of the given format read
+// from r. The results of every signature are returned even if some of them
+// failed, in which case the error wraps ErrInvalidSignature.
+func Verify(r io.Reader, format, keyFile string) ([]SignatureVerification, error) {
+	v, err := GetVerifier(format)
+	if err != nil {
+		return nil, err
+	}
+	results, err := v.Verify(r, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrUnsigned
+	}
+
+	var errs []error
+	for _, result := range results {
+		if !result.Valid() {
+			errs = append(errs, result.Err)
+		}
+	}
+	if len(errs) > 0 {
+		return results, fmt.Errorf("%w: %w", ErrInvalidSignature, errors.Join(errs...))
+	}
+	return results, nil
+}
@@ -0,0 +1,65 @@
+package nfpm_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetVerifier(t *testing.T) {
+	_, err := nfpm.GetVerifier("TestGetVerifierMissing")
+	require.EqualError(t, err, "no reader registered for the format TestGetVerifierMissing")
+
+	nfpm.RegisterReader("TestGetVerifierReader", &fakeReader{})
+	_, err = nfpm.GetVerifier("TestGetVerifierReader")
+	require.EqualError(t, err, "the format TestGetVerifierReader does not support signature verification")
+
+	verifier := &fakeVerifier{}
+	nfpm.RegisterReader("TestGetVerifier", verifier)
+	got, err := nfpm.GetVerifier("TestGetVerifier")
+	require.NoError(t, err)
+	require.Equal(t, verifier, got)
+}
+
+func TestVerify(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		nfpm.RegisterReader("TestVerifyValid", &fakeVerifier{results: []nfpm.SignatureVerification{
+			{SignatureInfo: nfpm.SignatureInfo{Type: "pgp"}},
+		}})
+		results, err := nfpm.Verify(nil, "TestVerifyValid", "key.asc")
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.True(t, results[0].Valid())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		nfpm.RegisterReader("TestVerifyInvalid", &fakeVerifier{results: []nfpm.SignatureVerification{
+			{SignatureInfo: nfpm.SignatureInfo{Type: "pgp"}},
+			{SignatureInfo: nfpm.SignatureInfo{Type: "rsa"}, Err: errors.New("bad signature")},
+		}})
+		results, err := nfpm.Verify(nil, "TestVerifyInvalid", "key.asc")
+		require.ErrorIs(t, err, nfpm.ErrInvalidSignature)
+		require.ErrorContains(t, err, "bad signature")
+		require.Len(t, results, 2)
+		require.True(t, results[0].Valid())
+		require.False(t, results[1].Valid())
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		nfpm.RegisterReader("TestVerifyUnsigned", &fakeVerifier{})
+		_, err := nfpm.Verify(nil, "TestVerifyUnsigned", "key.asc")
+		require.ErrorIs(t, err, nfpm.ErrUnsigned)
+	})
+}
+
+type fakeVerifier struct {
+	fakeReader
+	results []nfpm.SignatureVerification
+}
+
+func (v *fakeVerifier) Verify(_ io.Reader, _ string) ([]nfpm.SignatureVerification, error) {
+	return v.results, nil
+}